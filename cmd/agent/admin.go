@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/akosej/agent/internal/learning"
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/pkg/redact"
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// adminStore es el almacenamiento abierto por un comando de administración,
+// con la redacción de escrituras aplicada si la configuración lo indica
+type adminStore struct {
+	storage.Store
+	raw      storage.Store
+	redactor *redact.Redactor
+}
+
+// openStore carga la configuración y abre su almacenamiento
+func openStore(configPath string) (*Config, *adminStore, error) {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	redactor, err := config.newRedactor()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw, err := storage.NewStorage(config.storageConfig())
+	if err != nil {
+		redactor.Close()
+		return nil, nil, fmt.Errorf("error abriendo almacenamiento: %w", err)
+	}
+
+	s := &adminStore{Store: raw, raw: raw, redactor: redactor}
+	if redactor != nil {
+		s.Store = storage.WithVault(raw, redactor)
+		if config.Redaction.Storage {
+			s.Store = storage.WithRedaction(s.Store, redactor)
+		}
+	}
+	return config, s, nil
+}
+
+// Close cierra el almacenamiento y el vault de redacción
+func (s *adminStore) Close() error {
+	err := s.raw.Close()
+	if closeErr := s.redactor.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// confirm pide confirmación antes de una operación destructiva. Con yes no
+// pregunta; sin terminal en la entrada exige yes.
+func confirm(question string, yes bool) error {
+	if yes {
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("%s: usa -yes para confirmar sin terminal", question)
+	}
+
+	fmt.Printf("%s [s/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "s", "si", "sí", "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("operación cancelada")
+}
+
+// parseDate acepta una fecha (2006-01-02, hora local) o una marca RFC 3339
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fecha no válida %q (usa AAAA-MM-DD o RFC 3339)", value)
+	}
+	return t, nil
+}
+
+// truncate acorta text a max caracteres en una sola línea
+func truncate(text string, max int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:max-1]) + "…"
+}
+
+// printJSON escribe v con sangría en la salida estándar
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// storeStats son las estadísticas calculadas a partir del almacenamiento
+type storeStats struct {
+	Interactions      int            `json:"interactions"`
+	Users             int            `json:"users"`
+	Rated             int            `json:"rated"`
+	PositiveFeedback  int            `json:"positive_feedback"`
+	NegativeFeedback  int            `json:"negative_feedback"`
+	AverageRating     float64        `json:"average_rating"`
+	First             time.Time      `json:"first,omitempty"`
+	Last              time.Time      `json:"last,omitempty"`
+	Intents           []intentStats  `json:"intents"`
+	Conversations     int            `json:"conversations"`
+	OpenConversations int            `json:"open_conversations"`
+	Patterns          int            `json:"patterns"`
+	Stored            *storage.Stats `json:"stored"` // Las guardadas con UpdateStats
+	Backend           string         `json:"backend"`
+	Path              string         `json:"path"`
+	byIntent          map[string]*intentStats
+	ratingSum         int
+}
+
+// intentStats agrupa las interacciones de una intención
+type intentStats struct {
+	Intent        string  `json:"intent"`
+	Interactions  int     `json:"interactions"`
+	Rated         int     `json:"rated"`
+	AverageRating float64 `json:"average_rating"`
+	ratingSum     int
+}
+
+// add suma una interacción a las estadísticas
+func (s *storeStats) add(interaction *storage.Interaction, users map[string]bool) {
+	s.Interactions++
+	if interaction.UserID != "" {
+		users[interaction.UserID] = true
+	}
+	if s.First.IsZero() || interaction.Timestamp.Before(s.First) {
+		s.First = interaction.Timestamp
+	}
+	if interaction.Timestamp.After(s.Last) {
+		s.Last = interaction.Timestamp
+	}
+
+	intent := s.byIntent[interaction.Intent]
+	if intent == nil {
+		intent = &intentStats{Intent: interaction.Intent}
+		s.byIntent[interaction.Intent] = intent
+	}
+	intent.Interactions++
+
+	if feedback := interaction.Feedback; feedback != nil {
+		s.Rated++
+		s.ratingSum += feedback.Rating
+		intent.Rated++
+		intent.ratingSum += feedback.Rating
+		if feedback.Rating >= 4 {
+			s.PositiveFeedback++
+		} else if feedback.Rating <= 2 {
+			s.NegativeFeedback++
+		}
+	}
+}
+
+// computeStats recorre el almacenamiento y calcula sus estadísticas
+func computeStats(config *Config, store storage.Store) (*storeStats, error) {
+	interactions, err := store.GetRecentInteractions(0)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &storeStats{Backend: config.Storage.Type, Path: config.Storage.Path, byIntent: make(map[string]*intentStats)}
+	users := make(map[string]bool)
+	for _, interaction := range interactions {
+		stats.add(interaction, users)
+	}
+	stats.Users = len(users)
+	if stats.Rated > 0 {
+		stats.AverageRating = float64(stats.ratingSum) / float64(stats.Rated)
+	}
+
+	for _, intent := range stats.byIntent {
+		if intent.Rated > 0 {
+			intent.AverageRating = float64(intent.ratingSum) / float64(intent.Rated)
+		}
+		stats.Intents = append(stats.Intents, *intent)
+	}
+	sort.Slice(stats.Intents, func(i, j int) bool {
+		if stats.Intents[i].Interactions != stats.Intents[j].Interactions {
+			return stats.Intents[i].Interactions > stats.Intents[j].Interactions
+		}
+		return stats.Intents[i].Intent < stats.Intents[j].Intent
+	})
+
+	conversations, err := store.ListConversations(storage.ConversationFilter{})
+	if err != nil {
+		return nil, err
+	}
+	stats.Conversations = len(conversations)
+	for _, conversation := range conversations {
+		if conversation.Active() {
+			stats.OpenConversations++
+		}
+	}
+
+	patterns, err := store.GetPatterns()
+	if err != nil {
+		return nil, err
+	}
+	stats.Patterns = len(patterns)
+
+	if stats.Stored, err = store.GetStats(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// runStats implementa "agent stats": resume el contenido del almacenamiento
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	asJSON := fs.Bool("json", false, "salida en JSON (equivale a -format json)")
+	format := fs.String("format", "", "formato de salida: text, json o csv (csv solo con -analytics)")
+	analytics := fs.Bool("analytics", false, "informe de analíticas: intenciones, valoraciones, latencias y fallos")
+	since := fs.String("since", "", "analíticas desde esta fecha (AAAA-MM-DD o RFC 3339)")
+	until := fs.String("until", "", "analíticas hasta esta fecha, excluida")
+	window := fs.Duration("window", 24*time.Hour, "tamaño de cada ventana temporal de las analíticas")
+	semantic := fs.Bool("semantic", false, "agrupa los fallos con embeddings de Ollama en lugar de por palabras")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = "text"
+		if *asJSON || *analytics {
+			*format = "json"
+		}
+	}
+	switch *format {
+	case "text", "json":
+	case "csv":
+		if !*analytics {
+			return fmt.Errorf("-format csv solo está disponible con -analytics")
+		}
+	default:
+		return fmt.Errorf("formato desconocido: %s (usa text, json o csv)", *format)
+	}
+	if *analytics && *format == "text" {
+		return fmt.Errorf("las analíticas se exportan en json o csv")
+	}
+
+	config, store, err := openStore(*configPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if *analytics {
+		if *window <= 0 {
+			return fmt.Errorf("-window debe ser positivo: %s", *window)
+		}
+		opts := learning.AnalyticsOptions{Window: *window}
+		if opts.Since, err = parseDate(*since); err != nil {
+			return err
+		}
+		if opts.Until, err = parseDate(*until); err != nil {
+			return err
+		}
+		if *semantic {
+			opts.Embedder = nlp.NewProcessor(config.NLP.OllamaURL, config.nlpConfig())
+		}
+		return runAnalytics(config, store, opts, *format)
+	}
+
+	stats, err := computeStats(config, store)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		return printJSON(stats)
+	}
+
+	backend := stats.Backend
+	if backend == "" {
+		backend = "sqlite"
+	}
+	fmt.Printf("Almacenamiento: %s (%s)\n\n", stats.Path, backend)
+	fmt.Printf("Interacciones:   %d de %d usuarios\n", stats.Interactions, stats.Users)
+	if stats.Interactions > 0 {
+		fmt.Printf("Periodo:         %s — %s\n", stats.First.Local().Format("2006-01-02 15:04"), stats.Last.Local().Format("2006-01-02 15:04"))
+	}
+	fmt.Printf("Valoradas:       %d (%d positivas, %d negativas, media %.2f)\n",
+		stats.Rated, stats.PositiveFeedback, stats.NegativeFeedback, stats.AverageRating)
+	fmt.Printf("Conversaciones:  %d (%d abiertas)\n", stats.Conversations, stats.OpenConversations)
+	fmt.Printf("Patrones:        %d\n", stats.Patterns)
+
+	if len(stats.Intents) == 0 {
+		return nil
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INTENCIÓN\tINTERACCIONES\tVALORADAS\tMEDIA")
+	for _, intent := range stats.Intents {
+		average := "-"
+		if intent.Rated > 0 {
+			average = fmt.Sprintf("%.2f", intent.AverageRating)
+		}
+		name := intent.Intent
+		if name == "" {
+			name = "(sin intención)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", name, intent.Interactions, intent.Rated, average)
+	}
+	return w.Flush()
+}
+
+// runAnalytics calcula las analíticas de las interacciones guardadas y las
+// escribe en la salida estándar
+func runAnalytics(config *Config, store storage.Store, opts learning.AnalyticsOptions, format string) error {
+	engine, err := engineFromStore(config, store)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := engine.Analytics(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("error calculando analíticas: %w", err)
+	}
+	if format == "csv" {
+		return report.WriteCSV(os.Stdout)
+	}
+	return report.WriteJSON(os.Stdout)
+}
+
+// runBackup implementa "agent backup": crea una copia de seguridad verificada
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	_, store, err := openStore(*configPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	path, err := store.Backup()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("las copias de seguridad están deshabilitadas en %s (storage.backup_enabled)", *configPath)
+	}
+	fmt.Printf("✓ Copia de seguridad creada: %s\n", path)
+	return nil
+}
+
+// runRestore implementa "agent restore": reemplaza todos los datos con una copia
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	yes := fs.Bool("yes", false, "no pedir confirmación")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("uso: agent restore [-config ruta] [-yes] copia")
+	}
+	path := fs.Arg(0)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("error abriendo copia: %w", err)
+	}
+
+	_, store, err := openStore(*configPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := confirm(fmt.Sprintf("Se reemplazarán todos los datos con %s. ¿Continuar?", path), *yes); err != nil {
+		return err
+	}
+	if err := store.Restore(path); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Datos restaurados desde %s\n", path)
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/akosej/agent/internal/agent"
+	"github.com/akosej/agent/internal/learning"
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/internal/speech"
+	"github.com/akosej/agent/pkg/logger"
+	"github.com/akosej/agent/pkg/metrics"
+	"github.com/akosej/agent/pkg/redact"
+	"github.com/akosej/agent/pkg/storage"
+	"github.com/akosej/agent/pkg/tracing"
+)
+
+// app agrupa los componentes del agente construidos a partir de la configuración
+type app struct {
+	config    *Config
+	log       *logger.Logger
+	stopLog   func()            // deja de atender las señales del logger
+	redactor  *redact.Redactor  // nil sin redacción
+	metrics   *metrics.Registry // nil con las métricas deshabilitadas
+	tracer    *tracing.Tracer   // nil con las trazas deshabilitadas
+	store     storage.Store
+	data      storage.Store // store tal como lo usa el agente, con métricas y redacción
+	engine    *learning.Engine
+	processor *nlp.Processor
+	agent     *agent.Agent
+}
+
+// newApp construye logger, almacenamiento, motor de aprendizaje, procesador NLP,
+// transcriptor y agente, aplicando la redacción, las métricas y las trazas donde la
+// configuración lo indica. logOutput sustituye a stdout como salida de consola
+// del log (nil usa stdout).
+func newApp(config *Config, logOutput io.Writer) (*app, error) {
+	a := &app{config: config}
+
+	var m metrics.Metrics // nil deja cada componente con sus métricas descartadas
+	if config.Metrics.Enabled {
+		a.metrics = metrics.NewRegistry()
+		m = a.metrics
+	}
+
+	redactor, err := config.newRedactor()
+	if err != nil {
+		return nil, err
+	}
+	a.redactor = redactor
+
+	logConfig := config.loggerConfig()
+	logConfig.Output = logOutput
+	if redactor != nil && config.Redaction.Logs {
+		logConfig.Redactor = redactor
+	}
+	if a.log, err = logger.NewLogger(logConfig); err != nil {
+		a.Close()
+		return nil, fmt.Errorf("error configurando logger: %w", err)
+	}
+	a.stopLog = watchLogSignals(a.log)
+
+	traceLog := a.log.Component("tracing")
+	if a.tracer, err = config.newTracer(func(err error) { traceLog.WarnContext(context.Background(), "error exportando trazas", "error", err) }); err != nil {
+		a.Close()
+		return nil, fmt.Errorf("error configurando trazas: %w", err)
+	}
+
+	if a.store, err = storage.NewStorage(config.storageConfig()); err != nil {
+		a.Close()
+		return nil, fmt.Errorf("error abriendo almacenamiento: %w", err)
+	}
+	if sqlite, ok := a.store.(*storage.SQLiteStore); ok && !sqlite.FullTextSearch() && !config.Storage.Encryption.Enabled {
+		a.log.Warn("Búsqueda de texto completo (FTS5) no disponible en este binario: se busca en memoria. Compila con -tags sqlite_fts5 (make build) o usa storage.type sqlite-purego")
+	}
+	if redactor != nil {
+		a.store = storage.WithVault(a.store, redactor)
+	}
+	store := storage.WithMetrics(a.store, m)
+	if redactor != nil && config.Redaction.Storage {
+		store = storage.WithRedaction(store, redactor)
+	}
+	a.data = store
+
+	// El motor parte de los patrones aprendidos en ejecuciones anteriores; las
+	// interacciones y sus estadísticas de sesión empiezan de cero
+	a.engine = learning.NewEngine(config.learningConfig(), learning.WithMetrics(m))
+	patterns, err := patternsFromStore(store)
+	if err != nil {
+		a.Close()
+		return nil, fmt.Errorf("error cargando patrones aprendidos: %w", err)
+	}
+	a.engine.LoadPatterns(patterns)
+
+	opts := []nlp.Option{nlp.WithMetrics(m), nlp.WithTracer(a.tracer)}
+	if redactor != nil && config.Redaction.Prompts {
+		opts = append(opts, nlp.WithRedactor(redactor))
+	}
+	a.processor = nlp.NewProcessor(config.NLP.OllamaURL, config.nlpConfig(), opts...)
+
+	transcriber, err := config.newTranscriber(speech.WithMetrics(m), speech.WithTracer(a.tracer))
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	deps := agent.Dependencies{
+		Model:   a.processor,
+		Engine:  a.engine,
+		Store:   store,
+		Logger:  a.log,
+		Metrics: m,
+		Tracer:  a.tracer,
+	}
+	if transcriber != nil {
+		deps.Transcriber = transcriber
+	}
+	if config.Speech.TTSURL != "" {
+		deps.Synthesizer = speech.NewSynthesizer(config.Speech.TTSURL, config.Speech.TTSVoice)
+	}
+	if a.agent, err = agent.New(agent.Config{Model: config.NLP.Model}, deps); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// Close guarda los patrones aprendidos pendientes, cierra el almacenamiento,
+// el logger y el vault de redacción y por último exporta las trazas
+// pendientes, incluidas las de los cierres anteriores
+func (a *app) Close() error {
+	var first error
+	if a.agent != nil {
+		first = a.agent.SavePatterns(context.Background())
+	}
+	if a.store != nil {
+		if err := a.store.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	if a.log != nil {
+		a.stopLog()
+		if err := a.log.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	if err := a.redactor.Close(); err != nil && first == nil {
+		first = err
+	}
+	if err := a.tracer.Close(); err != nil && first == nil {
+		first = err
+	}
+	return first
+}
+
+// learningConfig convierte la sección learning a learning.Config
+func (c *Config) learningConfig() learning.Config {
+	return learning.Config{
+		LearningRate:        c.Learning.LearningRate,
+		ConfidenceThreshold: c.Learning.ConfidenceThreshold,
+		MaxInteractions:     c.Learning.MaxInteractions,
+		SaveInterval:        c.Learning.SaveInterval,
+	}
+}
+
+// nlpConfig convierte la sección nlp a nlp.Config
+func (c *Config) nlpConfig() nlp.Config {
+	return nlp.Config{
+		Model:       c.NLP.Model,
+		MaxTokens:   c.NLP.MaxTokens,
+		Temperature: c.NLP.Temperature,
+		OllamaURL:   c.NLP.OllamaURL,
+	}
+}
+
+// newTranscriber crea el transcriptor del proveedor configurado (nil si no hay)
+func (c *Config) newTranscriber(opts ...speech.Option) (*speech.Transcriber, error) {
+	switch c.Speech.Provider {
+	case "":
+		return nil, nil
+	case "whisper-cpp":
+		return speech.NewTranscriber(c.Speech.WhisperPath, c.Speech.ModelPath, c.Speech.Language, opts...), nil
+	case "whisper-api":
+		return speech.NewTranscriberWithAPI(c.Speech.APIURL, c.Speech.Language, opts...), nil
+	default:
+		return nil, fmt.Errorf("proveedor de voz desconocido: %q (whisper-cpp o whisper-api)", c.Speech.Provider)
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akosej/agent/internal/agent"
+	"github.com/akosej/agent/internal/health"
+	"github.com/akosej/agent/internal/speech"
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// slashCommand es un comando del REPL
+type slashCommand struct {
+	name  string
+	usage string
+	run   func(r *repl, ctx context.Context, args string) error
+}
+
+// slashCommands se inicializa en init porque /help la recorre
+var slashCommands []slashCommand
+
+func init() {
+	slashCommands = []slashCommand{
+		{"/voice", "/voice              Activa o desactiva la entrada por micrófono", (*repl).cmdVoice},
+		{"/rate", "/rate 1-5 [texto]   Valora la última respuesta", (*repl).cmdRate},
+		{"/stats", "/stats              Muestra las estadísticas", (*repl).cmdStats},
+		{"/history", "/history            Muestra la conversación actual", (*repl).cmdHistory},
+		{"/summary", "/summary            Resume la conversación actual", (*repl).cmdSummary},
+		{"/model", "/model [nombre]     Muestra los modelos o cambia de modelo", (*repl).cmdModel},
+		{"/export", "/export [archivo]   Exporta la base de conocimiento", (*repl).cmdExport},
+		{"/import", "/import archivo     Importa una base de conocimiento", (*repl).cmdImport},
+		{"/reset", "/reset              Empieza una conversación nueva", (*repl).cmdReset},
+		{"/help", "/help               Muestra esta ayuda", (*repl).cmdHelp},
+		{"/exit", "/exit               Sale (también /salir, /quit o Ctrl-D)", nil},
+	}
+}
+
+// errExit indica que el usuario pidió salir
+var errExit = errors.New("salir")
+
+// repl es una sesión interactiva con el agente
+type repl struct {
+	app     *app
+	console *console
+
+	userID          string
+	conversationID  string
+	lastInteraction string
+
+	voice      bool
+	recognizer *speech.Recognizer
+}
+
+// runChat implementa "agent chat": conversación interactiva en la terminal
+func runChat(args []string) error {
+	fs := flag.NewFlagSet("chat", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	userID := fs.String("user", os.Getenv("USER"), "usuario al que se asocian las interacciones")
+	conversationID := fs.String("conversation", "", "continúa una conversación guardada")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	// Con archivo de log, la consola queda para la conversación
+	var logOutput io.Writer = os.Stderr
+	if config.Logging.File != "" {
+		logOutput = io.Discard
+	}
+	a, err := newApp(config, logOutput)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	r := &repl{app: a, userID: *userID}
+	r.console = newConsole(r.complete)
+	defer r.closeRecognizer()
+
+	if config.NLP.AutoPull {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		_, err := a.pullMissingModel(ctx, func(message string) { r.console.Printf("%s\n", message) })
+		stop()
+		if err != nil {
+			return fmt.Errorf("error descargando el modelo %s: %w", config.NLP.Model, err)
+		}
+	}
+
+	if *conversationID != "" {
+		conversation, err := storage.ResumeConversation(a.store, *conversationID)
+		if err != nil {
+			return fmt.Errorf("error abriendo conversación %s: %w", *conversationID, err)
+		}
+		r.conversationID = conversation.ID
+		r.console.Printf("Continuando la conversación %s (%d turnos)\n", conversation.ID, conversation.TurnCount)
+	}
+
+	r.console.Printf("%s %s · modelo %s · /help para ver los comandos\n", config.Agent.Name, config.Agent.Version, a.agent.Model())
+	return r.loop()
+}
+
+// loop lee y atiende líneas hasta /exit, Ctrl-D o el fin de la entrada
+func (r *repl) loop() error {
+	for {
+		prompt := "tú> "
+		if r.voice {
+			prompt = "🎤 Enter para hablar> "
+		}
+		line, err := r.console.ReadLine(prompt)
+		if err == io.EOF {
+			r.console.Println()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" && !r.voice {
+			continue
+		}
+
+		// Ctrl-C durante una respuesta la interrumpe sin salir del REPL
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		switch {
+		case strings.HasPrefix(line, "/"):
+			err = r.command(ctx, line)
+		case line == "":
+			err = r.voiceTurn(ctx)
+		default:
+			err = r.textTurn(ctx, line)
+		}
+		stop()
+
+		switch {
+		case errors.Is(err, errExit):
+			return nil
+		case errors.Is(err, context.Canceled):
+			r.console.Println(r.console.dim("\n(interrumpido)"))
+		case err != nil:
+			r.console.Printf("Error: %v\n", err)
+		}
+	}
+}
+
+// commandAliases son nombres alternativos de algunos comandos
+var commandAliases = map[string]string{
+	"/ayuda": "/help",
+	"/salir": "/exit",
+	"/quit":  "/exit",
+}
+
+// command ejecuta un comando /nombre argumentos
+func (r *repl) command(ctx context.Context, line string) error {
+	name, args, _ := strings.Cut(line, " ")
+	if alias, ok := commandAliases[name]; ok {
+		name = alias
+	}
+	for _, cmd := range slashCommands {
+		if cmd.name == name {
+			if cmd.run == nil {
+				return errExit
+			}
+			return cmd.run(r, ctx, strings.TrimSpace(args))
+		}
+	}
+	return fmt.Errorf("comando desconocido: %s (/help para ver los comandos)", name)
+}
+
+// complete devuelve los comandos que empiezan por prefix
+func (r *repl) complete(prefix string) []string {
+	if !strings.HasPrefix(prefix, "/") || strings.Contains(prefix, " ") {
+		return nil
+	}
+	var options []string
+	for _, cmd := range slashCommands {
+		if strings.HasPrefix(cmd.name, prefix) {
+			options = append(options, cmd.name)
+		}
+	}
+	return options
+}
+
+// request devuelve la petición para el siguiente turno de la conversación
+func (r *repl) request(text string) agent.ChatRequest {
+	return agent.ChatRequest{Text: text, UserID: r.userID, ConversationID: r.conversationID}
+}
+
+// textTurn envía un mensaje y muestra la respuesta a medida que se genera
+func (r *repl) textTurn(ctx context.Context, text string) error {
+	out := r.newOutput()
+	result, err := r.app.agent.ChatStream(ctx, r.request(text), out.handler())
+	return r.finishTurn(out, result, err)
+}
+
+// voiceTurn graba del micrófono hasta que el usuario pulsa Enter, mostrando
+// la transcripción parcial, y responde como textTurn
+func (r *repl) voiceTurn(ctx context.Context) error {
+	recordCtx, stopRecording := context.WithCancel(ctx)
+	defer stopRecording()
+
+	chunks, err := r.recognizer.StartListening(recordCtx)
+	if err != nil {
+		r.voice = false
+		return fmt.Errorf("%w; entrada por voz desactivada", err)
+	}
+
+	out := r.newOutput()
+	audio := speech.ChunkConfig{SampleRate: r.app.config.Speech.SampleRate, Channels: r.app.config.Speech.Channels}
+
+	var result *agent.ChatResult
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result, err = r.app.agent.Voice(ctx, chunks, audio, r.request(""), out.handler())
+	}()
+
+	// Enter termina la grabación; Ctrl-D también, sin salir del REPL
+	r.console.ReadLine("⏺ Grabando… Enter para terminar ")
+	stopRecording()
+	<-done
+	return r.finishTurn(out, result, err)
+}
+
+// finishTurn cierra la salida de un turno y recuerda su interacción y conversación
+func (r *repl) finishTurn(out *turnOutput, result *agent.ChatResult, err error) error {
+	out.end()
+	if err != nil {
+		return err
+	}
+	r.conversationID = result.ConversationID
+	r.lastInteraction = result.InteractionID
+	r.console.Println(r.console.dim(fmt.Sprintf("  (%s · %.2f · %s)", result.Intent, result.Confidence,
+		(time.Duration(result.LatencyMs) * time.Millisecond).String())))
+	return nil
+}
+
+// turnOutput escribe los eventos de un turno en la consola
+type turnOutput struct {
+	console *console
+	mu      sync.Mutex
+	started bool
+}
+
+func (r *repl) newOutput() *turnOutput {
+	return &turnOutput{console: r.console}
+}
+
+// handler devuelve los callbacks que muestran transcripción y respuesta
+func (o *turnOutput) handler() agent.StreamHandler {
+	return agent.StreamHandler{
+		OnTranscript: func(text string, final bool) {
+			if final {
+				o.console.Printf("tú (voz)> %s\n", text)
+			} else {
+				o.console.Println(o.console.dim("… " + text))
+			}
+		},
+		OnToken: func(token string) error {
+			o.mu.Lock()
+			defer o.mu.Unlock()
+			if !o.started {
+				o.started = true
+				o.console.Printf("agente> ")
+			}
+			o.console.Printf("%s", token)
+			return nil
+		},
+	}
+}
+
+// end termina la línea de la respuesta, si se empezó
+func (o *turnOutput) end() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.started {
+		o.console.Println()
+	}
+}
+
+func (r *repl) cmdVoice(ctx context.Context, args string) error {
+	if r.voice {
+		r.voice = false
+		r.console.Println("Entrada por voz desactivada")
+		return nil
+	}
+	if !r.app.agent.CanTranscribe() {
+		return fmt.Errorf("no hay proveedor de voz configurado (speech.provider)")
+	}
+	if r.recognizer == nil {
+		recognizer, err := speech.NewRecognizer(speech.Config{
+			SampleRate: r.app.config.Speech.SampleRate,
+			Channels:   r.app.config.Speech.Channels,
+			Language:   r.app.config.Speech.Language,
+		})
+		if err != nil {
+			return err
+		}
+		r.recognizer = recognizer
+	}
+	r.voice = true
+	r.console.Println("Entrada por voz activada: pulsa Enter para hablar y otra vez para terminar; también puedes escribir")
+	return nil
+}
+
+// closeRecognizer libera el micrófono si se llegó a abrir
+func (r *repl) closeRecognizer() {
+	if r.recognizer != nil {
+		r.recognizer.Close()
+	}
+}
+
+func (r *repl) cmdRate(ctx context.Context, args string) error {
+	if r.lastInteraction == "" {
+		return fmt.Errorf("todavía no hay ninguna respuesta que valorar")
+	}
+	value, comment, _ := strings.Cut(args, " ")
+	rating, err := strconv.Atoi(value)
+	if err != nil || rating < 1 || rating > 5 {
+		return fmt.Errorf("uso: /rate 1-5 [comentario]")
+	}
+	if err := r.app.agent.Feedback(ctx, r.lastInteraction, rating, strings.TrimSpace(comment)); err != nil {
+		return err
+	}
+	r.console.Printf("✓ Valoración %d guardada\n", rating)
+	return nil
+}
+
+func (r *repl) cmdStats(ctx context.Context, args string) error {
+	stats, err := r.app.agent.Stats()
+	if err != nil {
+		return err
+	}
+	r.console.Printf("Modelo: %s\n", stats.Model)
+	r.console.Printf("Esta sesión:  %d interacciones, %d valoraciones positivas, %d negativas, media %.2f\n",
+		stats.Session.TotalInteractions, stats.Session.PositiveFeedback, stats.Session.NegativeFeedback, stats.Session.AverageRating)
+	r.console.Printf("Almacenadas:  %d interacciones, %d valoraciones positivas, %d negativas, media %.2f\n",
+		stats.Stored.TotalInteractions, stats.Stored.PositiveFeedback, stats.Stored.NegativeFeedback, stats.Stored.AverageRating)
+	return nil
+}
+
+func (r *repl) cmdHistory(ctx context.Context, args string) error {
+	if r.conversationID == "" {
+		r.console.Println("La conversación está vacía")
+		return nil
+	}
+	conversation, err := r.app.agent.Conversation(r.conversationID, "")
+	if err != nil {
+		return err
+	}
+	r.console.Printf("Conversación %s, iniciada %s\n", conversation.ID, conversation.StartedAt.Local().Format("2006-01-02 15:04"))
+	for _, turn := range conversation.Turns {
+		speaker := "tú"
+		if turn.Role == "assistant" {
+			speaker = "agente"
+		}
+		r.console.Printf("%s %s> %s\n", r.console.dim(turn.Timestamp.Local().Format("15:04")), speaker, turn.Content)
+	}
+	return nil
+}
+
+func (r *repl) cmdSummary(ctx context.Context, args string) error {
+	if r.conversationID == "" {
+		r.console.Println("La conversación está vacía")
+		return nil
+	}
+	summary, err := r.app.agent.Summarize(ctx, r.conversationID)
+	if err != nil {
+		return err
+	}
+	r.console.Println(summary)
+	return nil
+}
+
+func (r *repl) cmdModel(ctx context.Context, args string) error {
+	models, listErr := r.app.processor.ListModels(ctx)
+
+	if args == "" {
+		r.console.Printf("Modelo actual: %s\n", r.app.agent.Model())
+		if listErr != nil {
+			return listErr
+		}
+		sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+		for _, model := range models {
+			marker := "  "
+			if model.Name == r.app.agent.Model() {
+				marker = "* "
+			}
+			r.console.Printf("%s%-30s %s\n", marker, model.Name, r.console.dim(formatBytes(model.Size)))
+		}
+		return nil
+	}
+
+	if listErr == nil && !health.HasModel(models, args) {
+		return fmt.Errorf("el modelo %s no está instalado (ollama pull %s)", args, args)
+	}
+	if err := r.app.agent.SetModel(args); err != nil {
+		return err
+	}
+	r.console.Printf("✓ Modelo cambiado a %s\n", args)
+	return nil
+}
+
+// formatBytes muestra un tamaño en MB o GB
+func formatBytes(size int64) string {
+	const mb = 1 << 20
+	if size >= 1<<30 {
+		return fmt.Sprintf("%.1f GB", float64(size)/(1<<30))
+	}
+	return fmt.Sprintf("%d MB", size/mb)
+}
+
+// cmdExport exporta lo guardado en el almacenamiento, como "agent kb export",
+// tras guardar los patrones pendientes de la sesión
+func (r *repl) cmdExport(ctx context.Context, args string) error {
+	path := args
+	if path == "" {
+		path = filepath.Join(filepath.Dir(r.app.config.Storage.Path), "knowledge_"+time.Now().Format("20060102_150405")+".json")
+	}
+	if err := r.app.agent.SavePatterns(ctx); err != nil {
+		return err
+	}
+	engine, err := engineFromStore(r.app.config, r.app.data)
+	if err != nil {
+		return err
+	}
+	data, err := engine.Export()
+	if err != nil {
+		return fmt.Errorf("error exportando base de conocimiento: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("error escribiendo %s: %w", path, err)
+	}
+	r.console.Printf("✓ Exportadas %d interacciones y %d patrones a %s\n",
+		engine.GetStats().TotalInteractions, len(engine.GetPatterns()), path)
+	return nil
+}
+
+// cmdImport guarda un archivo exportado en el almacenamiento, como "agent kb
+// import", y recarga los patrones de la sesión desde él
+func (r *repl) cmdImport(ctx context.Context, args string) error {
+	if args == "" {
+		return fmt.Errorf("uso: /import archivo")
+	}
+	data, err := os.ReadFile(args)
+	if err != nil {
+		return fmt.Errorf("error leyendo %s: %w", args, err)
+	}
+	interactions, patterns, err := parseKnowledge(data)
+	if err != nil {
+		return err
+	}
+
+	// Los patrones pendientes se guardan antes para no perderlos al recargar
+	if err := r.app.agent.SavePatterns(ctx); err != nil {
+		return err
+	}
+	if err := saveKnowledge(r.app.data, interactions, patterns); err != nil {
+		return err
+	}
+	stored, err := patternsFromStore(r.app.data)
+	if err != nil {
+		return fmt.Errorf("error cargando patrones aprendidos: %w", err)
+	}
+	r.app.engine.LoadPatterns(stored)
+
+	r.console.Printf("✓ Importadas %d interacciones y %d patrones\n", len(interactions), len(patterns))
+	return nil
+}
+
+func (r *repl) cmdReset(ctx context.Context, args string) error {
+	r.conversationID = ""
+	r.lastInteraction = ""
+	r.console.Println("Conversación nueva")
+	return nil
+}
+
+func (r *repl) cmdHelp(ctx context.Context, args string) error {
+	r.console.Println("Escribe un mensaje o un comando:")
+	for _, cmd := range slashCommands {
+		r.console.Printf("  %s\n", cmd.usage)
+	}
+	r.console.Println(r.console.dim("  Ctrl-C interrumpe la respuesta en curso; ↑/↓ recorren el historial; Tab completa comandos"))
+	return nil
+}
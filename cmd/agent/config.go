@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/akosej/agent/pkg/logger"
+	"github.com/akosej/agent/pkg/storage"
+	"github.com/akosej/agent/pkg/tracing"
+)
+
+// defaultConfigPath es la ruta por defecto del archivo de configuración
+const defaultConfigPath = "./configs/config.yaml"
+
+// Config refleja la estructura de configs/config.yaml
+type Config struct {
+	Agent struct {
+		Name     string `yaml:"name"`
+		Version  string `yaml:"version"`
+		Language string `yaml:"language"`
+	} `yaml:"agent"`
+
+	Speech struct {
+		SampleRate  int    `yaml:"sample_rate"`
+		Channels    int    `yaml:"channels"`
+		Language    string `yaml:"language"`
+		Provider    string `yaml:"provider"`
+		WhisperPath string `yaml:"whisper_path"`
+		ModelPath   string `yaml:"model_path"`
+		APIURL      string `yaml:"api_url"`
+		TTSURL      string `yaml:"tts_url"`
+		TTSVoice    string `yaml:"tts_voice"`
+	} `yaml:"speech"`
+
+	NLP struct {
+		Model       string  `yaml:"model"`
+		MaxTokens   int     `yaml:"max_tokens"`
+		Temperature float32 `yaml:"temperature"`
+		OllamaURL   string  `yaml:"ollama_url"`
+		AutoPull    bool    `yaml:"auto_pull"`
+	} `yaml:"nlp"`
+
+	Learning struct {
+		Enabled             bool    `yaml:"enabled"`
+		LearningRate        float64 `yaml:"learning_rate"`
+		ConfidenceThreshold float64 `yaml:"confidence_threshold"`
+		MaxInteractions     int     `yaml:"max_interactions"`
+		SaveInterval        int     `yaml:"save_interval"`
+	} `yaml:"learning"`
+
+	Storage struct {
+		Type              string `yaml:"type"`
+		Path              string `yaml:"path"`
+		BackupEnabled     bool   `yaml:"backup_enabled"`
+		BackupInterval    int    `yaml:"backup_interval"`
+		BackupGenerations int    `yaml:"backup_generations"`
+		Retention         struct {
+			MaxAgeDays       int            `yaml:"max_age_days"`
+			MaxInteractions  int            `yaml:"max_interactions"`
+			IntentMaxAgeDays map[string]int `yaml:"intent_max_age_days"`
+			Interval         int            `yaml:"interval"`
+		} `yaml:"retention"`
+		Encryption struct {
+			Enabled bool   `yaml:"enabled"`
+			KeyEnv  string `yaml:"key_env"`
+			KeyFile string `yaml:"key_file"`
+		} `yaml:"encryption"`
+	} `yaml:"storage"`
+
+	Logging struct {
+		Level      string            `yaml:"level"`
+		Components map[string]string `yaml:"components"`
+		Format     string            `yaml:"format"`
+		File       string            `yaml:"file"`
+		MaxSize    int               `yaml:"max_size"`
+		MaxBackups int               `yaml:"max_backups"`
+		MaxAge     int               `yaml:"max_age"`
+		Compress   bool              `yaml:"compress"`
+	} `yaml:"logging"`
+
+	Redaction struct {
+		Enabled   bool     `yaml:"enabled"`
+		Logs      bool     `yaml:"logs"`
+		Storage   bool     `yaml:"storage"`
+		Prompts   bool     `yaml:"prompts"`
+		Detectors []string `yaml:"detectors"`
+		Custom    []struct {
+			Name    string `yaml:"name"`
+			Pattern string `yaml:"pattern"`
+		} `yaml:"custom"`
+		Tokenize struct {
+			Enabled bool   `yaml:"enabled"`
+			KeyEnv  string `yaml:"key_env"`
+			Vault   string `yaml:"vault"`
+		} `yaml:"tokenize"`
+	} `yaml:"redaction"`
+
+	Server struct {
+		Addr            string   `yaml:"addr"`
+		ReadTimeout     int      `yaml:"read_timeout"`
+		WriteTimeout    int      `yaml:"write_timeout"`
+		ShutdownTimeout int      `yaml:"shutdown_timeout"`
+		MaxBodyKB       int      `yaml:"max_body_kb"`
+		MaxAudioMB      int      `yaml:"max_audio_mb"`
+		AdminTokenEnv   string   `yaml:"admin_token_env"`
+		APITokenEnv     string   `yaml:"api_token_env"`
+		AllowedOrigins  []string `yaml:"allowed_origins"`
+	} `yaml:"server"`
+
+	Metrics struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"metrics"`
+
+	Health struct {
+		Timeout   int `yaml:"timeout"`
+		CacheTime int `yaml:"cache_time"`
+		MinFreeMB int `yaml:"min_free_mb"`
+	} `yaml:"health"`
+
+	Tracing struct {
+		Enabled      bool    `yaml:"enabled"`
+		Exporter     string  `yaml:"exporter"` // stdout, file u otlp
+		File         string  `yaml:"file"`
+		OTLPEndpoint string  `yaml:"otlp_endpoint"`
+		SampleRatio  float64 `yaml:"sample_ratio"`
+		ServiceName  string  `yaml:"service_name"`
+	} `yaml:"tracing"`
+}
+
+// loadConfig lee la configuración desde un archivo YAML
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo configuración: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parseando configuración: %w", err)
+	}
+
+	return &config, nil
+}
+
+// storageConfig convierte la sección storage a storage.Config
+func (c *Config) storageConfig() storage.Config {
+	const day = 24 * time.Hour
+
+	retention := c.Storage.Retention
+	policy := storage.RetentionPolicy{
+		MaxAge:          time.Duration(retention.MaxAgeDays) * day,
+		MaxInteractions: retention.MaxInteractions,
+		Interval:        time.Duration(retention.Interval) * time.Second,
+	}
+	if len(retention.IntentMaxAgeDays) > 0 {
+		policy.IntentMaxAge = make(map[string]time.Duration, len(retention.IntentMaxAgeDays))
+		for intent, days := range retention.IntentMaxAgeDays {
+			policy.IntentMaxAge[intent] = time.Duration(days) * day
+		}
+	}
+
+	return storage.Config{
+		Type:              c.Storage.Type,
+		Path:              c.Storage.Path,
+		BackupEnabled:     c.Storage.BackupEnabled,
+		BackupInterval:    c.Storage.BackupInterval,
+		BackupGenerations: c.Storage.BackupGenerations,
+		Retention:         policy,
+		Encryption: storage.EncryptionConfig{
+			Enabled: c.Storage.Encryption.Enabled,
+			KeyEnv:  c.Storage.Encryption.KeyEnv,
+			KeyFile: c.Storage.Encryption.KeyFile,
+		},
+	}
+}
+
+// loggerConfig convierte la sección logging a logger.Config
+func (c *Config) loggerConfig() logger.Config {
+	return logger.Config{
+		Level:      c.Logging.Level,
+		Components: c.Logging.Components,
+		Format:     c.Logging.Format,
+		File:       c.Logging.File,
+		MaxSize:    c.Logging.MaxSize,
+		MaxBackups: c.Logging.MaxBackups,
+		MaxAge:     c.Logging.MaxAge,
+		Compress:   c.Logging.Compress,
+	}
+}
+
+// newTracer crea el tracer según la sección tracing, o nil si está deshabilitada.
+// onError recibe los fallos al exportar spans.
+func (c *Config) newTracer(onError func(err error)) (*tracing.Tracer, error) {
+	if !c.Tracing.Enabled {
+		return nil, nil
+	}
+
+	var exporter tracing.Exporter
+	switch c.Tracing.Exporter {
+	case "", "stdout":
+		exporter = tracing.NewWriterExporter(os.Stdout)
+	case "file":
+		path := c.Tracing.File
+		if path == "" {
+			path = "./logs/traces.jsonl"
+		}
+		var err error
+		if exporter, err = tracing.NewFileExporter(path); err != nil {
+			return nil, err
+		}
+	case "otlp":
+		endpoint := c.Tracing.OTLPEndpoint
+		if endpoint == "" {
+			endpoint = "http://localhost:4318"
+		}
+		exporter = tracing.NewOTLPExporter(endpoint, c.Tracing.ServiceName, onError)
+	default:
+		return nil, fmt.Errorf("exportador de trazas desconocido: %s (usa stdout, file u otlp)", c.Tracing.Exporter)
+	}
+
+	return tracing.NewTracer(tracing.Config{
+		ServiceName: c.Tracing.ServiceName,
+		SampleRatio: c.Tracing.SampleRatio,
+		Exporter:    exporter,
+		OnError:     onError,
+	})
+}
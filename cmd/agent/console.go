@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// console lee líneas del usuario y escribe la salida del REPL. Con una terminal
+// ofrece edición de línea e historial (flechas, Ctrl-A/E/K/U, Tab para completar
+// comandos); si la entrada viene de un archivo o una tubería lee línea a línea.
+type console struct {
+	terminal *term.Terminal // nil si la entrada no es una terminal
+	fd       int
+	reader   *bufio.Reader
+	out      io.Writer
+}
+
+// newConsole prepara la consola sobre stdin/stdout; complete, si no es nil,
+// devuelve las opciones para completar el prefijo de una línea
+func newConsole(complete func(prefix string) []string) *console {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return &console{fd: -1, reader: bufio.NewReader(os.Stdin), out: os.Stdout}
+	}
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	if width, height, err := term.GetSize(fd); err == nil {
+		t.SetSize(width, height)
+	}
+	if complete != nil {
+		t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+			if key != '\t' || pos != len(line) {
+				return "", 0, false
+			}
+			options := complete(line)
+			if len(options) != 1 {
+				return "", 0, false
+			}
+			return options[0] + " ", len(options[0]) + 1, true
+		}
+	}
+	return &console{terminal: t, fd: fd, out: t}
+}
+
+// interactive indica si la consola está conectada a una terminal
+func (c *console) interactive() bool {
+	return c.terminal != nil
+}
+
+// ReadLine muestra prompt y lee una línea. Con terminal la lectura se hace en
+// modo raw, de modo que fuera de ella Ctrl-C llega como señal y puede
+// interrumpir la respuesta en curso. Devuelve io.EOF con Ctrl-D o Ctrl-C.
+func (c *console) ReadLine(prompt string) (string, error) {
+	if c.terminal == nil {
+		fmt.Fprint(c.out, prompt)
+		line, err := c.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	state, err := term.MakeRaw(c.fd)
+	if err != nil {
+		return "", fmt.Errorf("error preparando la terminal: %w", err)
+	}
+	defer term.Restore(c.fd, state)
+
+	c.terminal.SetPrompt(prompt)
+	return c.terminal.ReadLine()
+}
+
+// Write escribe en la salida; con terminal, sin romper la línea que se está editando
+func (c *console) Write(p []byte) (int, error) {
+	return c.out.Write(p)
+}
+
+// Printf escribe con formato en la salida
+func (c *console) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(c, format, args...)
+}
+
+// Println escribe una línea en la salida
+func (c *console) Println(args ...interface{}) {
+	fmt.Fprintln(c, args...)
+}
+
+// dim devuelve text en gris si la salida es una terminal
+func (c *console) dim(text string) string {
+	if c.terminal == nil {
+		return text
+	}
+	return "\x1b[2m" + text + "\x1b[0m"
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/akosej/agent/internal/health"
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// checkStatus es el resultado de una comprobación de "agent doctor"
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+// doctor acumula y muestra los resultados de las comprobaciones
+type doctor struct {
+	failures int
+	warnings int
+}
+
+// report muestra una comprobación con su símbolo
+func (d *doctor) report(status checkStatus, name, format string, args ...interface{}) {
+	symbol := "✓"
+	switch status {
+	case checkWarn:
+		symbol = "!"
+		d.warnings++
+	case checkFail:
+		symbol = "✗"
+		d.failures++
+	}
+	fmt.Printf("%s %-14s %s\n", symbol, name, fmt.Sprintf(format, args...))
+}
+
+// runDoctor implementa "agent doctor": comprueba que Ollama, los modelos, la
+// transcripción y el almacenamiento estén listos. Sale con error si algo falla.
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	timeout := fs.Duration("timeout", 5*time.Second, "tiempo máximo de cada comprobación de red")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	d := &doctor{}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		d.report(checkFail, "configuración", "%v", err)
+		return fmt.Errorf("doctor: la configuración no es válida")
+	}
+	d.report(checkOK, "configuración", "%s", *configPath)
+
+	d.checkOllama(config, *timeout)
+	d.checkSpeech(config, *timeout)
+	d.checkStorage(config)
+	d.checkLogs(config)
+
+	fmt.Println()
+	if d.failures > 0 {
+		return fmt.Errorf("doctor: %d comprobaciones fallidas, %d avisos", d.failures, d.warnings)
+	}
+	if d.warnings > 0 {
+		fmt.Printf("Todo listo, con %d avisos\n", d.warnings)
+		return nil
+	}
+	fmt.Println("Todo listo")
+	return nil
+}
+
+// checkOllama comprueba que Ollama responda y tenga instalado el modelo configurado
+func (d *doctor) checkOllama(config *Config, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	processor := nlp.NewProcessor(config.NLP.OllamaURL, config.nlpConfig())
+	models, err := processor.ListModels(ctx)
+	if err != nil {
+		d.report(checkFail, "ollama", "%s no responde: %v (¿ollama serve?)", config.NLP.OllamaURL, err)
+		return
+	}
+	d.report(checkOK, "ollama", "%s (%d modelos instalados)", config.NLP.OllamaURL, len(models))
+
+	if health.HasModel(models, config.NLP.Model) {
+		d.report(checkOK, "modelo", "%s", config.NLP.Model)
+	} else {
+		d.report(checkFail, "modelo", "%s no está instalado (ollama pull %s)", config.NLP.Model, config.NLP.Model)
+	}
+}
+
+// checkSpeech comprueba el proveedor de transcripción y el servidor de síntesis
+func (d *doctor) checkSpeech(config *Config, timeout time.Duration) {
+	voice := config.Speech
+	switch voice.Provider {
+	case "":
+		d.report(checkWarn, "transcripción", "deshabilitada (speech.provider vacío)")
+	case "whisper-cpp":
+		info, err := os.Stat(voice.WhisperPath)
+		switch {
+		case err != nil:
+			d.report(checkFail, "whisper", "%v", err)
+		case info.IsDir() || info.Mode().Perm()&0111 == 0:
+			d.report(checkFail, "whisper", "%s no es ejecutable", voice.WhisperPath)
+		default:
+			d.report(checkOK, "whisper", "%s", voice.WhisperPath)
+		}
+		if info, err := os.Stat(voice.ModelPath); err != nil {
+			d.report(checkFail, "modelo whisper", "%v", err)
+		} else {
+			d.report(checkOK, "modelo whisper", "%s (%s)", voice.ModelPath, formatBytes(info.Size()))
+		}
+	case "whisper-api":
+		d.checkURL("whisper api", voice.APIURL, timeout)
+	default:
+		d.report(checkFail, "transcripción", "proveedor desconocido: %q (whisper-cpp o whisper-api)", voice.Provider)
+	}
+
+	if voice.TTSURL != "" {
+		d.checkURL("síntesis", voice.TTSURL, timeout)
+	}
+}
+
+// checkURL comprueba que un servidor HTTP responda, con cualquier estado
+func (d *doctor) checkURL(name, url string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		d.report(checkFail, name, "URL no válida %q: %v", url, err)
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		d.report(checkFail, name, "%s no responde: %v", url, err)
+		return
+	}
+	resp.Body.Close()
+	d.report(checkOK, name, "%s", url)
+}
+
+// checkStorage comprueba el almacenamiento sin abrirlo para escribir: no crea
+// la base de datos, no aplica migraciones y funciona con el agente en marcha.
+// También comprueba que se pueda escribir en su directorio, donde van las copias.
+func (d *doctor) checkStorage(config *Config) {
+	storeConfig := config.storageConfig()
+	if _, err := storage.LoadKeyRing(storeConfig.Encryption); err != nil {
+		d.report(checkFail, "almacenamiento", "%v", err)
+		return
+	}
+
+	var ok bool
+	switch config.Storage.Type {
+	case "json":
+		ok = d.checkJSONFiles(filepath.Dir(config.Storage.Path))
+	default:
+		ok = d.checkSchema(storeConfig)
+	}
+	if !ok {
+		return
+	}
+
+	kind := config.Storage.Type
+	if kind == "" {
+		kind = "sqlite"
+	}
+	if err := checkWritable(existingDir(filepath.Dir(config.Storage.Path))); err != nil {
+		d.report(checkFail, "almacenamiento", "%s (%s): %v", config.Storage.Path, kind, err)
+		return
+	}
+	d.report(checkOK, "almacenamiento", "%s (%s)", config.Storage.Path, kind)
+
+	if config.Storage.BackupEnabled {
+		d.report(checkOK, "copias", "cada %ds, %d generaciones", config.Storage.BackupInterval, config.Storage.BackupGenerations)
+	} else {
+		d.report(checkWarn, "copias", "deshabilitadas (storage.backup_enabled)")
+	}
+}
+
+// checkSchema informa de la versión del esquema SQLite y de las migraciones
+// pendientes, consultándolas en solo lectura
+func (d *doctor) checkSchema(config storage.Config) bool {
+	migrator, err := storage.OpenMigratorReadOnly(config)
+	if errors.Is(err, os.ErrNotExist) {
+		d.report(checkWarn, "esquema", "%s aún no existe: se creará al arrancar", config.Path)
+		return true
+	}
+	if err != nil {
+		d.report(checkFail, "almacenamiento", "%v", err)
+		return false
+	}
+	defer migrator.Close()
+
+	status, err := migrator.Status()
+	if err != nil {
+		d.report(checkFail, "almacenamiento", "%s: %v", config.Path, err)
+		return false
+	}
+	current, err := migrator.Version()
+	if err != nil {
+		d.report(checkFail, "almacenamiento", "%s: %v", config.Path, err)
+		return false
+	}
+	if current > storage.LatestSchemaVersion() {
+		d.report(checkFail, "esquema", "versión %d, este binario soporta hasta la %d", current, storage.LatestSchemaVersion())
+		return false
+	}
+
+	pending := 0
+	for _, s := range status {
+		if !s.Applied {
+			pending++
+		}
+	}
+	if pending > 0 {
+		d.report(checkWarn, "esquema", "versión %d, %d migraciones pendientes (agent migrate up o al arrancar)", current, pending)
+	} else {
+		d.report(checkOK, "esquema", "versión %d", current)
+	}
+	return true
+}
+
+// checkJSONFiles comprueba que los archivos del almacenamiento JSON sean
+// legibles sin abrir el almacenamiento, que solo admite un proceso a la vez
+func (d *doctor) checkJSONFiles(dir string) bool {
+	for _, name := range []string{"interactions.json", "patterns.json", "stats.json"} {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			d.report(checkFail, "almacenamiento", "%v", err)
+			return false
+		}
+		if !json.Valid(data) {
+			d.report(checkFail, "almacenamiento", "%s no es JSON válido", path)
+			return false
+		}
+	}
+	return true
+}
+
+// checkLogs comprueba que se pueda escribir el archivo de log, si lo hay
+func (d *doctor) checkLogs(config *Config) {
+	if config.Logging.File == "" {
+		d.report(checkOK, "logs", "solo consola")
+		return
+	}
+	dir := filepath.Dir(config.Logging.File)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		d.report(checkFail, "logs", "%v", err)
+		return
+	}
+	if err := checkWritable(dir); err != nil {
+		d.report(checkFail, "logs", "%s: %v", config.Logging.File, err)
+		return
+	}
+	d.report(checkOK, "logs", "%s", config.Logging.File)
+}
+
+// existingDir devuelve dir o, si aún no existe, su ancestro más cercano que
+// exista, que es donde se crearía
+func existingDir(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// checkWritable crea y borra un archivo temporal en dir
+func checkWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return fmt.Errorf("no se puede escribir en %s: %w", dir, err)
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// runKeygen implementa "agent keygen": imprime una clave nueva para el cifrado en reposo
+func runKeygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key, err := storage.GenerateKey()
+	if err != nil {
+		return err
+	}
+	fmt.Println(key)
+	return nil
+}
+
+// runReencrypt implementa "agent reencrypt": recifra datos y copias con la clave actual.
+// Para rotar la clave se añade la nueva la primera (en la variable o en el archivo de claves),
+// se ejecuta este comando y después se retira la antigua.
+func runReencrypt(args []string) error {
+	fs := flag.NewFlagSet("reencrypt", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	storageConfig := config.storageConfig()
+	if !storageConfig.Encryption.Enabled {
+		return fmt.Errorf("el cifrado no está habilitado en %s (storage.encryption.enabled)", *configPath)
+	}
+
+	store, err := storage.NewStorage(storageConfig)
+	if err != nil {
+		return err
+	}
+
+	report, err := store.Reencrypt()
+	if closeErr := store.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Datos recifrados con la clave %s\n", report.KeyID)
+	fmt.Printf("  Interacciones: %d\n", report.Interactions)
+	fmt.Printf("  Patrones: %d\n", report.Patterns)
+	fmt.Printf("  Conversaciones: %d\n", report.Conversations)
+	fmt.Printf("  Copias de seguridad: %d\n", report.Backups)
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/akosej/agent/internal/eval"
+	"github.com/akosej/agent/internal/nlp"
+)
+
+// runEval implementa "agent eval": pasa un dataset de frases con su intención
+// esperada por uno o varios modelos y muestra exactitud, precisión y recall por
+// intención, matriz de confusión, latencias y, con referencias, la calidad de
+// las respuestas. Con varios modelos, o con -compare, los compara lado a lado.
+func runEval(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	models := fs.String("models", "", "modelos a evaluar separados por comas (por defecto nlp.model)")
+	responses := fs.Bool("responses", true, "generar y puntuar respuestas en los casos con reference o keywords")
+	semantic := fs.Bool("semantic", false, "puntuar la similitud semántica con la referencia (embeddings de Ollama)")
+	judgeModel := fs.String("judge", "", "modelo que puntúa las respuestas de 1 a 5 como juez (vacío no lo usa)")
+	outPath := fs.String("out", "", "guarda los informes en JSON para compararlos después")
+	compare := fs.String("compare", "", "compara informes JSON guardados con -out, separados por comas, sin evaluar")
+	verbose := fs.Bool("v", false, "muestra cada caso al terminarlo")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *compare != "" {
+		reports, err := loadReports(strings.Split(*compare, ","))
+		if err != nil {
+			return err
+		}
+		eval.WriteComparison(os.Stdout, reports)
+		return nil
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("uso: agent eval [opciones] <dataset.yaml|dataset.jsonl>")
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	cases, err := eval.LoadDataset(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	names := []string{config.NLP.Model}
+	if *models != "" {
+		names = nil
+		for _, name := range strings.Split(*models, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	evalConfig := eval.Config{Responses: *responses}
+	if *judgeModel != "" {
+		judgeConfig := config.nlpConfig()
+		judgeConfig.Model = *judgeModel
+		evalConfig.Judge = nlp.NewProcessor(config.NLP.OllamaURL, judgeConfig)
+	}
+	if *verbose {
+		evalConfig.OnCase = printEvalCase
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var reports []*eval.Report
+	for _, name := range names {
+		modelConfig := config.nlpConfig()
+		modelConfig.Model = name
+		processor := nlp.NewProcessor(config.NLP.OllamaURL, modelConfig)
+
+		evalConfig.Model = name
+		evalConfig.Embedder = nil
+		if *semantic {
+			evalConfig.Embedder = processor
+		}
+
+		fmt.Printf("Evaluando %s con %d casos de %s\n", name, len(cases), fs.Arg(0))
+		report, err := eval.Run(ctx, processor, cases, evalConfig)
+		if report != nil && len(report.Results) > 0 {
+			report.Dataset = fs.Arg(0)
+			fmt.Println()
+			eval.WriteText(os.Stdout, report)
+			fmt.Println()
+			reports = append(reports, report)
+		}
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				fmt.Println("Evaluación interrumpida")
+				break
+			}
+			return err
+		}
+	}
+
+	if len(reports) > 1 {
+		fmt.Println("Comparación")
+		eval.WriteComparison(os.Stdout, reports)
+	}
+	if *outPath != "" && len(reports) > 0 {
+		data, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error codificando informes: %w", err)
+		}
+		if err := os.WriteFile(*outPath, data, 0644); err != nil {
+			return fmt.Errorf("error guardando informes: %w", err)
+		}
+		fmt.Printf("\nInformes guardados en %s\n", *outPath)
+	}
+	return nil
+}
+
+// printEvalCase muestra un caso terminado
+func printEvalCase(result *eval.CaseResult) {
+	symbol := "✓"
+	if (result.ExpectedIntent != "" && !result.Correct) || len(result.Errors) > 0 {
+		symbol = "✗"
+	}
+	fmt.Printf("%s %-16s %-14s %6.0fms  %s\n", symbol, result.ID, result.Intent, result.IntentMs, result.Text)
+}
+
+// loadReports lee los informes guardados con -out; cada archivo tiene una lista
+func loadReports(paths []string) ([]*eval.Report, error) {
+	var reports []*eval.Report
+	for _, path := range paths {
+		data, err := os.ReadFile(strings.TrimSpace(path))
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo informe: %w", err)
+		}
+		var saved []*eval.Report
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return nil, fmt.Errorf("error parseando informe %s: %w", path, err)
+		}
+		reports = append(reports, saved...)
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("no hay informes que comparar")
+	}
+	return reports, nil
+}
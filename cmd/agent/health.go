@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akosej/agent/internal/health"
+	"github.com/akosej/agent/internal/nlp"
+)
+
+// defaultMinFreeMB es el espacio libre por debajo del cual se avisa
+const defaultMinFreeMB = 500
+
+// newHealth crea las comprobaciones de las dependencias configuradas. Ollama,
+// el almacenamiento y el disco de datos lleno son críticos; la voz, el disco de
+// logs y el aviso de poco espacio solo degradan el estado.
+func (a *app) newHealth() *health.Health {
+	config := a.config
+	checks := []health.Check{
+		health.Ollama(a.processor, config.NLP.Model),
+		health.Storage(a.store),
+	}
+
+	switch config.Speech.Provider {
+	case "whisper-cpp":
+		checks = append(checks,
+			health.Executable("whisper", config.Speech.WhisperPath, false),
+			health.File("whisper_model", config.Speech.ModelPath, false))
+	case "whisper-api":
+		apiURL := config.Speech.APIURL
+		if apiURL == "" {
+			apiURL = "http://localhost:8000"
+		}
+		checks = append(checks, health.HTTP("whisper_api", strings.TrimSuffix(apiURL, "/")+"/health", false))
+	}
+
+	minFree := config.Health.MinFreeMB
+	if minFree <= 0 {
+		minFree = defaultMinFreeMB
+	}
+	checks = append(checks, health.Disk("disk_data", filepath.Dir(config.Storage.Path), uint64(minFree)<<20, true))
+	if config.Logging.File != "" {
+		checks = append(checks, health.Disk("disk_logs", filepath.Dir(config.Logging.File), uint64(minFree)<<20, false))
+	}
+
+	return health.New(health.Config{
+		Timeout: time.Duration(config.Health.Timeout) * time.Second,
+		MaxAge:  time.Duration(config.Health.CacheTime) * time.Second,
+	}, checks...)
+}
+
+// pullMissingModel descarga con Ollama el modelo configurado si no está
+// instalado. report recibe el progreso: un mensaje por paso y cada 10 % de
+// cada capa. Devuelve false si el modelo ya estaba.
+func (a *app) pullMissingModel(ctx context.Context, report func(message string)) (bool, error) {
+	model := a.config.NLP.Model
+	models, err := a.processor.ListModels(ctx)
+	if err != nil {
+		return false, err
+	}
+	if health.HasModel(models, model) {
+		return false, nil
+	}
+
+	report(fmt.Sprintf("descargando el modelo %s", model))
+	lastStatus, lastStep := "", -1
+	err = a.processor.PullModel(ctx, model, func(progress nlp.PullProgress) {
+		if progress.Total <= 0 {
+			if progress.Status != lastStatus {
+				report(progress.Status)
+			}
+			lastStatus, lastStep = progress.Status, -1
+			return
+		}
+		step := int(progress.Completed * 10 / progress.Total)
+		if progress.Status == lastStatus && step == lastStep {
+			return
+		}
+		lastStatus, lastStep = progress.Status, step
+		report(fmt.Sprintf("%s: %d%% (%s / %s)", progress.Status, step*10,
+			formatBytes(progress.Completed), formatBytes(progress.Total)))
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
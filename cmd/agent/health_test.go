@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/testing/fakes"
+)
+
+func TestPullMissingModel(t *testing.T) {
+	ollama := fakes.NewOllama()
+	defer ollama.Close()
+
+	newTestApp := func(model string) *app {
+		config := &Config{}
+		config.NLP.Model = model
+		return &app{config: config, processor: nlp.NewProcessor(ollama.URL, nlp.Config{Model: model})}
+	}
+	ctx := context.Background()
+
+	// Con el modelo instalado no se descarga nada
+	var messages []string
+	report := func(message string) { messages = append(messages, message) }
+	pulled, err := newTestApp(fakes.DefaultModel).pullMissingModel(ctx, report)
+	if err != nil || pulled || len(messages) != 0 || ollama.Count("/api/pull") != 0 {
+		t.Fatalf("modelo instalado: pulled=%v err=%v mensajes=%q", pulled, err, messages)
+	}
+
+	// El progreso llega por pasos: los que no tienen tamaño una vez y las capas en tramos del 10 %
+	a := newTestApp("mistral")
+	pulled, err = a.pullMissingModel(ctx, report)
+	if err != nil || !pulled {
+		t.Fatalf("pullMissingModel: pulled=%v err=%v", pulled, err)
+	}
+	want := []string{
+		"descargando el modelo mistral",
+		"pulling manifest",
+		"pulling mistral: 50% (0 MB / 0 MB)",
+		"pulling mistral: 100% (0 MB / 0 MB)",
+		"success",
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("progreso = %q, want %q", messages, want)
+	}
+
+	// Una vez descargado ya no se repite
+	messages = nil
+	if pulled, err := a.pullMissingModel(ctx, report); err != nil || pulled || ollama.Count("/api/pull") != 1 {
+		t.Errorf("segunda llamada: pulled=%v err=%v, %d descargas", pulled, err, ollama.Count("/api/pull"))
+	}
+
+	ollama.Fail("/api/pull", fakes.Fault{Status: 500, Body: "sin espacio", Times: 1})
+	if _, err := newTestApp("phi3").pullMissingModel(ctx, report); err == nil {
+		t.Error("un fallo de la descarga no devolvió error")
+	}
+}
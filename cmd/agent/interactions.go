@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// runInteractions implementa "agent interactions search|tail|delete"
+func runInteractions(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("uso: agent interactions search|tail|delete [opciones]")
+	}
+
+	switch args[0] {
+	case "search":
+		return runInteractionsSearch(args[1:])
+	case "tail":
+		return runInteractionsTail(args[1:])
+	case "delete":
+		return runInteractionsDelete(args[1:])
+	default:
+		return fmt.Errorf("subcomando de interactions desconocido: %s (usa search, tail o delete)", args[0])
+	}
+}
+
+// interactionPrinter escribe interacciones como tabla o como JSON por líneas
+type interactionPrinter struct {
+	json   bool
+	header bool
+}
+
+// print escribe un lote de interacciones; la cabecera solo sale una vez
+func (p *interactionPrinter) print(interactions []*storage.Interaction, snippets []string) error {
+	if p.json {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, interaction := range interactions {
+			if err := encoder.Encode(interaction); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Anchos fijos para que los lotes de tail -f queden alineados
+	const row = "%-30s  %-19s  %-12s  %-14s  %-10s  %s\n"
+	if !p.header {
+		p.header = true
+		fmt.Printf(row, "ID", "FECHA", "USUARIO", "INTENCIÓN", "VALORACIÓN", "ENTRADA")
+	}
+	for i, interaction := range interactions {
+		user, rating := interaction.UserID, "-"
+		if user == "" {
+			user = "-"
+		}
+		if interaction.Feedback != nil {
+			rating = fmt.Sprintf("%d", interaction.Feedback.Rating)
+		}
+		text := interaction.UserInput
+		if snippets != nil && snippets[i] != "" {
+			text = snippets[i]
+		}
+		fmt.Printf(row, interaction.ID, interaction.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			truncate(user, 12), truncate(interaction.Intent, 14), rating, truncate(text, 60))
+	}
+	return nil
+}
+
+// runInteractionsSearch busca texto en entradas y respuestas con los filtros dados
+func runInteractionsSearch(args []string) error {
+	fs := flag.NewFlagSet("interactions search", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	intent := fs.String("intent", "", "solo esta intención")
+	user := fs.String("user", "", "solo este usuario")
+	since := fs.String("since", "", "desde esta fecha (AAAA-MM-DD o RFC 3339)")
+	until := fs.String("until", "", "hasta esta fecha, excluida")
+	minRating := fs.Int("min-rating", 0, "valoración mínima (1-5)")
+	maxRating := fs.Int("max-rating", 0, "valoración máxima (1-5)")
+	limit := fs.Int("limit", 20, "número máximo de resultados")
+	asJSON := fs.Bool("json", false, "salida en JSON, una interacción por línea")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filters := storage.SearchFilters{
+		Intent:    *intent,
+		UserID:    *user,
+		MinRating: *minRating,
+		MaxRating: *maxRating,
+		Limit:     *limit,
+	}
+	var err error
+	if filters.Since, err = parseDate(*since); err != nil {
+		return err
+	}
+	if filters.Until, err = parseDate(*until); err != nil {
+		return err
+	}
+
+	_, store, err := openStore(*configPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	results, err := store.SearchInteractions(strings.Join(fs.Args(), " "), filters)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 && !*asJSON {
+		fmt.Println("Sin resultados")
+		return nil
+	}
+
+	interactions := make([]*storage.Interaction, len(results))
+	snippets := make([]string, len(results))
+	for i, result := range results {
+		interactions[i] = result.Interaction
+		snippets[i] = result.Snippet
+	}
+	printer := &interactionPrinter{json: *asJSON}
+	return printer.print(interactions, snippets)
+}
+
+// runInteractionsTail muestra las últimas interacciones y, con -f, las nuevas
+// a medida que se guardan, hasta Ctrl-C
+func runInteractionsTail(args []string) error {
+	fs := flag.NewFlagSet("interactions tail", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	n := fs.Int("n", 10, "número de interacciones")
+	follow := fs.Bool("f", false, "sigue mostrando las nuevas")
+	interval := fs.Duration("interval", 2*time.Second, "frecuencia de consulta con -f")
+	asJSON := fs.Bool("json", false, "salida en JSON, una interacción por línea")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *n <= 0 {
+		return fmt.Errorf("-n debe ser mayor que 0")
+	}
+
+	config, store, err := openStore(*configPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	// El almacenamiento JSON se carga en memoria al abrirlo y no ve las
+	// escrituras de otros procesos
+	if *follow && config.Storage.Type == "json" {
+		return fmt.Errorf("-f requiere almacenamiento SQLite")
+	}
+
+	printer := &interactionPrinter{json: *asJSON}
+	seen := make(map[string]bool)
+
+	// show imprime de la más antigua a la más nueva las que aún no se mostraron
+	show := func(limit int) error {
+		recent, err := store.GetRecentInteractions(limit)
+		if err != nil {
+			return err
+		}
+		var fresh []*storage.Interaction
+		for i := len(recent) - 1; i >= 0; i-- {
+			if !seen[recent[i].ID] {
+				seen[recent[i].ID] = true
+				fresh = append(fresh, recent[i])
+			}
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+		return printer.print(fresh, nil)
+	}
+
+	if err := show(*n); err != nil {
+		return err
+	}
+	if !*follow {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// El límite holgado recoge varias nuevas entre dos consultas
+			if err := show(*n + 100); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// runInteractionsDelete elimina interacciones por ID o todos los datos de un usuario
+func runInteractionsDelete(args []string) error {
+	fs := flag.NewFlagSet("interactions delete", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	user := fs.String("user", "", "elimina todos los datos del usuario, también de las copias de seguridad")
+	yes := fs.Bool("yes", false, "no pedir confirmación")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*user == "") == (fs.NArg() == 0) {
+		return fmt.Errorf("uso: agent interactions delete [-config ruta] [-yes] id... | -user id")
+	}
+
+	_, store, err := openStore(*configPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if *user != "" {
+		question := fmt.Sprintf("Se eliminarán todas las interacciones, conversaciones y patrones de %s, también de las copias. ¿Continuar?", *user)
+		if err := confirm(question, *yes); err != nil {
+			return err
+		}
+		report, err := store.DeleteUserData(*user)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ Datos de %s eliminados\n", *user)
+		fmt.Printf("  Interacciones: %d (%d con feedback)\n", report.Interactions, report.Feedback)
+		fmt.Printf("  Conversaciones: %d (%d turnos)\n", report.Conversations, report.Turns)
+		fmt.Printf("  Patrones: %d\n", report.Patterns)
+		fmt.Printf("  Valores en el vault de redacción: %d\n", report.VaultEntries)
+		fmt.Printf("  Copias depuradas: %d\n", len(report.Backups))
+		for _, warning := range report.Warnings {
+			fmt.Printf("  Aviso: %s\n", warning)
+		}
+		return nil
+	}
+
+	if err := confirm(fmt.Sprintf("Se eliminarán %d interacciones. ¿Continuar?", fs.NArg()), *yes); err != nil {
+		return err
+	}
+	missing := 0
+	for _, id := range fs.Args() {
+		err := store.DeleteInteraction(id)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			missing++
+			fmt.Printf("✗ %s no existe\n", id)
+		case err != nil:
+			return fmt.Errorf("error eliminando %s: %w", id, err)
+		default:
+			fmt.Printf("✓ %s eliminada\n", id)
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d de %d interacciones no existen", missing, fs.NArg())
+	}
+	return nil
+}
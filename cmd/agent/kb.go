@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akosej/agent/internal/learning"
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// contextUserID es la clave del contexto con la que el archivo de conocimiento
+// conserva el usuario de cada interacción, que learning.Interaction no tiene
+const contextUserID = "user_id"
+
+// runKB implementa "agent kb export|import|prune|list"
+func runKB(args []string) error {
+	usage := fmt.Errorf("uso: agent kb export|import|prune|list [opciones]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case "export":
+		return runKBExport(args[1:])
+	case "import":
+		return runKBImport(args[1:])
+	case "prune":
+		return runKBPrune(args[1:])
+	case "list":
+		return runKBList(args[1:])
+	default:
+		return fmt.Errorf("subcomando de kb desconocido: %s (usa export, import, prune o list)", args[0])
+	}
+}
+
+// engineFromStore carga en un motor de aprendizaje las interacciones, el
+// feedback y los patrones del almacenamiento, con las estadísticas recalculadas
+func engineFromStore(config *Config, store storage.Store) (*learning.Engine, error) {
+	stored, err := store.GetRecentInteractions(0)
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := patternsFromStore(store)
+	if err != nil {
+		return nil, err
+	}
+
+	// El almacenamiento las devuelve de la más nueva a la más antigua
+	kb := &learning.KnowledgeBase{
+		Patterns:     patterns,
+		Interactions: make([]*learning.Interaction, 0, len(stored)),
+		Stats:        &learning.Stats{TotalInteractions: len(stored), LastUpdated: time.Now()},
+	}
+	ratingSum, rated := 0, 0
+	for i := len(stored) - 1; i >= 0; i-- {
+		interaction := toLearningInteraction(stored[i])
+		kb.Interactions = append(kb.Interactions, interaction)
+		if feedback := interaction.Feedback; feedback != nil {
+			ratingSum += feedback.Rating
+			rated++
+			if feedback.Rating >= 4 {
+				kb.Stats.PositiveFeedback++
+			} else if feedback.Rating <= 2 {
+				kb.Stats.NegativeFeedback++
+			}
+		}
+	}
+	if rated > 0 {
+		kb.Stats.AverageRating = float64(ratingSum) / float64(rated)
+	}
+
+	data, err := json.Marshal(kb)
+	if err != nil {
+		return nil, fmt.Errorf("error codificando base de conocimiento: %w", err)
+	}
+	engine := learning.NewEngine(config.learningConfig())
+	if err := engine.Import(data); err != nil {
+		return nil, fmt.Errorf("error cargando base de conocimiento: %w", err)
+	}
+	return engine, nil
+}
+
+// patternsFromStore carga los patrones guardados por clave en el formato del motor
+func patternsFromStore(store storage.Store) (map[string]*learning.Pattern, error) {
+	stored, err := store.GetPatterns()
+	if err != nil {
+		return nil, err
+	}
+	patterns := make(map[string]*learning.Pattern, len(stored))
+	for _, pattern := range stored {
+		patterns[pattern.Key] = &learning.Pattern{
+			Pattern:    pattern.Pattern,
+			Response:   pattern.Response,
+			Frequency:  pattern.Frequency,
+			Confidence: pattern.Confidence,
+			LastUsed:   pattern.LastUsed,
+		}
+	}
+	return patterns, nil
+}
+
+// toLearningInteraction convierte una interacción almacenada; el usuario pasa al contexto
+func toLearningInteraction(stored *storage.Interaction) *learning.Interaction {
+	interaction := &learning.Interaction{
+		ID:        stored.ID,
+		Timestamp: stored.Timestamp,
+		UserInput: stored.UserInput,
+		Response:  stored.Response,
+		Intent:    stored.Intent,
+		Context:   make(map[string]interface{}, len(stored.Context)+1),
+		Latency:   stored.Latency,
+	}
+	for key, value := range stored.Context {
+		interaction.Context[key] = value
+	}
+	if stored.UserID != "" {
+		interaction.Context[contextUserID] = stored.UserID
+	}
+	if stored.Feedback != nil {
+		interaction.Feedback = &learning.Feedback{
+			Rating:    stored.Feedback.Rating,
+			Comment:   stored.Feedback.Comment,
+			Timestamp: stored.Feedback.Timestamp,
+		}
+	}
+	return interaction
+}
+
+// toStoredInteraction es la conversión inversa de toLearningInteraction
+func toStoredInteraction(interaction *learning.Interaction) *storage.Interaction {
+	stored := &storage.Interaction{
+		ID:        interaction.ID,
+		Timestamp: interaction.Timestamp,
+		UserInput: interaction.UserInput,
+		Response:  interaction.Response,
+		Intent:    interaction.Intent,
+		Latency:   interaction.Latency,
+	}
+	if len(interaction.Context) > 0 {
+		stored.Context = make(map[string]interface{}, len(interaction.Context))
+		for key, value := range interaction.Context {
+			if key == contextUserID {
+				stored.UserID, _ = value.(string)
+				continue
+			}
+			stored.Context[key] = value
+		}
+	}
+	if interaction.Feedback != nil {
+		stored.Feedback = &storage.Feedback{
+			Rating:    interaction.Feedback.Rating,
+			Comment:   interaction.Feedback.Comment,
+			Timestamp: interaction.Feedback.Timestamp,
+		}
+	}
+	return stored
+}
+
+// runKBExport escribe la base de conocimiento del almacenamiento en el formato de Engine.Export
+func runKBExport(args []string) error {
+	fs := flag.NewFlagSet("kb export", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	output := fs.String("o", "", "archivo de salida (por defecto la salida estándar)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, store, err := openStore(*configPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	engine, err := engineFromStore(config, store)
+	if err != nil {
+		return err
+	}
+	data, err := engine.Export()
+	if err != nil {
+		return fmt.Errorf("error exportando base de conocimiento: %w", err)
+	}
+
+	if *output == "" {
+		_, err := os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0600); err != nil {
+		return fmt.Errorf("error escribiendo %s: %w", *output, err)
+	}
+	stats := engine.GetStats()
+	fmt.Fprintf(os.Stderr, "✓ Exportadas %d interacciones y %d patrones a %s\n",
+		stats.TotalInteractions, len(engine.GetPatterns()), *output)
+	return nil
+}
+
+// runKBImport guarda en el almacenamiento un archivo de Engine.Export (de
+// "agent kb export" o de /export). Las interacciones y patrones con el mismo
+// ID o clave se reemplazan, de modo que importar dos veces no duplica nada.
+func runKBImport(args []string) error {
+	fs := flag.NewFlagSet("kb import", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	dryRun := fs.Bool("dry-run", false, "valida el archivo sin guardar nada")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("uso: agent kb import [-config ruta] [-dry-run] archivo")
+	}
+
+	var data []byte
+	var err error
+	if fs.Arg(0) == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		return fmt.Errorf("error leyendo %s: %w", fs.Arg(0), err)
+	}
+
+	interactions, patterns, err := parseKnowledge(data)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		fmt.Printf("Archivo válido: %d interacciones y %d patrones\n", len(interactions), len(patterns))
+		return nil
+	}
+
+	_, store, err := openStore(*configPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := saveKnowledge(store, interactions, patterns); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Importadas %d interacciones y %d patrones\n", len(interactions), len(patterns))
+	return nil
+}
+
+// parseKnowledge valida un archivo de Engine.Export y devuelve sus
+// interacciones y patrones
+func parseKnowledge(data []byte) ([]*learning.Interaction, map[string]learning.Pattern, error) {
+	engine := learning.NewEngine(learning.Config{})
+	if err := engine.Import(data); err != nil {
+		return nil, nil, fmt.Errorf("error importando base de conocimiento: %w", err)
+	}
+	interactions := engine.GetRecentInteractions(math.MaxInt)
+	for _, interaction := range interactions {
+		if interaction.ID == "" {
+			return nil, nil, fmt.Errorf("el archivo contiene interacciones sin id")
+		}
+	}
+	return interactions, engine.GetPatterns(), nil
+}
+
+// saveKnowledge guarda interacciones y patrones importados, reemplazando los
+// que tengan el mismo ID o clave
+func saveKnowledge(store storage.Store, interactions []*learning.Interaction, patterns map[string]learning.Pattern) error {
+	for _, interaction := range interactions {
+		if err := store.SaveInteraction(toStoredInteraction(interaction)); err != nil {
+			return fmt.Errorf("error guardando interacción %s: %w", interaction.ID, err)
+		}
+	}
+	for key, pattern := range patterns {
+		err := store.SavePattern(&storage.Pattern{
+			Key:        key,
+			Pattern:    pattern.Pattern,
+			Response:   pattern.Response,
+			Frequency:  pattern.Frequency,
+			Confidence: pattern.Confidence,
+			LastUsed:   pattern.LastUsed,
+		})
+		if err != nil {
+			return fmt.Errorf("error guardando patrón %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// runKBPrune elimina los patrones aprendidos que cumplen alguno de los criterios
+func runKBPrune(args []string) error {
+	fs := flag.NewFlagSet("kb prune", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	olderThan := fs.Int("older-than", 0, "patrones sin usar en más de N días")
+	minFrequency := fs.Int("min-frequency", 0, "patrones usados menos de N veces")
+	minConfidence := fs.Float64("min-confidence", 0, "patrones con confianza menor que X (0-1)")
+	dryRun := fs.Bool("dry-run", false, "muestra qué se eliminaría sin eliminar nada")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *olderThan <= 0 && *minFrequency <= 0 && *minConfidence <= 0 {
+		return fmt.Errorf("uso: agent kb prune [-config ruta] [-older-than días] [-min-frequency n] [-min-confidence x] [-dry-run]; indica al menos un criterio")
+	}
+
+	_, store, err := openStore(*configPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	patterns, err := store.GetPatterns()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -*olderThan)
+	removed := 0
+	for _, pattern := range patterns {
+		stale := *olderThan > 0 && pattern.LastUsed.Before(cutoff)
+		rare := *minFrequency > 0 && pattern.Frequency < *minFrequency
+		weak := *minConfidence > 0 && pattern.Confidence < *minConfidence
+		if !stale && !rare && !weak {
+			continue
+		}
+
+		if !*dryRun {
+			if err := store.DeletePattern(pattern.Key); err != nil {
+				return fmt.Errorf("error eliminando patrón %s: %w", pattern.Key, err)
+			}
+		}
+		removed++
+		fmt.Printf("- %s (frecuencia %d, confianza %.2f, último uso %s)\n",
+			pattern.Key, pattern.Frequency, pattern.Confidence, pattern.LastUsed.Local().Format("2006-01-02"))
+	}
+
+	switch {
+	case *dryRun:
+		fmt.Printf("Se eliminarían %d de %d patrones\n", removed, len(patterns))
+	default:
+		fmt.Printf("✓ Eliminados %d de %d patrones\n", removed, len(patterns))
+	}
+	return nil
+}
+
+// runKBList muestra los patrones aprendidos
+func runKBList(args []string) error {
+	fs := flag.NewFlagSet("kb list", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	sortBy := fs.String("sort", "key", "orden: key, frequency, confidence o last-used")
+	asJSON := fs.Bool("json", false, "salida en JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	less := map[string]func(a, b *storage.Pattern) bool{
+		"key":        func(a, b *storage.Pattern) bool { return a.Key < b.Key },
+		"frequency":  func(a, b *storage.Pattern) bool { return a.Frequency > b.Frequency },
+		"confidence": func(a, b *storage.Pattern) bool { return a.Confidence > b.Confidence },
+		"last-used":  func(a, b *storage.Pattern) bool { return a.LastUsed.After(b.LastUsed) },
+	}[*sortBy]
+	if less == nil {
+		return fmt.Errorf("orden desconocido: %s (key, frequency, confidence o last-used)", *sortBy)
+	}
+
+	_, store, err := openStore(*configPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	patterns, err := store.GetPatterns()
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(patterns, func(i, j int) bool { return less(patterns[i], patterns[j]) })
+
+	if *asJSON {
+		return printJSON(patterns)
+	}
+	if len(patterns) == 0 {
+		fmt.Println("No hay patrones guardados")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLAVE\tUSUARIO\tFRECUENCIA\tCONFIANZA\tÚLTIMO USO\tPATRÓN")
+	for _, pattern := range patterns {
+		user := pattern.UserID
+		if user == "" {
+			user = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.2f\t%s\t%s\n", pattern.Key, user, pattern.Frequency, pattern.Confidence,
+			pattern.LastUsed.Local().Format("2006-01-02 15:04"), truncate(pattern.Pattern, 50))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command es un subcomando de la línea de comandos
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"chat", "chat [-config ruta] [-user id]        Conversa con el agente en la terminal", runChat},
+	{"serve", "serve [-config ruta] [-addr dir]      Expone el agente por HTTP (API /v1)", runServe},
+	{"transcribe", "transcribe [-format f] <dir|patrón>   Transcribe audios por lotes, con reanudación", runTranscribe},
+	{"eval", "eval [-models a,b] [-judge m] dataset Evalúa intenciones y respuestas de uno o varios modelos", runEval},
+	{"kb", "kb export|import|prune|list           Gestiona la base de conocimiento", runKB},
+	{"interactions", "interactions search|tail|delete       Consulta o elimina interacciones", runInteractions},
+	{"stats", "stats [-json|-analytics] [-format f]  Resume el almacenamiento o exporta analíticas", runStats},
+	{"backup", "backup [-config ruta]                 Crea una copia de seguridad verificada", runBackup},
+	{"restore", "restore [-config ruta] copia          Restaura todos los datos desde una copia", runRestore},
+	{"doctor", "doctor [-config ruta]                 Comprueba Ollama, modelos, voz y almacenamiento", runDoctor},
+	{"migrate", "migrate [-config ruta] status|up      Consulta o aplica migraciones del esquema", runMigrate},
+	{"keygen", "keygen                                Genera una clave de cifrado en base64", runKeygen},
+	{"reencrypt", "reencrypt [-config ruta]              Recifra datos y copias con la clave actual", runReencrypt},
+	{"detokenize", "detokenize [-config ruta] [archivo]   Restaura los datos personales tokenizados", runDetokenize},
+}
+
+func main() {
+	// Sin comando se abre la conversación interactiva
+	name, args := "chat", []string{}
+	if len(os.Args) > 1 {
+		name, args = os.Args[1], os.Args[2:]
+	}
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := cmd.run(args); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Comando desconocido: %s\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
+
+// printUsage muestra la ayuda de los subcomandos
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Uso: agent [comando] [opciones]   (sin comando: chat)")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Comandos:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", cmd.usage)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// runMigrate implementa "agent migrate status|up"
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("uso: agent migrate [-config ruta] status|up")
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "status":
+		// status no debe crear ni modificar la base de datos
+		migrator, err := storage.OpenMigratorReadOnly(config.storageConfig())
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("La base de datos %s aún no existe: se creará al arrancar el agente o con \"agent migrate up\"\n", config.Storage.Path)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer migrator.Close()
+		return printMigrationStatus(migrator)
+	case "up":
+		migrator, err := storage.OpenMigrator(config.storageConfig())
+		if err != nil {
+			return err
+		}
+		defer migrator.Close()
+
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Printf("✓ Migración %d aplicada: %s\n", migration.Version, migration.Description)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("El esquema ya está actualizado")
+		}
+		return nil
+	default:
+		return fmt.Errorf("subcomando de migrate desconocido: %s (usa status o up)", fs.Arg(0))
+	}
+}
+
+// printMigrationStatus muestra la tabla de migraciones y su estado
+func printMigrationStatus(migrator *storage.Migrator) error {
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	current, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Versión del esquema: %d (binario: %d)\n\n", current, storage.LatestSchemaVersion())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSIÓN\tESTADO\tAPLICADA\tDESCRIPCIÓN")
+	for _, s := range status {
+		state, appliedAt := "pendiente", "-"
+		if s.Applied {
+			state = "aplicada"
+			appliedAt = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, state, appliedAt, s.Description)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if current > storage.LatestSchemaVersion() {
+		return storage.ErrSchemaTooNew
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/akosej/agent/pkg/redact"
+)
+
+// newRedactor crea el redactor de la sección redaction (nil si está deshabilitada)
+func (c *Config) newRedactor() (*redact.Redactor, error) {
+	if !c.Redaction.Enabled {
+		return nil, nil
+	}
+
+	config := redact.Config{
+		Detectors: c.Redaction.Detectors,
+		Tokenize: redact.TokenizeConfig{
+			Enabled:   c.Redaction.Tokenize.Enabled,
+			KeyEnv:    c.Redaction.Tokenize.KeyEnv,
+			VaultPath: c.Redaction.Tokenize.Vault,
+		},
+	}
+	for _, custom := range c.Redaction.Custom {
+		config.Custom = append(config.Custom, redact.CustomPattern{Name: custom.Name, Pattern: custom.Pattern})
+	}
+
+	redactor, err := redact.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("error configurando redacción: %w", err)
+	}
+	return redactor, nil
+}
+
+// runDetokenize implementa "agent detokenize": restaura los valores originales
+// de los tokens de un archivo (o de la entrada estándar) para una exportación
+// autorizada. Requiere la clave de tokenización y el vault.
+func runDetokenize(args []string) error {
+	fs := flag.NewFlagSet("detokenize", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if !config.Redaction.Enabled || !config.Redaction.Tokenize.Enabled {
+		return fmt.Errorf("la tokenización no está habilitada en %s (redaction.tokenize.enabled)", *configPath)
+	}
+
+	redactor, err := config.newRedactor()
+	if err != nil {
+		return err
+	}
+	defer redactor.Close()
+
+	var input io.Reader = os.Stdin
+	if fs.NArg() > 0 {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("error abriendo %s: %w", fs.Arg(0), err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	output := bufio.NewWriter(os.Stdout)
+	defer output.Flush()
+
+	reader := bufio.NewReader(input)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if line != "" {
+			restored, err := redactor.Detokenize(line)
+			if err != nil {
+				return err
+			}
+			if _, err := output.WriteString(restored); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("error leyendo entrada: %w", readErr)
+		}
+	}
+}
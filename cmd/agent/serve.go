@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/akosej/agent/internal/server"
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// runServe implementa "agent serve": expone el agente por HTTP con las copias
+// de seguridad y la retención en segundo plano, hasta recibir SIGINT o SIGTERM
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	addr := fs.String("addr", "", "dirección de escucha (sustituye a server.addr)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *addr != "" {
+		config.Server.Addr = *addr
+	}
+
+	a, err := newApp(config, nil)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	a.log.LogStartup(config.Agent.Version)
+	defer a.log.LogShutdown()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	checks := a.newHealth()
+	var jobs sync.WaitGroup
+	if config.NLP.AutoPull {
+		// En segundo plano: mientras se descarga, /readyz responde 503
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			nlpLog := a.log.Component("nlp")
+			pulled, err := a.pullMissingModel(ctx, func(message string) {
+				nlpLog.InfoContext(ctx, "descarga del modelo", "model", config.NLP.Model, "progress", message)
+			})
+			switch {
+			case err != nil:
+				nlpLog.ErrorContext(ctx, "error descargando el modelo", "model", config.NLP.Model, "error", err)
+			case pulled:
+				nlpLog.InfoContext(ctx, "modelo descargado", "model", config.NLP.Model)
+				checks.Invalidate()
+			}
+		}()
+	}
+
+	storageConfig := config.storageConfig()
+	storeLog := a.log.Component("storage")
+	jobs.Add(2)
+	go func() {
+		defer jobs.Done()
+		storage.RunBackups(ctx, a.store, storageConfig, func(path string, err error) {
+			if err != nil {
+				storeLog.ErrorContext(ctx, "error creando copia de seguridad", "error", err)
+				return
+			}
+			storeLog.InfoContext(ctx, "copia de seguridad creada", "path", path)
+		})
+	}()
+	go func() {
+		defer jobs.Done()
+		storage.RunRetention(ctx, a.store, storageConfig.Retention, func(report *storage.RetentionReport, err error) {
+			if err != nil {
+				storeLog.ErrorContext(ctx, "error aplicando retención", "error", err)
+				return
+			}
+			storeLog.DebugContext(ctx, "retención aplicada", "interactions", report.Interactions, "conversations", report.Conversations, "vault_entries", report.VaultEntries)
+		})
+	}()
+
+	serverConfig := config.serverConfig()
+	if a.metrics != nil {
+		serverConfig.Metrics = a.metrics.Handler()
+	}
+	serverConfig.Tracer = a.tracer
+	serverConfig.Health = checks
+	if serverConfig.APIToken == "" && !isLoopback(serverConfig.Addr) {
+		a.log.WarnContext(ctx, "la API no exige token y acepta conexiones de otras máquinas; configura server.api_token_env", "addr", serverConfig.Addr)
+	}
+	srv := server.New(serverConfig, a.agent, a.log)
+	err = srv.ListenAndServe(ctx)
+	stop()
+	jobs.Wait()
+	return err
+}
+
+// serverConfig convierte la sección server a server.Config
+func (c *Config) serverConfig() server.Config {
+	config := server.Config{
+		Addr:            c.Server.Addr,
+		ReadTimeout:     time.Duration(c.Server.ReadTimeout) * time.Second,
+		WriteTimeout:    time.Duration(c.Server.WriteTimeout) * time.Second,
+		ShutdownTimeout: time.Duration(c.Server.ShutdownTimeout) * time.Second,
+		MaxBodyBytes:    int64(c.Server.MaxBodyKB) << 10,
+		MaxAudioBytes:   int64(c.Server.MaxAudioMB) << 20,
+		AllowedOrigins:  c.Server.AllowedOrigins,
+	}
+	if c.Server.AdminTokenEnv != "" {
+		config.AdminToken = os.Getenv(c.Server.AdminTokenEnv)
+	}
+	if c.Server.APITokenEnv != "" {
+		config.APIToken = os.Getenv(c.Server.APITokenEnv)
+	}
+	return config
+}
+
+// isLoopback indica si addr solo acepta conexiones de la propia máquina
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,52 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/akosej/agent/pkg/logger"
+)
+
+// watchLogSignals atiende las señales del logger hasta que se llama a la
+// función devuelta:
+//   - SIGHUP reabre el archivo de log, como espera logrotate sin copytruncate.
+//     Solo se escucha si hay archivo: sin él SIGHUP debe seguir cerrando el
+//     proceso, p. ej. el chat al cerrar la terminal.
+//   - SIGUSR1 alterna el nivel global entre debug y el de configuración
+func watchLogSignals(log *logger.Logger) func() {
+	notify := []os.Signal{syscall.SIGUSR1}
+	if log.HasFile() {
+		notify = append(notify, syscall.SIGHUP)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, notify...)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				switch sig {
+				case syscall.SIGHUP:
+					if err := log.Reopen(); err != nil {
+						fmt.Fprintf(os.Stderr, "logger: error reabriendo log: %v\n", err)
+					}
+				case syscall.SIGUSR1:
+					level := log.ToggleDebug()
+					log.Info("Nivel de log global cambiado a %s (SIGUSR1)", level)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "github.com/akosej/agent/pkg/logger"
+
+// watchLogSignals no hace nada en Windows, donde no existen SIGHUP ni SIGUSR1;
+// los niveles se cambian con el endpoint de administración
+func watchLogSignals(log *logger.Logger) func() {
+	return func() {}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/internal/speech"
+)
+
+// runTranscribe implementa "agent transcribe": transcribe todos los audios de
+// un directorio o patrón con el proveedor configurado. Un manifiesto registra
+// los archivos terminados, así que repetir el comando tras interrumpirlo
+// continúa con los pendientes.
+func runTranscribe(args []string) error {
+	fs := flag.NewFlagSet("transcribe", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "ruta al archivo de configuración")
+	formats := fs.String("format", "txt", "formatos de salida separados por comas: "+strings.Join(speech.TranscriptFormats, ", "))
+	outputDir := fs.String("out", "", "directorio de salida (por defecto, junto a cada audio)")
+	workers := fs.Int("workers", 2, "transcripciones simultáneas")
+	manifestPath := fs.String("manifest", "", "manifiesto para reanudar (por defecto transcribe-manifest.json en el directorio de salida)")
+	summarize := fs.Bool("summary", false, "resume cada grabación con el modelo en <nombre>.summary.txt")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("uso: agent transcribe [opciones] <directorio|patrón>")
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	transcriber, err := config.newTranscriber()
+	if err != nil {
+		return err
+	}
+	if transcriber == nil {
+		return fmt.Errorf("transcripción deshabilitada: configura speech.provider (whisper-cpp o whisper-api)")
+	}
+
+	files, baseDir, err := speech.FindAudioFiles(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no hay audios en %s (%s)", fs.Arg(0), strings.Join(speech.AudioExtensions, ", "))
+	}
+
+	batch := speech.BatchConfig{
+		Workers:   *workers,
+		BaseDir:   baseDir,
+		OutputDir: *outputDir,
+		Manifest:  *manifestPath,
+		OnResult:  printBatchResult,
+	}
+	for _, format := range strings.Split(*formats, ",") {
+		if format = strings.TrimSpace(format); format != "" {
+			batch.Formats = append(batch.Formats, format)
+		}
+	}
+	if *summarize {
+		processor := nlp.NewProcessor(config.NLP.OllamaURL, config.nlpConfig())
+		batch.Summarize = func(ctx context.Context, transcript *speech.Transcript) (string, error) {
+			return processor.SummarizeConversation(ctx, []nlp.Message{{Role: "user", Content: transcript.Text}})
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("Transcribiendo %d archivos con %s\n", len(files), config.Speech.Provider)
+	report, err := speech.TranscribeBatch(ctx, transcriber, files, batch)
+	if report != nil {
+		fmt.Printf("\n%d transcritos, %d ya estaban, %d fallidos\n", report.Done, report.Skipped, report.Failed)
+	}
+	if errors.Is(err, context.Canceled) {
+		pending := len(files)
+		if report != nil {
+			pending -= report.Done + report.Skipped + report.Failed
+		}
+		return fmt.Errorf("interrumpido con %d archivos pendientes; repite el comando para continuar", pending)
+	}
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d archivos fallidos; repite el comando para reintentarlos", report.Failed)
+	}
+	return nil
+}
+
+// printBatchResult muestra una línea por archivo terminado
+func printBatchResult(result speech.BatchResult) {
+	switch {
+	case result.Skipped:
+		fmt.Printf("= %s (ya transcrito)\n", result.Path)
+	case result.Err != nil:
+		fmt.Printf("✗ %s: %v\n", result.Path, result.Err)
+	default:
+		fmt.Printf("✓ %s (%.1fs) → %s\n", result.Path, result.Duration.Seconds(), strings.Join(result.Outputs, ", "))
+	}
+}
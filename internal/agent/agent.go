@@ -0,0 +1,512 @@
+// Package agent orquesta el flujo completo de una interacción: detección de
+// intención, generación de la respuesta con el historial de la conversación,
+// aprendizaje y persistencia. Lo comparten el servidor HTTP y la línea de comandos.
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akosej/agent/internal/learning"
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/pkg/logger"
+	"github.com/akosej/agent/pkg/metrics"
+	"github.com/akosej/agent/pkg/storage"
+	"github.com/akosej/agent/pkg/tracing"
+)
+
+// defaultHistoryTurns es el número de turnos previos que se envían al modelo
+const defaultHistoryTurns = 10
+
+var (
+	// ErrInvalidInput indica una petición con datos inválidos
+	ErrInvalidInput = errors.New("entrada inválida")
+	// ErrNoTranscriber indica que no hay transcriptor configurado
+	ErrNoTranscriber = errors.New("transcripción no disponible: no hay proveedor de voz configurado")
+)
+
+// LanguageModel es el modelo de lenguaje que usa el agente; nlp.Processor lo implementa
+type LanguageModel interface {
+	DetectIntent(ctx context.Context, text string) (*nlp.Intent, error)
+	ProcessText(ctx context.Context, text string, conversationHistory []nlp.Message) (string, error)
+	ProcessTextStream(ctx context.Context, text string, conversationHistory []nlp.Message, onToken func(token string) error) (string, error)
+	SummarizeConversation(ctx context.Context, messages []nlp.Message) (string, error)
+}
+
+// Transcriber convierte audio en texto; speech.Transcriber lo implementa
+type Transcriber interface {
+	TranscribeStream(ctx context.Context, audioData []byte, format string) (string, error)
+}
+
+// Synthesizer convierte texto en voz; speech.Synthesizer lo implementa
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string) (audio []byte, format string, err error)
+}
+
+// Config contiene la configuración del agente
+type Config struct {
+	Model        string // Modelo en uso, solo informativo para logs y respuestas
+	HistoryTurns int    // Turnos previos enviados al modelo (por defecto 10)
+}
+
+// Dependencies agrupa los componentes que orquesta el agente.
+// Transcriber, Synthesizer, Logger, Metrics y Tracer son opcionales.
+type Dependencies struct {
+	Model       LanguageModel
+	Engine      *learning.Engine
+	Store       storage.Store
+	Transcriber Transcriber
+	Synthesizer Synthesizer
+	Logger      *logger.Logger
+	Metrics     metrics.Metrics // Intenciones y valoraciones
+	Tracer      *tracing.Tracer // Spans de cada etapa de la interacción
+}
+
+// Agent coordina NLP, aprendizaje y almacenamiento. Es seguro para uso concurrente
+// siempre que lo sean sus dependencias.
+type Agent struct {
+	mu          sync.RWMutex // Protege config.Model, que SetModel cambia en caliente
+	config      Config
+	model       LanguageModel
+	engine      *learning.Engine
+	store       storage.Store
+	transcriber Transcriber
+	synthesizer Synthesizer
+	log         *logger.Logger
+	metrics     *agentMetrics
+	tracer      *tracing.Tracer
+	stored      storedStats // Estadísticas del almacenamiento, al día con cada escritura
+	patternsMu  sync.Mutex  // Serializa savePatterns para que no se guarde una copia antigua sobre una nueva
+}
+
+// New crea un agente con sus dependencias
+func New(config Config, deps Dependencies) (*Agent, error) {
+	if deps.Model == nil || deps.Engine == nil || deps.Store == nil {
+		return nil, errors.New("el agente necesita modelo, motor de aprendizaje y almacenamiento")
+	}
+	if config.HistoryTurns <= 0 {
+		config.HistoryTurns = defaultHistoryTurns
+	}
+
+	log := deps.Logger
+	if log == nil {
+		var err error
+		if log, err = logger.NewLogger(logger.Config{Level: "error"}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Agent{
+		config:      config,
+		model:       deps.Model,
+		engine:      deps.Engine,
+		store:       deps.Store,
+		transcriber: deps.Transcriber,
+		synthesizer: deps.Synthesizer,
+		log:         log.Component("agent"),
+		metrics:     newAgentMetrics(deps.Metrics),
+		tracer:      deps.Tracer,
+	}, nil
+}
+
+// ChatRequest es un mensaje del usuario
+type ChatRequest struct {
+	Text           string
+	UserID         string
+	ConversationID string // vacío inicia una conversación nueva
+}
+
+// ChatResult es la respuesta del agente a un mensaje
+type ChatResult struct {
+	InteractionID  string            `json:"interaction_id"`
+	ConversationID string            `json:"conversation_id"`
+	Response       string            `json:"response"`
+	Intent         string            `json:"intent"`
+	Confidence     float64           `json:"confidence"`
+	Entities       map[string]string `json:"entities,omitempty"`
+	Model          string            `json:"model,omitempty"`
+	LatencyMs      int64             `json:"latency_ms"`
+}
+
+// Chat procesa un mensaje: detecta la intención, genera la respuesta con el
+// historial de la conversación y registra la interacción
+func (a *Agent) Chat(ctx context.Context, request ChatRequest) (*ChatResult, error) {
+	return a.chat(ctx, request, StreamHandler{})
+}
+
+// chat implementa Chat y ChatStream; con handler.OnToken la respuesta se pide
+// al modelo en streaming
+func (a *Agent) chat(ctx context.Context, request ChatRequest, handler StreamHandler) (result *ChatResult, err error) {
+	start := time.Now()
+	model := a.Model()
+	ctx, span := a.tracer.Start(ctx, "agent.chat", tracing.KindInternal,
+		tracing.String("llm.model", model), tracing.Bool("llm.stream", handler.OnToken != nil))
+	defer func() { endSpan(span, err) }()
+
+	text := strings.TrimSpace(request.Text)
+	if text == "" {
+		return nil, fmt.Errorf("%w: el texto está vacío", ErrInvalidInput)
+	}
+
+	conversation, err := a.conversation(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(tracing.String("conversation.id", conversation.ID))
+
+	intent, err := a.model.DetectIntent(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	a.metrics.observeIntent(intent.Name)
+	span.SetAttributes(tracing.String("intent.name", intent.Name), tracing.Float("intent.confidence", intent.Confidence))
+
+	_, patternSpan := a.tracer.Start(ctx, "learning.find_pattern", tracing.KindInternal, tracing.String("intent.name", intent.Name))
+	_, learned := a.engine.FindSimilarPattern(intent.Name)
+	patternSpan.SetAttributes(tracing.Bool("learning.hit", learned))
+	patternSpan.End()
+	a.log.DebugContext(ctx, "intención detectada", "intent", intent.Name, "confidence", intent.Confidence, "learned_pattern", learned)
+	if handler.OnIntent != nil {
+		handler.OnIntent(intent)
+	}
+
+	var response string
+	if handler.OnToken != nil {
+		response, err = a.model.ProcessTextStream(ctx, text, a.history(conversation), handler.OnToken)
+	} else {
+		response, err = a.model.ProcessText(ctx, text, a.history(conversation))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error generando respuesta: %w", err)
+	}
+
+	latency := time.Since(start)
+	interaction := &learning.Interaction{
+		UserInput: text,
+		Response:  response,
+		Intent:    intent.Name,
+		Context: map[string]interface{}{
+			"conversation_id": conversation.ID,
+			"confidence":      intent.Confidence,
+		},
+		Latency: latency,
+	}
+	for key, value := range intent.Entities {
+		interaction.Context["entity_"+key] = value
+	}
+	_, learnSpan := a.tracer.Start(ctx, "learning.record_interaction", tracing.KindInternal, tracing.String("intent.name", intent.Name))
+	a.engine.RecordInteraction(interaction)
+	learnSpan.End()
+	span.SetAttributes(tracing.String("interaction.id", interaction.ID))
+
+	if err := a.persist(ctx, request.UserID, conversation.ID, interaction); err != nil {
+		return nil, err
+	}
+	if err := a.savePatterns(ctx, false); err != nil {
+		a.log.WarnContext(ctx, "error guardando patrones aprendidos", "error", err)
+	}
+
+	a.log.LogInteractionContext(ctx, logger.InteractionRecord{
+		Intent:    intent.Name,
+		Input:     text,
+		Response:  response,
+		Model:     model,
+		SessionID: conversation.ID,
+		Latency:   latency,
+	})
+
+	return &ChatResult{
+		InteractionID:  interaction.ID,
+		ConversationID: conversation.ID,
+		Response:       response,
+		Intent:         intent.Name,
+		Confidence:     intent.Confidence,
+		Entities:       intent.Entities,
+		Model:          model,
+		LatencyMs:      latency.Milliseconds(),
+	}, nil
+}
+
+// conversation devuelve la conversación indicada o crea una nueva
+func (a *Agent) conversation(ctx context.Context, request ChatRequest) (*storage.Conversation, error) {
+	if request.ConversationID != "" {
+		_, span := a.tracer.Start(ctx, "storage.get_conversation", tracing.KindInternal)
+		conversation, err := a.store.GetConversation(request.ConversationID)
+		endSpan(span, err)
+		if err != nil {
+			return nil, err
+		}
+		if request.UserID != "" && conversation.UserID != "" && conversation.UserID != request.UserID {
+			return nil, storage.ErrNotFound
+		}
+		if !conversation.Active() {
+			return nil, fmt.Errorf("%w: la conversación %s está cerrada", ErrInvalidInput, conversation.ID)
+		}
+		return conversation, nil
+	}
+
+	conversation := &storage.Conversation{UserID: request.UserID}
+	_, span := a.tracer.Start(ctx, "storage.save_conversation", tracing.KindInternal)
+	err := a.store.SaveConversation(conversation)
+	endSpan(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("error creando conversación: %w", err)
+	}
+	return conversation, nil
+}
+
+// history convierte los últimos turnos de la conversación en mensajes para el modelo
+func (a *Agent) history(conversation *storage.Conversation) []nlp.Message {
+	turns := conversation.Turns
+	if len(turns) > a.config.HistoryTurns {
+		turns = turns[len(turns)-a.config.HistoryTurns:]
+	}
+	messages := make([]nlp.Message, 0, len(turns)+1)
+	for _, turn := range turns {
+		messages = append(messages, nlp.Message{Role: turn.Role, Content: turn.Content})
+	}
+	return messages
+}
+
+// persist guarda la interacción y añade los dos turnos a la conversación
+func (a *Agent) persist(ctx context.Context, userID, conversationID string, interaction *learning.Interaction) error {
+	stored := &storage.Interaction{
+		ID:        interaction.ID,
+		UserID:    userID,
+		Timestamp: interaction.Timestamp,
+		UserInput: interaction.UserInput,
+		Response:  interaction.Response,
+		Intent:    interaction.Intent,
+		Context:   interaction.Context,
+		Latency:   interaction.Latency,
+	}
+	_, span := a.tracer.Start(ctx, "storage.save_interaction", tracing.KindInternal)
+	err := a.store.SaveInteraction(stored)
+	endSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("error guardando interacción: %w", err)
+	}
+	a.updateStoredStats(ctx, func(s *storedStats) { s.stats.TotalInteractions++ })
+
+	now := time.Now()
+	_, span = a.tracer.Start(ctx, "storage.append_turns", tracing.KindInternal)
+	err = a.store.AppendTurns(conversationID,
+		storage.Turn{InteractionID: interaction.ID, Role: "user", Content: interaction.UserInput, Timestamp: interaction.Timestamp},
+		storage.Turn{InteractionID: interaction.ID, Role: "assistant", Content: interaction.Response, Timestamp: now},
+	)
+	endSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("error guardando turnos: %w", err)
+	}
+	return nil
+}
+
+// Transcribe convierte audio en texto con el transcriptor configurado
+func (a *Agent) Transcribe(ctx context.Context, audio []byte, format string) (string, error) {
+	if a.transcriber == nil {
+		return "", ErrNoTranscriber
+	}
+	if len(audio) == 0 {
+		return "", fmt.Errorf("%w: el audio está vacío", ErrInvalidInput)
+	}
+
+	start := time.Now()
+	text, err := a.transcriber.TranscribeStream(ctx, audio, format)
+	if err != nil {
+		a.log.ErrorContext(ctx, "error transcribiendo", "format", format, "bytes", len(audio), "error", err)
+		return "", err
+	}
+	a.log.DebugContext(ctx, "audio transcrito", "format", format, "bytes", len(audio), "latency_ms", time.Since(start).Milliseconds())
+	return text, nil
+}
+
+// Feedback registra la valoración (1-5) de una interacción
+func (a *Agent) Feedback(ctx context.Context, interactionID string, rating int, comment string) error {
+	if interactionID == "" {
+		return fmt.Errorf("%w: falta interaction_id", ErrInvalidInput)
+	}
+	if rating < 1 || rating > 5 {
+		return fmt.Errorf("%w: rating debe estar entre 1 y 5", ErrInvalidInput)
+	}
+
+	// La valoración anterior, si la había, se descuenta de las estadísticas
+	previous := 0
+	if interaction, err := a.store.GetInteraction(interactionID); err == nil && interaction.Feedback != nil {
+		previous = interaction.Feedback.Rating
+	}
+
+	feedback := &storage.Feedback{Rating: rating, Comment: comment, Timestamp: time.Now()}
+	_, span := a.tracer.Start(ctx, "storage.save_feedback", tracing.KindInternal, tracing.Int("feedback.rating", rating))
+	err := a.store.SaveFeedback(interactionID, feedback)
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+	a.metrics.observeFeedback(rating)
+	a.updateStoredStats(ctx, func(s *storedStats) {
+		if previous > 0 {
+			s.rate(previous, -1)
+		}
+		s.rate(rating, 1)
+	})
+
+	// El motor solo conoce las interacciones de esta ejecución; las anteriores
+	// quedan valoradas en el almacenamiento aunque el motor no las tenga
+	if err := a.engine.AddFeedback(interactionID, rating, comment); err != nil {
+		a.log.DebugContext(ctx, "feedback sin interacción en memoria", "interaction_id", interactionID)
+	} else if err := a.savePatterns(ctx, false); err != nil {
+		a.log.WarnContext(ctx, "error guardando patrones aprendidos", "error", err)
+	}
+	return nil
+}
+
+// SavePatterns guarda los patrones aprendidos que aún estén pendientes sin
+// esperar a SaveInterval; se llama antes de cerrar el almacenamiento
+func (a *Agent) SavePatterns(ctx context.Context) error {
+	return a.savePatterns(ctx, true)
+}
+
+// savePatterns guarda los patrones que el motor tenga pendientes. Los que
+// fallan quedan pendientes para el siguiente intento.
+func (a *Agent) savePatterns(ctx context.Context, force bool) (err error) {
+	a.patternsMu.Lock()
+	defer a.patternsMu.Unlock()
+
+	pending := a.engine.PendingPatterns(force)
+	if len(pending) == 0 {
+		return nil
+	}
+	_, span := a.tracer.Start(ctx, "storage.save_patterns", tracing.KindInternal, tracing.Int("patterns.count", len(pending)))
+	defer func() { endSpan(span, err) }()
+
+	var failed []string
+	for key, pattern := range pending {
+		saveErr := a.store.SavePattern(&storage.Pattern{
+			Key:        key,
+			Pattern:    pattern.Pattern,
+			Response:   pattern.Response,
+			Frequency:  pattern.Frequency,
+			Confidence: pattern.Confidence,
+			LastUsed:   pattern.LastUsed,
+		})
+		if saveErr != nil {
+			failed = append(failed, key)
+			if err == nil {
+				err = fmt.Errorf("error guardando patrón %s: %w", key, saveErr)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		a.engine.MarkPending(failed...)
+	}
+	return err
+}
+
+// Stats resume el estado del agente
+type Stats struct {
+	Session *learning.Stats `json:"session"` // Desde el arranque, según el motor de aprendizaje
+	Stored  *storage.Stats  `json:"stored"`  // Acumuladas en el almacenamiento
+	Model   string          `json:"model,omitempty"`
+}
+
+// Stats devuelve las estadísticas de la sesión y las almacenadas
+func (a *Agent) Stats() (*Stats, error) {
+	stored, err := a.storedSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &Stats{Session: a.engine.GetStats(), Stored: stored, Model: a.Model()}, nil
+}
+
+// Conversations lista las conversaciones que cumplen el filtro, sin turnos.
+// Sin filter.UserID lista las de todos los usuarios: los llamadores que
+// atienden a usuarios distintos, como el servidor HTTP, deben exigirlo.
+func (a *Agent) Conversations(filter storage.ConversationFilter) ([]*storage.Conversation, error) {
+	return a.store.ListConversations(filter)
+}
+
+// Conversation devuelve una conversación con sus turnos. Con userID, solo si le
+// pertenece o no tiene usuario; sin él, sea de quien sea, como en la línea de comandos.
+func (a *Agent) Conversation(id, userID string) (*storage.Conversation, error) {
+	conversation, err := a.store.GetConversation(id)
+	if err != nil {
+		return nil, err
+	}
+	if userID != "" && conversation.UserID != "" && conversation.UserID != userID {
+		return nil, storage.ErrNotFound
+	}
+	return conversation, nil
+}
+
+// Summarize resume una conversación con el modelo sin terminarla
+func (a *Agent) Summarize(ctx context.Context, id string) (string, error) {
+	conversation, err := a.store.GetConversation(id)
+	if err != nil {
+		return "", err
+	}
+	return a.summarize(ctx, conversation)
+}
+
+// summarize resume los turnos de una conversación ("" si no tiene)
+func (a *Agent) summarize(ctx context.Context, conversation *storage.Conversation) (string, error) {
+	if len(conversation.Turns) == 0 {
+		return "", nil
+	}
+	messages := make([]nlp.Message, len(conversation.Turns))
+	for i, turn := range conversation.Turns {
+		messages[i] = nlp.Message{Role: turn.Role, Content: turn.Content}
+	}
+	return a.model.SummarizeConversation(ctx, messages)
+}
+
+// EndConversation resume una conversación con el modelo y la marca como terminada
+func (a *Agent) EndConversation(ctx context.Context, id string) (*storage.Conversation, error) {
+	conversation, err := a.store.GetConversation(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(conversation.Turns) > 0 {
+		summary, err := a.summarize(ctx, conversation)
+		if err != nil {
+			return nil, err
+		}
+		conversation.Summary = summary
+	}
+
+	conversation.EndedAt = time.Now()
+	if err := a.store.SaveConversation(conversation); err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
+
+// modelSwitcher lo implementan los modelos que permiten cambiar de modelo en ejecución
+type modelSwitcher interface {
+	SetModel(model string)
+}
+
+// SetModel cambia el modelo de lenguaje para las siguientes interacciones.
+// Devuelve error si el modelo configurado no permite cambiarlo.
+func (a *Agent) SetModel(model string) error {
+	switcher, ok := a.model.(modelSwitcher)
+	if !ok {
+		return errors.New("el modelo de lenguaje no permite cambiar de modelo")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switcher.SetModel(model)
+	a.config.Model = model
+	return nil
+}
+
+// Model devuelve el nombre del modelo en uso
+func (a *Agent) Model() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.config.Model
+}
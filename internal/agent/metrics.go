@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"strconv"
+
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/pkg/metrics"
+)
+
+// otherIntent agrupa en las métricas las intenciones que no están en nlp.Intents,
+// para que un modelo que invente nombres no multiplique las series
+const otherIntent = "otra"
+
+// agentMetrics son las métricas de las interacciones
+type agentMetrics struct {
+	intents  metrics.Counter
+	feedback metrics.Counter
+}
+
+func newAgentMetrics(m metrics.Metrics) *agentMetrics {
+	m = metrics.OrNop(m)
+	return &agentMetrics{
+		intents: m.Counter("agent_intents_total",
+			"Interacciones por intención detectada", "intent"),
+		feedback: m.Counter("agent_feedback_ratings_total",
+			"Valoraciones recibidas por puntuación (1-5)", "rating"),
+	}
+}
+
+// observeIntent cuenta una intención detectada
+func (m *agentMetrics) observeIntent(name string) {
+	for _, known := range nlp.Intents {
+		if name == known {
+			m.intents.Add(1, name)
+			return
+		}
+	}
+	m.intents.Add(1, otherIntent)
+}
+
+// observeFeedback cuenta una valoración
+func (m *agentMetrics) observeFeedback(rating int) {
+	m.feedback.Add(1, strconv.Itoa(rating))
+}
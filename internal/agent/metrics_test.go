@@ -0,0 +1,88 @@
+package agent_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/akosej/agent/internal/agent"
+	"github.com/akosej/agent/internal/learning"
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/pkg/metrics"
+	"github.com/akosej/agent/pkg/storage"
+	"github.com/akosej/agent/testing/fakes"
+)
+
+// Cada etapa de una interacción anota sus métricas en el registro compartido
+func TestStageMetrics(t *testing.T) {
+	ollama := fakes.NewOllama()
+	defer ollama.Close()
+	ollama.On(fakes.Match{System: "intenciones"}, fakes.FormatIntent("saludo", 0.9, nil))
+	ollama.Reply("Hola, ¿en qué te ayudo?")
+
+	registry := metrics.NewRegistry()
+	store, err := storage.NewJSONStore(storage.Config{Path: filepath.Join(t.TempDir(), "data.json")})
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	defer store.Close()
+	a, err := agent.New(agent.Config{Model: fakes.DefaultModel}, agent.Dependencies{
+		Model:   nlp.NewProcessor(ollama.URL, nlp.Config{Model: fakes.DefaultModel}, nlp.WithMetrics(registry)),
+		Engine:  learning.NewEngine(learning.Config{LearningRate: 0.1, ConfidenceThreshold: 0.5}, learning.WithMetrics(registry)),
+		Store:   storage.WithMetrics(store, registry),
+		Metrics: registry,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	// El primer turno no encuentra patrón; tras valorarlo bien, el segundo sí
+	first, err := a.Chat(ctx, agent.ChatRequest{Text: "hola"})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if err := a.Feedback(ctx, first.InteractionID, 5, ""); err != nil {
+		t.Fatalf("Feedback: %v", err)
+	}
+	if _, err := a.Chat(ctx, agent.ChatRequest{Text: "buenas", ConversationID: first.ConversationID}); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	// Un 500 al detectar la intención corta el turno y cuenta como error del backend
+	ollama.Fail("/api/chat", fakes.Fault{Status: 500, Times: 1})
+	if _, err := a.Chat(ctx, agent.ChatRequest{Text: "hola"}); err == nil {
+		t.Fatal("Chat con Ollama fallando no devolvió error")
+	}
+
+	tests := []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"agent_intents_total", []string{"saludo"}, 2},
+		{"agent_feedback_ratings_total", []string{"5"}, 1},
+		{"agent_pattern_lookups_total", []string{"miss"}, 1},
+		{"agent_pattern_lookups_total", []string{"hit"}, 1},
+		{"agent_llm_request_duration_seconds", []string{"intent", "ok"}, 2},
+		{"agent_llm_request_duration_seconds", []string{"intent", "error"}, 1},
+		{"agent_llm_request_duration_seconds", []string{"chat", "ok"}, 2},
+		{"agent_llm_tokens_per_second", []string{"chat"}, 2},
+		{"agent_backend_errors_total", []string{"ollama", "http_500"}, 1},
+		{"agent_storage_operation_duration_seconds", []string{"save_interaction"}, 2},
+		{"agent_storage_operation_duration_seconds", []string{"save_feedback"}, 1},
+	}
+	for _, tt := range tests {
+		if got := registry.Value(tt.name, tt.labels...); got != tt.want {
+			t.Errorf("%s%v = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+
+	// Los tokens vienen de prompt_eval_count y eval_count: "Hola, ¿en qué te ayudo?" son 5 por respuesta
+	if got := registry.Value("agent_llm_tokens_total", "chat", "completion"); got != 10 {
+		t.Errorf("tokens generados en chat = %v, want 10", got)
+	}
+	if got := registry.Value("agent_llm_tokens_total", "intent", "prompt"); got == 0 {
+		t.Error("no se contaron los tokens del prompt de intención")
+	}
+}
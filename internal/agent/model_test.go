@@ -0,0 +1,55 @@
+package agent_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/akosej/agent/internal/agent"
+	"github.com/akosej/agent/testing/fakes"
+)
+
+// Cambiar de modelo mientras se atienden mensajes no debe producir carreras
+// (se comprueba con go test -race)
+func TestSetModelWhileChatting(t *testing.T) {
+	a, _ := newAgent(t, filepath.Join(t.TempDir(), "data.json"))
+	models := []string{fakes.DefaultModel, "qwen2.5:3b"}
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			if err := a.SetModel(models[i%2]); err != nil {
+				t.Errorf("SetModel: %v", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			result, err := a.Chat(ctx, agent.ChatRequest{Text: "hola"})
+			if err != nil {
+				t.Errorf("Chat: %v", err)
+				return
+			}
+			if result.Model != models[0] && result.Model != models[1] {
+				t.Errorf("modelo = %q", result.Model)
+			}
+			if _, err := a.Stats(); err != nil {
+				t.Errorf("Stats: %v", err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	if err := a.SetModel("qwen2.5:3b"); err != nil {
+		t.Fatalf("SetModel: %v", err)
+	}
+	if got := a.Model(); got != "qwen2.5:3b" {
+		t.Errorf("Model() = %q, want qwen2.5:3b", got)
+	}
+}
@@ -0,0 +1,92 @@
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/akosej/agent/internal/agent"
+	"github.com/akosej/agent/internal/learning"
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/pkg/storage"
+	"github.com/akosej/agent/testing/fakes"
+)
+
+// Los patrones aprendidos se guardan cada SaveInterval interacciones o
+// valoraciones, y los pendientes con SavePatterns
+func TestLearnedPatternsPersisted(t *testing.T) {
+	ollama := fakes.NewOllama()
+	defer ollama.Close()
+	ollama.On(fakes.Match{System: "intenciones"}, fakes.FormatIntent("saludo", 0.9, nil))
+	ollama.Reply("Hola")
+
+	store, err := storage.NewJSONStore(storage.Config{Path: filepath.Join(t.TempDir(), "data.json")})
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	defer store.Close()
+	a, err := agent.New(agent.Config{Model: fakes.DefaultModel}, agent.Dependencies{
+		Model:  nlp.NewProcessor(ollama.URL, nlp.Config{Model: fakes.DefaultModel}),
+		Engine: learning.NewEngine(learning.Config{LearningRate: 0.1, SaveInterval: 2}),
+		Store:  store,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	saved := func() *storage.Pattern {
+		t.Helper()
+		pattern, err := store.GetPattern("saludo")
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			t.Fatalf("GetPattern: %v", err)
+		}
+		return pattern
+	}
+
+	first, err := a.Chat(ctx, agent.ChatRequest{Text: "hola"})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if pattern := saved(); pattern != nil {
+		t.Errorf("guardado antes de SaveInterval: %+v", pattern)
+	}
+
+	// La valoración es el segundo cambio y refuerza el patrón
+	if err := a.Feedback(ctx, first.InteractionID, 5, ""); err != nil {
+		t.Fatalf("Feedback: %v", err)
+	}
+	pattern := saved()
+	if pattern == nil || pattern.Frequency != 1 || math.Abs(pattern.Confidence-0.6) > 1e-9 || pattern.Pattern != "hola" {
+		t.Fatalf("tras valorar = %+v, want frecuencia 1 y confianza 0.6", pattern)
+	}
+
+	// Volver a valorar positivamente no refuerza otra vez
+	if err := a.Feedback(ctx, first.InteractionID, 4, ""); err != nil {
+		t.Fatalf("Feedback: %v", err)
+	}
+	if _, err := a.Chat(ctx, agent.ChatRequest{Text: "buenas"}); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if pattern := saved(); pattern.Frequency != 2 || math.Abs(pattern.Confidence-0.6) > 1e-9 {
+		t.Errorf("tras el segundo turno = %+v, want frecuencia 2 y confianza 0.6", pattern)
+	}
+
+	if _, err := a.Chat(ctx, agent.ChatRequest{Text: "hey"}); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if pattern := saved(); pattern.Frequency != 2 {
+		t.Errorf("guardado antes de SaveInterval: %+v", pattern)
+	}
+	if err := a.SavePatterns(ctx); err != nil {
+		t.Fatalf("SavePatterns: %v", err)
+	}
+	if pattern := saved(); pattern.Frequency != 3 {
+		t.Errorf("tras SavePatterns = %+v, want frecuencia 3", pattern)
+	}
+}
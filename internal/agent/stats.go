@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// storedStats mantiene al día las estadísticas del almacenamiento. Se calculan
+// una vez a partir de las interacciones guardadas (la primera vez que se
+// necesitan) y después se actualizan con cada interacción y valoración.
+type storedStats struct {
+	mu          sync.Mutex
+	loaded      bool
+	stats       storage.Stats
+	ratingSum   int
+	ratingCount int
+}
+
+// load recalcula las estadísticas recorriendo el almacenamiento. Requiere mu.
+func (s *storedStats) load(store storage.Store) error {
+	interactions, err := store.GetRecentInteractions(0)
+	if err != nil {
+		return err
+	}
+	s.stats = storage.Stats{TotalInteractions: len(interactions)}
+	s.ratingSum, s.ratingCount = 0, 0
+	for _, interaction := range interactions {
+		if interaction.Feedback != nil {
+			s.rate(interaction.Feedback.Rating, 1)
+		}
+	}
+	s.loaded = true
+	return nil
+}
+
+// rate suma (sign 1) o descuenta (sign -1) una valoración. Requiere mu.
+func (s *storedStats) rate(rating, sign int) {
+	if rating >= 4 {
+		s.stats.PositiveFeedback += sign
+	} else if rating <= 2 {
+		s.stats.NegativeFeedback += sign
+	}
+	s.ratingSum += sign * rating
+	s.ratingCount += sign
+	s.stats.AverageRating = 0
+	if s.ratingCount > 0 {
+		s.stats.AverageRating = float64(s.ratingSum) / float64(s.ratingCount)
+	}
+}
+
+// updateStoredStats aplica change y guarda las estadísticas. Si aún no se habían
+// calculado, se calculan del almacenamiento, que ya incluye el cambio.
+func (a *Agent) updateStoredStats(ctx context.Context, change func(s *storedStats)) {
+	a.stored.mu.Lock()
+	defer a.stored.mu.Unlock()
+
+	if a.stored.loaded {
+		change(&a.stored)
+	} else if err := a.stored.load(a.store); err != nil {
+		a.log.WarnContext(ctx, "error calculando estadísticas del almacenamiento", "error", err)
+		return
+	}
+	stats := a.stored.stats
+	if err := a.store.UpdateStats(&stats); err != nil {
+		a.log.WarnContext(ctx, "error guardando estadísticas", "error", err)
+		return
+	}
+	a.stored.stats.LastUpdated = time.Now()
+}
+
+// storedSnapshot devuelve una copia de las estadísticas del almacenamiento,
+// calculándolas si es la primera vez
+func (a *Agent) storedSnapshot() (*storage.Stats, error) {
+	a.stored.mu.Lock()
+	defer a.stored.mu.Unlock()
+
+	if !a.stored.loaded {
+		if err := a.stored.load(a.store); err != nil {
+			return nil, err
+		}
+		if saved, err := a.store.GetStats(); err == nil {
+			a.stored.stats.LastUpdated = saved.LastUpdated
+		}
+	}
+	stats := a.stored.stats
+	return &stats, nil
+}
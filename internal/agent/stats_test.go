@@ -0,0 +1,97 @@
+package agent_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/akosej/agent/internal/agent"
+	"github.com/akosej/agent/internal/learning"
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/pkg/storage"
+	"github.com/akosej/agent/testing/fakes"
+)
+
+// newAgent crea un agente con el Ollama falso y un almacenamiento JSON en path
+func newAgent(t *testing.T, path string) (*agent.Agent, storage.Store) {
+	t.Helper()
+	ollama := fakes.NewOllama(fakes.DefaultModel, "qwen2.5:3b")
+	t.Cleanup(ollama.Close)
+	ollama.On(fakes.Match{System: "intenciones"}, fakes.FormatIntent("saludo", 0.9, nil))
+	ollama.Reply("Hola")
+
+	store, err := storage.NewJSONStore(storage.Config{Type: "json", Path: path})
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	a, err := agent.New(agent.Config{Model: fakes.DefaultModel}, agent.Dependencies{
+		Model:  nlp.NewProcessor(ollama.URL, nlp.Config{Model: fakes.DefaultModel}),
+		Engine: learning.NewEngine(learning.Config{}),
+		Store:  store,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return a, store
+}
+
+func storedStats(t *testing.T, a *agent.Agent) *storage.Stats {
+	t.Helper()
+	stats, err := a.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	return stats.Stored
+}
+
+func TestStoredStatsFollowWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	a, store := newAgent(t, path)
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		result, err := a.Chat(ctx, agent.ChatRequest{Text: "hola", UserID: "ana"})
+		if err != nil {
+			t.Fatalf("Chat: %v", err)
+		}
+		ids = append(ids, result.InteractionID)
+	}
+	if saved, err := store.GetInteraction(ids[0]); err != nil || saved.Latency <= 0 {
+		t.Errorf("interacción guardada = %+v, %v, want con latencia", saved, err)
+	}
+	if stats := storedStats(t, a); stats.TotalInteractions != 3 || stats.AverageRating != 0 {
+		t.Errorf("tras 3 mensajes = %+v", stats)
+	}
+
+	for _, feedback := range []struct {
+		id     string
+		rating int
+	}{{ids[0], 5}, {ids[1], 1}, {ids[0], 3}} {
+		if err := a.Feedback(ctx, feedback.id, feedback.rating, ""); err != nil {
+			t.Fatalf("Feedback: %v", err)
+		}
+	}
+	want := storage.Stats{TotalInteractions: 3, PositiveFeedback: 0, NegativeFeedback: 1, AverageRating: 2}
+	stats := storedStats(t, a)
+	if stats.TotalInteractions != want.TotalInteractions || stats.PositiveFeedback != want.PositiveFeedback ||
+		stats.NegativeFeedback != want.NegativeFeedback || stats.AverageRating != want.AverageRating {
+		t.Errorf("tras valorar = %+v, want %+v", stats, want)
+	}
+	if stats.LastUpdated.IsZero() {
+		t.Error("LastUpdated vacío tras guardar")
+	}
+
+	// Un agente nuevo sobre el mismo almacenamiento parte de lo guardado; el
+	// primero debe cerrarlo antes, porque el almacén JSON es de un solo proceso
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	other, _ := newAgent(t, path)
+	reopened := storedStats(t, other)
+	if reopened.TotalInteractions != 3 || reopened.NegativeFeedback != 1 || reopened.AverageRating != 2 {
+		t.Errorf("al reabrir = %+v", reopened)
+	}
+}
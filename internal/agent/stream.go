@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/internal/speech"
+	"github.com/akosej/agent/pkg/tracing"
+)
+
+// StreamHandler recibe los eventos de una interacción en streaming. Los campos
+// nil se ignoran. OnAudio se llama desde otra goroutine, en paralelo con
+// OnToken, así que ambos deben poder usarse de forma concurrente.
+type StreamHandler struct {
+	OnTranscript func(text string, final bool)     // Transcripciones parciales y final (solo en Voice)
+	OnIntent     func(intent *nlp.Intent)          // Intención detectada, antes de generar la respuesta
+	OnToken      func(token string) error          // Fragmentos de la respuesta; un error interrumpe la generación
+	OnAudio      func(audio []byte, format string) // Voz sintetizada de cada frase de la respuesta
+}
+
+// CanTranscribe indica si hay un transcriptor configurado
+func (a *Agent) CanTranscribe() bool {
+	return a.transcriber != nil
+}
+
+// CanSynthesize indica si hay un sintetizador de voz configurado
+func (a *Agent) CanSynthesize() bool {
+	return a.synthesizer != nil
+}
+
+// ChatStream es como Chat pero entrega la intención y la respuesta por fragmentos
+// a medida que se generan. Con OnAudio y un sintetizador configurado, además
+// sintetiza la respuesta frase a frase.
+func (a *Agent) ChatStream(ctx context.Context, request ChatRequest, handler StreamHandler) (*ChatResult, error) {
+	if handler.OnToken == nil {
+		handler.OnToken = func(string) error { return nil }
+	}
+	if handler.OnAudio == nil || a.synthesizer == nil {
+		return a.chat(ctx, request, handler)
+	}
+
+	tts := a.startSpeaking(ctx, handler.OnAudio)
+	onToken := handler.OnToken
+	handler.OnToken = func(token string) error {
+		tts.add(token)
+		return onToken(token)
+	}
+
+	result, err := a.chat(ctx, request, handler)
+	tts.finish(err == nil)
+	return result, err
+}
+
+// Voice transcribe el audio PCM que llega por chunks, con transcripciones
+// parciales, y responde al texto final como ChatStream
+func (a *Agent) Voice(ctx context.Context, chunks <-chan []byte, audio speech.ChunkConfig, request ChatRequest, handler StreamHandler) (result *ChatResult, err error) {
+	if a.transcriber == nil {
+		return nil, ErrNoTranscriber
+	}
+	ctx, span := a.tracer.Start(ctx, "agent.voice", tracing.KindInternal)
+	defer func() { endSpan(span, err) }()
+
+	var onPartial func(string)
+	if handler.OnTranscript != nil {
+		onPartial = func(text string) { handler.OnTranscript(text, false) }
+	}
+	text, err := speech.TranscribeChunks(ctx, a.transcriber, chunks, audio, onPartial)
+	if err != nil {
+		return nil, err
+	}
+	text = strings.TrimSpace(text)
+	if handler.OnTranscript != nil {
+		handler.OnTranscript(text, true)
+	}
+	if text == "" {
+		return nil, fmt.Errorf("%w: no se detectó voz en el audio", ErrInvalidInput)
+	}
+
+	request.Text = text
+	return a.ChatStream(ctx, request, handler)
+}
+
+// speaker sintetiza frases completas en orden en una goroutine propia, para no
+// retrasar la entrega de los fragmentos de texto
+type speaker struct {
+	pending   strings.Builder
+	sentences chan string
+	done      sync.WaitGroup
+}
+
+// startSpeaking arranca la síntesis de frases; onAudio recibe cada audio en orden
+func (a *Agent) startSpeaking(ctx context.Context, onAudio func([]byte, string)) *speaker {
+	s := &speaker{sentences: make(chan string, 16)}
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		for sentence := range s.sentences {
+			if ctx.Err() != nil {
+				continue
+			}
+			audio, format, err := a.synthesizer.Synthesize(ctx, sentence)
+			if err != nil {
+				a.log.WarnContext(ctx, "error sintetizando voz", "error", err)
+				continue
+			}
+			onAudio(audio, format)
+		}
+	}()
+	return s
+}
+
+// add acumula un fragmento y envía a sintetizar las frases que completa
+func (s *speaker) add(token string) {
+	s.pending.WriteString(token)
+	text := s.pending.String()
+	end := strings.LastIndexAny(text, ".!?;:\n")
+	if end < 0 {
+		return
+	}
+	if sentence := strings.TrimSpace(text[:end+1]); sentence != "" {
+		s.sentences <- sentence
+	}
+	s.pending.Reset()
+	s.pending.WriteString(text[end+1:])
+}
+
+// finish sintetiza el resto pendiente (si speak) y espera a que termine la síntesis
+func (s *speaker) finish(speak bool) {
+	if rest := strings.TrimSpace(s.pending.String()); speak && rest != "" {
+		s.sentences <- rest
+	}
+	close(s.sentences)
+	s.done.Wait()
+}
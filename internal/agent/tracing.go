@@ -0,0 +1,11 @@
+package agent
+
+import (
+	"github.com/akosej/agent/pkg/tracing"
+)
+
+// endSpan cierra un span anotando el error, si lo hubo
+func endSpan(span *tracing.Span, err error) {
+	span.RecordError(err)
+	span.End()
+}
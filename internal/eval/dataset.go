@@ -0,0 +1,101 @@
+// Package eval mide la calidad del modelo sobre un conjunto de frases de
+// ejemplo: exactitud de la detección de intenciones y entidades, calidad de
+// las respuestas frente a respuestas de referencia y latencia, para comparar
+// modelos o cambios de prompt antes de ponerlos en uso.
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Case es una frase de ejemplo con lo que se espera del modelo
+type Case struct {
+	ID        string            `yaml:"id" json:"id"`
+	Text      string            `yaml:"text" json:"text"`
+	Intent    string            `yaml:"intent" json:"intent"`                           // Intención esperada; vacía no evalúa la intención
+	Entities  map[string]string `yaml:"entities,omitempty" json:"entities,omitempty"`   // Entidades que deben detectarse
+	Reference string            `yaml:"reference,omitempty" json:"reference,omitempty"` // Respuesta de referencia
+	Keywords  []string          `yaml:"keywords,omitempty" json:"keywords,omitempty"`   // Palabras que la respuesta debe incluir
+}
+
+// scoresResponse indica si el caso tiene con qué puntuar la respuesta
+func (c *Case) scoresResponse() bool {
+	return c.Reference != "" || len(c.Keywords) > 0
+}
+
+// LoadDataset lee los casos de un archivo YAML (lista de casos o "cases:" con
+// la lista) o JSONL (un caso por línea). Los casos sin ID reciben su posición.
+func LoadDataset(path string) ([]Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo dataset: %w", err)
+	}
+
+	var cases []Case
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		cases, err = parseJSONL(data)
+	case ".yaml", ".yml":
+		cases, err = parseYAML(data)
+	default:
+		return nil, fmt.Errorf("formato de dataset desconocido: %s (usa .yaml o .jsonl)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parseando %s: %w", path, err)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("el dataset %s no tiene casos", path)
+	}
+
+	for i := range cases {
+		if strings.TrimSpace(cases[i].Text) == "" {
+			return nil, fmt.Errorf("el caso %d de %s no tiene texto", i+1, path)
+		}
+		if cases[i].ID == "" {
+			cases[i].ID = fmt.Sprintf("%d", i+1)
+		}
+	}
+	return cases, nil
+}
+
+// parseYAML acepta una lista de casos o un documento con la clave cases
+func parseYAML(data []byte) ([]Case, error) {
+	var list []Case
+	if err := yaml.Unmarshal(data, &list); err == nil {
+		return list, nil
+	}
+	var document struct {
+		Cases []Case `yaml:"cases"`
+	}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return document.Cases, nil
+}
+
+// parseJSONL lee un caso por línea, ignorando las líneas vacías
+func parseJSONL(data []byte) ([]Case, error) {
+	var cases []Case
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var c Case
+		if err := json.Unmarshal(text, &c); err != nil {
+			return nil, fmt.Errorf("línea %d: %w", line, err)
+		}
+		cases = append(cases, c)
+	}
+	return cases, scanner.Err()
+}
@@ -0,0 +1,165 @@
+package eval
+
+import (
+	"context"
+	"time"
+
+	"github.com/akosej/agent/internal/nlp"
+)
+
+// errorLabel es la intención detectada de los casos en que el modelo falló
+const errorLabel = "(error)"
+
+// Model es el modelo evaluado; nlp.Processor lo implementa
+type Model interface {
+	DetectIntent(ctx context.Context, text string) (*nlp.Intent, error)
+	ProcessText(ctx context.Context, text string, conversationHistory []nlp.Message) (string, error)
+}
+
+// Embedder calcula embeddings para la similitud semántica; nlp.Processor lo implementa
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// Judge puntúa respuestas como un evaluador humano; nlp.Processor lo implementa
+type Judge interface {
+	JudgeResponse(ctx context.Context, question, reference, answer string) (*nlp.Judgement, error)
+}
+
+// Config contiene la configuración de una evaluación
+type Config struct {
+	Model     string   // Nombre del modelo, para el informe
+	Responses bool     // Generar y puntuar respuestas en los casos con referencia o palabras clave
+	Embedder  Embedder // Similitud semántica con la referencia; nil no la calcula
+	Judge     Judge    // Puntuación de un modelo juez; nil no la calcula
+
+	OnCase func(result *CaseResult) // Se llama al terminar cada caso; opcional
+}
+
+// CaseResult es el resultado de un caso. Las puntuaciones nil no se calcularon.
+type CaseResult struct {
+	ID             string            `json:"id"`
+	Text           string            `json:"text"`
+	ExpectedIntent string            `json:"expected_intent,omitempty"`
+	Intent         string            `json:"intent"`
+	Confidence     float64           `json:"confidence"`
+	Correct        bool              `json:"correct"`
+	Entities       map[string]string `json:"entities,omitempty"`
+	EntitiesWanted int               `json:"entities_expected,omitempty"`
+	EntitiesFound  int               `json:"entities_found,omitempty"`
+	IntentMs       float64           `json:"intent_ms"`
+
+	Response      string   `json:"response,omitempty"`
+	ResponseMs    float64  `json:"response_ms,omitempty"`
+	KeywordScore  *float64 `json:"keyword_score,omitempty"`  // Fracción de palabras clave presentes (0-1)
+	SemanticScore *float64 `json:"semantic_score,omitempty"` // Similitud coseno con la referencia
+	JudgeScore    *float64 `json:"judge_score,omitempty"`    // Puntuación del juez (1-5)
+	JudgeReason   string   `json:"judge_reason,omitempty"`
+
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Run evalúa los casos en orden, uno a uno para que las latencias no se
+// mezclen. Si ctx se cancela devuelve el informe de los casos terminados junto
+// con el error.
+func Run(ctx context.Context, model Model, cases []Case, config Config) (*Report, error) {
+	report := &Report{Model: config.Model, StartedAt: time.Now()}
+	for i := range cases {
+		result := runCase(ctx, model, &cases[i], config)
+		if ctx.Err() != nil {
+			report.summarize()
+			return report, ctx.Err()
+		}
+		report.Results = append(report.Results, result)
+		if config.OnCase != nil {
+			config.OnCase(result)
+		}
+	}
+	report.summarize()
+	return report, nil
+}
+
+// runCase detecta la intención del caso y, si corresponde, genera y puntúa la respuesta
+func runCase(ctx context.Context, model Model, c *Case, config Config) *CaseResult {
+	result := &CaseResult{ID: c.ID, Text: c.Text, ExpectedIntent: c.Intent}
+
+	start := time.Now()
+	intent, err := model.DetectIntent(ctx, c.Text)
+	result.IntentMs = milliseconds(time.Since(start))
+	if err != nil {
+		result.Intent = errorLabel
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		result.Intent = normalize(intent.Name)
+		result.Confidence = intent.Confidence
+		result.Entities = intent.Entities
+		result.Correct = c.Intent != "" && result.Intent == normalize(c.Intent)
+		result.EntitiesWanted, result.EntitiesFound = matchEntities(c.Entities, intent.Entities)
+	}
+
+	if !config.Responses || !c.scoresResponse() {
+		return result
+	}
+	start = time.Now()
+	response, err := model.ProcessText(ctx, c.Text, nil)
+	result.ResponseMs = milliseconds(time.Since(start))
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+	result.Response = response
+	scoreResponse(ctx, c, result, config)
+	return result
+}
+
+// scoreResponse calcula las puntuaciones de la respuesta que permiten el caso y la configuración
+func scoreResponse(ctx context.Context, c *Case, result *CaseResult, config Config) {
+	if keywords := c.Keywords; len(keywords) > 0 || c.Reference != "" {
+		if len(keywords) == 0 {
+			keywords = referenceKeywords(c.Reference)
+		}
+		if len(keywords) > 0 {
+			score := keywordScore(keywords, result.Response)
+			result.KeywordScore = &score
+		}
+	}
+
+	if config.Embedder != nil && c.Reference != "" {
+		embeddings, err := config.Embedder.Embed(ctx, []string{c.Reference, result.Response})
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		} else {
+			score := cosine(embeddings[0], embeddings[1])
+			result.SemanticScore = &score
+		}
+	}
+
+	if config.Judge != nil {
+		judgement, err := config.Judge.JudgeResponse(ctx, c.Text, c.Reference, result.Response)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		} else {
+			result.JudgeScore = &judgement.Score
+			result.JudgeReason = judgement.Reason
+		}
+	}
+}
+
+// matchEntities cuenta las entidades esperadas que el modelo detectó con el mismo valor
+func matchEntities(expected, detected map[string]string) (wanted, found int) {
+	for key, value := range expected {
+		wanted++
+		for detectedKey, detectedValue := range detected {
+			if normalize(detectedKey) == normalize(key) && normalize(detectedValue) == normalize(value) {
+				found++
+				break
+			}
+		}
+	}
+	return wanted, found
+}
+
+// milliseconds convierte una duración a milisegundos con decimales
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,161 @@
+package eval_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/internal/eval"
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/testing/fakes"
+)
+
+// near compara puntuaciones con tolerancia de redondeo
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		c        eval.Case
+		detected string  // Intención que responde el modelo
+		response string  // Respuesta del modelo; vacía si el caso no la puntúa
+		intent   string  // Intención del resultado
+		keyword  float64 // Puntuación de palabras clave; -1 si no se calcula
+	}{
+		// El primer caso se encuentra con un 500 al detectar la intención
+		{eval.Case{ID: "error", Text: "fallo total", Intent: "soporte"}, "soporte", "", "(error)", -1},
+		{eval.Case{ID: "hola", Text: "hola", Intent: "saludo", Keywords: []string{"hola", "ayudarte", "mañana"}},
+			"saludo", "¡Hola! ¿En qué puedo ayudarte hoy?", "saludo", 2.0 / 3},
+		{eval.Case{ID: "buenas", Text: "buenas tardes", Intent: "Saludo"}, "despedida", "", "despedida", -1},
+		// Sin palabras clave se usan las de la referencia: luego, tengas y buen
+		{eval.Case{ID: "adios", Text: "adiós", Intent: "despedida", Reference: "Hasta luego, que tengas un buen día"},
+			"despedida", "Hasta luego", "despedida", 1.0 / 3},
+		// Las palabras clave de varias palabras deben ir seguidas y las tildes no cuentan
+		{eval.Case{ID: "chao", Text: "chao", Intent: "despedida", Keywords: []string{"nos vemos", "adiós"}},
+			"Despedida", "Adios, nos vemos pronto", "despedida", 1},
+		{eval.Case{ID: "vpn", Text: "ayuda con la vpn", Intent: "soporte", Keywords: []string{"vpn", "reinicia"}},
+			"saludo", "Prueba a reiniciar el router", "saludo", 0},
+		// Sin intención esperada no entra en la matriz
+		{eval.Case{ID: "hora", Text: "qué hora es"}, "saludo", "", "saludo", -1},
+	}
+
+	ollama := fakes.NewOllama()
+	defer ollama.Close()
+	var cases []eval.Case
+	for _, tt := range tests {
+		ollama.On(fakes.Match{System: "intenciones", User: tt.c.Text}, fakes.FormatIntent(tt.detected, 0.8, nil))
+		if tt.response != "" {
+			ollama.On(fakes.Match{User: tt.c.Text}, tt.response)
+		}
+		cases = append(cases, tt.c)
+	}
+	// Los fallos se aplican por orden de petición: el 500 a la intención del
+	// primer caso y la espera a la del segundo, porque el primero no genera respuesta
+	ollama.Fail("/api/chat", fakes.Fault{Status: 500, Times: 1})
+	ollama.Fail("/api/chat", fakes.Fault{Delay: 200 * time.Millisecond, Times: 1})
+
+	model := nlp.NewProcessor(ollama.URL, nlp.Config{Model: fakes.DefaultModel})
+	var finished []string
+	report, err := eval.Run(context.Background(), model, cases, eval.Config{
+		Model:     fakes.DefaultModel,
+		Responses: true,
+		OnCase:    func(result *eval.CaseResult) { finished = append(finished, result.ID) },
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(report.Results) != len(tests) || len(finished) != len(tests) {
+		t.Fatalf("%d resultados y %d avisos, want %d", len(report.Results), len(finished), len(tests))
+	}
+	for i, tt := range tests {
+		result := report.Results[i]
+		if result.ID != tt.c.ID || result.Intent != tt.intent {
+			t.Errorf("caso %s: intención %q, want %q", tt.c.ID, result.Intent, tt.intent)
+		}
+		switch {
+		case tt.keyword < 0 && result.KeywordScore != nil:
+			t.Errorf("caso %s: puntuación de palabras clave %v sin nada que puntuar", tt.c.ID, *result.KeywordScore)
+		case tt.keyword >= 0 && (result.KeywordScore == nil || !near(*result.KeywordScore, tt.keyword)):
+			t.Errorf("caso %s: palabras clave %v, want %v", tt.c.ID, result.KeywordScore, tt.keyword)
+		}
+	}
+	if report.Cases != 7 || report.Errors != 1 || len(report.Results[0].Errors) != 1 {
+		t.Errorf("casos %d con %d errores, want 7 con 1", report.Cases, report.Errors)
+	}
+
+	// Matriz de confusión [esperada][detectada] con las etiquetas ordenadas
+	intent := report.Intent
+	wantLabels := []string{"(error)", "despedida", "saludo", "soporte"}
+	wantConfusion := [][]int{
+		{0, 0, 0, 0},
+		{0, 2, 0, 0},
+		{0, 1, 1, 0},
+		{1, 0, 1, 0},
+	}
+	if !reflect.DeepEqual(intent.Labels, wantLabels) || !reflect.DeepEqual(intent.Confusion, wantConfusion) {
+		t.Errorf("matriz %v %v, want %v %v", intent.Labels, intent.Confusion, wantLabels, wantConfusion)
+	}
+	if intent.Evaluated != 6 || intent.Correct != 3 || intent.Accuracy != 0.5 {
+		t.Errorf("exactitud %d/%d = %v, want 3/6", intent.Correct, intent.Evaluated, intent.Accuracy)
+	}
+
+	// La etiqueta de error no tiene puntuación propia pero resta recall a soporte
+	wantScores := []eval.IntentScore{
+		{Intent: "despedida", Precision: 2.0 / 3, Recall: 1, F1: 0.8, Support: 2},
+		{Intent: "saludo", Precision: 0.5, Recall: 0.5, F1: 0.5, Support: 2},
+		{Intent: "soporte", Precision: 0, Recall: 0, F1: 0, Support: 2},
+	}
+	if len(intent.PerIntent) != len(wantScores) {
+		t.Fatalf("puntuaciones por intención = %+v", intent.PerIntent)
+	}
+	for i, want := range wantScores {
+		got := intent.PerIntent[i]
+		if got.Intent != want.Intent || !near(got.Precision, want.Precision) || !near(got.Recall, want.Recall) || !near(got.F1, want.F1) || got.Support != want.Support {
+			t.Errorf("puntuación %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if !near(intent.MacroF1, 1.3/3) {
+		t.Errorf("F1 macro = %v, want %v", intent.MacroF1, 1.3/3)
+	}
+
+	// Se puntúan las respuestas de los casos con referencia o palabras clave
+	response := report.Response
+	if response == nil || response.Evaluated != 4 || response.Keyword == nil || !near(*response.Keyword, 0.5) {
+		t.Fatalf("resumen de respuestas = %+v, want 4 con palabras clave 0.5", response)
+	}
+	if response.Semantic != nil || response.Judge != nil {
+		t.Errorf("puntuaciones sin Embedder ni Judge: %+v", response)
+	}
+
+	// Solo un caso pasa de 200ms: queda fuera de p50 pero marca p95 y el máximo
+	latency := intent.Latency
+	if latency.MaxMs < 200 || latency.P95Ms != latency.MaxMs || latency.P50Ms >= 200 || latency.MeanMs < 200.0/7 || latency.MeanMs >= latency.MaxMs {
+		t.Errorf("latencia de intención = %+v", latency)
+	}
+	if response.Latency.P50Ms > response.Latency.P95Ms || response.Latency.P95Ms > response.Latency.MaxMs {
+		t.Errorf("latencia de respuesta = %+v", response.Latency)
+	}
+}
+
+// Al cancelar el contexto se devuelve el informe de los casos terminados
+func TestRunCanceled(t *testing.T) {
+	ollama := fakes.NewOllama()
+	defer ollama.Close()
+	ollama.On(fakes.Match{System: "intenciones"}, fakes.FormatIntent("saludo", 0.9, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cases := []eval.Case{{ID: "1", Text: "hola", Intent: "saludo"}, {ID: "2", Text: "buenas", Intent: "saludo"}}
+	model := nlp.NewProcessor(ollama.URL, nlp.Config{Model: fakes.DefaultModel})
+	report, err := eval.Run(ctx, model, cases, eval.Config{OnCase: func(*eval.CaseResult) { cancel() }})
+	if err != context.Canceled {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if report.Cases != 1 || report.Intent.Correct != 1 || report.Intent.MacroF1 != 1 {
+		t.Errorf("informe parcial = %+v", report.Intent)
+	}
+}
@@ -0,0 +1,356 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Report es el resultado de evaluar un modelo sobre un dataset
+type Report struct {
+	Model     string           `json:"model"`
+	Dataset   string           `json:"dataset,omitempty"`
+	StartedAt time.Time        `json:"started_at"`
+	Cases     int              `json:"cases"`
+	Errors    int              `json:"errors"` // Casos con algún error del modelo
+	Intent    IntentSummary    `json:"intent"`
+	Response  *ResponseSummary `json:"response,omitempty"` // nil si no se evaluaron respuestas
+	Results   []*CaseResult    `json:"results"`
+}
+
+// IntentSummary resume la detección de intenciones
+type IntentSummary struct {
+	Evaluated      int           `json:"evaluated"` // Casos con intención esperada
+	Correct        int           `json:"correct"`
+	Accuracy       float64       `json:"accuracy"`
+	MacroF1        float64       `json:"macro_f1"`
+	EntitiesWanted int           `json:"entities_expected"`
+	EntitiesFound  int           `json:"entities_found"`
+	Latency        Latency       `json:"latency"`
+	PerIntent      []IntentScore `json:"per_intent"`
+	Labels         []string      `json:"labels"`    // Filas y columnas de Confusion
+	Confusion      [][]int       `json:"confusion"` // [esperada][detectada]
+}
+
+// EntityAccuracy es la fracción de entidades esperadas que se detectaron
+func (s *IntentSummary) EntityAccuracy() float64 {
+	return ratio(s.EntitiesFound, s.EntitiesWanted)
+}
+
+// IntentScore es la precisión y exhaustividad de una intención
+type IntentScore struct {
+	Intent    string  `json:"intent"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"` // Casos con esta intención esperada
+}
+
+// ResponseSummary resume las puntuaciones de las respuestas. Las medias nil no
+// se calcularon en ningún caso.
+type ResponseSummary struct {
+	Evaluated int      `json:"evaluated"`
+	Keyword   *float64 `json:"keyword,omitempty"`
+	Semantic  *float64 `json:"semantic,omitempty"`
+	Judge     *float64 `json:"judge,omitempty"`
+	Latency   Latency  `json:"latency"`
+}
+
+// Latency resume una serie de latencias en milisegundos
+type Latency struct {
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// summarize calcula los resúmenes a partir de Results
+func (r *Report) summarize() {
+	r.Cases = len(r.Results)
+	r.Errors = 0
+
+	var intentLatencies, responseLatencies []float64
+	var keyword, semantic, judge []float64
+	expected := make(map[string]bool)
+	labelSet := make(map[string]bool)
+	responses := 0
+	for _, result := range r.Results {
+		if len(result.Errors) > 0 {
+			r.Errors++
+		}
+		intentLatencies = append(intentLatencies, result.IntentMs)
+		r.Intent.EntitiesWanted += result.EntitiesWanted
+		r.Intent.EntitiesFound += result.EntitiesFound
+		if result.ExpectedIntent != "" {
+			r.Intent.Evaluated++
+			if result.Correct {
+				r.Intent.Correct++
+			}
+			expected[normalize(result.ExpectedIntent)] = true
+			labelSet[normalize(result.ExpectedIntent)] = true
+			labelSet[result.Intent] = true
+		}
+		if result.Response != "" {
+			responses++
+			responseLatencies = append(responseLatencies, result.ResponseMs)
+			keyword = appendScore(keyword, result.KeywordScore)
+			semantic = appendScore(semantic, result.SemanticScore)
+			judge = appendScore(judge, result.JudgeScore)
+		}
+	}
+	r.Intent.Accuracy = ratio(r.Intent.Correct, r.Intent.Evaluated)
+	r.Intent.Latency = summarizeLatency(intentLatencies)
+	r.confusion(labelSet, expected)
+
+	if responses > 0 {
+		r.Response = &ResponseSummary{
+			Evaluated: responses,
+			Keyword:   mean(keyword),
+			Semantic:  mean(semantic),
+			Judge:     mean(judge),
+			Latency:   summarizeLatency(responseLatencies),
+		}
+	}
+}
+
+// confusion construye la matriz de confusión y las puntuaciones por intención
+func (r *Report) confusion(labelSet, expected map[string]bool) {
+	labels := make([]string, 0, len(labelSet))
+	for label := range labelSet {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	index := make(map[string]int, len(labels))
+	for i, label := range labels {
+		index[label] = i
+	}
+
+	matrix := make([][]int, len(labels))
+	for i := range matrix {
+		matrix[i] = make([]int, len(labels))
+	}
+	for _, result := range r.Results {
+		if result.ExpectedIntent != "" {
+			matrix[index[normalize(result.ExpectedIntent)]][index[result.Intent]]++
+		}
+	}
+
+	var f1Sum float64
+	for i, label := range labels {
+		if label == errorLabel {
+			continue
+		}
+		truePositives, predicted, support := matrix[i][i], 0, 0
+		for j := range labels {
+			predicted += matrix[j][i]
+			support += matrix[i][j]
+		}
+		score := IntentScore{
+			Intent:    label,
+			Precision: ratio(truePositives, predicted),
+			Recall:    ratio(truePositives, support),
+			Support:   support,
+		}
+		if score.Precision+score.Recall > 0 {
+			score.F1 = 2 * score.Precision * score.Recall / (score.Precision + score.Recall)
+		}
+		if expected[label] {
+			f1Sum += score.F1
+		}
+		r.Intent.PerIntent = append(r.Intent.PerIntent, score)
+	}
+	if len(expected) > 0 {
+		r.Intent.MacroF1 = f1Sum / float64(len(expected))
+	}
+	r.Intent.Labels = labels
+	r.Intent.Confusion = matrix
+}
+
+// appendScore añade la puntuación si se calculó
+func appendScore(scores []float64, score *float64) []float64 {
+	if score == nil {
+		return scores
+	}
+	return append(scores, *score)
+}
+
+// mean es la media de los valores, o nil si no hay ninguno
+func mean(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	m := sum / float64(len(values))
+	return &m
+}
+
+// summarizeLatency calcula media, percentiles y máximo
+func summarizeLatency(values []float64) Latency {
+	if len(values) == 0 {
+		return Latency{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	percentile := func(q float64) float64 {
+		return sorted[int(math.Ceil(q*float64(len(sorted))))-1]
+	}
+	return Latency{MeanMs: *mean(sorted), P50Ms: percentile(0.5), P95Ms: percentile(0.95), MaxMs: sorted[len(sorted)-1]}
+}
+
+// ratio divide evitando la división por cero
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// WriteText escribe el informe legible: resumen, puntuaciones por intención,
+// matriz de confusión y casos fallidos
+func WriteText(w io.Writer, r *Report) {
+	fmt.Fprintf(w, "Modelo %s · %d casos · %d con errores\n\n", r.Model, r.Cases, r.Errors)
+
+	intent := r.Intent
+	fmt.Fprintf(w, "Intenciones: exactitud %s (%d/%d) · F1 macro %.3f\n",
+		percent(intent.Accuracy), intent.Correct, intent.Evaluated, intent.MacroF1)
+	if intent.EntitiesWanted > 0 {
+		fmt.Fprintf(w, "Entidades:   %s (%d/%d)\n", percent(intent.EntityAccuracy()), intent.EntitiesFound, intent.EntitiesWanted)
+	}
+	fmt.Fprintf(w, "Latencia:    %s\n", formatLatency(intent.Latency))
+
+	if len(intent.PerIntent) > 0 {
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "intención\tprecisión\trecall\tF1\tcasos\t")
+		for _, score := range intent.PerIntent {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%.3f\t%d\t\n", score.Intent, percent(score.Precision), percent(score.Recall), score.F1, score.Support)
+		}
+		tw.Flush()
+
+		fmt.Fprintln(w, "\nMatriz de confusión (filas: esperada, columnas: detectada)")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprint(tw, "\t")
+		for _, label := range intent.Labels {
+			fmt.Fprintf(tw, "%s\t", label)
+		}
+		fmt.Fprintln(tw)
+		for i, label := range intent.Labels {
+			fmt.Fprintf(tw, "%s\t", label)
+			for _, count := range intent.Confusion[i] {
+				fmt.Fprintf(tw, "%d\t", count)
+			}
+			fmt.Fprintln(tw)
+		}
+		tw.Flush()
+	}
+
+	if response := r.Response; response != nil {
+		fmt.Fprintf(w, "\nRespuestas: %d evaluadas\n", response.Evaluated)
+		if response.Keyword != nil {
+			fmt.Fprintf(w, "  palabras clave  %s\n", percent(*response.Keyword))
+		}
+		if response.Semantic != nil {
+			fmt.Fprintf(w, "  semántica       %.3f\n", *response.Semantic)
+		}
+		if response.Judge != nil {
+			fmt.Fprintf(w, "  juez            %.2f / 5\n", *response.Judge)
+		}
+		fmt.Fprintf(w, "  latencia        %s\n", formatLatency(response.Latency))
+	}
+
+	var failed []*CaseResult
+	for _, result := range r.Results {
+		if (result.ExpectedIntent != "" && !result.Correct) || len(result.Errors) > 0 {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintln(w, "\nFallos:")
+		for _, result := range failed {
+			fmt.Fprintf(w, "  ✗ %s %q: esperada %s, detectada %s\n", result.ID, truncate(result.Text, 50), result.ExpectedIntent, result.Intent)
+			for _, err := range result.Errors {
+				fmt.Fprintf(w, "      %s\n", err)
+			}
+		}
+	}
+}
+
+// WriteComparison escribe las métricas principales de varios informes lado a
+// lado, p. ej. del mismo dataset con distintos modelos
+func WriteComparison(w io.Writer, reports []*Report) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	row := func(name string, value func(r *Report) string) {
+		fmt.Fprintf(tw, "%s\t", name)
+		for _, r := range reports {
+			fmt.Fprintf(tw, "%s\t", value(r))
+		}
+		fmt.Fprintln(tw)
+	}
+	optional := func(score func(s *ResponseSummary) *float64, format func(float64) string) func(r *Report) string {
+		return func(r *Report) string {
+			if r.Response == nil || score(r.Response) == nil {
+				return "-"
+			}
+			return format(*score(r.Response))
+		}
+	}
+	decimal := func(v float64) string { return fmt.Sprintf("%.3f", v) }
+
+	row("métrica", func(r *Report) string { return r.Model })
+	row("casos", func(r *Report) string { return fmt.Sprintf("%d", r.Cases) })
+	row("errores", func(r *Report) string { return fmt.Sprintf("%d", r.Errors) })
+	row("exactitud", func(r *Report) string { return percent(r.Intent.Accuracy) })
+	row("F1 macro", func(r *Report) string { return decimal(r.Intent.MacroF1) })
+	row("entidades", func(r *Report) string {
+		if r.Intent.EntitiesWanted == 0 {
+			return "-"
+		}
+		return percent(r.Intent.EntityAccuracy())
+	})
+	row("intención p50", func(r *Report) string { return formatMs(r.Intent.Latency.P50Ms) })
+	row("intención p95", func(r *Report) string { return formatMs(r.Intent.Latency.P95Ms) })
+	row("palabras clave", optional(func(s *ResponseSummary) *float64 { return s.Keyword }, percent))
+	row("semántica", optional(func(s *ResponseSummary) *float64 { return s.Semantic }, decimal))
+	row("juez (1-5)", optional(func(s *ResponseSummary) *float64 { return s.Judge }, func(v float64) string { return fmt.Sprintf("%.2f", v) }))
+	row("respuesta p50", func(r *Report) string {
+		if r.Response == nil {
+			return "-"
+		}
+		return formatMs(r.Response.Latency.P50Ms)
+	})
+	tw.Flush()
+}
+
+// percent formatea una fracción como porcentaje
+func percent(v float64) string {
+	return fmt.Sprintf("%.1f%%", v*100)
+}
+
+// formatLatency resume una latencia en una línea
+func formatLatency(l Latency) string {
+	return fmt.Sprintf("media %s · p50 %s · p95 %s · máx %s", formatMs(l.MeanMs), formatMs(l.P50Ms), formatMs(l.P95Ms), formatMs(l.MaxMs))
+}
+
+// formatMs muestra milisegundos, o segundos a partir de uno
+func formatMs(ms float64) string {
+	if ms >= 1000 {
+		return fmt.Sprintf("%.2fs", ms/1000)
+	}
+	return fmt.Sprintf("%.0fms", ms)
+}
+
+// truncate acorta un texto a n caracteres
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return s
+}
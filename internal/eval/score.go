@@ -0,0 +1,76 @@
+package eval
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// accents quita tildes y diéresis para que "conversación" y "conversacion" coincidan
+var accents = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "à", "a", "è", "e", "ì", "i", "ò", "o", "ù", "u")
+
+// normalize pasa a minúsculas, sin tildes ni espacios alrededor
+func normalize(s string) string {
+	return accents.Replace(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// words divide un texto normalizado en palabras
+func words(text string) []string {
+	return strings.FieldsFunc(normalize(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// stopwords son palabras demasiado comunes para usarlas como palabras clave
+var stopwords = map[string]bool{
+	"para": true, "como": true, "pero": true, "esta": true, "este": true, "esto": true,
+	"estas": true, "estos": true, "tiene": true, "puede": true, "puedes": true, "desde": true,
+	"hasta": true, "sobre": true, "entre": true, "cuando": true, "donde": true, "porque": true,
+	"tambien": true, "muy": true, "sus": true, "una": true, "unos": true, "unas": true,
+	"that": true, "this": true, "with": true, "from": true, "have": true,
+}
+
+// referenceKeywords extrae de una respuesta de referencia las palabras de
+// cuatro letras o más que no son de uso común, sin repetir
+func referenceKeywords(reference string) []string {
+	seen := make(map[string]bool)
+	var keywords []string
+	for _, word := range words(reference) {
+		if len([]rune(word)) < 4 || stopwords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		keywords = append(keywords, word)
+	}
+	return keywords
+}
+
+// keywordScore es la fracción de palabras clave presentes en la respuesta. Una
+// palabra clave de varias palabras debe aparecer seguida.
+func keywordScore(keywords []string, response string) float64 {
+	text := " " + strings.Join(words(response), " ") + " "
+	found := 0
+	for _, keyword := range keywords {
+		if phrase := strings.Join(words(keyword), " "); phrase != "" && strings.Contains(text, " "+phrase+" ") {
+			found++
+		}
+	}
+	return float64(found) / float64(len(keywords))
+}
+
+// cosine es la similitud coseno entre dos vectores (0 si alguno es nulo)
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
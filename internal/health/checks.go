@@ -0,0 +1,139 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// ModelLister lista los modelos instalados; nlp.Processor lo implementa
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]nlp.ModelInfo, error)
+}
+
+// Ollama comprueba que Ollama responda en /api/tags y tenga instalado model
+func Ollama(lister ModelLister, model string) Check {
+	return Check{Name: "ollama", Critical: true, Run: func(ctx context.Context) Result {
+		models, err := lister.ListModels(ctx)
+		if err != nil {
+			return Fail(err.Error())
+		}
+		if !HasModel(models, model) {
+			return Fail(fmt.Sprintf("el modelo %s no está instalado (ollama pull %s)", model, model))
+		}
+		return OK(fmt.Sprintf("modelo %s, %d instalados", model, len(models)))
+	}}
+}
+
+// HasModel indica si name está entre los modelos instalados; acepta el nombre
+// sin etiqueta (:latest)
+func HasModel(models []nlp.ModelInfo, name string) bool {
+	for _, model := range models {
+		if model.Name == name || model.Name == name+":latest" {
+			return true
+		}
+	}
+	return false
+}
+
+// Executable comprueba que path exista y sea ejecutable
+func Executable(name, path string, critical bool) Check {
+	return Check{Name: name, Critical: critical, Run: func(ctx context.Context) Result {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			return Fail(err.Error())
+		case info.IsDir() || (info.Mode().Perm()&0111 == 0 && filepath.Ext(path) != ".exe"):
+			return Fail(path + " no es ejecutable")
+		}
+		return OK(path)
+	}}
+}
+
+// File comprueba que path exista y sea un archivo
+func File(name, path string, critical bool) Check {
+	return Check{Name: name, Critical: critical, Run: func(ctx context.Context) Result {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			return Fail(err.Error())
+		case info.IsDir():
+			return Fail(path + " es un directorio")
+		}
+		return OK(fmt.Sprintf("%s (%d bytes)", path, info.Size()))
+	}}
+}
+
+// HTTP comprueba que GET url responda con un estado 2xx
+func HTTP(name, url string, critical bool) Check {
+	return Check{Name: name, Critical: critical, Run: func(ctx context.Context) Result {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return Fail(fmt.Sprintf("URL no válida %q: %v", url, err))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return Fail(fmt.Sprintf("%s no responde: %v", url, err))
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return Fail(fmt.Sprintf("%s respondió con %d", url, resp.StatusCode))
+		}
+		return OK(url)
+	}}
+}
+
+// Storage comprueba que el almacenamiento responda leyendo las estadísticas.
+// Es de solo lectura: una sonda no debe modificar datos ni competir con las
+// escrituras reales.
+func Storage(store storage.Store) Check {
+	return Check{Name: "storage", Critical: true, Run: func(ctx context.Context) Result {
+		stats, err := store.GetStats()
+		if err != nil {
+			return Fail("lectura: " + err.Error())
+		}
+		return OK(fmt.Sprintf("%d interacciones", stats.TotalInteractions))
+	}}
+}
+
+// freeSpace consulta el espacio libre de un directorio; los tests lo sustituyen
+var freeSpace = diskFree
+
+// Disk comprueba el espacio libre del disco de dir: avisa por debajo de
+// minFree bytes y falla si no queda nada. Con critical, un disco lleno deja al
+// agente no listo; un aviso solo degrada el estado.
+func Disk(name, dir string, minFree uint64, critical bool) Check {
+	return Check{Name: name, Critical: critical, Run: func(ctx context.Context) Result {
+		free, err := freeSpace(dir)
+		if err != nil {
+			return Warn(fmt.Sprintf("no se pudo consultar el espacio de %s: %v", dir, err))
+		}
+		message := fmt.Sprintf("%s: %s libres", dir, formatBytes(free))
+		switch {
+		case free == 0:
+			return Fail(message)
+		case free < minFree:
+			return Warn(message + ", menos de " + formatBytes(minFree))
+		}
+		return OK(message)
+	}}
+}
+
+// formatBytes muestra un tamaño en la unidad más adecuada
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/pkg/storage"
+	"github.com/akosej/agent/testing/fakes"
+)
+
+// statsStore es un storage.Store que solo implementa las estadísticas
+type statsStore struct {
+	storage.Store
+	err     error
+	updates int
+}
+
+func (s *statsStore) GetStats() (*storage.Stats, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &storage.Stats{TotalInteractions: 7}, nil
+}
+
+func (s *statsStore) UpdateStats(*storage.Stats) error {
+	s.updates++
+	return nil
+}
+
+func TestStorageIsReadOnly(t *testing.T) {
+	store := &statsStore{}
+	result := Storage(store).Run(context.Background())
+	if result.Status != StatusOK {
+		t.Fatalf("resultado = %+v, want ok", result)
+	}
+	if store.updates != 0 {
+		t.Errorf("la sonda escribió las estadísticas %d veces", store.updates)
+	}
+
+	store.err = errors.New("database is locked")
+	if result := Storage(store).Run(context.Background()); result.Status != StatusFail {
+		t.Errorf("resultado = %+v, want fail", result)
+	}
+}
+
+func TestHasModel(t *testing.T) {
+	models := []nlp.ModelInfo{{Name: "llama3.2:latest"}, {Name: "mistral:7b"}}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"llama3.2:latest", true},
+		{"llama3.2", true},
+		{"mistral:7b", true},
+		{"mistral", false},
+		{"llama3", false},
+	}
+	for _, tt := range tests {
+		if got := HasModel(models, tt.name); got != tt.want {
+			t.Errorf("HasModel(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOllama(t *testing.T) {
+	ollama := fakes.NewOllama()
+	defer ollama.Close()
+	processor := nlp.NewProcessor(ollama.URL, nlp.Config{Model: fakes.DefaultModel})
+	ctx := context.Background()
+
+	check := Ollama(processor, fakes.DefaultModel)
+	if !check.Critical {
+		t.Error("la comprobación de Ollama no es crítica")
+	}
+	if result := check.Run(ctx); result.Status != StatusOK {
+		t.Errorf("con el modelo instalado = %+v, want ok", result)
+	}
+
+	result := Ollama(processor, "mistral").Run(ctx)
+	if result.Status != StatusFail || !strings.Contains(result.Message, "ollama pull mistral") {
+		t.Errorf("sin el modelo = %+v, want fail con la orden para descargarlo", result)
+	}
+
+	ollama.Fail("/api/tags", fakes.Fault{Status: 500, Times: 1})
+	if result := check.Run(ctx); result.Status != StatusFail {
+		t.Errorf("con Ollama fallando = %+v, want fail", result)
+	}
+}
+
+func TestDisk(t *testing.T) {
+	defer func(original func(string) (uint64, error)) { freeSpace = original }(freeSpace)
+
+	tests := []struct {
+		name   string
+		free   uint64
+		err    error
+		status Status
+	}{
+		{"lleno", 0, nil, StatusFail},
+		{"poco espacio", 100 << 20, nil, StatusWarn},
+		{"suficiente", 1 << 30, nil, StatusOK},
+		{"sin consulta", 0, errors.New("no soportado en este sistema"), StatusWarn},
+	}
+	for _, tt := range tests {
+		freeSpace = func(string) (uint64, error) { return tt.free, tt.err }
+		result := Disk("disk_data", "/datos", 500<<20, true).Run(context.Background())
+		if result.Status != tt.status {
+			t.Errorf("%s: %+v, want %s", tt.name, result, tt.status)
+		}
+	}
+
+	// Con el disco de datos lleno el agente deja de estar listo; con el de logs, no
+	freeSpace = func(string) (uint64, error) { return 0, nil }
+	report := New(Config{}, Disk("disk_data", "/datos", 1, true)).Check(context.Background())
+	if report.Ready() {
+		t.Errorf("disco de datos lleno = %s, want fail", report.Status)
+	}
+	report = New(Config{}, Disk("disk_logs", "/logs", 1, false)).Check(context.Background())
+	if !report.Ready() || report.Status != StatusDegraded {
+		t.Errorf("disco de logs lleno = %s, want degraded", report.Status)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package health
+
+import "errors"
+
+// diskFree no está disponible en este sistema; Disk lo muestra como aviso
+func diskFree(dir string) (uint64, error) {
+	return 0, errors.New("no soportado en este sistema")
+}
@@ -0,0 +1,200 @@
+// Package health comprueba las dependencias del agente (Ollama y su modelo,
+// whisper, almacenamiento, espacio en disco) para saber si puede atender
+// peticiones. El servidor lo expone en /healthz y /readyz.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Status es el resultado de una comprobación o del conjunto
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded" // Falló algo opcional (voz, aviso de disco); el agente funciona
+	StatusWarn     Status = "warn"     // Solo en comprobaciones: funciona pero requiere atención
+	StatusFail     Status = "fail"
+)
+
+// Valores por defecto
+const (
+	defaultTimeout = 5 * time.Second
+	defaultMaxAge  = 10 * time.Second
+)
+
+// Check es una comprobación de una dependencia
+type Check struct {
+	Name     string
+	Critical bool // Si falla, el agente no está listo
+	Run      func(ctx context.Context) Result
+}
+
+// Result es el resultado de ejecutar un Check
+type Result struct {
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// OK, Warn y Fail crean resultados con un mensaje
+func OK(message string) Result   { return Result{Status: StatusOK, Message: message} }
+func Warn(message string) Result { return Result{Status: StatusWarn, Message: message} }
+func Fail(message string) Result { return Result{Status: StatusFail, Message: message} }
+
+// CheckResult es el resultado de una comprobación dentro de un Report
+type CheckResult struct {
+	Name       string `json:"name"`
+	Critical   bool   `json:"critical"`
+	DurationMs int64  `json:"duration_ms"`
+	Result
+}
+
+// Report es el estado del agente según todas sus comprobaciones
+type Report struct {
+	Status    Status        `json:"status"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Checks    []CheckResult `json:"checks"`
+}
+
+// Ready indica si el agente puede atender peticiones: ninguna comprobación
+// crítica falló
+func (r *Report) Ready() bool {
+	return r.Status != StatusFail
+}
+
+// Config contiene la configuración de las comprobaciones
+type Config struct {
+	Timeout time.Duration // Tiempo máximo de cada comprobación (por defecto 5s)
+	MaxAge  time.Duration // Tiempo que se reutiliza un Report (por defecto 10s; negativo no reutiliza)
+}
+
+// Health ejecuta las comprobaciones. Es seguro para uso concurrente.
+type Health struct {
+	config Config
+	checks []Check
+
+	mu     sync.Mutex
+	last   *Report
+	cached time.Time
+}
+
+// New crea un Health con las comprobaciones indicadas
+func New(config Config, checks ...Check) *Health {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = defaultMaxAge
+	}
+	return &Health{config: config, checks: checks}
+}
+
+// Check ejecuta todas las comprobaciones en paralelo, cada una con su tiempo
+// máximo. Si el último Report tiene menos de MaxAge lo devuelve sin repetirlas,
+// para que los sondeos frecuentes no carguen a Ollama ni al almacenamiento.
+func (h *Health) Check(ctx context.Context) *Report {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.last != nil && time.Since(h.cached) < h.config.MaxAge {
+		return h.last
+	}
+
+	// El Report se comparte con los siguientes sondeos: que un cliente corte la
+	// conexión no debe dejar guardadas comprobaciones fallidas
+	ctx = context.WithoutCancel(ctx)
+	report := &Report{Status: StatusOK, CheckedAt: time.Now(), Checks: make([]CheckResult, len(h.checks))}
+	var wg sync.WaitGroup
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			report.Checks[i] = h.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		switch {
+		case result.Status == StatusFail && result.Critical:
+			report.Status = StatusFail
+		case result.Status != StatusOK && report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+
+	h.last, h.cached = report, time.Now()
+	return report
+}
+
+// Invalidate descarta el último Report, para que el siguiente Check vuelva a
+// comprobar todo; p. ej. tras descargar el modelo
+func (h *Health) Invalidate() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.last = nil
+}
+
+// run ejecuta una comprobación con su tiempo máximo; si no termina a tiempo
+// cuenta como fallida
+func (h *Health) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan Result, 1)
+	go func() { done <- check.Run(ctx) }()
+
+	var result Result
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result = Fail("sin respuesta en " + h.config.Timeout.String())
+	}
+	return CheckResult{
+		Name:       check.Name,
+		Critical:   check.Critical,
+		DurationMs: time.Since(start).Milliseconds(),
+		Result:     result,
+	}
+}
+
+// LiveHandler atiende /healthz: responde 200 mientras el proceso pueda atender
+// peticiones, sin comprobar dependencias, para que un fallo de Ollama no
+// provoque reinicios del agente
+func (h *Health) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, http.StatusOK, Result{Status: StatusOK})
+	})
+}
+
+// ReadyHandler atiende /readyz: ejecuta las comprobaciones y responde 200 si
+// el agente está listo (ok o degraded) o 503 si falla alguna crítica
+func (h *Health) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.Check(r.Context())
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, r, status, report)
+	})
+}
+
+// writeJSON responde con v en JSON; rechaza los métodos distintos de GET y HEAD
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode(v)
+	}
+}
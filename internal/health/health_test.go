@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counted es una comprobación que cuenta sus ejecuciones y devuelve result
+func counted(name string, critical bool, runs *int32, result *Result) Check {
+	return Check{Name: name, Critical: critical, Run: func(ctx context.Context) Result {
+		atomic.AddInt32(runs, 1)
+		return *result
+	}}
+}
+
+func TestCheckCache(t *testing.T) {
+	var runs int32
+	result := OK("")
+	h := New(Config{MaxAge: time.Hour}, counted("ollama", true, &runs, &result))
+	ctx := context.Background()
+
+	first := h.Check(ctx)
+	result = Fail("caído")
+	if second := h.Check(ctx); second != first || runs != 1 {
+		t.Errorf("dentro de MaxAge se repitieron las comprobaciones: %d ejecuciones", runs)
+	}
+
+	// Invalidate obliga a comprobar de nuevo, p. ej. tras descargar el modelo
+	h.Invalidate()
+	if report := h.Check(ctx); runs != 2 || report.Status != StatusFail {
+		t.Errorf("tras Invalidate = %s con %d ejecuciones, want fail con 2", report.Status, runs)
+	}
+
+	// Un MaxAge negativo no reutiliza el informe
+	runs = 0
+	uncached := New(Config{MaxAge: -1}, counted("ollama", true, &runs, &result))
+	uncached.Check(ctx)
+	uncached.Check(ctx)
+	if runs != 2 {
+		t.Errorf("con MaxAge negativo = %d ejecuciones, want 2", runs)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	slow := Check{Name: "whisper_api", Critical: true, Run: func(ctx context.Context) Result {
+		<-ctx.Done()
+		return OK("tarde")
+	}}
+	report := New(Config{Timeout: 20 * time.Millisecond}, slow).Check(context.Background())
+	if report.Status != StatusFail || report.Checks[0].Message != "sin respuesta en 20ms" {
+		t.Errorf("comprobación sin respuesta = %+v", report.Checks[0])
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	var runs int32
+	critical, optional := OK(""), OK("")
+	h := New(Config{MaxAge: -1},
+		counted("ollama", true, &runs, &critical),
+		counted("whisper_api", false, &runs, &optional))
+	server := httptest.NewServer(h.ReadyHandler())
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		critical Result
+		optional Result
+		code     int
+		status   Status
+	}{
+		{"ok", http.MethodGet, OK(""), OK(""), http.StatusOK, StatusOK},
+		{"opcional caída", http.MethodGet, OK(""), Fail("sin voz"), http.StatusOK, StatusDegraded},
+		{"aviso", http.MethodGet, Warn("poco disco"), OK(""), http.StatusOK, StatusDegraded},
+		{"crítica caída", http.MethodGet, Fail("sin modelo"), OK(""), http.StatusServiceUnavailable, StatusFail},
+		{"head", http.MethodHead, Fail("sin modelo"), OK(""), http.StatusServiceUnavailable, ""},
+		{"método", http.MethodPost, OK(""), OK(""), http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		critical, optional = tt.critical, tt.optional
+		request, _ := http.NewRequest(tt.method, server.URL, nil)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+
+		if response.StatusCode != tt.code {
+			t.Errorf("%s: código %d, want %d", tt.name, response.StatusCode, tt.code)
+			continue
+		}
+		switch {
+		case tt.method == http.MethodHead && len(body) != 0:
+			t.Errorf("%s: HEAD con cuerpo %q", tt.name, body)
+		case tt.code == http.StatusMethodNotAllowed && response.Header.Get("Allow") != "GET, HEAD":
+			t.Errorf("%s: Allow = %q", tt.name, response.Header.Get("Allow"))
+		case tt.status != "":
+			var report Report
+			if err := json.Unmarshal(body, &report); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if report.Status != tt.status || len(report.Checks) != 2 || report.Checks[0].Name != "ollama" {
+				t.Errorf("%s: informe = %+v, want %s", tt.name, report, tt.status)
+			}
+			if response.Header.Get("Cache-Control") != "no-store" {
+				t.Errorf("%s: Cache-Control = %q", tt.name, response.Header.Get("Cache-Control"))
+			}
+		}
+	}
+
+	// /healthz no ejecuta comprobaciones
+	runs = 0
+	recorder := httptest.NewRecorder()
+	h.LiveHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK || runs != 0 {
+		t.Errorf("/healthz = %d con %d comprobaciones", recorder.Code, runs)
+	}
+}
@@ -0,0 +1,659 @@
+package learning
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxAnalyticsWindows limita las ventanas de un informe: una ventana diminuta
+// sobre un rango largo reservaría millones de ventanas vacías
+const maxAnalyticsWindows = 10000
+
+// Embedder genera embeddings de textos para agrupar entradas por significado.
+// nlp.Processor lo implementa usando /api/embed de Ollama.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// AnalyticsOptions configura el cálculo de analíticas
+type AnalyticsOptions struct {
+	Since              time.Time     // Inicio del rango (cero = sin límite)
+	Until              time.Time     // Fin del rango (cero = sin límite)
+	Window             time.Duration // Tamaño de cada ventana temporal (por defecto 24h)
+	LowRatingThreshold int           // Rating máximo considerado fallo (por defecto 2)
+	LowestPatterns     int           // Número de patrones peor valorados a reportar (por defecto 10)
+	ClusterThreshold   float64       // Similitud mínima para unir entradas en un grupo
+	Embedder           Embedder      // Si es nil se usa el agrupador léxico
+}
+
+// Analytics contiene el informe de analíticas de las interacciones registradas
+type Analytics struct {
+	GeneratedAt         time.Time                `json:"generated_at"`
+	Since               time.Time                `json:"since"`
+	Until               time.Time                `json:"until"`
+	WindowSeconds       int64                    `json:"window_seconds"`
+	TotalInteractions   int                      `json:"total_interactions"`
+	IntentDistribution  []IntentWindow           `json:"intent_distribution"`
+	RatingTrends        map[string][]RatingPoint `json:"rating_trends"`
+	LowestRatedPatterns []PatternRating          `json:"lowest_rated_patterns"`
+	Latency             LatencyStats             `json:"latency"`
+	LatencyByIntent     map[string]LatencyStats  `json:"latency_by_intent"`
+	ClusterMethod       string                   `json:"cluster_method"`
+	FailureClusters     []FailureCluster         `json:"failure_clusters"`
+}
+
+// IntentWindow es la distribución de intenciones en una ventana temporal
+type IntentWindow struct {
+	Start  time.Time      `json:"start"`
+	End    time.Time      `json:"end"`
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+}
+
+// RatingPoint es el rating promedio de una intención en una ventana temporal
+type RatingPoint struct {
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	AverageRating float64   `json:"average_rating"`
+	Ratings       int       `json:"ratings"`
+}
+
+// PatternRating resume la valoración de un patrón aprendido
+type PatternRating struct {
+	Key           string  `json:"key"`
+	Pattern       string  `json:"pattern"`
+	AverageRating float64 `json:"average_rating"`
+	Ratings       int     `json:"ratings"`
+	Confidence    float64 `json:"confidence"`
+}
+
+// LatencyStats contiene percentiles de latencia en milisegundos
+type LatencyStats struct {
+	Count int     `json:"count"`
+	P50Ms float64 `json:"p50_ms"`
+	P90Ms float64 `json:"p90_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+// FailureCluster agrupa entradas mal valoradas sobre un mismo tema
+type FailureCluster struct {
+	Label         string         `json:"label"`
+	Size          int            `json:"size"`
+	AverageRating float64        `json:"average_rating"`
+	Intents       map[string]int `json:"intents"`
+	Inputs        []string       `json:"inputs"`
+}
+
+// analyticsSample es una copia de los datos de una interacción usada fuera del lock
+type analyticsSample struct {
+	timestamp time.Time
+	input     string
+	intent    string
+	rating    int
+	latency   time.Duration
+}
+
+// Analytics calcula analíticas sobre las interacciones registradas
+func (e *Engine) Analytics(ctx context.Context, opts AnalyticsOptions) (*Analytics, error) {
+	if opts.Window <= 0 {
+		opts.Window = 24 * time.Hour
+	}
+	if opts.LowRatingThreshold <= 0 {
+		opts.LowRatingThreshold = 2
+	}
+	if opts.LowestPatterns <= 0 {
+		opts.LowestPatterns = 10
+	}
+
+	samples, patterns := e.analyticsSnapshot(opts.Since, opts.Until)
+
+	report := &Analytics{
+		GeneratedAt:       e.now(),
+		Since:             opts.Since,
+		Until:             opts.Until,
+		WindowSeconds:     int64(opts.Window / time.Second),
+		TotalInteractions: len(samples),
+		RatingTrends:      make(map[string][]RatingPoint),
+		LatencyByIntent:   make(map[string]LatencyStats),
+	}
+
+	if len(samples) == 0 {
+		return report, nil
+	}
+
+	start := opts.Since
+	if start.IsZero() {
+		start = samples[0].timestamp.Truncate(opts.Window)
+	}
+	if report.Since.IsZero() {
+		report.Since = start
+	}
+	if report.Until.IsZero() {
+		report.Until = samples[len(samples)-1].timestamp
+	}
+	if windows := int64(samples[len(samples)-1].timestamp.Sub(start)/opts.Window) + 1; windows > maxAnalyticsWindows {
+		return nil, fmt.Errorf("el rango ocupa %d ventanas de %s (máximo %d): usa una ventana mayor o acota el rango", windows, opts.Window, maxAnalyticsWindows)
+	}
+
+	report.IntentDistribution, report.RatingTrends = windowStats(samples, start, opts.Window)
+	report.LowestRatedPatterns = lowestRatedPatterns(samples, patterns, opts.LowestPatterns)
+
+	var all []time.Duration
+	byIntent := make(map[string][]time.Duration)
+	for _, s := range samples {
+		if s.latency > 0 {
+			all = append(all, s.latency)
+			byIntent[s.intent] = append(byIntent[s.intent], s.latency)
+		}
+	}
+	report.Latency = latencyStats(all)
+	for intent, latencies := range byIntent {
+		report.LatencyByIntent[intent] = latencyStats(latencies)
+	}
+
+	var failures []analyticsSample
+	for _, s := range samples {
+		if s.rating > 0 && s.rating <= opts.LowRatingThreshold {
+			failures = append(failures, s)
+		}
+	}
+
+	clusters, method, err := clusterFailures(ctx, failures, opts)
+	if err != nil {
+		return nil, err
+	}
+	report.FailureClusters = clusters
+	report.ClusterMethod = method
+
+	return report, nil
+}
+
+// analyticsSnapshot copia las interacciones del rango y los patrones bajo el lock
+func (e *Engine) analyticsSnapshot(since, until time.Time) ([]analyticsSample, map[string]Pattern) {
+	e.kb.mu.RLock()
+	defer e.kb.mu.RUnlock()
+
+	samples := make([]analyticsSample, 0, len(e.kb.Interactions))
+	for _, interaction := range e.kb.Interactions {
+		if !since.IsZero() && interaction.Timestamp.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !interaction.Timestamp.Before(until) {
+			continue
+		}
+
+		sample := analyticsSample{
+			timestamp: interaction.Timestamp,
+			input:     interaction.UserInput,
+			intent:    interaction.Intent,
+			latency:   interaction.Latency,
+		}
+		if interaction.Feedback != nil {
+			sample.rating = interaction.Feedback.Rating
+		}
+		samples = append(samples, sample)
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].timestamp.Before(samples[j].timestamp)
+	})
+
+	patterns := make(map[string]Pattern, len(e.kb.Patterns))
+	for key, pattern := range e.kb.Patterns {
+		patterns[key] = *pattern
+	}
+
+	return samples, patterns
+}
+
+// windowStats calcula la distribución de intenciones y la tendencia de ratings por ventana
+func windowStats(samples []analyticsSample, start time.Time, window time.Duration) ([]IntentWindow, map[string][]RatingPoint) {
+	type ratingAcc struct {
+		sum   int
+		count int
+	}
+
+	var windows []IntentWindow
+	ratings := make(map[string]map[int]*ratingAcc)
+
+	for _, s := range samples {
+		idx := int(s.timestamp.Sub(start) / window)
+		if idx < 0 {
+			idx = 0
+		}
+		for len(windows) <= idx {
+			wStart := start.Add(time.Duration(len(windows)) * window)
+			windows = append(windows, IntentWindow{
+				Start:  wStart,
+				End:    wStart.Add(window),
+				Counts: make(map[string]int),
+			})
+		}
+		windows[idx].Counts[s.intent]++
+		windows[idx].Total++
+
+		if s.rating > 0 {
+			if ratings[s.intent] == nil {
+				ratings[s.intent] = make(map[int]*ratingAcc)
+			}
+			acc := ratings[s.intent][idx]
+			if acc == nil {
+				acc = &ratingAcc{}
+				ratings[s.intent][idx] = acc
+			}
+			acc.sum += s.rating
+			acc.count++
+		}
+	}
+
+	trends := make(map[string][]RatingPoint, len(ratings))
+	for intent, byWindow := range ratings {
+		indexes := make([]int, 0, len(byWindow))
+		for idx := range byWindow {
+			indexes = append(indexes, idx)
+		}
+		sort.Ints(indexes)
+
+		for _, idx := range indexes {
+			acc := byWindow[idx]
+			trends[intent] = append(trends[intent], RatingPoint{
+				Start:         windows[idx].Start,
+				End:           windows[idx].End,
+				AverageRating: float64(acc.sum) / float64(acc.count),
+				Ratings:       acc.count,
+			})
+		}
+	}
+
+	return windows, trends
+}
+
+// lowestRatedPatterns ordena los patrones por su rating promedio ascendente
+func lowestRatedPatterns(samples []analyticsSample, patterns map[string]Pattern, limit int) []PatternRating {
+	sums := make(map[string]int)
+	counts := make(map[string]int)
+	for _, s := range samples {
+		if s.rating > 0 {
+			sums[s.intent] += s.rating
+			counts[s.intent]++
+		}
+	}
+
+	var result []PatternRating
+	for key, pattern := range patterns {
+		if counts[key] == 0 {
+			continue
+		}
+		result = append(result, PatternRating{
+			Key:           key,
+			Pattern:       pattern.Pattern,
+			AverageRating: float64(sums[key]) / float64(counts[key]),
+			Ratings:       counts[key],
+			Confidence:    pattern.Confidence,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].AverageRating != result[j].AverageRating {
+			return result[i].AverageRating < result[j].AverageRating
+		}
+		if result[i].Ratings != result[j].Ratings {
+			return result[i].Ratings > result[j].Ratings
+		}
+		return result[i].Key < result[j].Key
+	})
+
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// latencyStats calcula percentiles por rango más cercano
+func latencyStats(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		return durationMs(sorted[rank])
+	}
+
+	return LatencyStats{
+		Count: len(sorted),
+		P50Ms: percentile(50),
+		P90Ms: percentile(90),
+		P95Ms: percentile(95),
+		P99Ms: percentile(99),
+		MaxMs: durationMs(sorted[len(sorted)-1]),
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// clusterFailures agrupa las entradas mal valoradas con embeddings o, si no hay, léxicamente
+func clusterFailures(ctx context.Context, failures []analyticsSample, opts AnalyticsOptions) ([]FailureCluster, string, error) {
+	if len(failures) == 0 {
+		return nil, "", nil
+	}
+
+	inputs := make([]string, len(failures))
+	for i, f := range failures {
+		inputs[i] = f.input
+	}
+
+	var groups [][]int
+	method := "lexical"
+
+	if opts.Embedder != nil {
+		vectors, err := opts.Embedder.Embed(ctx, inputs)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, "", ctx.Err()
+			}
+			// Sin embeddings se recurre al agrupador léxico
+			vectors = nil
+		}
+		if vectors != nil {
+			threshold := opts.ClusterThreshold
+			if threshold <= 0 {
+				threshold = 0.8
+			}
+			groups = clusterByEmbedding(vectors, threshold)
+			method = "embeddings"
+		}
+	}
+
+	if groups == nil {
+		threshold := opts.ClusterThreshold
+		if threshold <= 0 {
+			threshold = 0.3
+		}
+		groups = clusterLexical(inputs, threshold)
+	}
+
+	clusters := make([]FailureCluster, 0, len(groups))
+	for _, members := range groups {
+		cluster := FailureCluster{
+			Size:    len(members),
+			Intents: make(map[string]int),
+		}
+		ratingSum := 0
+		var memberInputs []string
+		for _, idx := range members {
+			f := failures[idx]
+			cluster.Inputs = append(cluster.Inputs, f.input)
+			cluster.Intents[f.intent]++
+			ratingSum += f.rating
+			memberInputs = append(memberInputs, f.input)
+		}
+		cluster.AverageRating = float64(ratingSum) / float64(len(members))
+		cluster.Label = clusterLabel(memberInputs)
+		clusters = append(clusters, cluster)
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if clusters[i].Size != clusters[j].Size {
+			return clusters[i].Size > clusters[j].Size
+		}
+		return clusters[i].AverageRating < clusters[j].AverageRating
+	})
+
+	return clusters, method, nil
+}
+
+// clusterByEmbedding agrupa vectores por similitud coseno con el centroide de cada grupo
+func clusterByEmbedding(vectors [][]float64, threshold float64) [][]int {
+	var groups [][]int
+	var centroids [][]float64
+
+	for i, v := range vectors {
+		best, bestSim := -1, threshold
+		for g, c := range centroids {
+			if sim := cosine(v, c); sim >= bestSim {
+				best, bestSim = g, sim
+			}
+		}
+
+		if best < 0 {
+			groups = append(groups, []int{i})
+			centroids = append(centroids, append([]float64(nil), v...))
+			continue
+		}
+
+		groups[best] = append(groups[best], i)
+		n := float64(len(groups[best]))
+		for k := range centroids[best] {
+			if k < len(v) {
+				centroids[best][k] += (v[k] - centroids[best][k]) / n
+			}
+		}
+	}
+
+	return groups
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := 0; i < len(a) && i < len(b); i++ {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// clusterLexical agrupa textos por similitud de Jaccard entre sus términos
+func clusterLexical(inputs []string, threshold float64) [][]int {
+	var groups [][]int
+	var terms []map[string]bool
+
+	for i, input := range inputs {
+		tokens := tokenSet(input)
+
+		best, bestSim := -1, threshold
+		for g, groupTerms := range terms {
+			if sim := jaccard(tokens, groupTerms); sim >= bestSim {
+				best, bestSim = g, sim
+			}
+		}
+
+		if best < 0 {
+			groups = append(groups, []int{i})
+			terms = append(terms, tokens)
+			continue
+		}
+
+		groups[best] = append(groups[best], i)
+		for t := range tokens {
+			terms[best][t] = true
+		}
+	}
+
+	return groups
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for t := range a {
+		if b[t] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// stopwords contiene palabras vacías en español que no aportan al tema
+var stopwords = map[string]bool{
+	"que": true, "como": true, "con": true, "para": true, "por": true, "una": true,
+	"los": true, "las": true, "del": true, "el": true, "la": true, "de": true,
+	"en": true, "un": true, "es": true, "se": true, "no": true, "me": true,
+	"mi": true, "lo": true, "al": true, "le": true, "su": true, "y": true,
+	"o": true, "a": true, "qué": true, "cómo": true, "puedo": true, "hay": true,
+	"esto": true, "eso": true, "este": true, "esta": true, "the": true, "and": true,
+}
+
+// tokenize divide un texto en términos en minúsculas sin palabras vacías
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := fields[:0]
+	for _, f := range fields {
+		if len([]rune(f)) < 3 || stopwords[f] {
+			continue
+		}
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
+
+func tokenSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, t := range tokenize(text) {
+		set[t] = true
+	}
+	return set
+}
+
+// clusterLabel usa los términos más frecuentes del grupo como etiqueta
+func clusterLabel(inputs []string) string {
+	freq := make(map[string]int)
+	for _, input := range inputs {
+		for t := range tokenSet(input) {
+			freq[t]++
+		}
+	}
+
+	terms := make([]string, 0, len(freq))
+	for t := range freq {
+		terms = append(terms, t)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if freq[terms[i]] != freq[terms[j]] {
+			return freq[terms[i]] > freq[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+
+	if len(terms) > 3 {
+		terms = terms[:3]
+	}
+	return strings.Join(terms, " ")
+}
+
+// WriteJSON exporta el informe en formato JSON
+func (a *Analytics) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(a)
+}
+
+// WriteCSV exporta el informe en formato CSV largo:
+// section,window_start,window_end,key,metric,value
+func (a *Analytics) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	rows := [][]string{{"section", "window_start", "window_end", "key", "metric", "value"}}
+
+	for _, window := range a.IntentDistribution {
+		for _, intent := range sortedKeys(window.Counts) {
+			rows = append(rows, []string{"intent_distribution", formatTime(window.Start), formatTime(window.End), intent, "count", strconv.Itoa(window.Counts[intent])})
+		}
+	}
+
+	for _, intent := range sortedKeys(a.RatingTrends) {
+		for _, point := range a.RatingTrends[intent] {
+			rows = append(rows,
+				[]string{"rating_trend", formatTime(point.Start), formatTime(point.End), intent, "average_rating", formatFloat(point.AverageRating)},
+				[]string{"rating_trend", formatTime(point.Start), formatTime(point.End), intent, "ratings", strconv.Itoa(point.Ratings)},
+			)
+		}
+	}
+
+	for _, pattern := range a.LowestRatedPatterns {
+		rows = append(rows,
+			[]string{"lowest_rated_pattern", "", "", pattern.Key, "average_rating", formatFloat(pattern.AverageRating)},
+			[]string{"lowest_rated_pattern", "", "", pattern.Key, "ratings", strconv.Itoa(pattern.Ratings)},
+			[]string{"lowest_rated_pattern", "", "", pattern.Key, "confidence", formatFloat(pattern.Confidence)},
+		)
+	}
+
+	latencyRows := func(key string, stats LatencyStats) {
+		rows = append(rows,
+			[]string{"latency", formatTime(a.Since), formatTime(a.Until), key, "count", strconv.Itoa(stats.Count)},
+			[]string{"latency", formatTime(a.Since), formatTime(a.Until), key, "p50_ms", formatFloat(stats.P50Ms)},
+			[]string{"latency", formatTime(a.Since), formatTime(a.Until), key, "p90_ms", formatFloat(stats.P90Ms)},
+			[]string{"latency", formatTime(a.Since), formatTime(a.Until), key, "p95_ms", formatFloat(stats.P95Ms)},
+			[]string{"latency", formatTime(a.Since), formatTime(a.Until), key, "p99_ms", formatFloat(stats.P99Ms)},
+			[]string{"latency", formatTime(a.Since), formatTime(a.Until), key, "max_ms", formatFloat(stats.MaxMs)},
+		)
+	}
+	latencyRows("*", a.Latency)
+	for _, intent := range sortedKeys(a.LatencyByIntent) {
+		latencyRows(intent, a.LatencyByIntent[intent])
+	}
+
+	for _, cluster := range a.FailureClusters {
+		rows = append(rows,
+			[]string{"failure_cluster", "", "", cluster.Label, "size", strconv.Itoa(cluster.Size)},
+			[]string{"failure_cluster", "", "", cluster.Label, "average_rating", formatFloat(cluster.AverageRating)},
+		)
+	}
+
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("error escribiendo CSV: %w", err)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
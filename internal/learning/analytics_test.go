@@ -0,0 +1,263 @@
+package learning_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/internal/learning"
+)
+
+var base = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+// newEngine crea un motor con el reloj fijado en base
+func newEngine() *learning.Engine {
+	return learning.NewEngine(learning.Config{LearningRate: 0.1}, learning.WithClock(func() time.Time { return base }))
+}
+
+// record registra una interacción en base+offset y, si rating > 0, la valora
+func record(t *testing.T, engine *learning.Engine, offset time.Duration, intent, input string, latency time.Duration, rating int) {
+	t.Helper()
+	interaction := &learning.Interaction{
+		Timestamp: base.Add(offset),
+		UserInput: input,
+		Intent:    intent,
+		Latency:   latency,
+	}
+	engine.RecordInteraction(interaction)
+	if rating > 0 {
+		if err := engine.AddFeedback(interaction.ID, rating, ""); err != nil {
+			t.Fatalf("AddFeedback: %v", err)
+		}
+	}
+}
+
+func TestAnalyticsLatency(t *testing.T) {
+	engine := newEngine()
+	for i := 1; i <= 100; i++ {
+		intent := "consulta"
+		if i%10 == 0 {
+			intent = "saludo"
+		}
+		record(t, engine, time.Duration(i)*time.Second, intent, "hola", time.Duration(i)*time.Millisecond, 0)
+	}
+	// Las interacciones sin latencia no cuentan
+	record(t, engine, 0, "saludo", "hola", 0, 0)
+
+	report, err := engine.Analytics(context.Background(), learning.AnalyticsOptions{})
+	if err != nil {
+		t.Fatalf("Analytics: %v", err)
+	}
+	if !report.GeneratedAt.Equal(base) {
+		t.Errorf("GeneratedAt = %v, want el reloj inyectado %v", report.GeneratedAt, base)
+	}
+	want := learning.LatencyStats{Count: 100, P50Ms: 50, P90Ms: 90, P95Ms: 95, P99Ms: 99, MaxMs: 100}
+	if report.Latency != want {
+		t.Errorf("latencia = %+v, want %+v", report.Latency, want)
+	}
+	want = learning.LatencyStats{Count: 10, P50Ms: 50, P90Ms: 90, P95Ms: 100, P99Ms: 100, MaxMs: 100}
+	if got := report.LatencyByIntent["saludo"]; got != want {
+		t.Errorf("latencia de saludo = %+v, want %+v", got, want)
+	}
+
+	single := newEngine()
+	record(t, single, 0, "saludo", "hola", 7*time.Millisecond, 0)
+	report, _ = single.Analytics(context.Background(), learning.AnalyticsOptions{})
+	want = learning.LatencyStats{Count: 1, P50Ms: 7, P90Ms: 7, P95Ms: 7, P99Ms: 7, MaxMs: 7}
+	if report.Latency != want {
+		t.Errorf("latencia de una muestra = %+v, want %+v", report.Latency, want)
+	}
+}
+
+func TestAnalyticsWindows(t *testing.T) {
+	engine := newEngine()
+	record(t, engine, 10*time.Minute, "saludo", "hola", 0, 5)
+	record(t, engine, 20*time.Minute, "vpn", "la vpn no conecta", 0, 1)
+	record(t, engine, 25*time.Minute, "vpn", "vpn caída", 0, 3)
+	record(t, engine, 2*time.Hour+30*time.Minute, "vpn", "vpn lenta", 0, 5)
+
+	report, err := engine.Analytics(context.Background(), learning.AnalyticsOptions{Window: time.Hour})
+	if err != nil {
+		t.Fatalf("Analytics: %v", err)
+	}
+	if report.TotalInteractions != 4 || report.WindowSeconds != 3600 {
+		t.Errorf("total = %d, ventana = %ds", report.TotalInteractions, report.WindowSeconds)
+	}
+	if !report.Since.Equal(base) || !report.Until.Equal(base.Add(150*time.Minute)) {
+		t.Errorf("rango = %v - %v", report.Since, report.Until)
+	}
+
+	// La ventana vacía intermedia también se reporta
+	windows := report.IntentDistribution
+	if len(windows) != 3 {
+		t.Fatalf("ventanas = %d, want 3", len(windows))
+	}
+	totals := []int{windows[0].Total, windows[1].Total, windows[2].Total}
+	if totals[0] != 3 || totals[1] != 0 || totals[2] != 1 || windows[0].Counts["vpn"] != 2 {
+		t.Errorf("totales = %v, primera ventana = %v", totals, windows[0].Counts)
+	}
+	if !windows[2].Start.Equal(base.Add(2*time.Hour)) || !windows[2].End.Equal(base.Add(3*time.Hour)) {
+		t.Errorf("tercera ventana = %v - %v", windows[2].Start, windows[2].End)
+	}
+
+	// Tendencia: rating medio por ventana, solo en las ventanas con valoraciones
+	trend := report.RatingTrends["vpn"]
+	if len(trend) != 2 || trend[0].AverageRating != 2 || trend[0].Ratings != 2 || trend[1].AverageRating != 5 || !trend[1].Start.Equal(windows[2].Start) {
+		t.Errorf("tendencia de vpn = %+v", trend)
+	}
+
+	// Peor valorados primero
+	lowest := report.LowestRatedPatterns
+	if len(lowest) != 2 || lowest[0].Key != "vpn" || lowest[0].Ratings != 3 || lowest[0].Pattern != "la vpn no conecta" || lowest[1].Key != "saludo" {
+		t.Errorf("patrones peor valorados = %+v", lowest)
+	}
+	report, _ = engine.Analytics(context.Background(), learning.AnalyticsOptions{Window: time.Hour, LowestPatterns: 1})
+	if len(report.LowestRatedPatterns) != 1 {
+		t.Errorf("LowestPatterns 1 devolvió %d patrones", len(report.LowestRatedPatterns))
+	}
+
+	// Since y Until acotan el rango y Since fija el inicio de las ventanas
+	report, err = engine.Analytics(context.Background(), learning.AnalyticsOptions{
+		Since:  base.Add(15 * time.Minute),
+		Until:  base.Add(2 * time.Hour),
+		Window: 30 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Analytics: %v", err)
+	}
+	if report.TotalInteractions != 2 || len(report.IntentDistribution) != 1 || !report.IntentDistribution[0].Start.Equal(base.Add(15*time.Minute)) {
+		t.Errorf("rango acotado = %d interacciones en %+v", report.TotalInteractions, report.IntentDistribution)
+	}
+
+	empty, err := newEngine().Analytics(context.Background(), learning.AnalyticsOptions{})
+	if err != nil || empty.TotalInteractions != 0 || len(empty.IntentDistribution) != 0 {
+		t.Errorf("sin interacciones = %+v, %v", empty, err)
+	}
+}
+
+// Una ventana diminuta sobre un rango largo se rechaza en vez de reservar
+// millones de ventanas
+func TestAnalyticsWindowLimit(t *testing.T) {
+	engine := newEngine()
+	record(t, engine, 0, "saludo", "hola", 0, 0)
+	record(t, engine, time.Hour, "saludo", "hola", 0, 0)
+
+	if _, err := engine.Analytics(context.Background(), learning.AnalyticsOptions{Window: time.Millisecond}); err == nil {
+		t.Error("3.600.001 ventanas aceptadas")
+	}
+	if _, err := engine.Analytics(context.Background(), learning.AnalyticsOptions{Window: time.Second}); err != nil {
+		t.Errorf("3.601 ventanas rechazadas: %v", err)
+	}
+}
+
+// fakeEmbedder devuelve un vector por texto según su primera palabra clave
+type fakeEmbedder struct {
+	err error
+}
+
+func (f fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		if strings.Contains(text, "vpn") || strings.Contains(text, "red") {
+			vectors[i] = []float64{1, 0.1}
+		} else {
+			vectors[i] = []float64{0, 1}
+		}
+	}
+	return vectors, nil
+}
+
+func TestAnalyticsClusters(t *testing.T) {
+	engine := newEngine()
+	record(t, engine, 0, "vpn", "no funciona la vpn", 0, 1)
+	record(t, engine, time.Minute, "vpn", "la vpn no conecta", 0, 2)
+	record(t, engine, 2*time.Minute, "soporte", "error impresora atascada", 0, 1)
+	record(t, engine, 3*time.Minute, "soporte", "sin red en la oficina", 0, 2)
+	// Las bien valoradas y las no valoradas no son fallos
+	record(t, engine, 4*time.Minute, "vpn", "la vpn va genial", 0, 5)
+	record(t, engine, 5*time.Minute, "vpn", "vpn rota otra vez", 0, 0)
+
+	tests := []struct {
+		name     string
+		embedder learning.Embedder
+		method   string
+		sizes    []int
+		label    string
+	}{
+		{"lexical", nil, "lexical", []int{2, 1, 1}, "vpn conecta funciona"},
+		{"embeddings", fakeEmbedder{}, "embeddings", []int{3, 1}, "vpn conecta funciona"},
+		{"embeddings fail", fakeEmbedder{err: errors.New("sin modelo")}, "lexical", []int{2, 1, 1}, "vpn conecta funciona"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := engine.Analytics(context.Background(), learning.AnalyticsOptions{Embedder: tt.embedder})
+			if err != nil {
+				t.Fatalf("Analytics: %v", err)
+			}
+			if report.ClusterMethod != tt.method {
+				t.Errorf("método = %q, want %q", report.ClusterMethod, tt.method)
+			}
+			var sizes []int
+			for _, cluster := range report.FailureClusters {
+				sizes = append(sizes, cluster.Size)
+			}
+			if len(sizes) != len(tt.sizes) {
+				t.Fatalf("grupos = %v, want %v", sizes, tt.sizes)
+			}
+			for i := range sizes {
+				if sizes[i] != tt.sizes[i] {
+					t.Fatalf("grupos = %v, want %v", sizes, tt.sizes)
+				}
+			}
+			first := report.FailureClusters[0]
+			if tt.method == "lexical" && (first.Label != tt.label || first.AverageRating != 1.5 || first.Intents["vpn"] != 2) {
+				t.Errorf("primer grupo = %+v, want etiqueta %q y rating 1.5", first, tt.label)
+			}
+		})
+	}
+
+	// Un umbral de 1 exige textos con los mismos términos
+	report, _ := engine.Analytics(context.Background(), learning.AnalyticsOptions{ClusterThreshold: 1})
+	if len(report.FailureClusters) != 4 {
+		t.Errorf("con umbral 1 hay %d grupos, want 4", len(report.FailureClusters))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := engine.Analytics(ctx, learning.AnalyticsOptions{Embedder: fakeEmbedder{err: context.Canceled}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("con el contexto cancelado = %v, want context.Canceled", err)
+	}
+}
+
+func TestAnalyticsCSV(t *testing.T) {
+	engine := newEngine()
+	record(t, engine, 0, "saludo", "hola", 5*time.Millisecond, 1)
+
+	report, err := engine.Analytics(context.Background(), learning.AnalyticsOptions{})
+	if err != nil {
+		t.Fatalf("Analytics: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "section,window_start,window_end,key,metric,value" {
+		t.Errorf("cabecera = %q", lines[0])
+	}
+	for _, want := range []string{
+		"intent_distribution,2024-03-01T00:00:00Z,2024-03-02T00:00:00Z,saludo,count,1",
+		"latency,2024-03-01T00:00:00Z,2024-03-01T10:00:00Z,*,p50_ms,5",
+	} {
+		if !strings.Contains(buf.String(), want+"\n") {
+			t.Errorf("falta la fila %q en:\n%s", want, buf.String())
+		}
+	}
+}
@@ -16,6 +16,7 @@ type Interaction struct {
 	Intent    string                 `json:"intent"`
 	Feedback  *Feedback              `json:"feedback,omitempty"`
 	Context   map[string]interface{} `json:"context"`
+	Latency   time.Duration          `json:"latency,omitempty"` // Tiempo total hasta la respuesta
 }
 
 // Feedback representa retroalimentación del usuario
@@ -40,6 +41,11 @@ type KnowledgeBase struct {
 	Interactions []*Interaction      `json:"interactions"`
 	Stats        *Stats              `json:"stats"`
 	mu           sync.RWMutex
+
+	// Índices en memoria reconstruidos tras Import
+	byID        map[string]*Interaction
+	ratingSum   int
+	ratingCount int
 }
 
 // Stats contiene estadísticas del agente
@@ -47,7 +53,7 @@ type Stats struct {
 	TotalInteractions int       `json:"total_interactions"`
 	PositiveFeedback  int       `json:"positive_feedback"`
 	NegativeFeedback  int       `json:"negative_feedback"`
-	AverageRating     float64   `json:"average_rating"`
+	AverageRating     float64   `json:"average_rating"` // De las interacciones conservadas en memoria
 	LastUpdated       time.Time `json:"last_updated"`
 }
 
@@ -55,49 +61,119 @@ type Stats struct {
 type Config struct {
 	LearningRate        float64
 	ConfidenceThreshold float64
-	MaxInteractions     int
-	SaveInterval        int
+	MaxInteractions     int // Interacciones que se conservan en memoria (por defecto 1000)
+	SaveInterval        int // Interacciones o valoraciones entre guardados de patrones (por defecto 1)
 }
 
 // Engine maneja el aprendizaje del agente
 type Engine struct {
-	kb     *KnowledgeBase
-	config Config
+	kb      *KnowledgeBase
+	config  Config
+	now     Clock
+	newID   IDGenerator
+	metrics *engineMetrics
+
+	// Patrones cambiados desde el último PendingPatterns y cambios acumulados
+	// desde entonces (protegidos por kb.mu)
+	dirty   map[string]bool
+	changes int
+}
+
+// Option configura dependencias opcionales del motor
+type Option func(*Engine)
+
+// WithClock sustituye el reloj usado para timestamps (útil en tests)
+func WithClock(clock Clock) Option {
+	return func(e *Engine) {
+		e.now = clock
+	}
+}
+
+// WithIDGenerator sustituye el generador de IDs de interacciones
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(e *Engine) {
+		e.newID = gen
+	}
 }
 
 // NewEngine crea una nueva instancia del motor de aprendizaje
-func NewEngine(config Config) *Engine {
-	return &Engine{
-		kb: &KnowledgeBase{
-			Patterns:     make(map[string]*Pattern),
-			Interactions: make([]*Interaction, 0),
-			Stats: &Stats{
-				LastUpdated: time.Now(),
-			},
-		},
+func NewEngine(config Config, opts ...Option) *Engine {
+	if config.MaxInteractions <= 0 {
+		config.MaxInteractions = 1000
+	}
+	if config.SaveInterval <= 0 {
+		config.SaveInterval = 1
+	}
+	e := &Engine{
 		config: config,
+		now:    time.Now,
+		dirty:  make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.newID == nil {
+		e.newID = NewULIDGenerator(e.now)
+	}
+	if e.metrics == nil {
+		e.metrics = newEngineMetrics(nil)
 	}
+
+	e.kb = &KnowledgeBase{
+		Patterns:     make(map[string]*Pattern),
+		Interactions: make([]*Interaction, 0),
+		Stats: &Stats{
+			LastUpdated: e.now(),
+		},
+		byID: make(map[string]*Interaction),
+	}
+
+	return e
 }
 
-// RecordInteraction registra una nueva interacción
+// RecordInteraction registra una nueva interacción.
+// El ID y el timestamp solo se generan si el llamador no los ha fijado,
+// de modo que las interacciones importadas conservan su identidad.
 func (e *Engine) RecordInteraction(interaction *Interaction) {
 	e.kb.mu.Lock()
 	defer e.kb.mu.Unlock()
 
-	interaction.ID = fmt.Sprintf("int_%d", time.Now().UnixNano())
-	interaction.Timestamp = time.Now()
+	if interaction.ID == "" {
+		interaction.ID = e.newID()
+	}
+	if interaction.Timestamp.IsZero() {
+		interaction.Timestamp = e.now()
+	}
 
 	e.kb.Interactions = append(e.kb.Interactions, interaction)
+	e.kb.byID[interaction.ID] = interaction
 	e.kb.Stats.TotalInteractions++
-	e.kb.Stats.LastUpdated = time.Now()
+	e.kb.Stats.LastUpdated = e.now()
 
-	// Limitar el número de interacciones almacenadas
+	// Limitar el número de interacciones almacenadas. El rating promedio es el
+	// de las interacciones conservadas, como al reconstruirlo en Import, así
+	// que se descuentan las valoraciones de las descartadas.
 	if len(e.kb.Interactions) > e.config.MaxInteractions {
-		e.kb.Interactions = e.kb.Interactions[len(e.kb.Interactions)-e.config.MaxInteractions:]
+		excess := len(e.kb.Interactions) - e.config.MaxInteractions
+		for _, old := range e.kb.Interactions[:excess] {
+			if e.kb.byID[old.ID] == old {
+				delete(e.kb.byID, old.ID)
+			}
+			if old.Feedback != nil {
+				e.kb.ratingSum -= old.Feedback.Rating
+				e.kb.ratingCount--
+			}
+		}
+		e.kb.Interactions = e.kb.Interactions[excess:]
+		e.kb.Stats.AverageRating = 0
+		if e.kb.ratingCount > 0 {
+			e.kb.Stats.AverageRating = float64(e.kb.ratingSum) / float64(e.kb.ratingCount)
+		}
 	}
 
 	// Intentar extraer un patrón
 	e.learnPattern(interaction)
+	e.changes++
 }
 
 // learnPattern intenta aprender un patrón de la interacción
@@ -107,19 +183,21 @@ func (e *Engine) learnPattern(interaction *Interaction) {
 
 	if pattern, exists := e.kb.Patterns[patternKey]; exists {
 		pattern.Frequency++
-		pattern.LastUsed = time.Now()
+		pattern.LastUsed = e.now()
 		// Ajustar confianza basado en feedback
 		if interaction.Feedback != nil && interaction.Feedback.Rating >= 4 {
 			pattern.Confidence = min(1.0, pattern.Confidence+e.config.LearningRate)
 		}
+		e.dirty[patternKey] = true
 	} else {
 		e.kb.Patterns[patternKey] = &Pattern{
 			Pattern:    interaction.UserInput,
 			Response:   interaction.Response,
 			Frequency:  1,
 			Confidence: 0.5,
-			LastUsed:   time.Now(),
+			LastUsed:   e.now(),
 		}
+		e.dirty[patternKey] = true
 	}
 }
 
@@ -128,39 +206,50 @@ func (e *Engine) AddFeedback(interactionID string, rating int, comment string) e
 	e.kb.mu.Lock()
 	defer e.kb.mu.Unlock()
 
-	for _, interaction := range e.kb.Interactions {
-		if interaction.ID == interactionID {
-			interaction.Feedback = &Feedback{
-				Rating:    rating,
-				Comment:   comment,
-				Timestamp: time.Now(),
-			}
+	interaction, exists := e.kb.byID[interactionID]
+	if !exists {
+		return fmt.Errorf("interacción no encontrada: %s", interactionID)
+	}
 
-			// Actualizar estadísticas
-			if rating >= 4 {
-				e.kb.Stats.PositiveFeedback++
-			} else if rating <= 2 {
-				e.kb.Stats.NegativeFeedback++
-			}
+	// Una valoración positiva refuerza el patrón de su intención, una sola vez
+	// aunque se vuelva a valorar
+	previous := interaction.Feedback
+	if pattern, ok := e.kb.Patterns[interaction.Intent]; ok && rating >= 4 && (previous == nil || previous.Rating < 4) {
+		pattern.Confidence = min(1.0, pattern.Confidence+e.config.LearningRate)
+		e.dirty[interaction.Intent] = true
+	}
+	e.changes++
+
+	// Si ya tenía feedback, descontarlo antes de aplicar el nuevo
+	if previous != nil {
+		e.kb.ratingSum -= previous.Rating
+		e.kb.ratingCount--
+		if previous.Rating >= 4 {
+			e.kb.Stats.PositiveFeedback--
+		} else if previous.Rating <= 2 {
+			e.kb.Stats.NegativeFeedback--
+		}
+	}
 
-			// Recalcular rating promedio
-			totalRatings := 0.0
-			count := 0
-			for _, inter := range e.kb.Interactions {
-				if inter.Feedback != nil {
-					totalRatings += float64(inter.Feedback.Rating)
-					count++
-				}
-			}
-			if count > 0 {
-				e.kb.Stats.AverageRating = totalRatings / float64(count)
-			}
+	interaction.Feedback = &Feedback{
+		Rating:    rating,
+		Comment:   comment,
+		Timestamp: e.now(),
+	}
 
-			return nil
-		}
+	// Actualizar estadísticas
+	if rating >= 4 {
+		e.kb.Stats.PositiveFeedback++
+	} else if rating <= 2 {
+		e.kb.Stats.NegativeFeedback++
 	}
 
-	return fmt.Errorf("interacción no encontrada: %s", interactionID)
+	// Actualizar rating promedio
+	e.kb.ratingSum += rating
+	e.kb.ratingCount++
+	e.kb.Stats.AverageRating = float64(e.kb.ratingSum) / float64(e.kb.ratingCount)
+
+	return nil
 }
 
 // FindSimilarPattern busca un patrón similar en la base de conocimiento
@@ -170,23 +259,86 @@ func (e *Engine) FindSimilarPattern(intent string) (*Pattern, bool) {
 
 	pattern, exists := e.kb.Patterns[intent]
 	if !exists {
+		e.metrics.patternLookups.Add(1, "miss")
 		return nil, false
 	}
 
 	// Solo retornar si la confianza supera el umbral
 	if pattern.Confidence < e.config.ConfidenceThreshold {
+		e.metrics.patternLookups.Add(1, "miss")
 		return nil, false
 	}
 
+	e.metrics.patternLookups.Add(1, "hit")
 	return pattern, true
 }
 
-// GetStats obtiene las estadísticas actuales
+// GetStats obtiene una copia de las estadísticas actuales
 func (e *Engine) GetStats() *Stats {
 	e.kb.mu.RLock()
 	defer e.kb.mu.RUnlock()
 
-	return e.kb.Stats
+	stats := *e.kb.Stats
+	return &stats
+}
+
+// GetPatterns devuelve una copia de los patrones aprendidos por clave
+func (e *Engine) GetPatterns() map[string]Pattern {
+	e.kb.mu.RLock()
+	defer e.kb.mu.RUnlock()
+
+	patterns := make(map[string]Pattern, len(e.kb.Patterns))
+	for key, pattern := range e.kb.Patterns {
+		patterns[key] = *pattern
+	}
+	return patterns
+}
+
+// LoadPatterns reemplaza los patrones aprendidos por los guardados en una
+// ejecución anterior; no quedan pendientes de guardar
+func (e *Engine) LoadPatterns(patterns map[string]*Pattern) {
+	e.kb.mu.Lock()
+	defer e.kb.mu.Unlock()
+
+	e.kb.Patterns = make(map[string]*Pattern, len(patterns))
+	for key, pattern := range patterns {
+		copied := *pattern
+		e.kb.Patterns[key] = &copied
+	}
+	e.dirty = make(map[string]bool)
+	e.changes = 0
+}
+
+// PendingPatterns devuelve una copia de los patrones cambiados desde la última
+// llamada que los devolvió, si desde entonces ha habido SaveInterval
+// interacciones o valoraciones o force es true. Si no toca guardar devuelve nil
+// y los cambios siguen pendientes.
+func (e *Engine) PendingPatterns(force bool) map[string]Pattern {
+	e.kb.mu.Lock()
+	defer e.kb.mu.Unlock()
+
+	if len(e.dirty) == 0 || (!force && e.changes < e.config.SaveInterval) {
+		return nil
+	}
+	patterns := make(map[string]Pattern, len(e.dirty))
+	for key := range e.dirty {
+		if pattern, ok := e.kb.Patterns[key]; ok {
+			patterns[key] = *pattern
+		}
+	}
+	e.dirty = make(map[string]bool)
+	e.changes = 0
+	return patterns
+}
+
+// MarkPending vuelve a marcar como pendientes patrones que no se pudieron guardar
+func (e *Engine) MarkPending(keys ...string) {
+	e.kb.mu.Lock()
+	defer e.kb.mu.Unlock()
+
+	for _, key := range keys {
+		e.dirty[key] = true
+	}
 }
 
 // GetRecentInteractions obtiene las N interacciones más recientes
@@ -214,7 +366,34 @@ func (e *Engine) Import(data []byte) error {
 	e.kb.mu.Lock()
 	defer e.kb.mu.Unlock()
 
-	return json.Unmarshal(data, e.kb)
+	if err := json.Unmarshal(data, e.kb); err != nil {
+		return err
+	}
+
+	e.kb.reindex()
+	return nil
+}
+
+// reindex reconstruye los índices en memoria a partir de las interacciones
+func (kb *KnowledgeBase) reindex() {
+	kb.byID = make(map[string]*Interaction, len(kb.Interactions))
+	kb.ratingSum = 0
+	kb.ratingCount = 0
+
+	for _, interaction := range kb.Interactions {
+		kb.byID[interaction.ID] = interaction
+		if interaction.Feedback != nil {
+			kb.ratingSum += interaction.Feedback.Rating
+			kb.ratingCount++
+		}
+	}
+
+	if kb.Patterns == nil {
+		kb.Patterns = make(map[string]*Pattern)
+	}
+	if kb.Stats == nil {
+		kb.Stats = &Stats{}
+	}
 }
 
 func min(a, b float64) float64 {
@@ -0,0 +1,112 @@
+package learning_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/internal/learning"
+)
+
+// El rating promedio es el de las interacciones conservadas: las descartadas
+// por MaxInteractions dejan de contar, igual que al reconstruirlo en Import
+func TestAverageRatingEviction(t *testing.T) {
+	engine := learning.NewEngine(learning.Config{MaxInteractions: 2}, learning.WithClock(func() time.Time { return base }))
+	ids := make([]string, 3)
+	for i, rating := range []int{1, 5, 3} {
+		interaction := &learning.Interaction{UserInput: "hola", Intent: "saludo"}
+		engine.RecordInteraction(interaction)
+		ids[i] = interaction.ID
+		if err := engine.AddFeedback(interaction.ID, rating, ""); err != nil {
+			t.Fatalf("AddFeedback: %v", err)
+		}
+	}
+
+	stats := engine.GetStats()
+	if stats.AverageRating != 4 || stats.TotalInteractions != 3 || stats.PositiveFeedback != 1 || stats.NegativeFeedback != 1 {
+		t.Errorf("tras descartar la primera = %+v, want promedio 4 de las dos conservadas", stats)
+	}
+	if err := engine.AddFeedback(ids[0], 5, ""); err == nil {
+		t.Error("se valoró una interacción descartada")
+	}
+
+	// Revalorar descuenta la valoración anterior
+	if err := engine.AddFeedback(ids[1], 1, ""); err != nil {
+		t.Fatalf("AddFeedback: %v", err)
+	}
+	if stats := engine.GetStats(); stats.AverageRating != 2 || stats.PositiveFeedback != 0 || stats.NegativeFeedback != 2 {
+		t.Errorf("tras revalorar = %+v, want promedio 2", stats)
+	}
+
+	// Un motor importado sigue la misma cuenta
+	data, err := engine.Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	imported := learning.NewEngine(learning.Config{MaxInteractions: 2})
+	if err := imported.Import(data); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	for _, e := range []*learning.Engine{engine, imported} {
+		if err := e.AddFeedback(ids[2], 4, ""); err != nil {
+			t.Fatalf("AddFeedback: %v", err)
+		}
+		e.RecordInteraction(&learning.Interaction{UserInput: "adiós", Intent: "despedida"})
+	}
+	if live, restored := engine.GetStats().AverageRating, imported.GetStats().AverageRating; live != 4 || restored != live {
+		t.Errorf("promedio = %v en vivo y %v importado, want 4 en ambos", live, restored)
+	}
+
+	// Sin valoraciones conservadas el promedio vuelve a cero
+	engine.RecordInteraction(&learning.Interaction{UserInput: "adiós", Intent: "despedida"})
+	if stats := engine.GetStats(); stats.AverageRating != 0 {
+		t.Errorf("sin valoraciones conservadas = %+v, want promedio 0", stats)
+	}
+}
+
+// El reloj inyectado fija todas las fechas del motor y los IDs por defecto
+func TestEngineClock(t *testing.T) {
+	now := base
+	engine := learning.NewEngine(learning.Config{LearningRate: 0.1}, learning.WithClock(func() time.Time { return now }))
+
+	first := &learning.Interaction{UserInput: "hola", Intent: "saludo"}
+	engine.RecordInteraction(first)
+	now = now.Add(time.Minute)
+	if err := engine.AddFeedback(first.ID, 5, "bien"); err != nil {
+		t.Fatalf("AddFeedback: %v", err)
+	}
+	now = now.Add(time.Minute)
+	fixed := base.Add(-time.Hour)
+	second := &learning.Interaction{ID: "propio", Timestamp: fixed, UserInput: "buenas", Intent: "saludo"}
+	engine.RecordInteraction(second)
+
+	if !first.Timestamp.Equal(base) || !first.Feedback.Timestamp.Equal(base.Add(time.Minute)) {
+		t.Errorf("interacción en %v valorada en %v", first.Timestamp, first.Feedback.Timestamp)
+	}
+	if second.ID != "propio" || !second.Timestamp.Equal(fixed) {
+		t.Errorf("se sobrescribió el ID o la fecha del llamador: %s %v", second.ID, second.Timestamp)
+	}
+	if pattern := engine.GetPatterns()["saludo"]; !pattern.LastUsed.Equal(base.Add(2*time.Minute)) || pattern.Frequency != 2 || math.Abs(pattern.Confidence-0.6) > 1e-9 {
+		t.Errorf("patrón = %+v", pattern)
+	}
+	if stats := engine.GetStats(); !stats.LastUpdated.Equal(base.Add(2 * time.Minute)) {
+		t.Errorf("LastUpdated = %v", stats.LastUpdated)
+	}
+
+	// El ULID por defecto lleva la hora del reloj inyectado
+	if got, want := first.ID[len("int_"):len("int_")+10], ulidTime(base); got != want {
+		t.Errorf("ID %s con hora %s, want %s", first.ID, got, want)
+	}
+
+	counter := 0
+	custom := learning.NewEngine(learning.Config{}, learning.WithIDGenerator(func() string {
+		counter++
+		return "id-" + strconv.Itoa(counter)
+	}))
+	interaction := &learning.Interaction{UserInput: "hola"}
+	custom.RecordInteraction(interaction)
+	if interaction.ID != "id-1" {
+		t.Errorf("ID = %q, want id-1 del generador inyectado", interaction.ID)
+	}
+}
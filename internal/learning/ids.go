@@ -0,0 +1,86 @@
+package learning
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// Clock devuelve la hora actual; se puede sustituir en tests
+type Clock func() time.Time
+
+// IDGenerator genera identificadores únicos para interacciones
+type IDGenerator func() string
+
+// crockford es el alfabeto Base32 de Crockford usado por ULID
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULIDGenerator crea un generador de IDs tipo ULID con el prefijo "int_".
+// Los IDs ordenan lexicográficamente por tiempo y son monótonos dentro del
+// mismo milisegundo, por lo que no colisionan aunque el reloj sea grueso.
+func NewULIDGenerator(clock Clock) IDGenerator {
+	if clock == nil {
+		clock = time.Now
+	}
+
+	var (
+		mu      sync.Mutex
+		lastMs  uint64
+		entropy [10]byte
+	)
+
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ms := uint64(clock().UnixMilli())
+		if ms <= lastMs {
+			// Mismo milisegundo (o reloj hacia atrás): incrementar la entropía
+			ms = lastMs
+			incrementEntropy(&entropy)
+		} else {
+			lastMs = ms
+			if _, err := rand.Read(entropy[:]); err != nil {
+				binary.BigEndian.PutUint64(entropy[2:], uint64(time.Now().UnixNano()))
+			}
+		}
+
+		var raw [16]byte
+		raw[0] = byte(ms >> 40)
+		raw[1] = byte(ms >> 32)
+		raw[2] = byte(ms >> 24)
+		raw[3] = byte(ms >> 16)
+		raw[4] = byte(ms >> 8)
+		raw[5] = byte(ms)
+		copy(raw[6:], entropy[:])
+
+		return "int_" + encodeULID(raw)
+	}
+}
+
+// incrementEntropy suma uno a los 80 bits de entropía
+func incrementEntropy(entropy *[10]byte) {
+	for i := len(entropy) - 1; i >= 0; i-- {
+		entropy[i]++
+		if entropy[i] != 0 {
+			return
+		}
+	}
+}
+
+// encodeULID codifica 128 bits en 26 caracteres Base32 de Crockford
+func encodeULID(raw [16]byte) string {
+	var out [26]byte
+
+	// Los 128 bits se leen como un entero de 130 bits con dos ceros a la izquierda
+	hi := binary.BigEndian.Uint64(raw[:8])
+	lo := binary.BigEndian.Uint64(raw[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(out[:])
+}
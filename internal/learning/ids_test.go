@@ -0,0 +1,99 @@
+package learning_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/internal/learning"
+)
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidTime codifica los milisegundos de t como los 10 primeros caracteres de un ULID
+func ulidTime(t time.Time) string {
+	ms := uint64(t.UnixMilli())
+	out := make([]byte, 10)
+	for i := 9; i >= 0; i-- {
+		out[i] = crockford[ms&0x1f]
+		ms >>= 5
+	}
+	return string(out)
+}
+
+func TestULIDGenerator(t *testing.T) {
+	now := base
+	generate := learning.NewULIDGenerator(func() time.Time { return now })
+
+	var ids []string
+	steps := []time.Duration{0, 0, 0, time.Millisecond, 0, -time.Second, 0, time.Hour}
+	for _, step := range steps {
+		now = now.Add(step)
+		ids = append(ids, generate())
+	}
+
+	for i, id := range ids {
+		if !strings.HasPrefix(id, "int_") || len(id) != len("int_")+26 {
+			t.Fatalf("ID %q, want int_ y 26 caracteres", id)
+		}
+		if strings.Trim(id[4:], crockford) != "" {
+			t.Errorf("ID %q fuera del alfabeto de Crockford", id)
+		}
+		// Monótonos aunque se repita el milisegundo o el reloj retroceda
+		if i > 0 && id <= ids[i-1] {
+			t.Errorf("ID %d %s no es mayor que %s", i, id, ids[i-1])
+		}
+	}
+
+	tests := []struct {
+		index int
+		want  time.Time
+	}{
+		{0, base},
+		{3, base.Add(time.Millisecond)},
+		{5, base.Add(time.Millisecond)}, // el reloj retrocedió: se conserva el último milisegundo
+		{7, base.Add(time.Hour - time.Second + time.Millisecond)},
+	}
+	for _, tt := range tests {
+		if got := ids[tt.index][4:14]; got != ulidTime(tt.want) {
+			t.Errorf("ID %d con hora %s, want %s", tt.index, got, ulidTime(tt.want))
+		}
+	}
+
+	// En el mismo milisegundo la entropía se incrementa en uno
+	a, b := ids[0], ids[1]
+	if last := strings.IndexByte(crockford, a[len(a)-1]); last < len(crockford)-1 && b != a[:len(a)-1]+string(crockford[last+1]) {
+		t.Errorf("%s -> %s no es un incremento de la entropía", a, b)
+	}
+}
+
+func TestULIDGeneratorConcurrent(t *testing.T) {
+	generate := learning.NewULIDGenerator(func() time.Time { return base })
+
+	const workers, perWorker = 8, 250
+	results := make(chan string, workers*perWorker)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				results <- generate()
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[string]bool)
+	for id := range results {
+		if seen[id] {
+			t.Fatalf("ID repetido: %s", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != workers*perWorker {
+		t.Errorf("%d IDs únicos, want %d", len(seen), workers*perWorker)
+	}
+}
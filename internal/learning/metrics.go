@@ -0,0 +1,23 @@
+package learning
+
+import "github.com/akosej/agent/pkg/metrics"
+
+// engineMetrics son las métricas del motor de aprendizaje
+type engineMetrics struct {
+	patternLookups metrics.Counter
+}
+
+func newEngineMetrics(m metrics.Metrics) *engineMetrics {
+	m = metrics.OrNop(m)
+	return &engineMetrics{
+		patternLookups: m.Counter("agent_pattern_lookups_total",
+			"Búsquedas de patrones aprendidos en FindSimilarPattern por resultado (hit o miss)", "result"),
+	}
+}
+
+// WithMetrics registra los aciertos y fallos de FindSimilarPattern
+func WithMetrics(m metrics.Metrics) Option {
+	return func(e *Engine) {
+		e.metrics = newEngineMetrics(m)
+	}
+}
@@ -0,0 +1,85 @@
+package nlp
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akosej/agent/pkg/metrics"
+)
+
+// Operaciones con las que se etiquetan las llamadas al modelo
+const (
+	opChat     = "chat"
+	opIntent   = "intent"
+	opGenerate = "generate"
+	opSummary  = "summary"
+	opEmbed    = "embed"
+	opJudge    = "judge"
+)
+
+// processorMetrics son las métricas de las llamadas a Ollama
+type processorMetrics struct {
+	duration   metrics.Histogram
+	firstToken metrics.Histogram
+	tokenRate  metrics.Histogram
+	tokens     metrics.Counter
+	errors     metrics.Counter
+}
+
+func newProcessorMetrics(m metrics.Metrics) *processorMetrics {
+	m = metrics.OrNop(m)
+	return &processorMetrics{
+		duration: m.Histogram("agent_llm_request_duration_seconds",
+			"Duración de las llamadas a Ollama", metrics.DurationBuckets, "operation", "status"),
+		firstToken: m.Histogram("agent_llm_time_to_first_token_seconds",
+			"Tiempo hasta el primer fragmento de una respuesta en streaming", metrics.DurationBuckets, "operation"),
+		tokenRate: m.Histogram("agent_llm_tokens_per_second",
+			"Velocidad de generación según eval_count y eval_duration de Ollama", metrics.RateBuckets, "operation"),
+		tokens: m.Counter("agent_llm_tokens_total",
+			"Tokens procesados por Ollama (prompt) y generados (completion)", "operation", "type"),
+		errors: m.Counter("agent_backend_errors_total",
+			"Errores de los servicios externos por tipo", "backend", "type"),
+	}
+}
+
+// WithMetrics registra latencia, tiempo hasta el primer token, tokens por
+// segundo y errores de cada llamada a Ollama
+func WithMetrics(m metrics.Metrics) Option {
+	return func(p *Processor) {
+		p.metrics = newProcessorMetrics(m)
+	}
+}
+
+// observe anota la duración de una llamada y, si falló por causa de Ollama, el
+// error con su tipo. errType vacío lo deduce del error.
+func (m *processorMetrics) observe(operation string, start time.Time, err error, errType string) {
+	status := metrics.Status(err)
+	m.duration.Observe(time.Since(start).Seconds(), operation, status)
+	if status != "error" {
+		return
+	}
+
+	var statusErr *statusError
+	switch {
+	case errType != "":
+	case errors.As(err, &statusErr):
+		errType = fmt.Sprintf("http_%d", statusErr.code)
+	default:
+		errType = metrics.ErrorType(err)
+	}
+	m.errors.Add(1, "ollama", errType)
+}
+
+// observeEval anota los contadores que Ollama incluye en el último mensaje
+func (m *processorMetrics) observeEval(operation string, response *OllamaResponse) {
+	if response.PromptEvalCount > 0 {
+		m.tokens.Add(float64(response.PromptEvalCount), operation, "prompt")
+	}
+	if response.EvalCount > 0 {
+		m.tokens.Add(float64(response.EvalCount), operation, "completion")
+		if response.EvalDuration > 0 {
+			m.tokenRate.Observe(float64(response.EvalCount)/time.Duration(response.EvalDuration).Seconds(), operation)
+		}
+	}
+}
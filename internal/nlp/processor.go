@@ -8,6 +8,10 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/akosej/agent/pkg/tracing"
 )
 
 // Intent representa una intención detectada
@@ -17,12 +21,17 @@ type Intent struct {
 	Entities   map[string]string
 }
 
+// Intents son las intenciones que DetectIntent pide al modelo. El modelo puede
+// devolver otras, así que quien agrupe por intención no debe suponer que estén todas aquí.
+var Intents = []string{"saludo", "despedida", "pregunta", "comando", "conversacion", "ayuda"}
+
 // Config contiene la configuración del procesador NLP
 type Config struct {
 	Model       string
 	MaxTokens   int
 	Temperature float32
 	OllamaURL   string // URL del servidor Ollama local
+	EmbedModel  string // Modelo para embeddings (si está vacío se usa Model)
 }
 
 // Processor maneja el procesamiento de lenguaje natural
@@ -30,6 +39,26 @@ type Processor struct {
 	client    *http.Client
 	config    Config
 	ollamaURL string
+	redactor  Redactor // nil si los prompts se envían sin redactar
+	metrics   *processorMetrics
+	tracer    *tracing.Tracer // nil si no se registran trazas
+
+	mu sync.RWMutex // Protege config.Model, que SetModel cambia en caliente
+}
+
+// Redactor oculta datos personales en un texto; lo implementa redact.Redactor
+type Redactor interface {
+	Redact(text string) string
+}
+
+// Option configura dependencias opcionales del procesador
+type Option func(*Processor)
+
+// WithRedactor redacta el contenido de cada mensaje antes de enviarlo al modelo
+func WithRedactor(redactor Redactor) Option {
+	return func(p *Processor) {
+		p.redactor = redactor
+	}
 }
 
 // Message representa un mensaje en la conversación
@@ -52,70 +81,210 @@ type Options struct {
 	NumPredict  int     `json:"num_predict,omitempty"` // max_tokens en Ollama
 }
 
-// OllamaResponse representa la respuesta de Ollama
+// OllamaResponse representa la respuesta de Ollama. Los contadores solo vienen
+// en el último mensaje (Done); las duraciones están en nanosegundos.
 type OllamaResponse struct {
-	Model     string  `json:"model"`
-	CreatedAt string  `json:"created_at"`
-	Message   Message `json:"message"`
-	Done      bool    `json:"done"`
+	Model           string  `json:"model"`
+	CreatedAt       string  `json:"created_at"`
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	PromptEvalCount int     `json:"prompt_eval_count,omitempty"`
+	EvalCount       int     `json:"eval_count,omitempty"`
+	EvalDuration    int64   `json:"eval_duration,omitempty"`
+}
+
+// statusError es una respuesta de Ollama con un estado distinto de 200
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("Ollama respondió con error %d: %s", e.code, e.body)
+}
+
+// EmbedRequest representa una solicitud de embeddings a Ollama
+type EmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// EmbedResponse representa la respuesta de embeddings de Ollama
+type EmbedResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float64 `json:"embeddings"`
 }
 
 // NewProcessor crea una nueva instancia del procesador NLP
-func NewProcessor(ollamaURL string, config Config) *Processor {
+func NewProcessor(ollamaURL string, config Config, opts ...Option) *Processor {
 	if ollamaURL == "" {
 		ollamaURL = "http://localhost:11434" // Puerto por defecto de Ollama
 	}
-	return &Processor{
+	p := &Processor{
 		client:    &http.Client{},
 		config:    config,
 		ollamaURL: ollamaURL,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.metrics == nil {
+		p.metrics = newProcessorMetrics(nil)
+	}
+	return p
 }
 
-// ProcessText procesa texto y genera una respuesta
-func (p *Processor) ProcessText(ctx context.Context, text string, conversationHistory []Message) (string, error) {
-	messages := append(conversationHistory, Message{
-		Role:    "user",
-		Content: text,
-	})
+// Model devuelve el modelo de Ollama en uso
+func (p *Processor) Model() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.config.Model
+}
 
-	return p.callOllama(ctx, messages)
+// SetModel cambia el modelo de Ollama para las siguientes llamadas
+func (p *Processor) SetModel(model string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config.Model = model
 }
 
-// callOllama realiza una llamada al servidor Ollama local
-func (p *Processor) callOllama(ctx context.Context, messages []Message) (string, error) {
-	request := OllamaRequest{
-		Model:    p.config.Model,
-		Messages: messages,
-		Stream:   false,
-		Options: Options{
-			Temperature: p.config.Temperature,
-			NumPredict:  p.config.MaxTokens,
-		},
+// options devuelve las opciones de generación de la configuración
+func (p *Processor) options() Options {
+	return Options{Temperature: p.config.Temperature, NumPredict: p.config.MaxTokens}
+}
+
+// ModelInfo describe un modelo instalado en Ollama
+type ModelInfo struct {
+	Name       string `json:"name"`
+	Size       int64  `json:"size"`
+	ModifiedAt string `json:"modified_at"`
+}
+
+// ListModels obtiene los modelos instalados en Ollama con /api/tags
+func (p *Processor) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.ollamaURL+"/api/tags", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creando request: %w", err)
 	}
 
-	jsonData, err := json.Marshal(request)
+	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error codificando request: %w", err)
+		return nil, fmt.Errorf("error llamando a Ollama: %w (Asegúrate de que Ollama esté corriendo con: ollama serve)", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Ollama respondió con error %d: %s", resp.StatusCode, string(body))
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", p.ollamaURL+"/api/chat", bytes.NewBuffer(jsonData))
+	var tags struct {
+		Models []ModelInfo `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("error decodificando modelos: %w", err)
+	}
+	return tags.Models, nil
+}
+
+// PullProgress es un mensaje de progreso de PullModel. Total y Completed son
+// los bytes de la capa que se está descargando (0 en los pasos sin descarga).
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// PullModel descarga un modelo con /api/pull de Ollama. onProgress recibe cada
+// mensaje de progreso a medida que llega; puede ser nil.
+func (p *Processor) PullModel(ctx context.Context, model string, onProgress func(PullProgress)) error {
+	jsonData, err := json.Marshal(map[string]interface{}{"model": model, "name": model, "stream": true})
 	if err != nil {
-		return "", fmt.Errorf("error creando request: %w", err)
+		return fmt.Errorf("error codificando request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.ollamaURL+"/api/pull", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("error creando request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error llamando a Ollama: %w (Asegúrate de que Ollama esté corriendo con: ollama serve)", err)
+		return fmt.Errorf("error llamando a Ollama: %w (Asegúrate de que Ollama esté corriendo con: ollama serve)", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("Ollama respondió con error %d: %s", resp.StatusCode, string(body))
+		return &statusError{code: resp.StatusCode, body: string(body)}
 	}
 
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var progress PullProgress
+		if err := decoder.Decode(&progress); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("Ollama cerró la descarga de %s sin terminar", model)
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("error decodificando progreso: %w", err)
+		}
+		if progress.Error != "" {
+			return fmt.Errorf("error descargando %s: %s", model, progress.Error)
+		}
+		if onProgress != nil {
+			onProgress(progress)
+		}
+		if progress.Status == "success" {
+			return nil
+		}
+	}
+}
+
+// ProcessText procesa texto y genera una respuesta
+func (p *Processor) ProcessText(ctx context.Context, text string, conversationHistory []Message) (string, error) {
+	messages := append(conversationHistory, Message{
+		Role:    "user",
+		Content: text,
+	})
+
+	return p.callOllama(ctx, opChat, messages, p.options())
+}
+
+// ProcessTextStream es como ProcessText pero entrega la respuesta por fragmentos
+// a onToken a medida que el modelo la genera
+func (p *Processor) ProcessTextStream(ctx context.Context, text string, conversationHistory []Message, onToken func(token string) error) (string, error) {
+	messages := append(conversationHistory, Message{
+		Role:    "user",
+		Content: text,
+	})
+
+	return p.callOllamaStream(ctx, opChat, messages, p.options(), onToken)
+}
+
+// callOllama realiza una llamada al servidor Ollama local. operation etiqueta
+// la llamada en las métricas.
+func (p *Processor) callOllama(ctx context.Context, operation string, messages []Message, options Options) (content string, err error) {
+	start := time.Now()
+	errType := ""
+	ctx, span := p.startSpan(ctx, operation, p.Model(), len(messages))
+	defer func() {
+		p.metrics.observe(operation, start, err, errType)
+		span.RecordError(err)
+		span.End()
+	}()
+
+	resp, err := p.postChat(ctx, messages, options, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("error leyendo respuesta: %w", err)
@@ -123,12 +292,122 @@ func (p *Processor) callOllama(ctx context.Context, messages []Message) (string,
 
 	var ollamaResp OllamaResponse
 	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		errType = "decode"
 		return "", fmt.Errorf("error decodificando respuesta: %w", err)
 	}
+	p.metrics.observeEval(operation, &ollamaResp)
+	span.SetAttributes(evalAttributes(&ollamaResp)...)
 
 	return ollamaResp.Message.Content, nil
 }
 
+// callOllamaStream realiza una llamada con stream: Ollama responde con un objeto
+// JSON por línea y onToken recibe cada fragmento en cuanto llega. Si onToken
+// devuelve error la llamada se interrumpe. Devuelve la respuesta completa.
+func (p *Processor) callOllamaStream(ctx context.Context, operation string, messages []Message, options Options, onToken func(token string) error) (response string, err error) {
+	start := time.Now()
+	errType := ""
+	aborted := false // onToken interrumpió la respuesta: no es un fallo de Ollama
+	ctx, span := p.startSpan(ctx, operation, p.Model(), len(messages))
+	span.SetAttributes(tracing.Bool("llm.stream", true))
+	defer func() {
+		defer span.End()
+		if aborted {
+			p.metrics.duration.Observe(time.Since(start).Seconds(), operation, "canceled")
+			span.SetAttributes(tracing.Bool("llm.aborted", true))
+			return
+		}
+		p.metrics.observe(operation, start, err, errType)
+		span.RecordError(err)
+	}()
+
+	resp, err := p.postChat(ctx, messages, options, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var full strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk OllamaResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			errType = "decode"
+			if err == io.EOF {
+				errType = "stream_closed"
+				return "", fmt.Errorf("Ollama cerró el stream sin terminar la respuesta")
+			}
+			if ctx.Err() != nil {
+				errType = ""
+				return "", ctx.Err()
+			}
+			return "", fmt.Errorf("error decodificando stream: %w", err)
+		}
+
+		if chunk.Message.Content != "" {
+			if full.Len() == 0 {
+				ttft := time.Since(start)
+				p.metrics.firstToken.Observe(ttft.Seconds(), operation)
+				span.SetAttributes(tracing.Float("llm.time_to_first_token_ms", float64(ttft.Microseconds())/1000))
+			}
+			full.WriteString(chunk.Message.Content)
+			if err := onToken(chunk.Message.Content); err != nil {
+				aborted = true
+				return "", err
+			}
+		}
+		if chunk.Done {
+			p.metrics.observeEval(operation, &chunk)
+			span.SetAttributes(evalAttributes(&chunk)...)
+			return full.String(), nil
+		}
+	}
+}
+
+// postChat envía los mensajes a /api/chat y devuelve la respuesta si el estado es 200.
+// Las opciones van por llamada para no modificar la configuración compartida.
+func (p *Processor) postChat(ctx context.Context, messages []Message, options Options, stream bool) (*http.Response, error) {
+	if p.redactor != nil {
+		redacted := make([]Message, len(messages))
+		for i, msg := range messages {
+			redacted[i] = Message{Role: msg.Role, Content: p.redactor.Redact(msg.Content)}
+		}
+		messages = redacted
+	}
+
+	request := OllamaRequest{
+		Model:    p.Model(),
+		Messages: messages,
+		Stream:   stream,
+		Options:  options,
+	}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error codificando request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.ollamaURL+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creando request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error llamando a Ollama: %w (Asegúrate de que Ollama esté corriendo con: ollama serve)", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &statusError{code: resp.StatusCode, body: string(body)}
+	}
+
+	return resp, nil
+}
+
 // DetectIntent detecta la intención del usuario en el texto
 func (p *Processor) DetectIntent(ctx context.Context, text string) (*Intent, error) {
 	systemPrompt := `Eres un asistente que detecta intenciones. 
@@ -137,7 +416,7 @@ INTENCIÓN: [nombre_intención]
 CONFIANZA: [0.0-1.0]
 ENTIDADES: [clave1=valor1, clave2=valor2]
 
-Intenciones posibles: saludo, despedida, pregunta, comando, conversacion, ayuda`
+Intenciones posibles: ` + strings.Join(Intents, ", ")
 
 	messages := []Message{
 		{
@@ -151,17 +430,7 @@ Intenciones posibles: saludo, despedida, pregunta, comando, conversacion, ayuda`
 	}
 
 	// Usar temperatura más baja para detección de intenciones
-	oldTemp := p.config.Temperature
-	p.config.Temperature = 0.3
-	oldTokens := p.config.MaxTokens
-	p.config.MaxTokens = 150
-
-	response, err := p.callOllama(ctx, messages)
-
-	// Restaurar configuración
-	p.config.Temperature = oldTemp
-	p.config.MaxTokens = oldTokens
-
+	response, err := p.callOllama(ctx, opIntent, messages, Options{Temperature: 0.3, NumPredict: 150})
 	if err != nil {
 		return nil, fmt.Errorf("error detectando intención: %w", err)
 	}
@@ -218,7 +487,7 @@ Contexto actual: %v`, context)
 		},
 	}
 
-	response, err := p.callOllama(ctx, messages)
+	response, err := p.callOllama(ctx, opGenerate, messages, p.options())
 	if err != nil {
 		return "", fmt.Errorf("error generando respuesta: %w", err)
 	}
@@ -244,21 +513,115 @@ func (p *Processor) SummarizeConversation(ctx context.Context, messages []Messag
 		},
 	}
 
-	// Guardar y modificar temporalmente la configuración
-	oldTemp := p.config.Temperature
-	p.config.Temperature = 0.5
-	oldTokens := p.config.MaxTokens
-	p.config.MaxTokens = 200
+	response, err := p.callOllama(ctx, opSummary, summaryMessages, Options{Temperature: 0.5, NumPredict: 200})
+	if err != nil {
+		return "", fmt.Errorf("error resumiendo conversación: %w", err)
+	}
 
-	response, err := p.callOllama(ctx, summaryMessages)
+	return response, nil
+}
 
-	// Restaurar configuración
-	p.config.Temperature = oldTemp
-	p.config.MaxTokens = oldTokens
+// Judgement es la valoración de una respuesta hecha por el modelo como juez
+type Judgement struct {
+	Score  float64 // De 1 (incorrecta) a 5 (correcta y completa)
+	Reason string
+}
 
+// JudgeResponse pide al modelo que puntúe de 1 a 5 la respuesta answer a la
+// pregunta question comparándola con reference, que puede estar vacía
+func (p *Processor) JudgeResponse(ctx context.Context, question, reference, answer string) (*Judgement, error) {
+	systemPrompt := `Eres un evaluador estricto de respuestas de un asistente.
+Puntúa de 1 a 5 si la respuesta es correcta, completa y útil para la pregunta;
+si hay respuesta de referencia, úsala como la respuesta correcta.
+Responde SOLO con el formato:
+PUNTUACIÓN: [1-5]
+RAZÓN: [una frase]`
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Pregunta: %s\n", question)
+	if reference != "" {
+		fmt.Fprintf(&prompt, "Respuesta de referencia: %s\n", reference)
+	}
+	fmt.Fprintf(&prompt, "Respuesta a evaluar: %s\n", answer)
+
+	messages := []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: prompt.String()},
+	}
+	response, err := p.callOllama(ctx, opJudge, messages, Options{Temperature: 0.1, NumPredict: 150})
 	if err != nil {
-		return "", fmt.Errorf("error resumiendo conversación: %w", err)
+		return nil, fmt.Errorf("error evaluando respuesta: %w", err)
 	}
+	return parseJudgement(response)
+}
 
-	return response, nil
+// parseJudgement parsea la respuesta del juez; la puntuación es obligatoria
+func parseJudgement(response string) (*Judgement, error) {
+	judgement := &Judgement{}
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.Trim(strings.TrimSpace(line), "*"))
+		upper := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(upper, "PUNTUACIÓN:") || strings.HasPrefix(upper, "PUNTUACION:"):
+			value := strings.TrimSpace(line[strings.Index(line, ":")+1:])
+			fmt.Sscanf(strings.Trim(value, "[]*"), "%g", &judgement.Score)
+		case strings.HasPrefix(upper, "RAZÓN:") || strings.HasPrefix(upper, "RAZON:"):
+			judgement.Reason = strings.TrimSpace(line[strings.Index(line, ":")+1:])
+		}
+	}
+	if judgement.Score < 1 || judgement.Score > 5 {
+		return nil, fmt.Errorf("el juez no devolvió una puntuación válida: %q", strings.TrimSpace(response))
+	}
+	return judgement, nil
+}
+
+// Embed obtiene los embeddings de una lista de textos usando /api/embed de Ollama
+func (p *Processor) Embed(ctx context.Context, texts []string) (embeddings [][]float64, err error) {
+	start := time.Now()
+	errType := ""
+	model := p.config.EmbedModel
+	if model == "" {
+		model = p.Model()
+	}
+	ctx, span := p.startSpan(ctx, opEmbed, model, len(texts))
+	defer func() {
+		p.metrics.observe(opEmbed, start, err, errType)
+		span.RecordError(err)
+		span.End()
+	}()
+
+	jsonData, err := json.Marshal(EmbedRequest{Model: model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("error codificando request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.ollamaURL+"/api/embed", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creando request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error llamando a Ollama: %w (Asegúrate de que Ollama esté corriendo con: ollama serve)", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &statusError{code: resp.StatusCode, body: string(body)}
+	}
+
+	var embedResp EmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		errType = "decode"
+		return nil, fmt.Errorf("error decodificando embeddings: %w", err)
+	}
+
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("Ollama devolvió %d embeddings para %d textos", len(embedResp.Embeddings), len(texts))
+	}
+
+	return embedResp.Embeddings, nil
 }
@@ -0,0 +1,210 @@
+package nlp_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/testing/fakes"
+)
+
+func newProcessor(t *testing.T) (*nlp.Processor, *fakes.Ollama) {
+	t.Helper()
+	ollama := fakes.NewOllama()
+	t.Cleanup(ollama.Close)
+	return nlp.NewProcessor(ollama.URL, nlp.Config{Model: fakes.DefaultModel}), ollama
+}
+
+func TestProcessText(t *testing.T) {
+	processor, ollama := newProcessor(t)
+	ollama.Reply("Hola, ¿en qué te ayudo?")
+
+	got, err := processor.ProcessText(context.Background(), "hola", []nlp.Message{{Role: "assistant", Content: "antes"}})
+	if err != nil {
+		t.Fatalf("ProcessText: %v", err)
+	}
+	if got != "Hola, ¿en qué te ayudo?" {
+		t.Errorf("respuesta = %q", got)
+	}
+
+	requests := ollama.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("peticiones = %d, want 1", len(requests))
+	}
+	request := requests[0]
+	if request.Stream || request.Model != fakes.DefaultModel || request.User() != "hola" {
+		t.Errorf("petición = %+v", request)
+	}
+	if len(request.Messages) != 2 || request.Messages[0].Content != "antes" {
+		t.Errorf("mensajes = %+v, want historial y usuario", request.Messages)
+	}
+}
+
+func TestProcessTextStream(t *testing.T) {
+	processor, ollama := newProcessor(t)
+	ollama.Reply("uno dos tres")
+
+	var tokens []string
+	got, err := processor.ProcessTextStream(context.Background(), "cuenta", nil, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProcessTextStream: %v", err)
+	}
+	if got != "uno dos tres" || strings.Join(tokens, "|") != "uno |dos |tres" {
+		t.Errorf("respuesta = %q, tokens = %q", got, tokens)
+	}
+	if requests := ollama.Requests(); !requests[0].Stream {
+		t.Error("la petición no pidió stream")
+	}
+
+	stop := errors.New("basta")
+	_, err = processor.ProcessTextStream(context.Background(), "cuenta", nil, func(string) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("err = %v, want el error de onToken", err)
+	}
+}
+
+func TestDetectIntent(t *testing.T) {
+	processor, ollama := newProcessor(t)
+	ollama.On(fakes.Match{System: "intenciones"}, fakes.FormatIntent("comando", 0.8, map[string]string{"persona": "Ana"}))
+	ollama.Reply("no es una intención")
+
+	intent, err := processor.DetectIntent(context.Background(), "llama a Ana")
+	if err != nil {
+		t.Fatalf("DetectIntent: %v", err)
+	}
+	if intent.Name != "comando" || intent.Confidence != 0.8 || intent.Entities["persona"] != "Ana" {
+		t.Errorf("intención = %+v", intent)
+	}
+}
+
+func TestScriptedReplies(t *testing.T) {
+	processor, ollama := newProcessor(t)
+	ollama.Reply("por defecto")
+	ollama.Script("primera", "segunda")
+
+	for _, want := range []string{"primera", "segunda", "por defecto"} {
+		got, err := processor.ProcessText(context.Background(), "hola", nil)
+		if err != nil {
+			t.Fatalf("ProcessText: %v", err)
+		}
+		if got != want {
+			t.Errorf("respuesta = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	processor, ollama := newProcessor(t)
+
+	embeddings, err := processor.Embed(context.Background(), []string{"el gato negro", "el gato negro", "un avión"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(embeddings) != 3 {
+		t.Fatalf("embeddings = %d, want 3", len(embeddings))
+	}
+	for i := range embeddings[0] {
+		if embeddings[0][i] != embeddings[1][i] {
+			t.Fatal("el mismo texto dio vectores distintos")
+		}
+	}
+	if requests := ollama.Requests(); requests[0].Path != "/api/embed" || len(requests[0].Input) != 3 {
+		t.Errorf("petición = %+v", requests[0])
+	}
+}
+
+func TestListAndPullModels(t *testing.T) {
+	ollama := fakes.NewOllama()
+	defer ollama.Close()
+	processor := nlp.NewProcessor(ollama.URL, nlp.Config{Model: "qwen2.5:3b"})
+	ctx := context.Background()
+
+	if _, err := processor.ProcessText(ctx, "hola", nil); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("err = %v, want 404 por modelo no instalado", err)
+	}
+
+	var statuses []string
+	err := processor.PullModel(ctx, "qwen2.5:3b", func(progress nlp.PullProgress) {
+		statuses = append(statuses, progress.Status)
+	})
+	if err != nil {
+		t.Fatalf("PullModel: %v", err)
+	}
+	if len(statuses) == 0 || statuses[len(statuses)-1] != "success" {
+		t.Errorf("progreso = %q", statuses)
+	}
+
+	models, err := processor.ListModels(ctx)
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	var names []string
+	for _, model := range models {
+		names = append(names, model.Name)
+	}
+	if strings.Join(names, ",") != fakes.DefaultModel+",qwen2.5:3b" {
+		t.Errorf("modelos = %q", names)
+	}
+	if _, err := processor.ProcessText(ctx, "hola", nil); err != nil {
+		t.Errorf("ProcessText tras descargar: %v", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		fault  fakes.Fault
+		stream bool
+		want   string
+	}{
+		{"status", fakes.Fault{Status: 503, Body: "sobrecargado"}, false, "error 503: sobrecargado"},
+		{"malformed", fakes.Fault{Malformed: true}, false, "error decodificando respuesta"},
+		{"cut stream", fakes.Fault{CutAfter: 1}, true, "cerró el stream"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			processor, ollama := newProcessor(t)
+			ollama.Reply("una respuesta larga")
+			tt.fault.Times = 1
+			ollama.Fail("/api/chat", tt.fault)
+
+			call := func() error {
+				if tt.stream {
+					_, err := processor.ProcessTextStream(context.Background(), "hola", nil, func(string) error { return nil })
+					return err
+				}
+				_, err := processor.ProcessText(context.Background(), "hola", nil)
+				return err
+			}
+			if err := call(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+			if err := call(); err != nil {
+				t.Errorf("el fallo con Times 1 afectó a la segunda llamada: %v", err)
+			}
+		})
+	}
+}
+
+func TestDelayHonoursContext(t *testing.T) {
+	processor, ollama := newProcessor(t)
+	ollama.Fail("", fakes.Fault{Delay: 5 * time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := processor.ProcessText(ctx, "hola", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("la cancelación tardó %v", elapsed)
+	}
+}
@@ -0,0 +1,37 @@
+package nlp
+
+import (
+	"context"
+	"time"
+
+	"github.com/akosej/agent/pkg/tracing"
+)
+
+// WithTracer abre un span por cada llamada a Ollama con el modelo, la operación
+// y los tokens de la respuesta
+func WithTracer(tracer *tracing.Tracer) Option {
+	return func(p *Processor) {
+		p.tracer = tracer
+	}
+}
+
+// startSpan abre el span "nlp.<operation>" de una llamada a Ollama
+func (p *Processor) startSpan(ctx context.Context, operation, model string, inputs int) (context.Context, *tracing.Span) {
+	return p.tracer.Start(ctx, "nlp."+operation, tracing.KindClient,
+		tracing.String("llm.model", model),
+		tracing.String("llm.operation", operation),
+		tracing.Int("llm.inputs", inputs))
+}
+
+// evalAttributes son los contadores de tokens que Ollama incluye en el último mensaje
+func evalAttributes(response *OllamaResponse) []tracing.Attribute {
+	attrs := []tracing.Attribute{
+		tracing.Int("llm.prompt_tokens", response.PromptEvalCount),
+		tracing.Int("llm.completion_tokens", response.EvalCount),
+	}
+	if response.EvalCount > 0 && response.EvalDuration > 0 {
+		rate := float64(response.EvalCount) / time.Duration(response.EvalDuration).Seconds()
+		attrs = append(attrs, tracing.Float("llm.tokens_per_second", rate))
+	}
+	return attrs
+}
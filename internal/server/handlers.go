@@ -0,0 +1,323 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/akosej/agent/internal/agent"
+	"github.com/akosej/agent/internal/speech"
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// maxConversationsLimit limita el tamaño de una página de conversaciones
+const maxConversationsLimit = 500
+
+// audioFormats son los formatos aceptados en /v1/transcribe y su tipo MIME
+var audioFormats = map[string]string{
+	"wav":  "audio/wav",
+	"mp3":  "audio/mpeg",
+	"ogg":  "audio/ogg",
+	"flac": "audio/flac",
+	"m4a":  "audio/mp4",
+	"webm": "audio/webm",
+}
+
+// errorBody es el cuerpo de todas las respuestas de error
+type errorBody struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// writeJSON escribe value como JSON con el código indicado
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError escribe un error con el formato {"error": {"code", "message"}}
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	var body errorBody
+	body.Error.Code = code
+	body.Error.Message = message
+	writeJSON(w, status, body)
+}
+
+// fail traduce un error del agente a su código HTTP; los errores internos se
+// registran y no se muestran al cliente
+func (s *Server) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) {
+		return // El cliente cerró la conexión; no hay a quién responder
+	}
+	status, body := s.classify(r, err)
+	writeJSON(w, status, body)
+}
+
+// classify devuelve el código HTTP y el cuerpo de error de un error del agente
+func (s *Server) classify(r *http.Request, err error) (int, errorBody) {
+	var body errorBody
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, agent.ErrInvalidInput), errors.Is(err, speech.ErrAudioTooLong):
+		status, body.Error.Code, body.Error.Message = http.StatusBadRequest, "invalid_request", err.Error()
+	case errors.Is(err, storage.ErrNotFound):
+		status, body.Error.Code, body.Error.Message = http.StatusNotFound, "not_found", "recurso no encontrado"
+	case errors.Is(err, agent.ErrNoTranscriber):
+		status, body.Error.Code, body.Error.Message = http.StatusServiceUnavailable, "unavailable", err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		status, body.Error.Code, body.Error.Message = http.StatusGatewayTimeout, "timeout", "el modelo no respondió a tiempo"
+	default:
+		s.log.ErrorContext(r.Context(), "error atendiendo petición", "path", r.URL.Path, "error", err)
+		body.Error.Code, body.Error.Message = "internal", "error interno"
+	}
+	return status, body
+}
+
+// decode lee un cuerpo JSON estricto: tipo application/json, tamaño limitado,
+// sin campos desconocidos y un único objeto
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "se esperaba Content-Type: application/json")
+		return false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.config.MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "too_large", fmt.Sprintf("el cuerpo supera %d bytes", s.config.MaxBodyBytes))
+		} else {
+			writeError(w, http.StatusBadRequest, "invalid_json", "JSON inválido: "+err.Error())
+		}
+		return false
+	}
+	if decoder.More() {
+		writeError(w, http.StatusBadRequest, "invalid_json", "el cuerpo debe contener un único objeto JSON")
+		return false
+	}
+	return true
+}
+
+// chatRequest es el cuerpo de POST /v1/chat
+type chatRequest struct {
+	Text           string `json:"text"`
+	ConversationID string `json:"conversation_id"`
+	UserID         string `json:"user_id"`
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var request chatRequest
+	if !s.decode(w, r, &request) {
+		return
+	}
+	if strings.TrimSpace(request.Text) == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "el campo text es obligatorio")
+		return
+	}
+
+	result, err := s.agent.Chat(r.Context(), agent.ChatRequest{
+		Text:           request.Text,
+		UserID:         request.UserID,
+		ConversationID: request.ConversationID,
+	})
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// transcribeResponse es la respuesta de POST /v1/transcribe
+type transcribeResponse struct {
+	Text   string `json:"text"`
+	Format string `json:"format"`
+}
+
+// handleTranscribe acepta el audio como multipart/form-data (campo "file") o
+// como cuerpo binario con Content-Type audio/*; ?format= fuerza el formato
+func (s *Server) handleTranscribe(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxAudioBytes)
+	format := strings.ToLower(r.URL.Query().Get("format"))
+
+	var audio []byte
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "multipart/form-data":
+		file, header, ferr := r.FormFile("file")
+		if ferr != nil {
+			s.failUpload(w, ferr)
+			return
+		}
+		defer file.Close()
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+		audio, err = io.ReadAll(file)
+	case strings.HasPrefix(mediaType, "audio/"):
+		if format == "" {
+			format = formatForMediaType(mediaType)
+		}
+		audio, err = io.ReadAll(r.Body)
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "envía el audio como multipart/form-data (campo file) o con Content-Type audio/*")
+		return
+	}
+	if err != nil {
+		s.failUpload(w, err)
+		return
+	}
+
+	if _, ok := audioFormats[format]; !ok {
+		writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("formato de audio no soportado: %q (wav, mp3, ogg, flac, m4a, webm)", format))
+		return
+	}
+
+	text, err := s.agent.Transcribe(r.Context(), audio, format)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transcribeResponse{Text: text, Format: format})
+}
+
+// failUpload responde a un error leyendo el audio subido
+func (s *Server) failUpload(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, "too_large", fmt.Sprintf("el audio supera %d bytes", s.config.MaxAudioBytes))
+		return
+	}
+	writeError(w, http.StatusBadRequest, "invalid_request", "error leyendo el audio: "+err.Error())
+}
+
+// formatForMediaType devuelve el formato de un tipo MIME de audio, o "" si no se conoce
+func formatForMediaType(mediaType string) string {
+	for format, known := range audioFormats {
+		if known == mediaType {
+			return format
+		}
+	}
+	switch mediaType {
+	case "audio/x-wav", "audio/wave":
+		return "wav"
+	case "audio/mp3":
+		return "mp3"
+	case "audio/x-flac":
+		return "flac"
+	case "audio/x-m4a":
+		return "m4a"
+	}
+	return ""
+}
+
+// feedbackRequest es el cuerpo de POST /v1/feedback
+type feedbackRequest struct {
+	InteractionID string `json:"interaction_id"`
+	Rating        int    `json:"rating"`
+	Comment       string `json:"comment"`
+}
+
+func (s *Server) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	var request feedbackRequest
+	if !s.decode(w, r, &request) {
+		return
+	}
+	if request.InteractionID == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "el campo interaction_id es obligatorio")
+		return
+	}
+	if request.Rating < 1 || request.Rating > 5 {
+		writeError(w, http.StatusBadRequest, "invalid_request", "el campo rating debe estar entre 1 y 5")
+		return
+	}
+
+	if err := s.agent.Feedback(r.Context(), request.InteractionID, request.Rating, request.Comment); err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.agent.Stats()
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// conversationList es la respuesta de GET /v1/conversations
+type conversationList struct {
+	Conversations []*storage.Conversation `json:"conversations"`
+}
+
+// handleConversations atiende GET /v1/conversations. El servidor no autentica
+// usuarios: user_id es obligatorio para que un cliente no liste por descuido
+// las de todos, pero quien tenga acceso a la API puede consultar cualquier
+// user_id. Los clientes deben pasar el del usuario que ya han autenticado.
+func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := storage.ConversationFilter{UserID: query.Get("user_id"), Limit: 50}
+	if filter.UserID == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "el parámetro user_id es obligatorio")
+		return
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxConversationsLimit {
+			writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("limit debe ser un entero entre 1 y %d", maxConversationsLimit))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	conversations, err := s.agent.Conversations(filter)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	if conversations == nil {
+		conversations = []*storage.Conversation{}
+	}
+	writeJSON(w, http.StatusOK, conversationList{Conversations: conversations})
+}
+
+// handleConversation atiende GET /v1/conversations/{id}
+func (s *Server) handleConversation(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/conversations/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, "not_found", "ruta no encontrada: "+r.URL.Path)
+		return
+	}
+
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "el parámetro user_id es obligatorio")
+		return
+	}
+
+	conversation, err := s.agent.Conversation(id, userID)
+	if err != nil {
+		s.fail(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, conversation)
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/testing/fakes"
+)
+
+// do envía una petición y devuelve el código, las cabeceras y el código de error del cuerpo
+func do(t *testing.T, method, url, contentType, body string, header http.Header) (int, http.Header, string) {
+	t.Helper()
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer response.Body.Close()
+	var errBody errorBody
+	data, _ := io.ReadAll(response.Body)
+	json.Unmarshal(data, &errBody)
+	return response.StatusCode, response.Header, errBody.Error.Code
+}
+
+func TestHandlersValidation(t *testing.T) {
+	s, _ := newTestServer(t, Config{MaxBodyBytes: 64, MaxAudioBytes: 64})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	const jsonType = "application/json"
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{"chat without content type", "POST", "/v1/chat", "", `{"text":"hola"}`, 415, "unsupported_media_type"},
+		{"chat text/plain", "POST", "/v1/chat", "text/plain", `{"text":"hola"}`, 415, "unsupported_media_type"},
+		{"chat too large", "POST", "/v1/chat", jsonType, `{"text":"` + strings.Repeat("a", 100) + `"}`, 413, "too_large"},
+		{"chat empty text", "POST", "/v1/chat", jsonType, `{"text":"  "}`, 400, "invalid_request"},
+		{"chat unknown field", "POST", "/v1/chat", jsonType, `{"texto":"hola"}`, 400, "invalid_json"},
+		{"chat two objects", "POST", "/v1/chat", jsonType, `{"text":"a"}{"text":"b"}`, 400, "invalid_json"},
+		{"chat malformed", "POST", "/v1/chat", jsonType + "; charset=utf-8", `{"text":`, 400, "invalid_json"},
+		{"chat get", "GET", "/v1/chat", "", "", 405, "method_not_allowed"},
+		{"feedback without id", "POST", "/v1/feedback", jsonType, `{"rating":3}`, 400, "invalid_request"},
+		{"feedback rating", "POST", "/v1/feedback", jsonType, `{"interaction_id":"x","rating":6}`, 400, "invalid_request"},
+		{"stats post", "POST", "/v1/stats", jsonType, `{}`, 405, "method_not_allowed"},
+		{"conversations without user", "GET", "/v1/conversations", "", "", 400, "invalid_request"},
+		{"conversations limit", "GET", "/v1/conversations?user_id=ana&limit=0", "", "", 400, "invalid_request"},
+		{"conversations limit too big", "GET", "/v1/conversations?user_id=ana&limit=501", "", "", 400, "invalid_request"},
+		{"conversation without user", "GET", "/v1/conversations/c1", "", "", 400, "invalid_request"},
+		{"conversation nested path", "GET", "/v1/conversations/c1/turns?user_id=ana", "", "", 404, "not_found"},
+		{"transcribe text", "POST", "/v1/transcribe", "text/plain", "hola", 415, "unsupported_media_type"},
+		{"transcribe too large", "POST", "/v1/transcribe", "audio/wav", strings.Repeat("a", 100), 413, "too_large"},
+		{"transcribe unknown format", "POST", "/v1/transcribe", "audio/x-desconocido", "RIFF", 400, "invalid_request"},
+		{"transcribe empty", "POST", "/v1/transcribe", "audio/wav", "", 400, "invalid_request"},
+		{"unknown route", "GET", "/v2/chat", "", "", 404, "not_found"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			status, header, code := do(t, tt.method, server.URL+tt.path, tt.contentType, tt.body, nil)
+			if status != tt.status || code != tt.code {
+				t.Errorf("= %d %q, want %d %q", status, code, tt.status, tt.code)
+			}
+			if status == http.StatusMethodNotAllowed && header.Get("Allow") == "" {
+				t.Error("405 sin cabecera Allow")
+			}
+			if header.Get(requestIDHeader) == "" {
+				t.Errorf("respuesta sin %s", requestIDHeader)
+			}
+		})
+	}
+}
+
+func TestHandlersErrorMapping(t *testing.T) {
+	s, ollama := newTestServer(t, Config{})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/chat", strings.NewReader(`{"text":"hola","user_id":"ana"}`))
+	request.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	var result struct {
+		InteractionID  string `json:"interaction_id"`
+		ConversationID string `json:"conversation_id"`
+	}
+	json.NewDecoder(response.Body).Decode(&result)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || result.ConversationID == "" {
+		t.Fatalf("chat = %d, %+v", response.StatusCode, result)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"own conversation", "GET", "/v1/conversations/" + result.ConversationID + "?user_id=ana", "", 200, ""},
+		{"other user's conversation", "GET", "/v1/conversations/" + result.ConversationID + "?user_id=luis", "", 404, "not_found"},
+		{"unknown conversation", "GET", "/v1/conversations/no-existe?user_id=ana", "", 404, "not_found"},
+		{"continue other user's conversation", "POST", "/v1/chat", `{"text":"hola","user_id":"luis","conversation_id":"` + result.ConversationID + `"}`, 404, "not_found"},
+		{"feedback", "POST", "/v1/feedback", `{"interaction_id":"` + result.InteractionID + `","rating":5}`, 204, ""},
+		{"feedback unknown interaction", "POST", "/v1/feedback", `{"interaction_id":"no-existe","rating":5}`, 404, "not_found"},
+	}
+	for _, tt := range tests {
+		contentType := ""
+		if tt.body != "" {
+			contentType = "application/json"
+		}
+		status, _, code := do(t, tt.method, server.URL+tt.path, contentType, tt.body, nil)
+		if status != tt.status || code != tt.code {
+			t.Errorf("%s: = %d %q, want %d %q", tt.name, status, code, tt.status, tt.code)
+		}
+	}
+
+	// Cada usuario ve solo sus conversaciones
+	for user, want := range map[string]int{"ana": 1, "luis": 0} {
+		response, err := http.Get(server.URL + "/v1/conversations?user_id=" + user)
+		if err != nil {
+			t.Fatalf("conversations: %v", err)
+		}
+		var list conversationList
+		json.NewDecoder(response.Body).Decode(&list)
+		response.Body.Close()
+		if len(list.Conversations) != want {
+			t.Errorf("conversaciones de %s = %d, want %d", user, len(list.Conversations), want)
+		}
+	}
+
+	// Los fallos del modelo son internos: no se muestra el detalle
+	ollama.Fail("/api/chat", fakes.Fault{Status: http.StatusInternalServerError, Body: "detalle interno"})
+	request, _ = http.NewRequest(http.MethodPost, server.URL+"/v1/chat", strings.NewReader(`{"text":"hola"}`))
+	request.Header.Set("Content-Type", "application/json")
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	data, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusInternalServerError || strings.Contains(string(data), "detalle interno") {
+		t.Errorf("fallo del modelo = %d %s", response.StatusCode, data)
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	s, _ := newTestServer(t, Config{APIToken: "secreto"})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	bearer := func(token string) http.Header {
+		return http.Header{"Authorization": {"Bearer " + token}}
+	}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header http.Header
+		status int
+	}{
+		{"no token", "GET", "/v1/stats", nil, 401},
+		{"wrong token", "GET", "/v1/stats", bearer("otro"), 401},
+		{"not bearer", "GET", "/v1/stats", http.Header{"Authorization": {"secreto"}}, 401},
+		{"bearer", "GET", "/v1/stats", bearer("secreto"), 200},
+		{"query on get", "GET", "/v1/stats?access_token=secreto", nil, 200},
+		{"wrong query", "GET", "/v1/stats?access_token=otro", nil, 401},
+		{"query on post", "POST", "/v1/feedback?access_token=secreto", nil, 401},
+		{"websocket", "GET", "/v1/ws", nil, 401},
+		{"stream", "GET", "/v1/chat/stream?text=hola", nil, 401},
+		{"spec is public", "GET", "/v1/openapi.yaml", nil, 200},
+	}
+	for _, tt := range tests {
+		status, header, code := do(t, tt.method, server.URL+tt.path, "", "", tt.header)
+		if status != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.status)
+		}
+		if status == http.StatusUnauthorized && (code != "unauthorized" || header.Get("WWW-Authenticate") != "Bearer") {
+			t.Errorf("%s: 401 con código %q y WWW-Authenticate %q", tt.name, code, header.Get("WWW-Authenticate"))
+		}
+	}
+}
+
+// Al detenerse, el servidor termina las peticiones en curso antes de volver
+func TestGracefulShutdown(t *testing.T) {
+	s, ollama := newTestServer(t, Config{ShutdownTimeout: 5 * time.Second})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	base := "http://" + listener.Addr().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, listener) }()
+
+	ollama.Fail("/api/chat", fakes.Fault{Delay: 300 * time.Millisecond, Times: 1})
+	statuses := make(chan int, 1)
+	go func() {
+		response, err := http.Post(base+"/v1/chat", "application/json", strings.NewReader(`{"text":"hola"}`))
+		if err != nil {
+			t.Errorf("petición en curso: %v", err)
+			statuses <- 0
+			return
+		}
+		response.Body.Close()
+		statuses <- response.StatusCode
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for ollama.Count("/api/chat") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("la petición no llegó al modelo")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if status := <-statuses; status != http.StatusOK {
+		t.Errorf("petición en curso = %d, want 200", status)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Serve no volvió tras cancelar")
+	}
+	if _, err := http.Get(base + "/v1/stats"); err == nil {
+		t.Error("el servidor sigue aceptando peticiones tras detenerse")
+	}
+}
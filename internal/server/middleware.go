@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akosej/agent/pkg/logger"
+	"github.com/akosej/agent/pkg/tracing"
+)
+
+// requestIDHeader es la cabecera con el ID de la petición, recibido o generado
+const requestIDHeader = "X-Request-ID"
+
+// method rechaza con 405 las peticiones con un método distinto del indicado
+func (s *Server) method(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "método no permitido: "+r.Method)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// admin exige el token de administración en la cabecera Authorization: Bearer
+func (s *Server) admin(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + s.config.AdminToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized", "token de administración inválido")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// api exige el token de la API, si está configurado, en la cabecera
+// Authorization: Bearer. Las peticiones GET pueden enviarlo en el parámetro
+// access_token, porque EventSource y WebSocket no permiten cabeceras en los
+// navegadores; la consulta no se registra en logs ni trazas.
+func (s *Server) api(next http.HandlerFunc) http.Handler {
+	if s.config.APIToken == "" {
+		return next
+	}
+	expected := []byte(s.config.APIToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, bearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !bearer && r.Method == http.MethodGet {
+			token = r.URL.Query().Get("access_token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized", "token de API inválido")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestID propaga el X-Request-ID del cliente, o genera uno, al contexto
+// (para los logs) y a la respuesta
+func (s *Server) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
+	})
+}
+
+// trace abre el span raíz de cada petición, continuando la traza del cliente si
+// envía traceparent. accessLog anota el estado de la respuesta en el span.
+func (s *Server) trace(next http.Handler) http.Handler {
+	if s.config.Tracer == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if untracedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := tracing.Extract(r.Context(), r.Header)
+		route := routeName(r.URL.Path)
+		ctx, span := s.config.Tracer.Start(ctx, r.Method+" "+route, tracing.KindServer,
+			tracing.String("http.method", r.Method), tracing.String("http.route", route))
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// untracedPaths son las rutas de sondeo, que llenarían las trazas sin aportar nada
+var untracedPaths = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// routeName agrupa las rutas con parámetros para que los nombres de los spans
+// no dependan de cada ID
+func routeName(path string) string {
+	if strings.HasPrefix(path, "/v1/conversations/") {
+		return "/v1/conversations/{id}"
+	}
+	return path
+}
+
+// validRequestID acepta IDs cortos de caracteres imprimibles sin espacios
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID genera un ID aleatorio de 16 caracteres hexadecimales
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder guarda el código de estado y los bytes escritos
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Hijack registra el cambio de protocolo (101) y cede la conexión al manejador
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap permite a http.ResponseController acceder al ResponseWriter original
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// accessLog registra cada petición con su estado y duración
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		args := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", recorder.bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote", r.RemoteAddr,
+		}
+		span := tracing.SpanFromContext(r.Context())
+		span.SetAttributes(tracing.Int("http.status_code", status))
+		if status >= 500 {
+			span.RecordError(fmt.Errorf("HTTP %d", status))
+		}
+		switch {
+		case status >= 500:
+			s.log.ErrorContext(r.Context(), "petición HTTP", args...)
+		case strings.HasPrefix(r.URL.Path, "/admin/"):
+			s.log.InfoContext(r.Context(), "petición HTTP", args...)
+		default:
+			s.log.DebugContext(r.Context(), "petición HTTP", args...)
+		}
+	})
+}
+
+// recoverPanics convierte un pánico en un manejador en una respuesta 500
+func (s *Server) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				s.log.ErrorContext(r.Context(), "pánico atendiendo petición", "path", r.URL.Path, "panic", v)
+				writeError(w, http.StatusInternalServerError, "internal", "error interno")
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
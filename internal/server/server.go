@@ -0,0 +1,217 @@
+// Package server expone el agente mediante una API HTTP/JSON para que otras
+// aplicaciones (el portal de la intranet, scripts) usen una misma instancia en
+// ejecución. La especificación OpenAPI se sirve en /v1/openapi.yaml.
+package server
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/akosej/agent/internal/agent"
+	"github.com/akosej/agent/internal/health"
+	"github.com/akosej/agent/pkg/logger"
+	"github.com/akosej/agent/pkg/tracing"
+)
+
+// Valores por defecto
+const (
+	defaultAddr            = "127.0.0.1:8080"
+	defaultReadTimeout     = 30 * time.Second
+	defaultWriteTimeout    = 5 * time.Minute // las respuestas del modelo pueden tardar
+	defaultShutdownTimeout = 30 * time.Second
+	defaultMaxBodyBytes    = 1 << 20  // 1 MB
+	defaultMaxAudioBytes   = 25 << 20 // 25 MB
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// Config contiene la configuración del servidor HTTP
+type Config struct {
+	Addr            string          // Dirección de escucha (por defecto 127.0.0.1:8080)
+	ReadTimeout     time.Duration   // Tiempo máximo para leer una petición
+	WriteTimeout    time.Duration   // Tiempo máximo para escribir la respuesta
+	ShutdownTimeout time.Duration   // Espera máxima a las peticiones en curso al detenerse
+	MaxBodyBytes    int64           // Tamaño máximo de un cuerpo JSON
+	MaxAudioBytes   int64           // Tamaño máximo de un audio en /v1/transcribe
+	AdminToken      string          // Token Bearer de /admin/*; vacío deshabilita la administración
+	APIToken        string          // Token Bearer de /v1/* salvo la especificación; vacío deja la API abierta
+	AllowedOrigins  []string        // Orígenes de navegador admitidos en /v1/ws además del propio ("*" cualquiera)
+	Metrics         http.Handler    // Manejador de GET /metrics; nil no expone métricas
+	Tracer          *tracing.Tracer // Spans de cada petición; nil no registra trazas
+	Health          *health.Health  // Comprobaciones de /healthz y /readyz; nil no las expone
+}
+
+// Server sirve la API del agente
+type Server struct {
+	config Config
+	agent  *agent.Agent
+	log    *logger.Logger
+	root   *logger.Logger // logger raíz, para /admin/log-level
+	http   *http.Server
+
+	// Sesiones WebSocket abiertas: http.Server.Shutdown no espera a las
+	// conexiones tomadas con Hijack, así que se cierran y esperan aparte
+	sessionsMu sync.Mutex
+	sessions   map[*wsSession]struct{}
+	stopping   bool // no se admiten sesiones nuevas
+	sessionsWG sync.WaitGroup
+}
+
+// New crea un servidor para el agente
+func New(config Config, a *agent.Agent, log *logger.Logger) *Server {
+	if config.Addr == "" {
+		config.Addr = defaultAddr
+	}
+	if config.ReadTimeout <= 0 {
+		config.ReadTimeout = defaultReadTimeout
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = defaultWriteTimeout
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if config.MaxAudioBytes <= 0 {
+		config.MaxAudioBytes = defaultMaxAudioBytes
+	}
+
+	s := &Server{
+		config:   config,
+		agent:    a,
+		log:      log.Component("server"),
+		root:     log,
+		sessions: make(map[*wsSession]struct{}),
+	}
+	s.http = &http.Server{
+		Addr:              config.Addr,
+		Handler:           s.Handler(),
+		ReadTimeout:       config.ReadTimeout,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       2 * time.Minute,
+	}
+	s.http.RegisterOnShutdown(s.closeSessions)
+	return s
+}
+
+// Handler devuelve el manejador con todas las rutas y el middleware
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/chat", s.api(s.method(http.MethodPost, s.handleChat)))
+	mux.Handle("/v1/chat/stream", s.api(s.handleChatStream))
+	mux.Handle("/v1/ws", s.api(s.handleWebSocket))
+	mux.Handle("/v1/transcribe", s.api(s.method(http.MethodPost, s.handleTranscribe)))
+	mux.Handle("/v1/feedback", s.api(s.method(http.MethodPost, s.handleFeedback)))
+	mux.Handle("/v1/stats", s.api(s.method(http.MethodGet, s.handleStats)))
+	mux.Handle("/v1/conversations", s.api(s.method(http.MethodGet, s.handleConversations)))
+	mux.Handle("/v1/conversations/", s.api(s.method(http.MethodGet, s.handleConversation)))
+	mux.HandleFunc("/v1/openapi.yaml", s.method(http.MethodGet, s.handleOpenAPI))
+	if s.config.AdminToken != "" {
+		mux.Handle("/admin/log-level", s.admin(s.root.LevelHandler()))
+	}
+	if s.config.Metrics != nil {
+		mux.Handle("/metrics", s.config.Metrics)
+	}
+	if s.config.Health != nil {
+		mux.Handle("/healthz", s.config.Health.LiveHandler())
+		mux.Handle("/readyz", s.config.Health.ReadyHandler())
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not_found", "ruta no encontrada: "+r.URL.Path)
+	})
+
+	return s.requestID(s.trace(s.accessLog(s.recoverPanics(mux))))
+}
+
+// ListenAndServe atiende peticiones hasta que ctx se cancela; entonces deja de
+// aceptar conexiones, cierra las sesiones WebSocket y espera a las peticiones
+// y sesiones en curso durante ShutdownTimeout
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return fmt.Errorf("error escuchando en %s: %w", s.config.Addr, err)
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve es como ListenAndServe pero con un listener ya abierto
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	errCh := make(chan error, 1)
+	go func() {
+		s.log.InfoContext(ctx, "servidor HTTP escuchando", "addr", listener.Addr().String())
+		errCh <- s.http.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.log.InfoContext(ctx, "deteniendo servidor HTTP", "timeout", s.config.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		s.http.Close()
+		return fmt.Errorf("error deteniendo servidor: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	// Shutdown ejecuta closeSessions en otra goroutine; llamarla aquí también
+	// garantiza que ninguna sesión se registre después de empezar a esperar
+	s.closeSessions()
+	done := make(chan struct{})
+	go func() {
+		s.sessionsWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-shutdownCtx.Done():
+		return fmt.Errorf("error deteniendo servidor: sesiones WebSocket sin terminar: %w", shutdownCtx.Err())
+	}
+}
+
+// addSession registra una sesión WebSocket; devuelve false si el servidor se está deteniendo
+func (s *Server) addSession(session *wsSession) bool {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	if s.stopping {
+		return false
+	}
+	s.sessions[session] = struct{}{}
+	s.sessionsWG.Add(1)
+	return true
+}
+
+// removeSession da por terminada una sesión registrada con addSession
+func (s *Server) removeSession(session *wsSession) {
+	s.sessionsMu.Lock()
+	delete(s.sessions, session)
+	s.sessionsMu.Unlock()
+	s.sessionsWG.Done()
+}
+
+// closeSessions deja de admitir sesiones y cierra las abiertas, cancelando
+// su turno en curso. Se puede llamar varias veces.
+func (s *Server) closeSessions() {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	s.stopping = true
+	for session := range s.sessions {
+		session.shutdown()
+	}
+}
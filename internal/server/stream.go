@@ -0,0 +1,384 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akosej/agent/internal/agent"
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/internal/speech"
+)
+
+// Límites de las sesiones WebSocket
+const (
+	wsMaxMessage   = 1 << 20 // 1 MB por mensaje
+	wsIdleTimeout  = 2 * time.Minute
+	wsChunkBacklog = 32 // chunks de audio en cola mientras se transcribe
+)
+
+// intentEvent es el evento con la intención detectada
+type intentEvent struct {
+	Type       string            `json:"type,omitempty"`
+	Intent     string            `json:"intent"`
+	Confidence float64           `json:"confidence"`
+	Entities   map[string]string `json:"entities,omitempty"`
+}
+
+func newIntentEvent(intent *nlp.Intent) intentEvent {
+	return intentEvent{Type: "intent", Intent: intent.Name, Confidence: intent.Confidence, Entities: intent.Entities}
+}
+
+// textEvent es un fragmento de respuesta o una transcripción
+type textEvent struct {
+	Type string `json:"type,omitempty"`
+	Text string `json:"text"`
+}
+
+// doneEvent cierra un turno con el resultado completo
+type doneEvent struct {
+	Type string `json:"type"`
+	*agent.ChatResult
+}
+
+// handleChatStream atiende /v1/chat/stream con Server-Sent Events. Acepta
+// POST con el mismo cuerpo que /v1/chat o GET con los parámetros text,
+// conversation_id y user_id (para EventSource). Emite los eventos intent,
+// token (uno por fragmento), done con el resultado completo, o error.
+func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
+	var request chatRequest
+	switch r.Method {
+	case http.MethodPost:
+		if !s.decode(w, r, &request) {
+			return
+		}
+	case http.MethodGet:
+		query := r.URL.Query()
+		request = chatRequest{Text: query.Get("text"), ConversationID: query.Get("conversation_id"), UserID: query.Get("user_id")}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "método no permitido: "+r.Method)
+		return
+	}
+	if strings.TrimSpace(request.Text) == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "el campo text es obligatorio")
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // evita el buffer de proxies como nginx
+	w.WriteHeader(http.StatusOK)
+
+	// send escribe un evento y amplía el plazo de escritura, que de otro modo
+	// cortaría las respuestas largas
+	send := func(event string, value interface{}) error {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		controller.SetWriteDeadline(time.Now().Add(s.config.WriteTimeout))
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return err
+		}
+		return controller.Flush()
+	}
+
+	result, err := s.agent.ChatStream(r.Context(), agent.ChatRequest{
+		Text:           request.Text,
+		UserID:         request.UserID,
+		ConversationID: request.ConversationID,
+	}, agent.StreamHandler{
+		OnIntent: func(intent *nlp.Intent) {
+			event := newIntentEvent(intent)
+			event.Type = ""
+			send("intent", event)
+		},
+		OnToken: func(token string) error {
+			return send("token", textEvent{Text: token})
+		},
+	})
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			_, body := s.classify(r, err)
+			send("error", body)
+		}
+		return
+	}
+	send("done", result)
+}
+
+// wsClientMessage es un mensaje de control del cliente en /v1/ws
+type wsClientMessage struct {
+	Type           string `json:"type"` // start, stop, text o cancel
+	Text           string `json:"text,omitempty"`
+	ConversationID string `json:"conversation_id,omitempty"`
+	UserID         string `json:"user_id,omitempty"`
+	SampleRate     int    `json:"sample_rate,omitempty"`
+	Channels       int    `json:"channels,omitempty"`
+	TTS            bool   `json:"tts,omitempty"`
+}
+
+// audioEvent anuncia el mensaje binario con audio sintetizado que le sigue
+type audioEvent struct {
+	Type   string `json:"type"`
+	Format string `json:"format"`
+	Bytes  int    `json:"bytes"`
+}
+
+// wsSession es el estado de una conexión en /v1/ws: un turno a la vez, que
+// continúa la misma conversación salvo que el cliente indique otra
+type wsSession struct {
+	server *Server
+	conn   *wsConn
+	r      *http.Request
+
+	conversationID string
+	userID         string
+
+	// chunks recibe el audio del turno de voz en curso (nil si no hay). Solo la
+	// goroutine que lee de la conexión envía a chunks y lo cierra.
+	chunks chan []byte
+
+	mu     sync.Mutex
+	cancel context.CancelFunc // cancela el turno en curso; nil si no hay
+	turns  sync.WaitGroup
+}
+
+// handleWebSocket atiende /v1/ws. Protocolo:
+//
+//	cliente → {"type":"start", "sample_rate":16000, "channels":1, "tts":true}
+//	cliente → mensajes binarios con PCM de 16 bits little-endian
+//	cliente → {"type":"stop"}                (fin del audio)
+//	cliente → {"type":"text", "text":"..."}   (turno de texto)
+//	cliente → {"type":"cancel"}               (interrumpe el turno en curso)
+//
+//	servidor → {"type":"ready"}, transcript.partial, transcript.final, intent,
+//	           token, audio (seguido de un mensaje binario WAV), done, error
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r, s.config.AllowedOrigins) {
+		writeError(w, http.StatusForbidden, "forbidden", "origen no permitido: "+r.Header.Get("Origin"))
+		return
+	}
+	conn, err := upgradeWebSocket(w, r, wsMaxMessage, wsIdleTimeout)
+	if err != nil {
+		s.log.DebugContext(r.Context(), "handshake WebSocket rechazado", "error", err)
+		return
+	}
+
+	session := &wsSession{server: s, conn: conn, r: r}
+	if !s.addSession(session) {
+		conn.Close(closeGoingAway, "servidor deteniéndose")
+		return
+	}
+	defer s.removeSession(session)
+	defer session.close()
+
+	conn.WriteJSON(struct {
+		Type       string `json:"type"`
+		Transcribe bool   `json:"transcribe"`
+		TTS        bool   `json:"tts"`
+	}{"ready", s.agent.CanTranscribe(), s.agent.CanSynthesize()})
+
+	for {
+		opcode, message, err := conn.ReadMessage()
+		if err != nil {
+			if !errors.Is(err, errWebSocketClosed) {
+				s.log.DebugContext(r.Context(), "conexión WebSocket terminada", "error", err)
+			}
+			return
+		}
+
+		if opcode == opBinary {
+			session.audio(message)
+			continue
+		}
+
+		var msg wsClientMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			session.sendError("invalid_json", "mensaje inválido: "+err.Error())
+			continue
+		}
+		session.handle(msg)
+	}
+}
+
+// handle procesa un mensaje de control del cliente
+func (ws *wsSession) handle(msg wsClientMessage) {
+	switch msg.Type {
+	case "start":
+		if !ws.server.agent.CanTranscribe() {
+			ws.sendError("unavailable", agent.ErrNoTranscriber.Error())
+			return
+		}
+		ws.startTurn(msg, true)
+	case "text":
+		if strings.TrimSpace(msg.Text) == "" {
+			ws.sendError("invalid_request", "el campo text es obligatorio")
+			return
+		}
+		ws.startTurn(msg, false)
+	case "stop":
+		ws.endAudio()
+	case "cancel":
+		ws.mu.Lock()
+		if ws.cancel != nil {
+			ws.cancel()
+		}
+		ws.mu.Unlock()
+	default:
+		ws.sendError("invalid_request", fmt.Sprintf("tipo de mensaje desconocido: %q", msg.Type))
+	}
+}
+
+// audio encola un chunk de PCM para el turno de voz en curso
+func (ws *wsSession) audio(chunk []byte) {
+	if ws.chunks == nil {
+		ws.sendError("invalid_request", `audio recibido sin {"type":"start"}`)
+		return
+	}
+	// Si la transcripción va retrasada, esperar aplica contrapresión al cliente
+	ws.chunks <- chunk
+}
+
+// endAudio cierra el audio del turno de voz en curso, si lo hay
+func (ws *wsSession) endAudio() {
+	if ws.chunks != nil {
+		close(ws.chunks)
+		ws.chunks = nil
+	}
+}
+
+// startTurn inicia un turno de voz (los chunks llegan después) o de texto
+func (ws *wsSession) startTurn(msg wsClientMessage, voice bool) {
+	ws.mu.Lock()
+	if ws.cancel != nil {
+		ws.mu.Unlock()
+		ws.sendError("busy", `ya hay un turno en curso; envía {"type":"cancel"} para interrumpirlo`)
+		return
+	}
+	if msg.ConversationID != "" {
+		ws.conversationID = msg.ConversationID
+	}
+	if msg.UserID != "" {
+		ws.userID = msg.UserID
+	}
+	request := agent.ChatRequest{Text: msg.Text, ConversationID: ws.conversationID, UserID: ws.userID}
+
+	ctx, cancel := context.WithCancel(ws.r.Context())
+	ws.cancel = cancel
+	ws.mu.Unlock()
+
+	ws.endAudio()
+	var chunks chan []byte
+	if voice {
+		chunks = make(chan []byte, wsChunkBacklog)
+		ws.chunks = chunks
+	}
+
+	handler := ws.handler(msg.TTS)
+	ws.turns.Add(1)
+	go func() {
+		defer ws.turns.Done()
+
+		var result *agent.ChatResult
+		var err error
+		if voice {
+			audio := speech.ChunkConfig{SampleRate: msg.SampleRate, Channels: msg.Channels}
+			result, err = ws.server.agent.Voice(ctx, chunks, audio, request, handler)
+			// Si terminó antes del "stop", descartar el audio que siga llegando
+			go drain(chunks)
+		} else {
+			result, err = ws.server.agent.ChatStream(ctx, request, handler)
+		}
+
+		ws.mu.Lock()
+		ws.cancel = nil
+		if result != nil {
+			ws.conversationID = result.ConversationID
+		}
+		ws.mu.Unlock()
+		cancel()
+
+		switch {
+		case err == nil:
+			ws.conn.WriteJSON(doneEvent{Type: "done", ChatResult: result})
+		case errors.Is(err, context.Canceled):
+			ws.sendError("canceled", "turno cancelado")
+		default:
+			_, body := ws.server.classify(ws.r, err)
+			ws.sendError(body.Error.Code, body.Error.Message)
+		}
+	}()
+}
+
+// drain descarta los chunks pendientes hasta que se cierre el canal
+func drain(chunks <-chan []byte) {
+	for range chunks {
+	}
+}
+
+// handler devuelve los callbacks que envían los eventos del turno al cliente
+func (ws *wsSession) handler(tts bool) agent.StreamHandler {
+	handler := agent.StreamHandler{
+		OnTranscript: func(text string, final bool) {
+			event := "transcript.partial"
+			if final {
+				event = "transcript.final"
+			}
+			ws.conn.WriteJSON(textEvent{Type: event, Text: text})
+		},
+		OnIntent: func(intent *nlp.Intent) {
+			ws.conn.WriteJSON(newIntentEvent(intent))
+		},
+		OnToken: func(token string) error {
+			return ws.conn.WriteJSON(textEvent{Type: "token", Text: token})
+		},
+	}
+	if tts {
+		handler.OnAudio = func(audio []byte, format string) {
+			ws.conn.WriteJSONAndBinary(audioEvent{Type: "audio", Format: format, Bytes: len(audio)}, audio)
+		}
+	}
+	return handler
+}
+
+// sendError envía un evento de error sin cerrar la conexión
+func (ws *wsSession) sendError(code, message string) {
+	var body errorBody
+	body.Error.Code = code
+	body.Error.Message = message
+	ws.conn.WriteJSON(struct {
+		Type string `json:"type"`
+		errorBody
+	}{"error", body})
+}
+
+// shutdown cancela el turno en curso y cierra la conexión porque el servidor
+// se detiene; la goroutine de lectura termina entonces y llama a close
+func (ws *wsSession) shutdown() {
+	ws.mu.Lock()
+	if ws.cancel != nil {
+		ws.cancel()
+	}
+	ws.mu.Unlock()
+	ws.conn.Close(closeGoingAway, "servidor deteniéndose")
+}
+
+// close cancela el turno en curso, espera a que termine y cierra la conexión
+func (ws *wsSession) close() {
+	ws.mu.Lock()
+	if ws.cancel != nil {
+		ws.cancel()
+	}
+	ws.mu.Unlock()
+	ws.endAudio()
+	ws.turns.Wait()
+	ws.conn.Close(closeNormal, "")
+}
@@ -0,0 +1,359 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/internal/agent"
+	"github.com/akosej/agent/internal/learning"
+	"github.com/akosej/agent/internal/nlp"
+	"github.com/akosej/agent/internal/speech"
+	"github.com/akosej/agent/pkg/logger"
+	"github.com/akosej/agent/pkg/storage"
+	"github.com/akosej/agent/testing/fakes"
+)
+
+// newTestServer crea un servidor con un agente completo sobre Ollama y
+// Whisper falsos y un almacén JSON temporal
+func newTestServer(t *testing.T, config Config) (*Server, *fakes.Ollama) {
+	t.Helper()
+	ollama := fakes.NewOllama()
+	t.Cleanup(ollama.Close)
+	ollama.On(fakes.Match{System: "intenciones"}, fakes.FormatIntent("saludo", 0.9, nil))
+	ollama.Reply("Hola, ¿en qué te ayudo?")
+	whisper := fakes.NewWhisperAPI()
+	t.Cleanup(whisper.Close)
+
+	store, err := storage.NewJSONStore(storage.Config{Type: "json", Path: filepath.Join(t.TempDir(), "data.json")})
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	log, err := logger.NewLogger(logger.Config{Output: io.Discard})
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+
+	a, err := agent.New(agent.Config{Model: fakes.DefaultModel}, agent.Dependencies{
+		Model:       nlp.NewProcessor(ollama.URL, nlp.Config{Model: fakes.DefaultModel}),
+		Engine:      learning.NewEngine(learning.Config{}),
+		Store:       store,
+		Transcriber: speech.NewTranscriberWithAPI(whisper.URL, "es"),
+		Logger:      log,
+	})
+	if err != nil {
+		t.Fatalf("agent.New: %v", err)
+	}
+	return New(config, a, log), ollama
+}
+
+// sseEvent es un evento de Server-Sent Events
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvents lee todos los eventos de una respuesta SSE
+func readEvents(t *testing.T, body io.Reader) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			events = append(events, current)
+			current = sseEvent{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("leyendo eventos: %v", err)
+	}
+	return events
+}
+
+func TestChatStream(t *testing.T) {
+	s, ollama := newTestServer(t, Config{})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	post := func(body string) *http.Request {
+		request, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/stream", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		return request
+	}
+	get := func(query string) *http.Request {
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/chat/stream?"+query, nil)
+		return request
+	}
+
+	for name, request := range map[string]*http.Request{
+		"post": post(`{"text":"hola","user_id":"ana"}`),
+		"get":  get(url.Values{"text": {"hola"}, "user_id": {"ana"}}.Encode()),
+	} {
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		events := readEvents(t, response.Body)
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK || !strings.HasPrefix(response.Header.Get("Content-Type"), "text/event-stream") {
+			t.Fatalf("%s: status %d, tipo %q", name, response.StatusCode, response.Header.Get("Content-Type"))
+		}
+
+		if len(events) < 3 || events[0].name != "intent" || events[len(events)-1].name != "done" {
+			t.Fatalf("%s: eventos = %+v, want intent, token..., done", name, events)
+		}
+		var intent intentEvent
+		json.Unmarshal([]byte(events[0].data), &intent)
+		if intent.Intent != "saludo" || intent.Type != "" {
+			t.Errorf("%s: intent = %s", name, events[0].data)
+		}
+		var text strings.Builder
+		for _, event := range events[1 : len(events)-1] {
+			var token textEvent
+			if event.name != "token" || json.Unmarshal([]byte(event.data), &token) != nil {
+				t.Fatalf("%s: evento inesperado %+v", name, event)
+			}
+			text.WriteString(token.Text)
+		}
+		var result agent.ChatResult
+		if err := json.Unmarshal([]byte(events[len(events)-1].data), &result); err != nil {
+			t.Fatalf("%s: done = %s: %v", name, events[len(events)-1].data, err)
+		}
+		if text.String() != "Hola, ¿en qué te ayudo?" || result.Response != text.String() || result.ConversationID == "" {
+			t.Errorf("%s: tokens %q, resultado %+v", name, text.String(), result)
+		}
+	}
+
+	// Errores antes de empezar el stream: respuesta JSON normal
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+	}{
+		{"empty text", post(`{"text":"  "}`), http.StatusBadRequest},
+		{"get without text", get(""), http.StatusBadRequest},
+		{"unknown field", post(`{"texto":"hola"}`), http.StatusBadRequest},
+		{"put", func() *http.Request {
+			request, _ := http.NewRequest(http.MethodPut, server.URL+"/v1/chat/stream", nil)
+			return request
+		}(), http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		response, err := http.DefaultClient.Do(tt.request)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		response.Body.Close()
+		if response.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, response.StatusCode, tt.status)
+		}
+	}
+
+	// Un fallo del modelo una vez abierto el stream llega como evento error
+	ollama.Fail("/api/chat", fakes.Fault{Status: http.StatusInternalServerError})
+	response, err := http.DefaultClient.Do(post(`{"text":"hola"}`))
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	events := readEvents(t, response.Body)
+	response.Body.Close()
+	if len(events) == 0 || events[len(events)-1].name != "error" {
+		t.Fatalf("eventos = %+v, want error al final", events)
+	}
+	var body errorBody
+	if err := json.Unmarshal([]byte(events[len(events)-1].data), &body); err != nil || body.Error.Code == "" {
+		t.Errorf("error = %s", events[len(events)-1].data)
+	}
+}
+
+// dialWebSocket abre /v1/ws en el servidor de base y completa el handshake
+func dialWebSocket(t *testing.T, base string) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(base, "http://"))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	request, _ := http.NewRequest(http.MethodGet, base+"/v1/ws", nil)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := request.Write(conn); err != nil {
+		t.Fatalf("escribiendo handshake: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		t.Fatalf("ReadResponse: %v", err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake = %d", response.StatusCode)
+	}
+	return &wsClient{conn: conn, reader: reader}
+}
+
+// sendJSON envía un mensaje de control
+func (c *wsClient) sendJSON(t *testing.T, value interface{}) {
+	t.Helper()
+	data, _ := json.Marshal(value)
+	c.send(t, opText, data)
+}
+
+// nextEvent devuelve el tipo y el contenido del siguiente mensaje de texto
+func (c *wsClient) nextEvent(t *testing.T) (string, []byte) {
+	t.Helper()
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			t.Fatalf("leyendo evento: %v", err)
+		}
+		switch opcode {
+		case opText:
+			var event struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(payload, &event); err != nil {
+				t.Fatalf("evento inválido %s: %v", payload, err)
+			}
+			return event.Type, payload
+		case opClose:
+			t.Fatalf("conexión cerrada: %v", payload)
+		}
+	}
+}
+
+// turnEvents lee eventos hasta done o error y devuelve sus tipos, con los
+// tokens agrupados en uno, y el último evento
+func (c *wsClient) turnEvents(t *testing.T) ([]string, []byte) {
+	t.Helper()
+	var types []string
+	for {
+		kind, payload := c.nextEvent(t)
+		if kind != "token" || len(types) == 0 || types[len(types)-1] != "token" {
+			types = append(types, kind)
+		}
+		if kind == "done" || kind == "error" {
+			return types, payload
+		}
+	}
+}
+
+func TestWebSocketVoiceTurn(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+	client := dialWebSocket(t, server.URL)
+
+	if kind, payload := client.nextEvent(t); kind != "ready" || !strings.Contains(string(payload), `"transcribe":true`) {
+		t.Fatalf("primer evento = %s", payload)
+	}
+
+	// Audio sin start
+	client.send(t, opBinary, make([]byte, 10))
+	if kind, payload := client.nextEvent(t); kind != "error" || !strings.Contains(string(payload), "invalid_request") {
+		t.Errorf("audio sin start = %s", payload)
+	}
+
+	// Turno de voz: 2 s de audio a 16 kHz producen una transcripción parcial
+	client.sendJSON(t, wsClientMessage{Type: "start", SampleRate: 16000, Channels: 1, UserID: "ana"})
+	client.send(t, opBinary, make([]byte, 32000))
+	client.send(t, opBinary, make([]byte, 32000))
+	client.sendJSON(t, wsClientMessage{Type: "stop"})
+
+	types, payload := client.turnEvents(t)
+	want := []string{"transcript.partial", "transcript.final", "intent", "token", "done"}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Fatalf("eventos = %v, want %v (último %s)", types, want, payload)
+	}
+	var done doneEvent
+	if err := json.Unmarshal(payload, &done); err != nil || done.ChatResult == nil {
+		t.Fatalf("done = %s: %v", payload, err)
+	}
+	if done.Intent != "saludo" || done.Response != "Hola, ¿en qué te ayudo?" {
+		t.Errorf("resultado = %+v", done.ChatResult)
+	}
+
+	// El turno de texto siguiente continúa la misma conversación
+	client.sendJSON(t, wsClientMessage{Type: "text", Text: "¿sigues ahí?"})
+	types, payload = client.turnEvents(t)
+	var next doneEvent
+	json.Unmarshal(payload, &next)
+	if types[len(types)-1] != "done" || next.ChatResult == nil || next.ConversationID != done.ConversationID {
+		t.Errorf("turno de texto = %v, %s; want conversación %s", types, payload, done.ConversationID)
+	}
+
+	client.sendJSON(t, wsClientMessage{Type: "bailar"})
+	if kind, payload := client.nextEvent(t); kind != "error" {
+		t.Errorf("tipo desconocido = %s", payload)
+	}
+
+	client.send(t, opClose, []byte{0x03, 0xE8})
+	client.expectClose(t, closeNormal)
+}
+
+// Al detenerse, el servidor cancela los turnos en curso, cierra las sesiones
+// con 1001 y espera a que terminen antes de que Serve vuelva
+func TestWebSocketShutdown(t *testing.T) {
+	s, ollama := newTestServer(t, Config{ShutdownTimeout: 5 * time.Second})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, listener) }()
+
+	// El modelo tarda más que el plazo de apagado: solo cancelando el turno
+	// puede Serve volver a tiempo
+	ollama.Fail("/api/chat", fakes.Fault{Delay: time.Minute})
+	client := dialWebSocket(t, "http://"+listener.Addr().String())
+	if kind, _ := client.nextEvent(t); kind != "ready" {
+		t.Fatalf("primer evento = %s", kind)
+	}
+	client.sendJSON(t, wsClientMessage{Type: "text", Text: "hola"})
+	deadline := time.Now().Add(5 * time.Second)
+	for ollama.Count("/api/chat") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("el turno no llegó al modelo")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	start := time.Now()
+	cancel()
+	client.expectClose(t, closeGoingAway)
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("Serve tardó %s en volver", elapsed)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Serve no volvió tras cancelar")
+	}
+	if len(s.sessions) != 0 {
+		t.Errorf("quedan %d sesiones registradas", len(s.sessions))
+	}
+}
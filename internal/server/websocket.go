@@ -0,0 +1,336 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Implementación mínima de WebSocket (RFC 6455) para el servidor: sin
+// extensiones ni subprotocolos, suficiente para los navegadores y clientes
+// habituales sin añadir dependencias.
+
+// websocketGUID es la constante del protocolo para calcular Sec-WebSocket-Accept
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Códigos de operación de los frames
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Códigos de cierre
+const (
+	closeNormal      = 1000
+	closeGoingAway   = 1001
+	closeProtocol    = 1002
+	closeUnsupported = 1003
+	closeTooBig      = 1009
+)
+
+// errWebSocketClosed indica que el cliente cerró la conexión
+var errWebSocketClosed = errors.New("conexión WebSocket cerrada")
+
+// wsConn es una conexión WebSocket del lado del servidor. Las lecturas se
+// hacen desde una sola goroutine; las escrituras son seguras en paralelo.
+type wsConn struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	maxMessage  int64
+	idleTimeout time.Duration
+
+	writeMu sync.Mutex
+	closed  bool
+}
+
+// isWebSocketRequest indica si la petición pide cambiar a WebSocket
+func isWebSocketRequest(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// headerContainsToken busca un token en una cabecera con valores separados por comas
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket completa el handshake y toma el control de la conexión.
+// Si falla ya ha respondido al cliente con el error.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, maxMessage int64, idleTimeout time.Duration) (*wsConn, error) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "método no permitido: "+r.Method)
+		return nil, errors.New("método no permitido")
+	}
+	if !isWebSocketRequest(r) {
+		w.Header().Set("Upgrade", "websocket")
+		writeError(w, http.StatusUpgradeRequired, "upgrade_required", "se esperaba una petición WebSocket")
+		return nil, errors.New("no es una petición WebSocket")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, http.StatusUpgradeRequired, "upgrade_required", "versión de WebSocket no soportada")
+		return nil, errors.New("versión de WebSocket no soportada")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Sec-WebSocket-Key inválida")
+		return nil, errors.New("Sec-WebSocket-Key inválida")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", "la conexión no admite WebSocket")
+		return nil, fmt.Errorf("error tomando la conexión: %w", err)
+	}
+	// Los plazos del servidor HTTP ya no aplican; los gestiona wsConn
+	conn.SetDeadline(time.Time{})
+
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n"
+	if _, err := rw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &wsConn{conn: conn, reader: rw.Reader, maxMessage: maxMessage, idleTimeout: idleTimeout}, nil
+}
+
+// sameOrigin comprueba la cabecera Origin contra el Host de la petición y la
+// lista de orígenes permitidos ("*" permite cualquiera). Los clientes que no
+// son navegadores no envían Origin y se aceptan.
+func sameOrigin(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(a, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// frameHeader es la cabecera de un frame recibido
+type frameHeader struct {
+	fin    bool
+	opcode byte
+	length int64
+	mask   [4]byte
+}
+
+// readFrameHeader lee y valida la cabecera de un frame del cliente
+func (c *wsConn) readFrameHeader() (frameHeader, error) {
+	var h frameHeader
+	var b [8]byte
+	if _, err := io.ReadFull(c.reader, b[:2]); err != nil {
+		return h, err
+	}
+	h.fin = b[0]&0x80 != 0
+	h.opcode = b[0] & 0x0F
+	if b[0]&0x70 != 0 {
+		return h, c.fail(closeProtocol, "bits RSV no soportados")
+	}
+	if b[1]&0x80 == 0 {
+		return h, c.fail(closeProtocol, "los frames del cliente deben ir enmascarados")
+	}
+
+	switch length := b[1] & 0x7F; length {
+	case 126:
+		if _, err := io.ReadFull(c.reader, b[:2]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.reader, b[:8]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint64(b[:8]))
+		if h.length < 0 {
+			return h, c.fail(closeProtocol, "longitud de frame inválida")
+		}
+	default:
+		h.length = int64(length)
+	}
+
+	if _, err := io.ReadFull(c.reader, h.mask[:]); err != nil {
+		return h, err
+	}
+	if h.opcode >= opClose && (!h.fin || h.length > 125) {
+		return h, c.fail(closeProtocol, "frame de control inválido")
+	}
+	return h, nil
+}
+
+// readPayload lee y desenmascara el contenido de un frame
+func (c *wsConn) readPayload(h frameHeader) ([]byte, error) {
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return nil, err
+	}
+	for i := range payload {
+		payload[i] ^= h.mask[i%4]
+	}
+	return payload, nil
+}
+
+// ReadMessage devuelve el siguiente mensaje de texto o binario, reuniendo los
+// fragmentos y respondiendo a ping y close. Devuelve errWebSocketClosed cuando
+// el cliente cierra la conexión.
+func (c *wsConn) ReadMessage() (opcode byte, message []byte, err error) {
+	for {
+		if c.idleTimeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+		}
+		h, err := c.readFrameHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+		if int64(len(message))+h.length > c.maxMessage {
+			return 0, nil, c.fail(closeTooBig, "mensaje demasiado grande")
+		}
+		payload, err := c.readPayload(h)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch h.opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			code := closeNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.Close(code, "")
+			return 0, nil, errWebSocketClosed
+		case opText, opBinary:
+			if opcode != 0 {
+				return 0, nil, c.fail(closeProtocol, "se esperaba un frame de continuación")
+			}
+			opcode = h.opcode
+		case opContinuation:
+			if opcode == 0 {
+				return 0, nil, c.fail(closeProtocol, "frame de continuación inesperado")
+			}
+		default:
+			return 0, nil, c.fail(closeProtocol, "código de operación desconocido")
+		}
+
+		message = append(message, payload...)
+		if h.fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// writeFrame escribe un frame sin fragmentar ni enmascarar
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *wsConn) writeFrameLocked(opcode byte, payload []byte) error {
+	if c.closed {
+		return errWebSocketClosed
+	}
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteJSON envía value como mensaje de texto JSON
+func (c *wsConn) WriteJSON(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(opText, data)
+}
+
+// WriteJSONAndBinary envía un mensaje JSON seguido de uno binario sin que otra
+// escritura se intercale entre ambos
+func (c *wsConn) WriteJSONAndBinary(value interface{}, data []byte) error {
+	header, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.writeFrameLocked(opText, header); err != nil {
+		return err
+	}
+	return c.writeFrameLocked(opBinary, data)
+}
+
+// fail cierra la conexión por un error de protocolo y lo devuelve
+func (c *wsConn) fail(code int, reason string) error {
+	c.Close(code, reason)
+	return fmt.Errorf("error de protocolo WebSocket: %s", reason)
+}
+
+// Close envía el frame de cierre (una sola vez) y cierra la conexión
+func (c *wsConn) Close(code int, reason string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	c.writeFrameLocked(opClose, append(payload, reason...))
+	c.closed = true
+	return c.conn.Close()
+}
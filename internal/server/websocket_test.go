@@ -0,0 +1,366 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient es el extremo del cliente de una conexión WebSocket: escribe frames
+// enmascarados (o no, para probar errores) y lee los del servidor
+type wsClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// writeFrame escribe un frame con la longitud codificada en 7, 16 o 64 bits
+func (c *wsClient) writeFrame(t *testing.T, fin bool, opcode byte, payload []byte, masked bool) {
+	t.Helper()
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first, 0}
+	switch n := len(payload); {
+	case n <= 125:
+		frame[1] = byte(n)
+	case n <= 0xFFFF:
+		frame[1] = 126
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame[1] = 127
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	body := append([]byte(nil), payload...)
+	if masked {
+		frame[1] |= 0x80
+		mask := []byte{0x37, 0xfa, 0x21, 0x3d}
+		frame = append(frame, mask...)
+		for i := range body {
+			body[i] ^= mask[i%4]
+		}
+	}
+	if _, err := c.conn.Write(append(frame, body...)); err != nil {
+		t.Errorf("escribiendo frame: %v", err)
+	}
+}
+
+// send escribe un mensaje completo en un frame enmascarado
+func (c *wsClient) send(t *testing.T, opcode byte, payload []byte) {
+	t.Helper()
+	c.writeFrame(t, true, opcode, payload, true)
+}
+
+// readFrame lee un frame del servidor, que nunca van enmascarados
+func (c *wsClient) readFrame() (opcode byte, payload []byte, err error) {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var b [8]byte
+	if _, err := io.ReadFull(c.reader, b[:2]); err != nil {
+		return 0, nil, err
+	}
+	if b[0]&0x80 == 0 || b[1]&0x80 != 0 {
+		return 0, nil, errors.New("frame del servidor fragmentado o enmascarado")
+	}
+	opcode = b[0] & 0x0F
+	length := uint64(b[1] & 0x7F)
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(c.reader, b[:2]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.reader, b[:8]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(b[:8])
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(c.reader, payload)
+	return opcode, payload, err
+}
+
+// expectClose lee frames hasta el de cierre y comprueba su código
+func (c *wsClient) expectClose(t *testing.T, code int) {
+	t.Helper()
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			t.Fatalf("esperando cierre %d: %v", code, err)
+		}
+		if opcode != opClose {
+			continue
+		}
+		if len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != code {
+			t.Fatalf("cierre = %v, want código %d", payload, code)
+		}
+		return
+	}
+}
+
+// wsPair conecta un wsConn con un wsClient por TCP local
+func wsPair(t *testing.T, maxMessage int64) (*wsConn, *wsClient) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := listener.Accept()
+		accepted <- conn
+	}()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	server := <-accepted
+	if server == nil {
+		t.Fatal("Accept falló")
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return &wsConn{conn: server, reader: bufio.NewReader(server), maxMessage: maxMessage},
+		&wsClient{conn: client, reader: bufio.NewReader(client)}
+}
+
+func TestWebSocketReadMessage(t *testing.T) {
+	long := bytes.Repeat([]byte("a"), 300)
+	huge := bytes.Repeat([]byte("b"), 70000)
+
+	tests := []struct {
+		name   string
+		write  func(t *testing.T, c *wsClient)
+		opcode byte
+		want   []byte
+	}{
+		{"text", func(t *testing.T, c *wsClient) { c.send(t, opText, []byte("hola")) }, opText, []byte("hola")},
+		{"binary", func(t *testing.T, c *wsClient) { c.send(t, opBinary, []byte{0, 1, 2}) }, opBinary, []byte{0, 1, 2}},
+		{"empty", func(t *testing.T, c *wsClient) { c.send(t, opText, nil) }, opText, []byte{}},
+		{"16-bit length", func(t *testing.T, c *wsClient) { c.send(t, opText, long) }, opText, long},
+		{"64-bit length", func(t *testing.T, c *wsClient) { c.send(t, opBinary, huge) }, opBinary, huge},
+		{"fragmented", func(t *testing.T, c *wsClient) {
+			c.writeFrame(t, false, opText, []byte("ho"), true)
+			c.writeFrame(t, false, opContinuation, []byte("l"), true)
+			c.writeFrame(t, true, opContinuation, []byte("a"), true)
+		}, opText, []byte("hola")},
+		{"pong ignored", func(t *testing.T, c *wsClient) {
+			c.send(t, opPong, []byte("x"))
+			c.send(t, opText, []byte("hola"))
+		}, opText, []byte("hola")},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := wsPair(t, 1<<20)
+			go tt.write(t, client)
+
+			opcode, message, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("ReadMessage: %v", err)
+			}
+			if opcode != tt.opcode || !bytes.Equal(message, tt.want) {
+				t.Errorf("ReadMessage = %d, %d bytes, want %d, %d bytes", opcode, len(message), tt.opcode, len(tt.want))
+			}
+		})
+	}
+}
+
+// Un ping entre fragmentos se contesta con un pong con el mismo contenido
+func TestWebSocketPingBetweenFragments(t *testing.T) {
+	conn, client := wsPair(t, 1<<20)
+	go func() {
+		client.writeFrame(t, false, opText, []byte("ho"), true)
+		client.send(t, opPing, []byte("latido"))
+		client.writeFrame(t, true, opContinuation, []byte("la"), true)
+	}()
+
+	_, message, err := conn.ReadMessage()
+	if err != nil || string(message) != "hola" {
+		t.Fatalf("ReadMessage = %q, %v", message, err)
+	}
+	opcode, payload, err := client.readFrame()
+	if err != nil || opcode != opPong || string(payload) != "latido" {
+		t.Errorf("respuesta al ping = %d %q, %v", opcode, payload, err)
+	}
+}
+
+func TestWebSocketClose(t *testing.T) {
+	conn, client := wsPair(t, 1<<20)
+	go client.send(t, opClose, binary.BigEndian.AppendUint16(nil, closeGoingAway))
+
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, errWebSocketClosed) {
+		t.Fatalf("ReadMessage = %v, want errWebSocketClosed", err)
+	}
+	// El servidor devuelve el código recibido y no vuelve a escribir
+	client.expectClose(t, closeGoingAway)
+	if err := conn.WriteJSON("tarde"); !errors.Is(err, errWebSocketClosed) {
+		t.Errorf("WriteJSON tras cerrar = %v, want errWebSocketClosed", err)
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		max   int64
+		write func(t *testing.T, c *wsClient)
+		code  int
+	}{
+		{"unmasked", 1 << 20, func(t *testing.T, c *wsClient) { c.writeFrame(t, true, opText, []byte("hola"), false) }, closeProtocol},
+		{"rsv bits", 1 << 20, func(t *testing.T, c *wsClient) { c.send(t, opText|0x40, []byte("hola")) }, closeProtocol},
+		{"unknown opcode", 1 << 20, func(t *testing.T, c *wsClient) { c.send(t, 0x3, []byte("hola")) }, closeProtocol},
+		{"continuation first", 1 << 20, func(t *testing.T, c *wsClient) { c.send(t, opContinuation, []byte("hola")) }, closeProtocol},
+		{"text inside fragments", 1 << 20, func(t *testing.T, c *wsClient) {
+			c.writeFrame(t, false, opText, []byte("ho"), true)
+			c.send(t, opText, []byte("la"))
+		}, closeProtocol},
+		{"fragmented control", 1 << 20, func(t *testing.T, c *wsClient) { c.writeFrame(t, false, opPing, nil, true) }, closeProtocol},
+		{"long control", 1 << 20, func(t *testing.T, c *wsClient) { c.send(t, opPing, bytes.Repeat([]byte("x"), 126)) }, closeProtocol},
+		{"too big", 8, func(t *testing.T, c *wsClient) { c.send(t, opText, []byte("demasiado largo")) }, closeTooBig},
+		{"too big fragmented", 8, func(t *testing.T, c *wsClient) {
+			c.writeFrame(t, false, opText, []byte("cinco"), true)
+			c.writeFrame(t, true, opContinuation, []byte("cinco"), true)
+		}, closeTooBig},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := wsPair(t, tt.max)
+			go tt.write(t, client)
+
+			if _, _, err := conn.ReadMessage(); err == nil || errors.Is(err, errWebSocketClosed) {
+				t.Fatalf("ReadMessage = %v, want error de protocolo", err)
+			}
+			client.expectClose(t, tt.code)
+		})
+	}
+}
+
+func TestWebSocketWriteFraming(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 0xFFFF, 0x10000} {
+		conn, client := wsPair(t, 1<<20)
+		payload := bytes.Repeat([]byte("z"), size)
+		go conn.writeFrame(opBinary, payload)
+
+		opcode, got, err := client.readFrame()
+		if err != nil || opcode != opBinary || !bytes.Equal(got, payload) {
+			t.Errorf("frame de %d bytes = %d, %d bytes, %v", size, opcode, len(got), err)
+		}
+	}
+
+	// El JSON y el binario del audio llegan seguidos
+	conn, client := wsPair(t, 1<<20)
+	go conn.WriteJSONAndBinary(audioEvent{Type: "audio", Format: "wav", Bytes: 3}, []byte{1, 2, 3})
+	if opcode, payload, err := client.readFrame(); err != nil || opcode != opText || !strings.Contains(string(payload), `"bytes":3`) {
+		t.Errorf("cabecera del audio = %d %q, %v", opcode, payload, err)
+	}
+	if opcode, payload, err := client.readFrame(); err != nil || opcode != opBinary || len(payload) != 3 {
+		t.Errorf("audio = %d %v, %v", opcode, payload, err)
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		allowed []string
+		want    bool
+	}{
+		{"no origin", "", nil, true},
+		{"same host", "http://agent.local:8080", nil, true},
+		{"same host other scheme", "https://AGENT.local:8080", nil, true},
+		{"other host", "http://evil.example", nil, false},
+		{"other port", "http://agent.local:9090", nil, false},
+		{"allowed", "http://portal.intranet", []string{"http://portal.intranet"}, true},
+		{"allowed case", "http://Portal.Intranet", []string{"http://portal.intranet"}, true},
+		{"wildcard", "http://evil.example", []string{"*"}, true},
+		{"invalid", "://", nil, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://agent.local:8080/v1/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := sameOrigin(r, tt.allowed); got != tt.want {
+				t.Errorf("sameOrigin(%q, %v) = %v, want %v", tt.origin, tt.allowed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	upgraded := make(chan *wsConn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgradeWebSocket(w, r, 1<<20, time.Minute)
+		if err == nil {
+			upgraded <- conn
+		}
+	}))
+	defer server.Close()
+
+	valid := http.Header{
+		"Connection":            {"keep-alive, Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Version": {"13"},
+		"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+	}
+	tests := []struct {
+		name   string
+		method string
+		edit   func(h http.Header)
+		status int
+	}{
+		{"post", http.MethodPost, func(h http.Header) {}, http.StatusMethodNotAllowed},
+		{"not upgrade", http.MethodGet, func(h http.Header) { h.Del("Upgrade") }, http.StatusUpgradeRequired},
+		{"old version", http.MethodGet, func(h http.Header) { h.Set("Sec-WebSocket-Version", "8") }, http.StatusUpgradeRequired},
+		{"bad key", http.MethodGet, func(h http.Header) { h.Set("Sec-WebSocket-Key", "corta") }, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			request, _ := http.NewRequest(tt.method, server.URL, nil)
+			request.Header = valid.Clone()
+			tt.edit(request.Header)
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			response.Body.Close()
+			if response.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", response.StatusCode, tt.status)
+			}
+		})
+	}
+
+	// Ejemplo de la sección 1.3 de RFC 6455
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	request.Header = valid.Clone()
+	request.Write(conn)
+	response, err := http.ReadResponse(bufio.NewReader(conn), request)
+	if err != nil {
+		t.Fatalf("ReadResponse: %v", err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("handshake = %d, accept %q", response.StatusCode, response.Header.Get("Sec-WebSocket-Accept"))
+	}
+	(<-upgraded).Close(closeNormal, "")
+}
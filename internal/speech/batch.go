@@ -0,0 +1,397 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AudioExtensions son las extensiones que se consideran audio al recorrer directorios
+var AudioExtensions = []string{".wav", ".mp3", ".ogg", ".flac", ".m4a", ".webm"}
+
+// IsAudioFile indica si path tiene una extensión de audio
+func IsAudioFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, audio := range AudioExtensions {
+		if ext == audio {
+			return true
+		}
+	}
+	return false
+}
+
+// DetailedTranscriber transcribe un archivo con sus segmentos; lo implementa Transcriber
+type DetailedTranscriber interface {
+	TranscribeDetailed(ctx context.Context, audioPath string) (*Transcript, error)
+}
+
+// BatchConfig configura una transcripción por lotes
+type BatchConfig struct {
+	Workers   int      // Transcripciones simultáneas (por defecto 2)
+	BaseDir   string   // Las salidas reproducen la ruta de cada audio relativa a BaseDir
+	OutputDir string   // Vacío escribe las salidas junto a cada audio
+	Formats   []string // txt, srt, vtt o json (por defecto txt)
+	Manifest  string   // Por defecto OutputDir (o BaseDir)/transcribe-manifest.json
+
+	// Summarize, si no es nil, resume cada transcripción; el resumen se guarda
+	// en <nombre>.summary.txt
+	Summarize func(ctx context.Context, transcript *Transcript) (string, error)
+	// OnResult recibe cada archivo terminado, saltado o fallido
+	OnResult func(BatchResult)
+}
+
+// BatchResult es el resultado de un archivo del lote
+type BatchResult struct {
+	Path     string
+	Outputs  []string
+	Skipped  bool // Ya estaba transcrito según el manifiesto
+	Err      error
+	Duration time.Duration
+}
+
+// BatchReport resume un lote
+type BatchReport struct {
+	Done    int
+	Skipped int
+	Failed  int
+}
+
+// manifestEntry es el estado de un archivo en el manifiesto
+type manifestEntry struct {
+	Status      string    `json:"status"` // done o failed
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	Formats     []string  `json:"formats,omitempty"`
+	Outputs     []string  `json:"outputs,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// manifest registra los archivos procesados para poder reanudar un lote
+// interrumpido; se reescribe de forma atómica tras cada archivo
+type manifest struct {
+	path    string
+	mu      sync.Mutex
+	Entries map[string]*manifestEntry `json:"files"`
+}
+
+// loadManifest lee el manifiesto o devuelve uno vacío si no existe
+func loadManifest(path string) (*manifest, error) {
+	m := &manifest{path: path, Entries: make(map[string]*manifestEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo manifiesto: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("error parseando manifiesto %s: %w", path, err)
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]*manifestEntry)
+	}
+	return m, nil
+}
+
+// done indica si el archivo ya se transcribió sin cambios desde entonces, con
+// los mismos formatos y con sus salidas aún presentes
+func (m *manifest) done(path string, info os.FileInfo, formats []string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.Entries[path]
+	if entry == nil || entry.Status != "done" || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return false
+	}
+	for _, format := range formats {
+		if !contains(entry.Formats, format) {
+			return false
+		}
+	}
+	for _, output := range entry.Outputs {
+		if _, err := os.Stat(output); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// record guarda el estado de un archivo y reescribe el manifiesto
+func (m *manifest) record(path string, entry *manifestEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Entries[path] = entry
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error codificando manifiesto: %w", err)
+	}
+	return writeFileAtomic(m.path, data)
+}
+
+// writeFileAtomic escribe en un temporal y lo renombra para no dejar el
+// archivo a medias si el proceso se interrumpe
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creando directorio: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creando temporal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error escribiendo %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error escribiendo %s: %w", path, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// TranscribeBatch transcribe files con un máximo de config.Workers a la vez.
+// Los archivos que el manifiesto da por transcritos se saltan, de modo que
+// repetir el comando tras una interrupción continúa donde se quedó. Si ctx se
+// cancela, los archivos en curso no se marcan como fallidos.
+func TranscribeBatch(ctx context.Context, transcriber DetailedTranscriber, files []string, config BatchConfig) (*BatchReport, error) {
+	if config.Workers <= 0 {
+		config.Workers = 2
+	}
+	if len(config.Formats) == 0 {
+		config.Formats = []string{"txt"}
+	}
+	for _, format := range config.Formats {
+		if !contains(TranscriptFormats, format) {
+			return nil, fmt.Errorf("formato desconocido: %s (%s)", format, strings.Join(TranscriptFormats, ", "))
+		}
+	}
+	if config.Manifest == "" {
+		dir := config.OutputDir
+		if dir == "" {
+			dir = config.BaseDir
+		}
+		config.Manifest = filepath.Join(dir, "transcribe-manifest.json")
+	}
+
+	m, err := loadManifest(config.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BatchReport{}
+	var reportMu sync.Mutex
+	finish := func(result BatchResult) {
+		reportMu.Lock()
+		switch {
+		case result.Skipped:
+			report.Skipped++
+		case result.Err != nil:
+			report.Failed++
+		default:
+			report.Done++
+		}
+		reportMu.Unlock()
+		if config.OnResult != nil {
+			config.OnResult(result)
+		}
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				result, interrupted := transcribeOne(ctx, transcriber, path, config, m)
+				if interrupted {
+					continue
+				}
+				finish(result)
+			}
+		}()
+	}
+
+	for _, path := range files {
+		select {
+		case jobs <- path:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return report, ctx.Err()
+}
+
+// transcribeOne procesa un archivo y lo anota en el manifiesto. interrupted
+// indica que ctx se canceló a medias y el archivo queda pendiente.
+func transcribeOne(ctx context.Context, transcriber DetailedTranscriber, path string, config BatchConfig, m *manifest) (result BatchResult, interrupted bool) {
+	result.Path = path
+	start := time.Now()
+
+	// El manifiesto usa rutas absolutas para servir desde cualquier directorio
+	key, err := filepath.Abs(path)
+	if err != nil {
+		result.Err = err
+		return result, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		result.Err = err
+		return result, false
+	}
+	if m.done(key, info, config.Formats) {
+		result.Skipped = true
+		return result, false
+	}
+
+	entry := &manifestEntry{Size: info.Size(), ModTime: info.ModTime(), Formats: config.Formats}
+	result.Outputs, result.Err = transcribeAndWrite(ctx, transcriber, path, config)
+	if ctx.Err() != nil {
+		return result, true
+	}
+	result.Duration = time.Since(start)
+
+	entry.CompletedAt = time.Now()
+	for _, output := range result.Outputs {
+		if abs, err := filepath.Abs(output); err == nil {
+			output = abs
+		}
+		entry.Outputs = append(entry.Outputs, output)
+	}
+	if result.Err != nil {
+		entry.Status = "failed"
+		entry.Error = result.Err.Error()
+	} else {
+		entry.Status = "done"
+	}
+	if err := m.record(key, entry); err != nil && result.Err == nil {
+		result.Err = err
+	}
+	return result, false
+}
+
+// transcribeAndWrite transcribe un archivo y escribe una salida por formato y,
+// si hay Summarize, el resumen
+func transcribeAndWrite(ctx context.Context, transcriber DetailedTranscriber, path string, config BatchConfig) ([]string, error) {
+	transcript, err := transcriber.TranscribeDetailed(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := outputBase(path, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var outputs []string
+	for _, format := range config.Formats {
+		var buf bytes.Buffer
+		if err := WriteTranscript(&buf, transcript, format); err != nil {
+			return outputs, fmt.Errorf("formato %s: %w", format, err)
+		}
+		output := base + "." + format
+		if err := writeFileAtomic(output, buf.Bytes()); err != nil {
+			return outputs, err
+		}
+		outputs = append(outputs, output)
+	}
+
+	if config.Summarize != nil {
+		summary, err := config.Summarize(ctx, transcript)
+		if err != nil {
+			return outputs, fmt.Errorf("error resumiendo: %w", err)
+		}
+		output := base + ".summary.txt"
+		if err := writeFileAtomic(output, []byte(strings.TrimSpace(summary)+"\n")); err != nil {
+			return outputs, err
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+// outputBase devuelve la ruta de salida sin extensión para un audio
+func outputBase(path string, config BatchConfig) (string, error) {
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	if config.OutputDir == "" {
+		return stem, nil
+	}
+
+	rel := filepath.Base(stem)
+	if config.BaseDir != "" {
+		r, err := filepath.Rel(config.BaseDir, stem)
+		if err == nil && !strings.HasPrefix(r, "..") {
+			rel = r
+		}
+	}
+	return filepath.Join(config.OutputDir, rel), nil
+}
+
+// FindAudioFiles devuelve los audios de un directorio (recursivamente), de un
+// patrón glob o un archivo suelto, ordenados, y el directorio base para las
+// rutas de salida
+func FindAudioFiles(pattern string) (files []string, baseDir string, err error) {
+	if info, statErr := os.Stat(pattern); statErr == nil && info.IsDir() {
+		err = filepath.WalkDir(pattern, func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && IsAudioFile(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("error recorriendo %s: %w", pattern, err)
+		}
+		sort.Strings(files)
+		return files, pattern, nil
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, "", fmt.Errorf("patrón no válido %q: %w", pattern, err)
+	}
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			files = append(files, match)
+		}
+	}
+	sort.Strings(files)
+	return files, globBase(pattern), nil
+}
+
+// globBase es el directorio anterior al primer comodín del patrón; sin
+// comodines el patrón es un archivo y la base es su directorio
+func globBase(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for strings.ContainsAny(dir, "*?[") {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
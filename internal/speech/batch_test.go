@@ -0,0 +1,234 @@
+package speech_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/internal/speech"
+)
+
+// scriptedTranscriber transcribe cada archivo con su nombre y cuenta las
+// llamadas; onCall, si no es nil, decide el resultado
+type scriptedTranscriber struct {
+	mu     sync.Mutex
+	calls  []string
+	onCall func(ctx context.Context, path string) error
+}
+
+func (s *scriptedTranscriber) TranscribeDetailed(ctx context.Context, path string) (*speech.Transcript, error) {
+	s.mu.Lock()
+	s.calls = append(s.calls, filepath.Base(path))
+	s.mu.Unlock()
+	if s.onCall != nil {
+		if err := s.onCall(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+	text := "Audio " + filepath.Base(path)
+	return &speech.Transcript{Text: text, Segments: []speech.Segment{{End: time.Second, Text: text}}}, nil
+}
+
+// called devuelve los archivos transcritos, ordenados, y reinicia la cuenta
+func (s *scriptedTranscriber) called() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := s.calls
+	s.calls = nil
+	sort.Strings(calls)
+	return calls
+}
+
+// createFiles crea archivos vacíos bajo dir
+func createFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("RIFF"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindAudioFiles(t *testing.T) {
+	dir := t.TempDir()
+	createFiles(t, dir, "b.wav", "a.MP3", "notas.txt", "sub/c.ogg", "sub/d.wav", "carpeta.wav/e.wav")
+
+	tests := []struct {
+		name    string
+		pattern string
+		files   []string
+		base    string
+	}{
+		{"directory", dir, []string{"a.MP3", "b.wav", "carpeta.wav/e.wav", "sub/c.ogg", "sub/d.wav"}, dir},
+		{"glob", filepath.Join(dir, "*.wav"), []string{"b.wav"}, dir},
+		{"glob in subdirectory", filepath.Join(dir, "s*", "*.wav"), []string{"sub/d.wav"}, dir},
+		{"single file", filepath.Join(dir, "sub", "c.ogg"), []string{"sub/c.ogg"}, filepath.Join(dir, "sub")},
+		{"missing file", filepath.Join(dir, "no.wav"), nil, dir},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			files, base, err := speech.FindAudioFiles(tt.pattern)
+			if err != nil {
+				t.Fatalf("FindAudioFiles: %v", err)
+			}
+			var rel []string
+			for _, file := range files {
+				r, _ := filepath.Rel(dir, file)
+				rel = append(rel, filepath.ToSlash(r))
+			}
+			if strings.Join(rel, ",") != strings.Join(tt.files, ",") {
+				t.Errorf("archivos = %v, want %v", rel, tt.files)
+			}
+			if base != tt.base {
+				t.Errorf("base = %q, want %q", base, tt.base)
+			}
+		})
+	}
+
+	if _, _, err := speech.FindAudioFiles(filepath.Join(dir, "[")); err == nil {
+		t.Error("patrón inválido aceptado")
+	}
+}
+
+func TestTranscribeBatchOutputs(t *testing.T) {
+	dir := t.TempDir()
+	audios := filepath.Join(dir, "audios")
+	other := filepath.Join(dir, "otros")
+	createFiles(t, audios, "a.wav", "sub/b.wav")
+	createFiles(t, other, "c.wav")
+	files := []string{filepath.Join(audios, "a.wav"), filepath.Join(audios, "sub", "b.wav"), filepath.Join(other, "c.wav")}
+
+	tests := []struct {
+		name    string
+		config  speech.BatchConfig
+		outputs []string // relativas a dir
+	}{
+		{"next to audio", speech.BatchConfig{BaseDir: audios, Formats: []string{"txt"}},
+			[]string{"audios/a.txt", "audios/sub/b.txt", "otros/c.txt"}},
+		{"mirrored", speech.BatchConfig{BaseDir: audios, OutputDir: filepath.Join(dir, "salida"), Formats: []string{"srt", "json"}},
+			[]string{"salida/a.srt", "salida/a.json", "salida/sub/b.srt", "salida/sub/b.json", "salida/c.srt", "salida/c.json"}},
+		{"no base", speech.BatchConfig{OutputDir: filepath.Join(dir, "plana"), Manifest: filepath.Join(dir, "plana.json")},
+			[]string{"plana/a.txt", "plana/b.txt", "plana/c.txt"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := speech.TranscribeBatch(context.Background(), &scriptedTranscriber{}, files, tt.config)
+			if err != nil {
+				t.Fatalf("TranscribeBatch: %v", err)
+			}
+			if report.Done != 3 || report.Failed != 0 {
+				t.Errorf("informe = %+v", report)
+			}
+			for _, output := range tt.outputs {
+				if _, err := os.Stat(filepath.Join(dir, output)); err != nil {
+					t.Errorf("falta la salida %s", output)
+				}
+			}
+		})
+	}
+
+	if _, err := speech.TranscribeBatch(context.Background(), &scriptedTranscriber{}, files, speech.BatchConfig{BaseDir: audios, Formats: []string{"doc"}}); err == nil {
+		t.Error("formato desconocido aceptado")
+	}
+}
+
+func TestTranscribeBatchResume(t *testing.T) {
+	dir := t.TempDir()
+	createFiles(t, dir, "a.wav", "b.wav", "c.wav")
+	files := []string{filepath.Join(dir, "a.wav"), filepath.Join(dir, "b.wav"), filepath.Join(dir, "c.wav")}
+	config := speech.BatchConfig{BaseDir: dir, Workers: 3}
+	ctx := context.Background()
+
+	transcriber := &scriptedTranscriber{onCall: func(ctx context.Context, path string) error {
+		if filepath.Base(path) == "c.wav" {
+			return errors.New("audio corrupto")
+		}
+		return nil
+	}}
+	report, err := speech.TranscribeBatch(ctx, transcriber, files, config)
+	if err != nil {
+		t.Fatalf("TranscribeBatch: %v", err)
+	}
+	if report.Done != 2 || report.Failed != 1 {
+		t.Errorf("primera pasada = %+v, want 2 hechos y 1 fallido", report)
+	}
+	transcriber.called()
+
+	// Los hechos se saltan y los fallidos se reintentan
+	transcriber.onCall = nil
+	report, _ = speech.TranscribeBatch(ctx, transcriber, files, config)
+	if got := transcriber.called(); report.Skipped != 2 || report.Done != 1 || strings.Join(got, ",") != "c.wav" {
+		t.Errorf("segunda pasada = %+v transcribiendo %v, want solo c.wav", report, got)
+	}
+
+	// Un audio modificado, una salida borrada o un formato nuevo obligan a repetir
+	if err := os.WriteFile(files[0], []byte("RIFF más largo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	speech.TranscribeBatch(ctx, transcriber, files, config)
+	if got := transcriber.called(); strings.Join(got, ",") != "a.wav,b.wav" {
+		t.Errorf("tras cambios se transcribieron %v, want a.wav y b.wav", got)
+	}
+	config.Formats = []string{"txt", "vtt"}
+	report, _ = speech.TranscribeBatch(ctx, transcriber, files, config)
+	if report.Done != 3 {
+		t.Errorf("con un formato nuevo = %+v, want 3 hechos", report)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "transcribe-manifest.json")); err != nil {
+		t.Errorf("manifiesto: %v", err)
+	}
+}
+
+// Al cancelar, el archivo en curso y los pendientes quedan para la siguiente pasada
+func TestTranscribeBatchCanceled(t *testing.T) {
+	dir := t.TempDir()
+	createFiles(t, dir, "a.wav", "b.wav", "c.wav")
+	files := []string{filepath.Join(dir, "a.wav"), filepath.Join(dir, "b.wav"), filepath.Join(dir, "c.wav")}
+	config := speech.BatchConfig{BaseDir: dir, Workers: 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	transcriber := &scriptedTranscriber{onCall: func(ctx context.Context, path string) error {
+		if filepath.Base(path) != "b.wav" {
+			return nil
+		}
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	var results []speech.BatchResult
+	config.OnResult = func(result speech.BatchResult) { results = append(results, result) }
+	report, err := speech.TranscribeBatch(ctx, transcriber, files, config)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if report.Done != 1 || report.Failed != 0 || len(results) != 1 {
+		t.Errorf("informe = %+v con %d resultados, want solo a.wav hecho", report, len(results))
+	}
+
+	transcriber.onCall = nil
+	transcriber.called()
+	config.OnResult = nil
+	report, err = speech.TranscribeBatch(context.Background(), transcriber, files, config)
+	if err != nil {
+		t.Fatalf("reanudando: %v", err)
+	}
+	if got := transcriber.called(); report.Skipped != 1 || strings.Join(got, ",") != "b.wav,c.wav" {
+		t.Errorf("al reanudar = %+v transcribiendo %v, want b.wav y c.wav", report, got)
+	}
+}
@@ -0,0 +1,50 @@
+package speech
+
+import (
+	"time"
+
+	"github.com/akosej/agent/pkg/metrics"
+)
+
+// Option configura dependencias opcionales del transcriptor
+type Option func(*Transcriber)
+
+// transcriberMetrics son las métricas de la transcripción
+type transcriberMetrics struct {
+	duration metrics.Histogram
+	errors   metrics.Counter
+}
+
+func newTranscriberMetrics(m metrics.Metrics) *transcriberMetrics {
+	m = metrics.OrNop(m)
+	return &transcriberMetrics{
+		duration: m.Histogram("agent_transcription_duration_seconds",
+			"Duración de cada transcripción", metrics.DurationBuckets, "provider", "status"),
+		errors: m.Counter("agent_backend_errors_total",
+			"Errores de los servicios externos por tipo", "backend", "type"),
+	}
+}
+
+// WithMetrics registra la duración y los errores de cada transcripción
+func WithMetrics(m metrics.Metrics) Option {
+	return func(t *Transcriber) {
+		t.metrics = newTranscriberMetrics(m)
+	}
+}
+
+// provider es la etiqueta del proveedor de transcripción
+func (t *Transcriber) provider() string {
+	if t.useWhisperCpp {
+		return "whisper-cpp"
+	}
+	return "whisper-api"
+}
+
+// observe anota la duración de una transcripción y, si falló, el error con su tipo
+func (t *Transcriber) observe(start time.Time, err error) {
+	status := metrics.Status(err)
+	t.metrics.duration.Observe(time.Since(start).Seconds(), t.provider(), status)
+	if status == "error" {
+		t.metrics.errors.Add(1, t.provider(), metrics.ErrorType(err))
+	}
+}
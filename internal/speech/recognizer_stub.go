@@ -19,8 +19,8 @@ func NewRecognizer(config Config) (*Recognizer, error) {
 }
 
 // StartListening no hace nada sin PortAudio
-func (r *Recognizer) StartListening(ctx context.Context) error {
-	return fmt.Errorf("reconocimiento de voz en vivo no disponible: compila con tag 'portaudio' para habilitarlo")
+func (r *Recognizer) StartListening(ctx context.Context) (<-chan []byte, error) {
+	return nil, fmt.Errorf("reconocimiento de voz en vivo no disponible: compila con tag 'portaudio' para habilitarlo")
 }
 
 // StopListening no hace nada sin PortAudio
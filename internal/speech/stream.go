@@ -0,0 +1,110 @@
+package speech
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Valores por defecto de TranscribeChunks
+const (
+	defaultPartialInterval = 2 * time.Second
+	defaultMaxDuration     = 60 * time.Second
+)
+
+// ErrAudioTooLong indica que el audio recibido supera la duración máxima
+var ErrAudioTooLong = errors.New("el audio supera la duración máxima")
+
+// StreamTranscriber transcribe un audio completo; Transcriber lo implementa
+type StreamTranscriber interface {
+	TranscribeStream(ctx context.Context, audioData []byte, format string) (string, error)
+}
+
+// ChunkConfig describe el audio PCM que llega por trozos
+type ChunkConfig struct {
+	SampleRate      int           // Muestras por segundo (por defecto 16000)
+	Channels        int           // Canales (por defecto 1)
+	PartialInterval time.Duration // Audio nuevo necesario para una transcripción parcial; < 0 las desactiva
+	MaxDuration     time.Duration // Duración máxima del audio (por defecto 60s)
+}
+
+// bytesFor devuelve cuántos bytes de PCM de 16 bits ocupa una duración
+func (c ChunkConfig) bytesFor(d time.Duration) int {
+	return int(d.Seconds() * float64(c.SampleRate*c.Channels*2))
+}
+
+// TranscribeChunks transcribe audio PCM de 16 bits little-endian que llega por
+// trozos, como los que produce Recognizer.StartListening o un cliente remoto.
+// Cada PartialInterval de audio nuevo transcribe lo acumulado y lo entrega a
+// onPartial; al cerrarse chunks devuelve la transcripción final.
+func TranscribeChunks(ctx context.Context, transcriber StreamTranscriber, chunks <-chan []byte, config ChunkConfig, onPartial func(text string)) (string, error) {
+	if config.SampleRate <= 0 {
+		config.SampleRate = 16000
+	}
+	if config.Channels <= 0 {
+		config.Channels = 1
+	}
+	if config.PartialInterval == 0 {
+		config.PartialInterval = defaultPartialInterval
+	}
+	if config.MaxDuration <= 0 {
+		config.MaxDuration = defaultMaxDuration
+	}
+
+	maxBytes := config.bytesFor(config.MaxDuration)
+	partialBytes := config.bytesFor(config.PartialInterval)
+
+	var pcm []byte
+	lastPartial := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case chunk, ok := <-chunks:
+			if !ok {
+				if len(pcm) == 0 {
+					return "", nil
+				}
+				return transcriber.TranscribeStream(ctx, EncodeWAV(pcm, config.SampleRate, config.Channels), "wav")
+			}
+
+			if len(pcm)+len(chunk) > maxBytes {
+				return "", fmt.Errorf("%w (%s)", ErrAudioTooLong, config.MaxDuration)
+			}
+			pcm = append(pcm, chunk...)
+
+			if onPartial != nil && config.PartialInterval > 0 && len(pcm)-lastPartial >= partialBytes {
+				lastPartial = len(pcm)
+				text, err := transcriber.TranscribeStream(ctx, EncodeWAV(pcm, config.SampleRate, config.Channels), "wav")
+				if err != nil {
+					return "", err
+				}
+				onPartial(text)
+			}
+		}
+	}
+}
+
+// EncodeWAV envuelve audio PCM de 16 bits little-endian en un archivo WAV
+func EncodeWAV(pcm []byte, sampleRate, channels int) []byte {
+	const headerSize = 44
+	blockAlign := channels * 2
+
+	wav := make([]byte, headerSize, headerSize+len(pcm))
+	copy(wav[0:], "RIFF")
+	binary.LittleEndian.PutUint32(wav[4:], uint32(headerSize-8+len(pcm)))
+	copy(wav[8:], "WAVE")
+	copy(wav[12:], "fmt ")
+	binary.LittleEndian.PutUint32(wav[16:], 16) // tamaño del chunk fmt
+	binary.LittleEndian.PutUint16(wav[20:], 1)  // formato PCM
+	binary.LittleEndian.PutUint16(wav[22:], uint16(channels))
+	binary.LittleEndian.PutUint32(wav[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(wav[28:], uint32(sampleRate*blockAlign)) // byte rate
+	binary.LittleEndian.PutUint16(wav[32:], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(wav[34:], 16) // bits por muestra
+	copy(wav[36:], "data")
+	binary.LittleEndian.PutUint32(wav[40:], uint32(len(pcm)))
+	return append(wav, pcm...)
+}
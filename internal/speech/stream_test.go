@@ -0,0 +1,140 @@
+package speech_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/internal/speech"
+)
+
+// countingTranscriber responde con el número de bytes de PCM del WAV recibido
+type countingTranscriber struct {
+	mu    sync.Mutex
+	rates []int
+	err   error
+}
+
+func (c *countingTranscriber) TranscribeStream(ctx context.Context, audio []byte, format string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if format != "wav" || len(audio) < 44 {
+		return "", fmt.Errorf("audio inesperado: %s de %d bytes", format, len(audio))
+	}
+	c.rates = append(c.rates, int(binary.LittleEndian.Uint32(audio[24:])))
+	if c.err != nil {
+		return "", c.err
+	}
+	return fmt.Sprintf("%d bytes", len(audio)-44), nil
+}
+
+// feed envía los chunks indicados y cierra el canal
+func feed(sizes ...int) <-chan []byte {
+	chunks := make(chan []byte, len(sizes))
+	for _, size := range sizes {
+		chunks <- make([]byte, size)
+	}
+	close(chunks)
+	return chunks
+}
+
+func TestTranscribeChunks(t *testing.T) {
+	// 1000 muestras por segundo en mono: 2000 bytes por segundo
+	config := speech.ChunkConfig{SampleRate: 1000, PartialInterval: time.Second, MaxDuration: 5 * time.Second}
+	noPartials := config
+	noPartials.PartialInterval = -1
+
+	tests := []struct {
+		name     string
+		config   speech.ChunkConfig
+		chunks   []int
+		want     string
+		partials []string
+		err      error
+	}{
+		{"partials", config, []int{1000, 1000, 1500, 1000, 500}, "5000 bytes", []string{"2000 bytes", "4500 bytes"}, nil},
+		{"partials disabled", noPartials, []int{3000, 3000}, "6000 bytes", nil, nil},
+		{"empty", config, nil, "", nil, nil},
+		{"too long", config, []int{6000, 4001}, "", []string{"6000 bytes"}, speech.ErrAudioTooLong},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			transcriber := &countingTranscriber{}
+			var partials []string
+			text, err := speech.TranscribeChunks(context.Background(), transcriber, feed(tt.chunks...), tt.config, func(text string) {
+				partials = append(partials, text)
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if text != tt.want {
+				t.Errorf("texto = %q, want %q", text, tt.want)
+			}
+			if fmt.Sprint(partials) != fmt.Sprint(tt.partials) {
+				t.Errorf("parciales = %q, want %q", partials, tt.partials)
+			}
+			for _, rate := range transcriber.rates {
+				if rate != 1000 {
+					t.Errorf("WAV a %d Hz, want 1000", rate)
+				}
+			}
+		})
+	}
+}
+
+func TestTranscribeChunksDefaults(t *testing.T) {
+	transcriber := &countingTranscriber{}
+	// Sin configuración: 16 kHz mono, una parcial cada 2 s (64000 bytes)
+	var partials int
+	text, err := speech.TranscribeChunks(context.Background(), transcriber, feed(32000, 32000, 100), speech.ChunkConfig{}, func(string) {
+		partials++
+	})
+	if err != nil {
+		t.Fatalf("TranscribeChunks: %v", err)
+	}
+	if text != "64100 bytes" || partials != 1 {
+		t.Errorf("texto = %q con %d parciales, want 64100 bytes con 1", text, partials)
+	}
+	if len(transcriber.rates) != 2 || transcriber.rates[0] != 16000 {
+		t.Errorf("frecuencias = %v, want [16000 16000]", transcriber.rates)
+	}
+}
+
+func TestTranscribeChunksErrors(t *testing.T) {
+	t.Run("transcriber", func(t *testing.T) {
+		failure := errors.New("whisper caído")
+		transcriber := &countingTranscriber{err: failure}
+		config := speech.ChunkConfig{SampleRate: 1000, PartialInterval: time.Second}
+		_, err := speech.TranscribeChunks(context.Background(), transcriber, feed(2000, 2000), config, func(string) {
+			t.Error("parcial entregada pese al error")
+		})
+		if !errors.Is(err, failure) || len(transcriber.rates) != 1 {
+			t.Errorf("error = %v tras %d llamadas, want %v tras 1", err, len(transcriber.rates), failure)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		chunks := make(chan []byte)
+		done := make(chan error, 1)
+		go func() {
+			_, err := speech.TranscribeChunks(ctx, &countingTranscriber{}, chunks, speech.ChunkConfig{}, nil)
+			done <- err
+		}()
+		chunks <- make([]byte, 100)
+		cancel()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("error = %v, want context.Canceled", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("TranscribeChunks no terminó al cancelar")
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// maxSynthesisBytes limita el tamaño del audio aceptado del servidor de síntesis
+const maxSynthesisBytes = 20 << 20
+
+// Synthesizer convierte texto en voz con un servidor HTTP compatible con el de
+// Piper (python -m piper.http_server): recibe el texto en el cuerpo de un POST
+// y responde con audio WAV
+type Synthesizer struct {
+	client *http.Client
+	apiURL string
+	voice  string
+}
+
+// NewSynthesizer crea un sintetizador para el servidor en apiURL; voice es
+// opcional y se envía como parámetro si el servidor admite varias voces
+func NewSynthesizer(apiURL, voice string) *Synthesizer {
+	return &Synthesizer{
+		client: &http.Client{},
+		apiURL: apiURL,
+		voice:  voice,
+	}
+}
+
+// Synthesize devuelve el audio de text y su formato ("wav")
+func (s *Synthesizer) Synthesize(ctx context.Context, text string) ([]byte, string, error) {
+	endpoint := s.apiURL
+	if s.voice != "" {
+		endpoint += "?voice=" + url.QueryEscape(s.voice)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBufferString(text))
+	if err != nil {
+		return nil, "", fmt.Errorf("error creando request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("error llamando al servidor de síntesis: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, "", fmt.Errorf("el servidor de síntesis respondió con error %d: %s", resp.StatusCode, string(body))
+	}
+
+	audio, err := io.ReadAll(io.LimitReader(resp.Body, maxSynthesisBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("error leyendo audio sintetizado: %w", err)
+	}
+	if len(audio) > maxSynthesisBytes {
+		return nil, "", fmt.Errorf("el audio sintetizado supera %d bytes", maxSynthesisBytes)
+	}
+	return audio, "wav", nil
+}
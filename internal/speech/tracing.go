@@ -0,0 +1,75 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/akosej/agent/pkg/tracing"
+)
+
+// WithTracer abre un span por cada transcripción con el proveedor, el tamaño y
+// la duración del audio
+func WithTracer(tracer *tracing.Tracer) Option {
+	return func(t *Transcriber) {
+		t.tracer = tracer
+	}
+}
+
+// startSpan abre el span "speech.transcribe" de un archivo de audio
+func (t *Transcriber) startSpan(ctx context.Context, audioPath string) (context.Context, *tracing.Span) {
+	if t.tracer == nil {
+		return ctx, nil
+	}
+	attrs := []tracing.Attribute{
+		tracing.String("speech.provider", t.provider()),
+		tracing.String("speech.language", t.language),
+		tracing.String("audio.format", strings.TrimPrefix(strings.ToLower(filepath.Ext(audioPath)), ".")),
+	}
+	if info, err := os.Stat(audioPath); err == nil {
+		attrs = append(attrs, tracing.Int64("audio.bytes", info.Size()))
+	}
+	if duration, ok := wavDuration(audioPath); ok {
+		attrs = append(attrs, tracing.Float("audio.duration_seconds", duration))
+	}
+	return t.tracer.Start(ctx, "speech.transcribe", tracing.KindClient, attrs...)
+}
+
+// wavDuration calcula la duración en segundos de un WAV a partir de su
+// encabezado. Devuelve false si el archivo no es WAV o el encabezado no se entiende.
+func wavDuration(path string) (float64, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	header := make([]byte, 4096)
+	n, _ := io.ReadFull(file, header)
+	header = header[:n]
+	if len(header) < 12 || !bytes.Equal(header[0:4], []byte("RIFF")) || !bytes.Equal(header[8:12], []byte("WAVE")) {
+		return 0, false
+	}
+
+	// Recorrer los chunks hasta encontrar fmt (byte rate) y data (tamaño del audio)
+	var byteRate uint32
+	for offset := 12; offset+8 <= len(header); {
+		id := string(header[offset : offset+4])
+		size := binary.LittleEndian.Uint32(header[offset+4 : offset+8])
+		switch {
+		case id == "fmt " && offset+20 <= len(header):
+			byteRate = binary.LittleEndian.Uint32(header[offset+16 : offset+20])
+		case id == "data":
+			if byteRate == 0 {
+				return 0, false
+			}
+			return float64(size) / float64(byteRate), true
+		}
+		offset += 8 + int(size) + int(size%2)
+	}
+	return 0, false
+}
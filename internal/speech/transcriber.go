@@ -12,6 +12,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/akosej/agent/pkg/tracing"
 )
 
 // Transcriber maneja la transcripción de audio a texto usando whisper.cpp local
@@ -21,35 +24,57 @@ type Transcriber struct {
 	whisperPath   string // Ruta al ejecutable de whisper.cpp
 	useWhisperCpp bool   // Si usar whisper.cpp o API local
 	apiURL        string // URL de API local de Whisper (si se usa)
+	metrics       *transcriberMetrics
+	tracer        *tracing.Tracer // nil si no se registran trazas
 }
 
 // NewTranscriber crea una nueva instancia del transcriptor
 // whisperPath: ruta al ejecutable main de whisper.cpp (ej: ./whisper.cpp/main)
 // modelPath: ruta al modelo .bin (ej: ./models/ggml-base.bin)
-func NewTranscriber(whisperPath, modelPath, language string) *Transcriber {
-	return &Transcriber{
+func NewTranscriber(whisperPath, modelPath, language string, opts ...Option) *Transcriber {
+	return newTranscriber(&Transcriber{
 		language:      language,
 		modelPath:     modelPath,
 		whisperPath:   whisperPath,
 		useWhisperCpp: true,
 		apiURL:        "",
-	}
+	}, opts)
 }
 
 // NewTranscriberWithAPI crea un transcriptor que usa una API local de Whisper
-func NewTranscriberWithAPI(apiURL, language string) *Transcriber {
+func NewTranscriberWithAPI(apiURL, language string, opts ...Option) *Transcriber {
 	if apiURL == "" {
 		apiURL = "http://localhost:8000" // Puerto por defecto
 	}
-	return &Transcriber{
+	return newTranscriber(&Transcriber{
 		language:      language,
 		useWhisperCpp: false,
 		apiURL:        apiURL,
+	}, opts)
+}
+
+// newTranscriber aplica las opciones y los valores por defecto
+func newTranscriber(t *Transcriber, opts []Option) *Transcriber {
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.metrics == nil {
+		t.metrics = newTranscriberMetrics(nil)
 	}
+	return t
 }
 
 // TranscribeFile transcribe un archivo de audio a texto usando whisper.cpp
-func (t *Transcriber) TranscribeFile(ctx context.Context, audioPath string) (string, error) {
+func (t *Transcriber) TranscribeFile(ctx context.Context, audioPath string) (text string, err error) {
+	start := time.Now()
+	ctx, span := t.startSpan(ctx, audioPath)
+	defer func() {
+		t.observe(start, err)
+		span.SetAttributes(tracing.Int("transcript.chars", len(text)))
+		span.RecordError(err)
+		span.End()
+	}()
+
 	if t.useWhisperCpp {
 		return t.transcribeWithWhisperCpp(ctx, audioPath)
 	}
@@ -104,9 +129,30 @@ func (t *Transcriber) transcribeWithWhisperCpp(ctx context.Context, audioPath st
 
 // transcribeWithAPI usa una API local de Whisper (como whisper-api o faster-whisper)
 func (t *Transcriber) transcribeWithAPI(ctx context.Context, audioPath string) (string, error) {
+	result, err := t.postAPI(ctx, audioPath)
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+// apiResponse es la respuesta de la API de Whisper; language y segments
+// (en segundos) son opcionales
+type apiResponse struct {
+	Text     string `json:"text"`
+	Language string `json:"language"`
+	Segments []struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Text  string  `json:"text"`
+	} `json:"segments"`
+}
+
+// postAPI envía el archivo a /transcribe y decodifica la respuesta
+func (t *Transcriber) postAPI(ctx context.Context, audioPath string) (*apiResponse, error) {
 	file, err := os.Open(audioPath)
 	if err != nil {
-		return "", fmt.Errorf("error abriendo archivo: %w", err)
+		return nil, fmt.Errorf("error abriendo archivo: %w", err)
 	}
 	defer file.Close()
 
@@ -115,11 +161,11 @@ func (t *Transcriber) transcribeWithAPI(ctx context.Context, audioPath string) (
 
 	part, err := writer.CreateFormFile("file", filepath.Base(audioPath))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if _, err := io.Copy(part, file); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Agregar parámetros
@@ -128,37 +174,36 @@ func (t *Transcriber) transcribeWithAPI(ctx context.Context, audioPath string) (
 
 	req, err := http.NewRequestWithContext(ctx, "POST", t.apiURL+"/transcribe", body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
+	tracing.Inject(ctx, req.Header)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error llamando a API de Whisper: %w (Asegúrate de que el servidor esté corriendo)", err)
+		return nil, fmt.Errorf("error llamando a API de Whisper: %w (Asegúrate de que el servidor esté corriendo)", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API respondió con error %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, fmt.Errorf("API respondió con error %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 
 	// Parsear respuesta JSON
-	var result struct {
-		Text string `json:"text"`
-	}
+	var result apiResponse
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error leyendo respuesta: %w", err)
+		return nil, fmt.Errorf("error leyendo respuesta: %w", err)
 	}
 
 	if err := json.Unmarshal(bodyBytes, &result); err != nil {
-		return "", fmt.Errorf("error parseando respuesta: %w", err)
+		return nil, fmt.Errorf("error parseando respuesta: %w", err)
 	}
 
-	return result.Text, nil
+	return &result, nil
 }
 
 // TranscribeStream transcribe audio desde un stream
@@ -0,0 +1,160 @@
+package speech_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/internal/speech"
+	"github.com/akosej/agent/pkg/metrics"
+	"github.com/akosej/agent/testing/fakes"
+)
+
+// writeAudio crea un archivo de audio; el contenido da igual a los fakes
+func writeAudio(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("RIFF\x00\x00\x00\x00WAVE"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTranscribeWithAPI(t *testing.T) {
+	api := fakes.NewWhisperAPI()
+	defer api.Close()
+	api.On("frases.wav", fakes.Transcription{Text: "Primera frase. ¿Segunda?", Language: "es"})
+	transcriber := speech.NewTranscriberWithAPI(api.URL, "es")
+	ctx := context.Background()
+
+	text, err := transcriber.TranscribeFile(ctx, writeAudio(t, "hola.wav"))
+	if err != nil {
+		t.Fatalf("TranscribeFile: %v", err)
+	}
+	if text != "Hola, esto es una prueba." {
+		t.Errorf("texto = %q", text)
+	}
+
+	transcript, err := transcriber.TranscribeDetailed(ctx, writeAudio(t, "frases.wav"))
+	if err != nil {
+		t.Fatalf("TranscribeDetailed: %v", err)
+	}
+	want := []speech.Segment{
+		{Start: 0, End: time.Second, Text: "Primera frase."},
+		{Start: time.Second, End: 2 * time.Second, Text: "¿Segunda?"},
+	}
+	if transcript.Language != "es" || len(transcript.Segments) != len(want) {
+		t.Fatalf("transcripción = %+v", transcript)
+	}
+	for i, segment := range transcript.Segments {
+		if segment != want[i] {
+			t.Errorf("segmento %d = %+v, want %+v", i, segment, want[i])
+		}
+	}
+
+	uploads := api.Uploads()
+	if len(uploads) != 2 || uploads[0].Filename != "hola.wav" || uploads[0].Language != "es" || uploads[0].Size == 0 {
+		t.Errorf("subidas = %+v", uploads)
+	}
+}
+
+func TestTranscribeWithAPIFault(t *testing.T) {
+	api := fakes.NewWhisperAPI()
+	defer api.Close()
+	api.Fail("/transcribe", fakes.Fault{Status: 500, Body: "sin GPU", Times: 1})
+	registry := metrics.NewRegistry()
+	transcriber := speech.NewTranscriberWithAPI(api.URL, "es", speech.WithMetrics(registry))
+	audio := writeAudio(t, "hola.wav")
+
+	_, err := transcriber.TranscribeFile(context.Background(), audio)
+	if err == nil || !strings.Contains(err.Error(), "500") || !strings.Contains(err.Error(), "sin GPU") {
+		t.Fatalf("err = %v, want error 500", err)
+	}
+	if _, err := transcriber.TranscribeFile(context.Background(), audio); err != nil {
+		t.Errorf("el fallo con Times 1 afectó a la segunda llamada: %v", err)
+	}
+
+	// Cada transcripción cuenta su duración por resultado y el fallo, por tipo
+	for _, status := range []string{"ok", "error"} {
+		if got := registry.Value("agent_transcription_duration_seconds", "whisper-api", status); got != 1 {
+			t.Errorf("transcripciones %s = %v, want 1", status, got)
+		}
+	}
+	if got := registry.Value("agent_backend_errors_total", "whisper-api", "other"); got != 1 {
+		t.Errorf("errores de whisper-api = %v, want 1", got)
+	}
+}
+
+func TestTranscribeWithWhisperCpp(t *testing.T) {
+	whisper := fakes.NewWhisperCpp(t, fakes.WhisperCppConfig{
+		Transcription: fakes.Transcription{Text: "Uno dos. Tres cuatro."},
+	})
+	transcriber := speech.NewTranscriber(whisper.Path, whisper.ModelPath, "es")
+	audio := writeAudio(t, "audio.wav")
+	ctx := context.Background()
+
+	text, err := transcriber.TranscribeFile(ctx, audio)
+	if err != nil {
+		t.Fatalf("TranscribeFile: %v", err)
+	}
+	if text != "Uno dos. Tres cuatro." {
+		t.Errorf("texto = %q, want sin las líneas de log de whisper.cpp", text)
+	}
+
+	transcript, err := transcriber.TranscribeDetailed(ctx, audio)
+	if err != nil {
+		t.Fatalf("TranscribeDetailed: %v", err)
+	}
+	if transcript.Language != "es" || len(transcript.Segments) != 2 || transcript.Segments[1].End != 2*time.Second {
+		t.Errorf("transcripción = %+v", transcript)
+	}
+
+	calls := whisper.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("ejecuciones = %d, want 2", len(calls))
+	}
+	if args := strings.Join(calls[0], " "); !strings.Contains(args, "-f "+audio) || !strings.Contains(args, "-nt") {
+		t.Errorf("argumentos = %q", args)
+	}
+	if args := strings.Join(calls[1], " "); !strings.Contains(args, "-oj") || !strings.Contains(args, "-of ") {
+		t.Errorf("argumentos = %q", args)
+	}
+}
+
+func TestWhisperCppFailures(t *testing.T) {
+	audio := writeAudio(t, "audio.wav")
+
+	t.Run("exit code", func(t *testing.T) {
+		whisper := fakes.NewWhisperCpp(t, fakes.WhisperCppConfig{ExitCode: 2, Stderr: "error: sin memoria\n"})
+		_, err := speech.NewTranscriber(whisper.Path, whisper.ModelPath, "es").TranscribeDetailed(context.Background(), audio)
+		if err == nil || !strings.Contains(err.Error(), "sin memoria") {
+			t.Fatalf("err = %v, want la salida de error de whisper.cpp", err)
+		}
+	})
+
+	t.Run("missing model", func(t *testing.T) {
+		whisper := fakes.NewWhisperCpp(t, fakes.WhisperCppConfig{})
+		missing := filepath.Join(t.TempDir(), "no-existe.bin")
+		_, err := speech.NewTranscriber(whisper.Path, missing, "es").TranscribeFile(context.Background(), audio)
+		if err == nil || !strings.Contains(err.Error(), "failed to open") {
+			t.Fatalf("err = %v, want modelo no encontrado", err)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		whisper := fakes.NewWhisperCpp(t, fakes.WhisperCppConfig{Delay: 10 * time.Second})
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := speech.NewTranscriber(whisper.Path, whisper.ModelPath, "es").TranscribeDetailed(ctx, audio)
+		if err == nil {
+			t.Fatal("la transcripción no se canceló")
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("la cancelación tardó %v", elapsed)
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package speech
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akosej/agent/pkg/tracing"
+)
+
+// ErrNoSegments indica que el proveedor no devolvió tiempos y el formato los necesita
+var ErrNoSegments = errors.New("la transcripción no tiene segmentos con tiempos")
+
+// Segment es un fragmento de una transcripción con su posición en el audio
+type Segment struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Transcript es una transcripción completa con sus segmentos
+type Transcript struct {
+	Text     string
+	Language string
+	Segments []Segment // Vacío si el proveedor no da tiempos
+}
+
+// TranscribeDetailed transcribe un archivo conservando los segmentos con sus
+// tiempos, necesarios para subtítulos. Con la API los segmentos solo están si
+// el servidor los incluye en la respuesta.
+func (t *Transcriber) TranscribeDetailed(ctx context.Context, audioPath string) (transcript *Transcript, err error) {
+	start := time.Now()
+	ctx, span := t.startSpan(ctx, audioPath)
+	defer func() {
+		t.observe(start, err)
+		if transcript != nil {
+			span.SetAttributes(tracing.Int("transcript.chars", len(transcript.Text)),
+				tracing.Int("transcript.segments", len(transcript.Segments)))
+		}
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if t.useWhisperCpp {
+		return t.transcribeDetailedWithWhisperCpp(ctx, audioPath)
+	}
+
+	response, err := t.postAPI(ctx, audioPath)
+	if err != nil {
+		return nil, err
+	}
+	transcript = &Transcript{Text: strings.TrimSpace(response.Text), Language: response.Language}
+	for _, segment := range response.Segments {
+		transcript.Segments = append(transcript.Segments, Segment{
+			Start: seconds(segment.Start),
+			End:   seconds(segment.End),
+			Text:  strings.TrimSpace(segment.Text),
+		})
+	}
+	return transcript, nil
+}
+
+// seconds convierte segundos con decimales a time.Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// whisperCppOutput es el archivo que whisper.cpp escribe con -oj
+type whisperCppOutput struct {
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"` // Milisegundos
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text string `json:"text"`
+	} `json:"transcription"`
+}
+
+// transcribeDetailedWithWhisperCpp ejecuta whisper.cpp con salida JSON en un
+// directorio temporal y la convierte en segmentos
+func (t *Transcriber) transcribeDetailedWithWhisperCpp(ctx context.Context, audioPath string) (*Transcript, error) {
+	if t.whisperPath == "" {
+		return nil, fmt.Errorf("whisperPath no configurado. Instala whisper.cpp y configura la ruta")
+	}
+	if t.modelPath == "" {
+		return nil, fmt.Errorf("modelPath no configurado. Descarga un modelo de Whisper")
+	}
+
+	dir, err := os.MkdirTemp("", "whisper-")
+	if err != nil {
+		return nil, fmt.Errorf("error creando directorio temporal: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	prefix := filepath.Join(dir, "out")
+	args := []string{
+		"-m", t.modelPath,
+		"-f", audioPath,
+		"-l", t.language,
+		"-oj", // Salida JSON con segmentos
+		"-of", prefix,
+	}
+	output, err := exec.CommandContext(ctx, t.whisperPath, args...).CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("error ejecutando whisper.cpp: %w\nOutput: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	data, err := os.ReadFile(prefix + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("whisper.cpp no generó la salida JSON: %w", err)
+	}
+	var result whisperCppOutput
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("error parseando salida de whisper.cpp: %w", err)
+	}
+
+	transcript := &Transcript{Language: result.Result.Language}
+	var text []string
+	for _, segment := range result.Transcription {
+		content := strings.TrimSpace(segment.Text)
+		if content == "" {
+			continue
+		}
+		text = append(text, content)
+		transcript.Segments = append(transcript.Segments, Segment{
+			Start: time.Duration(segment.Offsets.From) * time.Millisecond,
+			End:   time.Duration(segment.Offsets.To) * time.Millisecond,
+			Text:  content,
+		})
+	}
+	transcript.Text = strings.Join(text, " ")
+	if transcript.Text == "" {
+		return nil, fmt.Errorf("no se pudo transcribir el audio")
+	}
+	return transcript, nil
+}
+
+// TranscriptFormats son los formatos de salida de WriteTranscript
+var TranscriptFormats = []string{"txt", "srt", "vtt", "json"}
+
+// WriteTranscript escribe la transcripción en el formato indicado: txt (texto
+// plano), srt o vtt (subtítulos) o json (texto, idioma y segmentos en segundos)
+func WriteTranscript(w io.Writer, transcript *Transcript, format string) error {
+	switch format {
+	case "txt":
+		_, err := fmt.Fprintln(w, transcript.Text)
+		return err
+	case "srt", "vtt":
+		return writeSubtitles(w, transcript, format)
+	case "json":
+		return writeTranscriptJSON(w, transcript)
+	default:
+		return fmt.Errorf("formato desconocido: %s (%s)", format, strings.Join(TranscriptFormats, ", "))
+	}
+}
+
+// writeSubtitles escribe los segmentos como SRT o WebVTT
+func writeSubtitles(w io.Writer, transcript *Transcript, format string) error {
+	if len(transcript.Segments) == 0 {
+		return ErrNoSegments
+	}
+
+	separator := ","
+	if format == "vtt" {
+		separator = "."
+		if _, err := fmt.Fprint(w, "WEBVTT\n\n"); err != nil {
+			return err
+		}
+	}
+	for i, segment := range transcript.Segments {
+		if format == "srt" {
+			if _, err := fmt.Fprintf(w, "%d\n", i+1); err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n",
+			formatTimestamp(segment.Start, separator), formatTimestamp(segment.End, separator), segment.Text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatTimestamp da el formato hh:mm:ss,mmm (SRT) o hh:mm:ss.mmm (VTT)
+func formatTimestamp(d time.Duration, separator string) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
+
+// writeTranscriptJSON escribe la transcripción con los tiempos en segundos
+func writeTranscriptJSON(w io.Writer, transcript *Transcript) error {
+	type segment struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Text  string  `json:"text"`
+	}
+	out := struct {
+		Text     string    `json:"text"`
+		Language string    `json:"language,omitempty"`
+		Segments []segment `json:"segments"`
+	}{Text: transcript.Text, Language: transcript.Language, Segments: []segment{}}
+	for _, s := range transcript.Segments {
+		out.Segments = append(out.Segments, segment{Start: s.Start.Seconds(), End: s.End.Seconds(), Text: s.Text})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
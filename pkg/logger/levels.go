@@ -0,0 +1,239 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// levelNames son los niveles válidos en configuración y en tiempo de ejecución
+var levelNames = map[string]Level{
+	"debug": DEBUG,
+	"info":  INFO,
+	"warn":  WARN,
+	"error": ERROR,
+}
+
+// String devuelve el nombre del nivel
+func (l Level) String() string {
+	switch l {
+	case DEBUG:
+		return "debug"
+	case WARN:
+		return "warn"
+	case ERROR:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// ParseLevel convierte un nombre de nivel; "" equivale a info.
+// Devuelve error con los niveles válidos si el nombre no existe.
+func ParseLevel(name string) (Level, error) {
+	if name == "" {
+		return INFO, nil
+	}
+	level, ok := levelNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return INFO, fmt.Errorf("nivel de log desconocido: %q (válidos: debug, info, warn, error)", name)
+	}
+	return level, nil
+}
+
+// levels guarda el nivel global y los de cada componente; lo comparten
+// un logger y todos sus hijos, así que un cambio afecta a todos a la vez
+type levels struct {
+	mu         sync.RWMutex
+	base       Level
+	configured Level // nivel de configuración, para volver a él tras ToggleDebug
+	components map[string]Level
+}
+
+func newLevels(base Level, components map[string]Level) *levels {
+	copied := make(map[string]Level, len(components))
+	for name, level := range components {
+		copied[name] = level
+	}
+	return &levels{base: base, configured: base, components: copied}
+}
+
+// level devuelve el nivel efectivo de un componente ("" es el global)
+func (l *levels) level(component string) Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if level, ok := l.components[component]; ok && component != "" {
+		return level
+	}
+	return l.base
+}
+
+// componentLeveler implementa slog.Leveler consultando los niveles en cada registro
+type componentLeveler struct {
+	levels    *levels
+	component string
+}
+
+func (c componentLeveler) Level() slog.Level {
+	return c.levels.level(c.component).slogLevel()
+}
+
+// levelHandler filtra los registros por el nivel de su componente
+type levelHandler struct {
+	slog.Handler
+	leveler slog.Leveler
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.leveler.Level() && h.Handler.Enabled(ctx, level)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{h.Handler.WithAttrs(attrs), h.leveler}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{h.Handler.WithGroup(name), h.leveler}
+}
+
+// SetLevel cambia el nivel global; los componentes con nivel propio no cambian
+func (l *Logger) SetLevel(name string) error {
+	level, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	l.levels.mu.Lock()
+	l.levels.base = level
+	l.levels.mu.Unlock()
+	return nil
+}
+
+// SetComponentLevel fija el nivel de un componente; con name vacío el
+// componente vuelve a usar el nivel global
+func (l *Logger) SetComponentLevel(component, name string) error {
+	if component == "" {
+		return fmt.Errorf("componente vacío")
+	}
+
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+
+	if name == "" {
+		delete(l.levels.components, component)
+		return nil
+	}
+	level, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	l.levels.components[component] = level
+	return nil
+}
+
+// ToggleDebug alterna el nivel global entre debug y el de configuración
+func (l *Logger) ToggleDebug() Level {
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+
+	if l.levels.base == DEBUG {
+		l.levels.base = l.levels.configured
+		if l.levels.base == DEBUG {
+			l.levels.base = INFO
+		}
+	} else {
+		l.levels.base = DEBUG
+	}
+	return l.levels.base
+}
+
+// LevelStatus describe los niveles actuales
+type LevelStatus struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+// Levels devuelve el nivel global y los de cada componente
+func (l *Logger) Levels() LevelStatus {
+	l.levels.mu.RLock()
+	defer l.levels.mu.RUnlock()
+
+	status := LevelStatus{
+		Level:      l.levels.base.String(),
+		Components: make(map[string]string, len(l.levels.components)),
+	}
+	for name, level := range l.levels.components {
+		status.Components[name] = level.String()
+	}
+	return status
+}
+
+// levelRequest es el cuerpo aceptado por LevelHandler
+type levelRequest struct {
+	Component string `json:"component,omitempty"` // vacío cambia el nivel global
+	Level     string `json:"level"`               // vacío elimina el nivel del componente
+}
+
+// LevelHandler expone los niveles por HTTP para el endpoint de administración:
+// GET devuelve LevelStatus y PUT/POST con {"component": "speech", "level": "debug"}
+// cambia un nivel sin reiniciar el agente.
+func (l *Logger) LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var request levelRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&request); err != nil {
+				http.Error(w, "JSON inválido: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var err error
+			if request.Component == "" {
+				err = l.SetLevel(request.Level)
+			} else {
+				err = l.SetComponentLevel(request.Component, request.Level)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			l.InfoContext(r.Context(), "nivel de log cambiado", "component", request.Component, "level", request.Level)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(l.Levels())
+	})
+}
+
+// componentNames devuelve los componentes con nivel propio, ordenados
+func (s LevelStatus) componentNames() []string {
+	names := make([]string, 0, len(s.Components))
+	for name := range s.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String resume los niveles, p. ej. "info (nlp=info, speech=debug)"
+func (s LevelStatus) String() string {
+	names := s.componentNames()
+	if len(names) == 0 {
+		return s.Level
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + s.Components[name]
+	}
+	return s.Level + " (" + strings.Join(parts, ", ") + ")"
+}
@@ -1,11 +1,14 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"time"
 )
 
@@ -19,113 +22,272 @@ const (
 	ERROR
 )
 
-// Logger maneja el logging de la aplicación
+// slogLevel convierte un Level a su equivalente en slog
+func (l Level) slogLevel() slog.Level {
+	switch l {
+	case DEBUG:
+		return slog.LevelDebug
+	case WARN:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Formatos de salida
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Logger maneja el logging de la aplicación sobre log/slog. Los métodos
+// Debug/Info/Warn/Error aceptan formato printf; los métodos *Context
+// aceptan pares clave-valor y añaden los IDs de petición y traza del contexto.
 type Logger struct {
-	debugLogger *log.Logger
-	infoLogger  *log.Logger
-	warnLogger  *log.Logger
-	errorLogger *log.Logger
-	level       Level
+	slog   *slog.Logger
+	levels *levels
+
+	file *RotatingFile // nil si solo se escribe en stdout
 }
 
 // Config contiene la configuración del logger
 type Config struct {
-	Level      string
+	Level      string            // debug, info (por defecto), warn o error
+	Components map[string]string // Nivel propio por componente, p. ej. speech: debug
+	Format     string            // "text" (por defecto) o "json"
 	File       string
-	MaxSize    int
-	MaxBackups int
+	MaxSize    int  // Tamaño máximo en MB antes de rotar (por defecto 10)
+	MaxBackups int  // Archivos rotados que se conservan (por defecto 5)
+	MaxAge     int  // Días que se conservan los archivos rotados (0 = sin límite)
+	Compress   bool // Comprimir con gzip los archivos rotados
+
+	// Output sustituye a stdout como salida de consola (útil en pruebas)
+	Output io.Writer
+	// Redactor, si no es nil, oculta datos personales en mensajes y atributos
+	Redactor Redactor
 }
 
 // NewLogger crea una nueva instancia del logger
 func NewLogger(config Config) (*Logger, error) {
-	level := parseLevel(config.Level)
-
-	// Crear directorio de logs si no existe
-	if config.File != "" {
-		if err := os.MkdirAll(filepath.Dir(config.File), 0755); err != nil {
-			return nil, fmt.Errorf("error creando directorio de logs: %w", err)
+	base, err := ParseLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+	components := make(map[string]Level, len(config.Components))
+	for component, name := range config.Components {
+		level, err := ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("componente %s: %w", component, err)
 		}
+		components[component] = level
 	}
+	levels := newLevels(base, components)
 
-	// Abrir archivo de log
+	// Abrir archivo de log con rotación
 	var writer io.Writer = os.Stdout
+	if config.Output != nil {
+		writer = config.Output
+	}
+	var file *RotatingFile
 	if config.File != "" {
-		file, err := os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			return nil, fmt.Errorf("error abriendo archivo de log: %w", err)
+		if file, err = NewRotatingFile(config); err != nil {
+			return nil, err
 		}
-		writer = io.MultiWriter(os.Stdout, file)
+		writer = io.MultiWriter(writer, file)
+	}
+
+	// El filtrado por nivel lo hace levelHandler según el componente
+	options := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       slog.LevelDebug,
+		ReplaceAttr: shortSource,
+	}
+
+	var handler slog.Handler
+	switch config.Format {
+	case "", FormatText:
+		handler = slog.NewTextHandler(writer, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(writer, options)
+	default:
+		if file != nil {
+			file.Close()
+		}
+		return nil, fmt.Errorf("formato de log desconocido: %q", config.Format)
+	}
+
+	if config.Redactor != nil {
+		handler = redactHandler{handler, config.Redactor}
 	}
 
 	return &Logger{
-		debugLogger: log.New(writer, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile),
-		infoLogger:  log.New(writer, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile),
-		warnLogger:  log.New(writer, "WARN: ", log.Ldate|log.Ltime|log.Lshortfile),
-		errorLogger: log.New(writer, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile),
-		level:       level,
+		slog:   slog.New(levelHandler{contextHandler{handler}, componentLeveler{levels, ""}}),
+		levels: levels,
+		file:   file,
 	}, nil
 }
 
+// shortSource reduce la ruta del código fuente a archivo:línea, como log.Lshortfile
+func shortSource(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key != slog.SourceKey || len(groups) > 0 {
+		return attr
+	}
+	if source, ok := attr.Value.Any().(*slog.Source); ok {
+		attr.Value = slog.StringValue(filepath.Base(source.File) + ":" + strconv.Itoa(source.Line))
+	}
+	return attr
+}
+
+// With devuelve un logger hijo que añade los pares clave-valor a cada registro
+func (l *Logger) With(args ...any) *Logger {
+	child := *l
+	child.slog = l.slog.With(args...)
+	return &child
+}
+
+// Component devuelve un logger hijo con el atributo component=name que
+// filtra por el nivel propio del componente, si lo tiene
+func (l *Logger) Component(name string) *Logger {
+	child := *l
+	handler := l.slog.Handler().(levelHandler)
+	child.slog = slog.New(levelHandler{
+		handler.Handler.WithAttrs([]slog.Attr{slog.String("component", name)}),
+		componentLeveler{l.levels, name},
+	})
+	return &child
+}
+
+// Slog devuelve el *slog.Logger subyacente para bibliotecas que lo aceptan
+func (l *Logger) Slog() *slog.Logger {
+	return l.slog
+}
+
+// log emite un registro con la línea del llamador que está skip marcos por encima
+func (l *Logger) log(ctx context.Context, skip int, level slog.Level, msg string, args ...any) {
+	if !l.slog.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(skip+2, pcs[:])
+	record := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	record.Add(args...)
+	_ = l.slog.Handler().Handle(ctx, record)
+}
+
+// logf emite un mensaje con formato printf
+func (l *Logger) logf(level slog.Level, format string, v ...interface{}) {
+	if !l.slog.Enabled(context.Background(), level) {
+		return
+	}
+	l.log(context.Background(), 2, level, fmt.Sprintf(format, v...))
+}
+
 // Debug registra un mensaje de debug
 func (l *Logger) Debug(format string, v ...interface{}) {
-	if l.level <= DEBUG {
-		l.debugLogger.Output(2, fmt.Sprintf(format, v...))
-	}
+	l.logf(slog.LevelDebug, format, v...)
 }
 
 // Info registra un mensaje informativo
 func (l *Logger) Info(format string, v ...interface{}) {
-	if l.level <= INFO {
-		l.infoLogger.Output(2, fmt.Sprintf(format, v...))
-	}
+	l.logf(slog.LevelInfo, format, v...)
 }
 
 // Warn registra una advertencia
 func (l *Logger) Warn(format string, v ...interface{}) {
-	if l.level <= WARN {
-		l.warnLogger.Output(2, fmt.Sprintf(format, v...))
-	}
+	l.logf(slog.LevelWarn, format, v...)
 }
 
 // Error registra un error
 func (l *Logger) Error(format string, v ...interface{}) {
-	if l.level <= ERROR {
-		l.errorLogger.Output(2, fmt.Sprintf(format, v...))
-	}
-}
-
-// parseLevel convierte un string a Level
-func parseLevel(levelStr string) Level {
-	switch levelStr {
-	case "debug":
-		return DEBUG
-	case "info":
-		return INFO
-	case "warn":
-		return WARN
-	case "error":
-		return ERROR
-	default:
-		return INFO
+	l.logf(slog.LevelError, format, v...)
+}
+
+// DebugContext registra un mensaje de debug con atributos clave-valor
+func (l *Logger) DebugContext(ctx context.Context, msg string, args ...any) {
+	l.log(ctx, 1, slog.LevelDebug, msg, args...)
+}
+
+// InfoContext registra un mensaje informativo con atributos clave-valor
+func (l *Logger) InfoContext(ctx context.Context, msg string, args ...any) {
+	l.log(ctx, 1, slog.LevelInfo, msg, args...)
+}
+
+// WarnContext registra una advertencia con atributos clave-valor
+func (l *Logger) WarnContext(ctx context.Context, msg string, args ...any) {
+	l.log(ctx, 1, slog.LevelWarn, msg, args...)
+}
+
+// ErrorContext registra un error con atributos clave-valor
+func (l *Logger) ErrorContext(ctx context.Context, msg string, args ...any) {
+	l.log(ctx, 1, slog.LevelError, msg, args...)
+}
+
+// Reopen vuelve a abrir el archivo de log tras una rotación externa
+func (l *Logger) Reopen() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Reopen()
+}
+
+// HasFile indica si el logger escribe en un archivo que se pueda reabrir
+func (l *Logger) HasFile() bool {
+	return l.file != nil
+}
+
+// Close cierra el archivo de log
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
 	}
+	return l.file.Close()
+}
+
+// InteractionRecord describe una interacción para LogInteractionContext
+type InteractionRecord struct {
+	Intent    string
+	Input     string
+	Response  string
+	Model     string
+	SessionID string
+	Latency   time.Duration
 }
 
 // LogInteraction registra una interacción
 func (l *Logger) LogInteraction(userInput, response, intent string) {
-	l.Info("Interacción - Intent: %s | Input: %s | Response: %s", intent, userInput, response)
+	l.log(context.Background(), 1, slog.LevelInfo, "interacción",
+		"intent", intent, "input", userInput, "response", response)
+}
+
+// LogInteractionContext registra una interacción con modelo, sesión y latencia
+func (l *Logger) LogInteractionContext(ctx context.Context, record InteractionRecord) {
+	args := []any{"intent", record.Intent, "input", record.Input, "response", record.Response}
+	if record.Model != "" {
+		args = append(args, "model", record.Model)
+	}
+	if record.SessionID != "" {
+		args = append(args, "session_id", record.SessionID)
+	}
+	if record.Latency > 0 {
+		args = append(args, "latency_ms", record.Latency.Milliseconds())
+	}
+	l.log(ctx, 1, slog.LevelInfo, "interacción", args...)
 }
 
 // LogError registra un error con contexto
 func (l *Logger) LogError(component, operation string, err error) {
-	l.Error("Error en %s.%s: %v", component, operation, err)
+	l.log(context.Background(), 1, slog.LevelError, "error",
+		"component", component, "operation", operation, "error", err)
 }
 
 // LogStartup registra el inicio de la aplicación
 func (l *Logger) LogStartup(version string) {
-	l.Info("=== AgentIA Iniciado - Versión %s - %s ===", version, time.Now().Format(time.RFC3339))
+	l.log(context.Background(), 1, slog.LevelInfo, "AgentIA iniciado", "version", version)
 }
 
 // LogShutdown registra el cierre de la aplicación
 func (l *Logger) LogShutdown() {
-	l.Info("=== AgentIA Detenido - %s ===", time.Now().Format(time.RFC3339))
+	l.log(context.Background(), 1, slog.LevelInfo, "AgentIA detenido")
 }
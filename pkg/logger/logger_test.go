@@ -0,0 +1,242 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akosej/agent/pkg/logger"
+)
+
+// entries decodifica las líneas JSON escritas por el logger
+func entries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var result []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("línea no JSON %q: %v", line, err)
+		}
+		result = append(result, entry)
+	}
+	buf.Reset()
+	return result
+}
+
+func newJSONLogger(t *testing.T, config logger.Config) (*logger.Logger, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	config.Format = logger.FormatJSON
+	config.Output = &buf
+	log, err := logger.NewLogger(config)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	t.Cleanup(func() { log.Close() })
+	return log, &buf
+}
+
+func TestNewLoggerConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config logger.Config
+	}{
+		{"unknown level", logger.Config{Level: "verbose"}},
+		{"unknown component level", logger.Config{Components: map[string]string{"nlp": "todo"}}},
+		{"unknown format", logger.Config{Format: "xml"}},
+	}
+	for _, tt := range tests {
+		if _, err := logger.NewLogger(tt.config); err == nil {
+			t.Errorf("%s: configuración aceptada", tt.name)
+		}
+	}
+
+	var buf bytes.Buffer
+	log, err := logger.NewLogger(logger.Config{Level: " WARN ", Output: &buf})
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	log.Info("oculto")
+	log.Warn("visible %d", 1)
+	if out := buf.String(); strings.Contains(out, "oculto") || !strings.Contains(out, `msg="visible 1"`) || !strings.Contains(out, "source=logger_test.go:") {
+		t.Errorf("salida de texto:\n%s", out)
+	}
+}
+
+func TestContextHandler(t *testing.T) {
+	log, buf := newJSONLogger(t, logger.Config{})
+
+	ctx := logger.WithTraceID(logger.WithRequestID(context.Background(), "req-1"), "trace-1")
+	log.With("user", "ana").InfoContext(ctx, "petición", "status", 200)
+	log.InfoContext(context.Background(), "sin ids")
+
+	got := entries(t, buf)
+	if len(got) != 2 {
+		t.Fatalf("%d registros, want 2", len(got))
+	}
+	first := got[0]
+	if first["request_id"] != "req-1" || first["trace_id"] != "trace-1" || first["user"] != "ana" || first["status"] != float64(200) {
+		t.Errorf("registro con contexto = %v", first)
+	}
+	if _, ok := got[1]["request_id"]; ok {
+		t.Errorf("registro sin contexto con request_id: %v", got[1])
+	}
+	if logger.RequestID(context.Background()) != "" || logger.TraceID(ctx) != "trace-1" {
+		t.Error("RequestID o TraceID no leen el contexto")
+	}
+}
+
+// fakeRedactor oculta un correo concreto
+type fakeRedactor struct{}
+
+func (fakeRedactor) Redact(text string) string {
+	return strings.ReplaceAll(text, "ana@example.com", "[EMAIL]")
+}
+
+func TestRedactHandler(t *testing.T) {
+	log, buf := newJSONLogger(t, logger.Config{Redactor: fakeRedactor{}})
+
+	log.With("from", "ana@example.com").InfoContext(context.Background(), "correo de ana@example.com",
+		"input", "escribe a ana@example.com",
+		"count", 3,
+		slog.Group("user", "email", "ana@example.com"),
+		"error", errors.New("rebotado: ana@example.com"),
+	)
+	log.Error("fallo enviando a %s", "ana@example.com")
+
+	out := buf.String()
+	if strings.Contains(out, "ana@example.com") {
+		t.Errorf("el correo sigue en el log:\n%s", out)
+	}
+	got := entries(t, buf)
+	first := got[0]
+	user, _ := first["user"].(map[string]interface{})
+	if first["msg"] != "correo de [EMAIL]" || first["from"] != "[EMAIL]" || first["input"] != "escribe a [EMAIL]" ||
+		first["error"] != "rebotado: [EMAIL]" || first["count"] != float64(3) || user["email"] != "[EMAIL]" {
+		t.Errorf("registro redactado = %v", first)
+	}
+	if got[1]["msg"] != "fallo enviando a [EMAIL]" {
+		t.Errorf("mensaje printf = %v", got[1]["msg"])
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	log, buf := newJSONLogger(t, logger.Config{Level: "warn", Components: map[string]string{"speech": "debug"}})
+	speech, nlp := log.Component("speech"), log.Component("nlp")
+
+	logged := func() []string {
+		var msgs []string
+		for _, entry := range entries(t, buf) {
+			msgs = append(msgs, entry["component"].(string)+":"+entry["msg"].(string))
+		}
+		return msgs
+	}
+	emit := func() []string {
+		speech.Debug("d")
+		nlp.Debug("d")
+		nlp.Warn("w")
+		return logged()
+	}
+
+	if got := strings.Join(emit(), ","); got != "speech:d,nlp:w" {
+		t.Errorf("con speech=debug y global warn = %s", got)
+	}
+
+	// Los cambios afectan a los hijos ya creados
+	if err := log.SetComponentLevel("speech", "error"); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(emit(), ","); got != "nlp:d,nlp:w" {
+		t.Errorf("con speech=error y global debug = %s", got)
+	}
+
+	// Sin nivel propio el componente vuelve al global
+	if err := log.SetComponentLevel("speech", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(emit(), ","); got != "speech:d,nlp:d,nlp:w" {
+		t.Errorf("sin nivel propio = %s", got)
+	}
+	if log.SetLevel("todo") == nil || log.SetComponentLevel("", "debug") == nil || log.SetComponentLevel("nlp", "todo") == nil {
+		t.Error("nivel o componente no válido aceptado")
+	}
+
+	// ToggleDebug alterna entre debug y el nivel de configuración
+	log.SetLevel("warn")
+	if level := log.ToggleDebug(); level != logger.DEBUG {
+		t.Errorf("primer ToggleDebug = %s, want debug", level)
+	}
+	if level := log.ToggleDebug(); level != logger.WARN {
+		t.Errorf("segundo ToggleDebug = %s, want warn", level)
+	}
+	if status := log.Levels().String(); status != "warn" {
+		t.Errorf("Levels = %q, want warn", status)
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	log, _ := newJSONLogger(t, logger.Config{Components: map[string]string{"nlp": "warn"}})
+	server := httptest.NewServer(log.LevelHandler())
+	defer server.Close()
+
+	send := func(method, body string) (int, logger.LevelStatus, http.Header) {
+		t.Helper()
+		request, _ := http.NewRequest(method, server.URL, strings.NewReader(body))
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		defer response.Body.Close()
+		var status logger.LevelStatus
+		json.NewDecoder(response.Body).Decode(&status)
+		return response.StatusCode, status, response.Header
+	}
+
+	code, status, _ := send(http.MethodGet, "")
+	if code != http.StatusOK || status.String() != "info (nlp=warn)" {
+		t.Errorf("GET = %d %v", code, status)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		code   int
+		want   string
+	}{
+		{"global", http.MethodPut, `{"level":"debug"}`, 200, "debug (nlp=warn)"},
+		{"component", http.MethodPost, `{"component":"speech","level":"error"}`, 200, "debug (nlp=warn, speech=error)"},
+		{"clear component", http.MethodPut, `{"component":"nlp","level":""}`, 200, "debug (speech=error)"},
+		{"unknown level", http.MethodPut, `{"level":"todo"}`, 400, ""},
+		{"invalid json", http.MethodPut, `{"level":`, 400, ""},
+		{"method", http.MethodDelete, "", 405, ""},
+	}
+	for _, tt := range tests {
+		code, status, header := send(tt.method, tt.body)
+		if code != tt.code {
+			t.Errorf("%s: código %d, want %d", tt.name, code, tt.code)
+			continue
+		}
+		if tt.want != "" && status.String() != tt.want {
+			t.Errorf("%s: niveles %q, want %q", tt.name, status.String(), tt.want)
+		}
+		if code == http.StatusMethodNotAllowed && header.Get("Allow") != "GET, PUT, POST" {
+			t.Errorf("%s: Allow = %q", tt.name, header.Get("Allow"))
+		}
+	}
+	if got := log.Levels().String(); got != "debug (speech=error)" {
+		t.Errorf("niveles finales = %q", got)
+	}
+}
@@ -0,0 +1,327 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	megabyte = 1024 * 1024
+
+	// defaultMaxSize y defaultMaxBackups se aplican si la configuración no los indica,
+	// para que el log nunca crezca sin límite
+	defaultMaxSize    = 10
+	defaultMaxBackups = 5
+
+	// rotatedTimeFormat forma parte del nombre de los archivos rotados; el orden
+	// alfabético coincide con el cronológico
+	rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+	compressedExt = ".gz"
+)
+
+// RotatingFile es un io.WriteCloser que escribe en un archivo y lo rota al
+// superar MaxSize. Los archivos rotados se llaman <nombre>-<fecha><ext>,
+// se comprimen con gzip si Compress está activo y se eliminan cuando
+// superan MaxBackups o MaxAge. Es seguro para escrituras concurrentes.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	// millCh despierta a la goroutine que comprime y elimina archivos rotados
+	millCh   chan struct{}
+	millDone chan struct{}
+}
+
+// NewRotatingFile abre (o crea) el archivo de log según la configuración
+func NewRotatingFile(config Config) (*RotatingFile, error) {
+	if config.File == "" {
+		return nil, errors.New("archivo de log no especificado")
+	}
+	if err := os.MkdirAll(filepath.Dir(config.File), 0755); err != nil {
+		return nil, fmt.Errorf("error creando directorio de logs: %w", err)
+	}
+
+	maxSize := config.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxSize
+	}
+	maxBackups := config.MaxBackups
+	if maxBackups <= 0 {
+		maxBackups = defaultMaxBackups
+	}
+
+	r := &RotatingFile{
+		path:       config.File,
+		maxSize:    int64(maxSize) * megabyte,
+		maxBackups: maxBackups,
+		maxAge:     time.Duration(config.MaxAge) * 24 * time.Hour,
+		compress:   config.Compress,
+		millCh:     make(chan struct{}, 1),
+		millDone:   make(chan struct{}),
+	}
+	if err := r.openLocked(); err != nil {
+		return nil, err
+	}
+
+	go r.millRun()
+	// Comprimir o eliminar lo que haya quedado pendiente de una ejecución anterior
+	r.millCh <- struct{}{}
+
+	return r, nil
+}
+
+// openLocked abre el archivo actual en modo append (requiere mu)
+func (r *RotatingFile) openLocked() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error abriendo archivo de log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error leyendo archivo de log: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// Write escribe p, rotando antes el archivo si no cabe
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	// Un mensaje mayor que MaxSize se escribe igualmente en un archivo nuevo
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate fuerza la rotación del archivo actual
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.rotateLocked()
+}
+
+// rotateLocked renombra el archivo actual y abre uno nuevo (requiere mu)
+func (r *RotatingFile) rotateLocked() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("error cerrando archivo de log: %w", err)
+	}
+	r.file = nil
+
+	// Dos rotaciones en el mismo milisegundo no deben pisarse
+	now := time.Now()
+	name := r.rotatedName(now)
+	for i := 1; fileExists(name) || fileExists(name+compressedExt); i++ {
+		name = r.rotatedName(now.Add(time.Duration(i) * time.Millisecond))
+	}
+
+	if err := os.Rename(r.path, name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		// Sin poder renombrar, seguir escribiendo en el mismo archivo antes que perder logs
+		if openErr := r.openLocked(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("error rotando archivo de log: %w", err)
+	}
+
+	if err := r.openLocked(); err != nil {
+		return err
+	}
+
+	select {
+	case r.millCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Reopen cierra y vuelve a abrir el archivo por su ruta. Se usa cuando una
+// herramienta externa (logrotate) ha movido el archivo.
+func (r *RotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("error cerrando archivo de log: %w", err)
+	}
+	r.file = nil
+	return r.openLocked()
+}
+
+// Close cierra el archivo y espera a que termine la compresión pendiente
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	if r.file == nil {
+		r.mu.Unlock()
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	close(r.millCh)
+	r.mu.Unlock()
+
+	<-r.millDone
+	return err
+}
+
+// rotatedName devuelve el nombre de un archivo rotado en t
+func (r *RotatingFile) rotatedName(t time.Time) string {
+	dir := filepath.Dir(r.path)
+	ext := filepath.Ext(r.path)
+	base := strings.TrimSuffix(filepath.Base(r.path), ext)
+	return filepath.Join(dir, base+"-"+t.Format(rotatedTimeFormat)+ext)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// rotatedFile es un archivo rotado con la fecha de su nombre
+type rotatedFile struct {
+	path      string
+	timestamp time.Time
+}
+
+// rotatedFiles devuelve los archivos rotados, del más reciente al más antiguo
+func (r *RotatingFile) rotatedFiles() ([]rotatedFile, error) {
+	dir := filepath.Dir(r.path)
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(filepath.Base(r.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []rotatedFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimPrefix(name, prefix)
+		stamp = strings.TrimSuffix(stamp, compressedExt)
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		t, err := time.ParseInLocation(rotatedTimeFormat, strings.TrimSuffix(stamp, ext), time.Local)
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{path: filepath.Join(dir, name), timestamp: t})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].timestamp.After(files[j].timestamp)
+	})
+	return files, nil
+}
+
+// millRun atiende las peticiones de mantenimiento hasta que se cierra millCh
+func (r *RotatingFile) millRun() {
+	defer close(r.millDone)
+	for range r.millCh {
+		if err := r.mill(); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: %v\n", err)
+		}
+	}
+}
+
+// mill elimina los archivos rotados sobrantes o caducados y comprime el resto
+func (r *RotatingFile) mill() error {
+	files, err := r.rotatedFiles()
+	if err != nil {
+		return fmt.Errorf("error listando logs rotados: %w", err)
+	}
+
+	cutoff := time.Now().Add(-r.maxAge)
+	var errs []error
+	for i, file := range files {
+		if i >= r.maxBackups || (r.maxAge > 0 && file.timestamp.Before(cutoff)) {
+			if err := os.Remove(file.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("error eliminando log rotado: %w", err))
+			}
+			continue
+		}
+		if r.compress && !strings.HasSuffix(file.path, compressedExt) {
+			if err := compressFile(file.path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compressFile comprime path en path.gz y elimina el original
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error abriendo log rotado: %w", err)
+	}
+	defer src.Close()
+
+	dstPath := path + compressedExt
+	tmpPath := dstPath + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("error creando log comprimido: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		return fmt.Errorf("error comprimiendo log: %w", err)
+	}
+	if err = gz.Close(); err != nil {
+		return fmt.Errorf("error comprimiendo log: %w", err)
+	}
+	if err = dst.Close(); err != nil {
+		return fmt.Errorf("error cerrando log comprimido: %w", err)
+	}
+	if err = os.Rename(tmpPath, dstPath); err != nil {
+		return fmt.Errorf("error renombrando log comprimido: %w", err)
+	}
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,180 @@
+package logger_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/pkg/logger"
+)
+
+// rotated devuelve los archivos rotados de agent.log en dir, ordenados
+func rotated(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Name() != "agent.log" {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readLog lee un archivo de log, descomprimiéndolo si termina en .gz
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("gzip %s: %v", path, err)
+		}
+		r = gz
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("leyendo %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	dir := t.TempDir()
+	file, err := logger.NewRotatingFile(logger.Config{File: filepath.Join(dir, "agent.log"), MaxSize: 1})
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+
+	chunk := strings.Repeat("a", 600*1024) + "\n"
+	for i := 0; i < 2; i++ {
+		if _, err := file.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	// Un mensaje mayor que MaxSize se escribe entero en un archivo nuevo
+	big := strings.Repeat("b", 1100*1024) + "\n"
+	if _, err := file.Write([]byte(big)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := file.Write([]byte("x")); err == nil {
+		t.Error("Write tras Close no falló")
+	}
+
+	names := rotated(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("archivos rotados = %v, want 2", names)
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, "agent-") || !strings.HasSuffix(name, ".log") {
+			t.Errorf("nombre rotado %q, want agent-<fecha>.log", name)
+		}
+	}
+	if got := readLog(t, filepath.Join(dir, names[0])); got != chunk {
+		t.Errorf("primer rotado con %d bytes, want %d", len(got), len(chunk))
+	}
+	if got := readLog(t, filepath.Join(dir, "agent.log")); got != big {
+		t.Errorf("archivo actual con %d bytes, want %d", len(got), len(big))
+	}
+}
+
+func TestRotatingFileBackupsAndCompress(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.log")
+
+	// Un rotado de una ejecución anterior que ya superó MaxAge se elimina al abrir
+	stale := filepath.Join(dir, "agent-"+time.Now().AddDate(0, 0, -3).Format("2006-01-02T15-04-05.000")+".log")
+	if err := os.WriteFile(stale, []byte("viejo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := logger.NewRotatingFile(logger.Config{File: path, MaxBackups: 2, MaxAge: 1, Compress: true})
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+	for _, line := range []string{"uno\n", "dos\n", "tres\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		if err := file.Rotate(); err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+	}
+	// Close espera a que terminen la compresión y la limpieza
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	names := rotated(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("archivos rotados = %v, want los 2 más recientes", names)
+	}
+	var contents []string
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".log.gz") {
+			t.Errorf("%s no está comprimido", name)
+		}
+		contents = append(contents, readLog(t, filepath.Join(dir, name)))
+	}
+	if strings.Join(contents, "") != "dos\ntres\n" {
+		t.Errorf("contenido de los rotados = %q, want dos y tres", contents)
+	}
+}
+
+// Tras mover el archivo como hace logrotate, Reopen vuelve a escribir en la ruta original
+func TestLoggerReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.log")
+	log, err := logger.NewLogger(logger.Config{File: path, Output: io.Discard})
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	defer log.Close()
+	if !log.HasFile() {
+		t.Fatal("HasFile = false con archivo configurado")
+	}
+
+	log.Info("antes")
+	moved := filepath.Join(dir, "agent.log.1")
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	log.Info("movido")
+	if err := log.Reopen(); err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	log.Info("después")
+
+	old, current := readLog(t, moved), readLog(t, path)
+	if !strings.Contains(old, "antes") || !strings.Contains(old, "movido") || strings.Contains(old, "después") {
+		t.Errorf("archivo movido:\n%s", old)
+	}
+	if !strings.Contains(current, "después") || strings.Contains(current, "antes") {
+		t.Errorf("archivo reabierto:\n%s", current)
+	}
+
+	console, err := logger.NewLogger(logger.Config{Output: io.Discard})
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	if console.HasFile() || console.Reopen() != nil || console.Close() != nil {
+		t.Error("un logger sin archivo debe ignorar Reopen y Close")
+	}
+}
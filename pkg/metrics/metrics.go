@@ -0,0 +1,101 @@
+// Package metrics define contadores e histogramas con etiquetas que los
+// componentes del agente usan sin depender de un servidor HTTP.
+//
+// Cada componente recibe un Metrics y crea sus métricas al construirse. Registry
+// las acumula en memoria y las exporta en el formato de texto de Prometheus;
+// Nop las descarta, y es lo que usan los componentes si no se les da ninguno.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os/exec"
+)
+
+// Counter es un contador que solo crece, con una serie por combinación de etiquetas
+type Counter interface {
+	// Add suma value a la serie con esos valores de etiqueta, en el orden en
+	// que se declararon las etiquetas
+	Add(value float64, labels ...string)
+}
+
+// Histogram agrupa observaciones en buckets, con una serie por combinación de etiquetas
+type Histogram interface {
+	Observe(value float64, labels ...string)
+}
+
+// Metrics crea las métricas de un componente. Pedir dos veces el mismo nombre
+// devuelve la misma métrica, de modo que varias instancias de un componente
+// comparten sus series.
+type Metrics interface {
+	Counter(name, help string, labels ...string) Counter
+	Histogram(name, help string, buckets []float64, labels ...string) Histogram
+}
+
+// Buckets habituales
+var (
+	// DurationBuckets, en segundos, cubre desde consultas locales hasta
+	// respuestas largas del modelo
+	DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+	// RateBuckets, en unidades por segundo, para tokens por segundo
+	RateBuckets = []float64{1, 2, 5, 10, 20, 30, 50, 75, 100, 150, 200}
+)
+
+// nop descarta todas las observaciones
+type nop struct{}
+
+func (nop) Counter(string, string, ...string) Counter                { return nop{} }
+func (nop) Histogram(string, string, []float64, ...string) Histogram { return nop{} }
+func (nop) Add(float64, ...string)                                   {}
+func (nop) Observe(float64, ...string)                               {}
+
+// Nop devuelve un Metrics que descarta todo
+func Nop() Metrics {
+	return nop{}
+}
+
+// OrNop devuelve m, o Nop si m es nil
+func OrNop(m Metrics) Metrics {
+	if m == nil {
+		return nop{}
+	}
+	return m
+}
+
+// Status es la etiqueta de resultado de una operación: "ok", "canceled" si el
+// llamador canceló el contexto, o "error"
+func Status(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// ErrorType clasifica un error de un servicio externo para la etiqueta type de
+// los contadores de errores: canceled, timeout, connection, exit (un proceso
+// terminó con error) u other. Los componentes usan tipos propios (como
+// http_503 o decode) cuando conocen mejor la causa.
+func ErrorType(err error) string {
+	var netErr net.Error
+	var opErr *net.OpError
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &opErr):
+		return "connection"
+	case errors.As(err, &exitErr):
+		return "exit"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,277 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry guarda las métricas en memoria y las exporta en el formato de texto
+// de Prometheus. Es seguro para uso concurrente.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry crea un registro vacío
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// kind es el tipo de una familia de métricas
+type kind string
+
+const (
+	kindCounter   kind = "counter"
+	kindHistogram kind = "histogram"
+)
+
+// family es una métrica con todas sus series
+type family struct {
+	name    string
+	help    string
+	kind    kind
+	labels  []string
+	buckets []float64 // solo histogramas, ordenados y sin +Inf
+
+	mu     sync.Mutex
+	series map[string]*series // por valores de etiqueta unidos con \xff
+}
+
+// series son los valores acumulados de una combinación de etiquetas
+type series struct {
+	labels []string
+	value  float64  // contadores
+	counts []uint64 // histogramas: observaciones por bucket, sin acumular
+	count  uint64
+	sum    float64
+}
+
+// Counter devuelve el contador name, creándolo si no existe. Registrar el
+// mismo nombre con otro tipo o etiquetas es un error de programación y provoca
+// un pánico.
+func (r *Registry) Counter(name, help string, labels ...string) Counter {
+	return r.family(name, help, kindCounter, nil, labels)
+}
+
+// Histogram devuelve el histograma name, creándolo si no existe. Sin buckets
+// usa DurationBuckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) Histogram {
+	if len(buckets) == 0 {
+		buckets = DurationBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return r.family(name, help, kindHistogram, sorted, labels)
+}
+
+// family busca o registra una familia comprobando que coincida con la existente
+func (r *Registry) family(name, help string, k kind, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.kind != k || strings.Join(f.labels, ",") != strings.Join(labels, ",") {
+			panic(fmt.Sprintf("métrica %s registrada como %s%v, se pidió como %s%v", name, f.kind, f.labels, k, labels))
+		}
+		return f
+	}
+
+	f := &family{
+		name:    name,
+		help:    help,
+		kind:    k,
+		labels:  append([]string(nil), labels...),
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// get devuelve la serie de esos valores de etiqueta; los que falten quedan
+// vacíos y los que sobren se ignoran
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		fixed := make([]string, len(f.labels))
+		copy(fixed, values)
+		values = fixed
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Add suma value al contador; los valores negativos se ignoran
+func (f *family) Add(value float64, labels ...string) {
+	if value < 0 || math.IsNaN(value) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.get(labels).value += value
+}
+
+// Observe anota una observación en el histograma
+func (f *family) Observe(value float64, labels ...string) {
+	if math.IsNaN(value) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := f.get(labels)
+	s.count++
+	s.sum += value
+	if i := sort.SearchFloat64s(f.buckets, value); i < len(f.buckets) {
+		s.counts[i]++
+	}
+}
+
+// Value devuelve el valor de un contador o el número de observaciones de un
+// histograma para esos valores de etiqueta (0 si no existen). Sirve para
+// comprobar métricas en tests sin exportarlas.
+func (r *Registry) Value(name string, labels ...string) float64 {
+	r.mu.Lock()
+	f, ok := r.families[name]
+	r.mu.Unlock()
+	if !ok {
+		return 0
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[strings.Join(labels, "\xff")]
+	switch {
+	case !ok:
+		return 0
+	case f.kind == kindHistogram:
+		return float64(s.count)
+	default:
+		return s.value
+	}
+}
+
+// WritePrometheus escribe todas las métricas en el formato de texto de
+// Prometheus 0.0.4, ordenadas por nombre y etiquetas
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	out := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(out)
+	}
+	return out.Flush()
+}
+
+// write escribe una familia con HELP, TYPE y sus series
+func (f *family) write(out *bufio.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(out, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(out, "# TYPE %s %s\n", f.name, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind == kindCounter {
+			fmt.Fprintf(out, "%s%s %s\n", f.name, formatLabels(f.labels, s.labels, "", ""), formatValue(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, upper := range f.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(out, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labels, "le", formatValue(upper)), cumulative)
+		}
+		fmt.Fprintf(out, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(out, "%s_sum%s %s\n", f.name, formatLabels(f.labels, s.labels, "", ""), formatValue(s.sum))
+		fmt.Fprintf(out, "%s_count%s %d\n", f.name, formatLabels(f.labels, s.labels, "", ""), s.count)
+	}
+}
+
+// formatLabels da {a="x",b="y"}, con una etiqueta extra opcional (le), o ""
+// si no hay etiquetas
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, escapeLabel(values[i]))
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string { return labelEscaper.Replace(value) }
+func escapeHelp(help string) string   { return helpEscaper.Replace(help) }
+
+// formatValue da el número en la forma más corta, con +Inf, -Inf y NaN
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Handler sirve las métricas para que Prometheus las recoja con GET
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if req.Method == http.MethodHead {
+			return
+		}
+		r.WritePrometheus(w)
+	})
+}
@@ -0,0 +1,219 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/akosej/agent/pkg/metrics"
+)
+
+// prometheus devuelve la salida de texto del registro
+func prometheus(t *testing.T, registry *metrics.Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := registry.WritePrometheus(&buf); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	return buf.String()
+}
+
+func TestWritePrometheus(t *testing.T) {
+	registry := metrics.NewRegistry()
+	// Los buckets se ordenan y cada uno acumula los anteriores
+	latency := registry.Histogram("b_latency_seconds", "Latencia", []float64{1, 0.5}, "operation")
+	for _, v := range []float64{0.2, 0.5, 0.7, 3} {
+		latency.Observe(v, "chat")
+	}
+	latency.Observe(math.NaN(), "chat")
+
+	requests := registry.Counter("a_requests_total", "Peticiones\ncon \\ barra", "path", "code")
+	requests.Add(2, "/hola", "200")
+	requests.Add(1.5, "/hola", "200")
+	requests.Add(-1, "/hola", "200")
+	requests.Add(math.NaN(), "/hola", "200")
+	requests.Add(1, `di "hola"`+"\n"+`c:\tmp`, "500")
+	// Los valores que faltan quedan vacíos y los que sobran se ignoran
+	requests.Add(1, "/adiós")
+	requests.Add(1, "/extra", "404", "sobra")
+
+	registry.Counter("c_plain_total", "Sin etiquetas").Add(1e21)
+
+	want := `# HELP a_requests_total Peticiones\ncon \\ barra
+# TYPE a_requests_total counter
+a_requests_total{path="/adiós",code=""} 1
+a_requests_total{path="/extra",code="404"} 1
+a_requests_total{path="/hola",code="200"} 3.5
+a_requests_total{path="di \"hola\"\nc:\\tmp",code="500"} 1
+# HELP b_latency_seconds Latencia
+# TYPE b_latency_seconds histogram
+b_latency_seconds_bucket{operation="chat",le="0.5"} 2
+b_latency_seconds_bucket{operation="chat",le="1"} 3
+b_latency_seconds_bucket{operation="chat",le="+Inf"} 4
+b_latency_seconds_sum{operation="chat"} 4.4
+b_latency_seconds_count{operation="chat"} 4
+# HELP c_plain_total Sin etiquetas
+# TYPE c_plain_total counter
+c_plain_total 1e+21
+`
+	if got := prometheus(t, registry); got != want {
+		t.Errorf("salida:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := registry.Value("a_requests_total", "/hola", "200"); got != 3.5 {
+		t.Errorf("Value del contador = %v, want 3.5", got)
+	}
+	if got := registry.Value("b_latency_seconds", "chat"); got != 4 {
+		t.Errorf("Value del histograma = %v, want 4 observaciones", got)
+	}
+	if registry.Value("a_requests_total", "/nada", "200") != 0 || registry.Value("no_existe") != 0 {
+		t.Error("Value de una serie o métrica inexistente distinto de 0")
+	}
+}
+
+func TestDefaultBuckets(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Histogram("latency_seconds", "Latencia", nil).Observe(200)
+
+	out := prometheus(t, registry)
+	if got := strings.Count(out, "latency_seconds_bucket{"); got != len(metrics.DurationBuckets)+1 {
+		t.Errorf("%d buckets, want DurationBuckets y +Inf:\n%s", got, out)
+	}
+	if !strings.Contains(out, `latency_seconds_bucket{le="120"} 0`) || !strings.Contains(out, `latency_seconds_bucket{le="+Inf"} 1`) {
+		t.Errorf("una observación mayor que todos los buckets solo cuenta en +Inf:\n%s", out)
+	}
+}
+
+// Pedir dos veces el mismo nombre comparte las series; con otro tipo o
+// etiquetas es un error de programación
+func TestRegistrationConflict(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Counter("requests_total", "Peticiones", "path").Add(1, "/")
+	registry.Counter("requests_total", "Otra ayuda", "path").Add(2, "/")
+	if got := registry.Value("requests_total", "/"); got != 3 {
+		t.Errorf("contador compartido = %v, want 3", got)
+	}
+	if out := prometheus(t, registry); !strings.Contains(out, "# HELP requests_total Peticiones\n") {
+		t.Errorf("la ayuda de la primera definición se perdió:\n%s", out)
+	}
+
+	tests := []struct {
+		name     string
+		register func()
+	}{
+		{"otro tipo", func() { registry.Histogram("requests_total", "Peticiones", nil, "path") }},
+		{"otras etiquetas", func() { registry.Counter("requests_total", "Peticiones", "path", "code") }},
+		{"sin etiquetas", func() { registry.Counter("requests_total", "Peticiones") }},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Fatal("el registro en conflicto no provocó un pánico")
+				}
+				if message := fmt.Sprint(r); !strings.Contains(message, "métrica requests_total registrada como counter[path]") {
+					t.Errorf("pánico = %q", message)
+				}
+			}()
+			tt.register()
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Counter("requests_total", "Peticiones").Add(1)
+	server := httptest.NewServer(registry.Handler())
+	defer server.Close()
+
+	tests := []struct {
+		method string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, http.StatusOK, "requests_total 1\n"},
+		{http.MethodHead, http.StatusOK, ""},
+		{http.MethodPost, http.StatusMethodNotAllowed, "método no permitido"},
+	}
+	for _, tt := range tests {
+		request, _ := http.NewRequest(tt.method, server.URL, nil)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.method, err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+
+		if response.StatusCode != tt.code {
+			t.Errorf("%s: código %d, want %d", tt.method, response.StatusCode, tt.code)
+			continue
+		}
+		if tt.body == "" && len(body) != 0 || !strings.Contains(string(body), tt.body) {
+			t.Errorf("%s: cuerpo %q, want %q", tt.method, body, tt.body)
+		}
+		if tt.code == http.StatusOK {
+			if got := response.Header.Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+				t.Errorf("%s: Content-Type = %q", tt.method, got)
+			}
+		} else if got := response.Header.Get("Allow"); got != "GET, HEAD" {
+			t.Errorf("%s: Allow = %q", tt.method, got)
+		}
+	}
+}
+
+// timeoutError es un net.Error que vence por tiempo
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestStatusAndErrorType(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		status    string
+		errorType string
+	}{
+		{"nil", nil, "ok", ""},
+		{"canceled", fmt.Errorf("error llamando: %w", context.Canceled), "canceled", "canceled"},
+		{"deadline", fmt.Errorf("error llamando: %w", context.DeadlineExceeded), "error", "timeout"},
+		{"net timeout", &net.OpError{Op: "read", Err: timeoutError{}}, "error", "timeout"},
+		{"connection", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, "error", "connection"},
+		{"exit", fmt.Errorf("error ejecutando: %w", &exec.ExitError{}), "error", "exit"},
+		{"other", errors.New("respuesta inesperada"), "error", "other"},
+	}
+	for _, tt := range tests {
+		if got := metrics.Status(tt.err); got != tt.status {
+			t.Errorf("%s: Status = %q, want %q", tt.name, got, tt.status)
+		}
+		if tt.err == nil {
+			continue
+		}
+		if got := metrics.ErrorType(tt.err); got != tt.errorType {
+			t.Errorf("%s: ErrorType = %q, want %q", tt.name, got, tt.errorType)
+		}
+	}
+}
+
+func TestNop(t *testing.T) {
+	m := metrics.OrNop(nil)
+	m.Counter("requests_total", "Peticiones").Add(1)
+	m.Histogram("requests_total", "Peticiones", nil).Observe(1)
+
+	registry := metrics.NewRegistry()
+	if metrics.OrNop(registry) != metrics.Metrics(registry) {
+		t.Error("OrNop no devolvió el Metrics recibido")
+	}
+}
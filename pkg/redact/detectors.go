@@ -0,0 +1,202 @@
+package redact
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Nombres de los detectores incluidos
+const (
+	DetectorEmail      = "email"
+	DetectorIBAN       = "iban"
+	DetectorPhone      = "phone"
+	DetectorNationalID = "national_id"
+	DetectorCard       = "card"
+	DetectorIP         = "ip"
+)
+
+// detector reconoce un tipo de dato personal. valid, si no es nil,
+// descarta coincidencias del patrón que no superan la comprobación;
+// skipAfter, si no es nil, las descarta cuando el texto anterior termina
+// con ese patrón (p. ej. "versión 1.2.3.4" no es una IP).
+type detector struct {
+	name      string
+	label     string
+	re        *regexp.Regexp
+	valid     func(match string) bool
+	skipAfter *regexp.Regexp
+}
+
+// builtinDetectors devuelve los detectores incluidos en el orden en que se aplican:
+// primero los más específicos, para que un IBAN o una tarjeta no acaben como teléfono
+func builtinDetectors() map[string][]detector {
+	return map[string][]detector{
+		DetectorEmail: {{
+			name:  DetectorEmail,
+			label: "EMAIL",
+			re:    regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+		}},
+		DetectorIBAN: {{
+			name:  DetectorIBAN,
+			label: "IBAN",
+			re:    regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`),
+			valid: validIBAN,
+		}},
+		DetectorCard: {{
+			name:  DetectorCard,
+			label: "TARJETA",
+			re:    regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+			valid: validCard,
+		}},
+		DetectorNationalID: {
+			{name: DetectorNationalID, label: "DNI", re: regexp.MustCompile(`\b\d{8}[ -]?[A-Za-z]\b`), valid: validDNI},
+			{name: DetectorNationalID, label: "NIE", re: regexp.MustCompile(`\b[XYZxyz][ -]?\d{7}[ -]?[A-Za-z]\b`), valid: validNIE},
+			// CURP (México) y SSN (EE. UU.)
+			{name: DetectorNationalID, label: "CURP", re: regexp.MustCompile(`\b[A-Z]{4}\d{6}[HM][A-Z]{5}[A-Z0-9]\d\b`)},
+			{name: DetectorNationalID, label: "SSN", re: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), valid: validSSN},
+		},
+		DetectorIP: {
+			{name: DetectorIP, label: "IP", re: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), valid: validIP, skipAfter: versionPrefix},
+			// IPv6 terminada en un grupo, sin pegarse a una palabra: "std::vector" o "Foo::Bar" no son IPs
+			{name: DetectorIP, label: "IP", re: regexp.MustCompile(`(?i)(?:\b[0-9a-f]{1,4})?(?::[0-9a-f]{0,4}){1,6}:[0-9a-f]{1,4}\b`), valid: validIPv6, skipAfter: wordBefore},
+		},
+		DetectorPhone: {
+			// Internacional, con prefijo + o 00
+			{name: DetectorPhone, label: "TELEFONO", re: regexp.MustCompile(`(?:\+|\b00)[1-9][\d ().\-]{6,17}\d\b`), valid: validPhone},
+			// Nacional, solo si está agrupado con espacios o guiones: 612 345 678, (91) 123 45 67
+			{name: DetectorPhone, label: "TELEFONO", re: regexp.MustCompile(`\(?\b\d{2,4}\)?[ \-]\d{2,4}(?:[ \-]\d{2,4}){1,3}\b`), valid: validPhone},
+		},
+	}
+}
+
+// detectorOrder es el orden de aplicación de los detectores incluidos
+var detectorOrder = []string{DetectorEmail, DetectorIBAN, DetectorCard, DetectorNationalID, DetectorIP, DetectorPhone}
+
+// versionPrefix reconoce el texto que precede a un número de versión
+var versionPrefix = regexp.MustCompile(`(?i)\b(?:versi[oó]n|release|build|v)\.?\s*$`)
+
+// wordBefore reconoce el texto que termina en una palabra o en ':', como el
+// espacio de nombres en "Foo::Bar"
+var wordBefore = regexp.MustCompile(`[\w:]$`)
+
+// digits devuelve solo los dígitos de s
+func digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// validCard comprueba longitud y dígito de control (algoritmo de Luhn)
+func validCard(match string) bool {
+	number := digits(match)
+	if len(number) < 13 || len(number) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// dniLetters es la tabla de letras de control del DNI/NIE
+const dniLetters = "TRWAGMYFPDXBNJZSQVHLCKE"
+
+// validDNI comprueba la letra de control de un DNI
+func validDNI(match string) bool {
+	number := digits(match)
+	letter := strings.ToUpper(match[len(match)-1:])
+	return checkDNILetter(number, letter)
+}
+
+// validNIE comprueba la letra de control de un NIE (X=0, Y=1, Z=2)
+func validNIE(match string) bool {
+	prefix := strings.IndexByte("XYZ", strings.ToUpper(match[:1])[0])
+	number := string(rune('0'+prefix)) + digits(match)
+	letter := strings.ToUpper(match[len(match)-1:])
+	return checkDNILetter(number, letter)
+}
+
+func checkDNILetter(number, letter string) bool {
+	n := 0
+	for _, r := range number {
+		n = n*10 + int(r-'0')
+	}
+	return string(dniLetters[n%23]) == letter
+}
+
+// validSSN descarta los rangos que la administración estadounidense nunca asigna
+func validSSN(match string) bool {
+	parts := strings.Split(match, "-")
+	return parts[0] != "000" && parts[0] != "666" && parts[0][0] != '9' && parts[1] != "00" && parts[2] != "0000"
+}
+
+func validIP(match string) bool {
+	return net.ParseIP(match) != nil
+}
+
+// validIPv6 exige la forma de una dirección real: al menos tres grupos, o
+// "::" junto a un grupo de dos o más dígitos
+func validIPv6(match string) bool {
+	if net.ParseIP(match) == nil {
+		return false
+	}
+	groups, long := 0, false
+	for _, group := range strings.Split(match, ":") {
+		if group != "" {
+			groups++
+			long = long || len(group) >= 2
+		}
+	}
+	return groups >= 3 || (strings.Contains(match, "::") && long)
+}
+
+// validIBAN comprueba la longitud y el dígito de control (módulo 97, ISO 13616)
+func validIBAN(match string) bool {
+	iban := strings.ReplaceAll(match, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	rest := 0
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			rest = (rest*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			rest = (rest*100 + int(r-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return rest == 1
+}
+
+// Formas numéricas que el patrón de teléfono puede confundir con uno
+var (
+	phoneDate    = regexp.MustCompile(`\b(?:(?:19|20)\d{2}[-./](?:0?[1-9]|1[0-2])[-./](?:0?[1-9]|[12]\d|3[01])|(?:0?[1-9]|[12]\d|3[01])[-./](?:0?[1-9]|1[0-2])[-./](?:19|20)\d{2})\b`)
+	phoneDecimal = regexp.MustCompile(`^(?:\+|00)?\d+[.,]\d+$`)
+)
+
+// validPhone acepta entre 9 y 15 dígitos (E.164), descartando fechas y decimales
+func validPhone(match string) bool {
+	n := len(digits(match))
+	if n < 9 || n > 15 {
+		return false
+	}
+	return !phoneDate.MatchString(match) && !phoneDecimal.MatchString(match)
+}
@@ -0,0 +1,79 @@
+package redact
+
+import "testing"
+
+func TestRedactDetectors(t *testing.T) {
+	redactor, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// Datos personales
+		{"email", "escribe a ana.perez@example.com hoy", "escribe a [EMAIL] hoy"},
+		{"phone international", "llama al +34 612 345 678", "llama al [TELEFONO]"},
+		{"phone 00 prefix", "llama al 0034612345678", "llama al [TELEFONO]"},
+		{"phone grouped", "mi móvil es 612 34 56 78", "mi móvil es [TELEFONO]"},
+		{"phone hyphens", "fijo: 91-123-45-67", "fijo: [TELEFONO]"},
+		{"phone area code", "oficina (91) 123 45 67", "oficina [TELEFONO]"},
+		{"iban", "IBAN ES91 2100 0418 4502 0005 1332", "IBAN [IBAN]"},
+		{"iban compact", "cuenta ES9121000418450200051332.", "cuenta [IBAN]."},
+		{"card", "tarjeta 4111 1111 1111 1111", "tarjeta [TARJETA]"},
+		{"dni", "DNI 12345678Z", "DNI [DNI]"},
+		{"ipv4", "conexión desde 192.168.1.20", "conexión desde [IP]"},
+		{"ipv6", "desde 2001:db8::1", "desde [IP]"},
+		{"ipv6 full", "host 2001:0db8:85a3:0000:0000:8a2e:0370:7334.", "host [IP]."},
+		{"ipv6 compressed", "ruta ::ffff:c000 y fe80::1ff:fe23%eth0", "ruta [IP] y [IP]%eth0"},
+
+		// Texto ordinario que no debe tocarse
+		{"date time", "2024-01-15 10:30", "2024-01-15 10:30"},
+		{"date dmy", "el 15-01-2024 a las 10 30 12", "el 15-01-2024 a las 10 30 12"},
+		{"decimal", "pi vale 3.14159265", "pi vale 3.14159265"},
+		{"decimal with sign", "saldo +3.14159265", "saldo +3.14159265"},
+		{"order number", "pedido 123456789", "pedido 123456789"},
+		{"thousands", "ventas de 1.234.567.890 euros", "ventas de 1.234.567.890 euros"},
+		{"version", "versión 1.2.3.4", "versión 1.2.3.4"},
+		{"version v", "release v1.2.3.4", "release v1.2.3.4"},
+		{"invalid iban", "ref ES00 2100 0418 4502 0005 1332", "ref ES00 2100 0418 4502 0005 1332"},
+		{"invalid card", "código 1234 5678 9012 3456", "código 1234 5678 9012 3456"},
+		{"short number", "llama al 612 345", "llama al 612 345"},
+		{"cpp scope", "usa std::vector", "usa std::vector"},
+		{"cpp method", "llama a Foo::Bar()", "llama a Foo::Bar()"},
+		{"cpp hex name", "llama a Foo::Bad()", "llama a Foo::Bad()"},
+		{"rust path", "use std::collections::HashMap;", "use std::collections::HashMap;"},
+		{"rust nested", "crate::a::b::c", "crate::a::b::c"},
+		{"empty address", "el operador :: de C++", "el operador :: de C++"},
+		{"clock", "a las 10:30:00", "a las 10:30:00"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactor.Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidIBAN(t *testing.T) {
+	tests := []struct {
+		iban string
+		want bool
+	}{
+		{"ES9121000418450200051332", true},
+		{"ES91 2100 0418 4502 0005 1332", true},
+		{"GB82WEST12345698765432", true},
+		{"DE89370400440532013000", true},
+		{"ES9221000418450200051332", false},
+		{"ES91", false},
+	}
+	for _, tt := range tests {
+		if got := validIBAN(tt.iban); got != tt.want {
+			t.Errorf("validIBAN(%q) = %v, want %v", tt.iban, got, tt.want)
+		}
+	}
+}
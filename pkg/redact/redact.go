@@ -0,0 +1,211 @@
+// Package redact detecta y oculta datos personales (correos, teléfonos,
+// documentos de identidad, IBAN, tarjetas, IPs y patrones propios) en texto libre.
+//
+// En modo máscara cada dato se sustituye por una etiqueta ([EMAIL]); en modo
+// tokenización por un token estable ([EMAIL_3fa2c19b04d1]) cuyo valor original
+// se guarda cifrado en un Vault para poder revertirlo en exportaciones autorizadas.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config contiene la configuración del redactor
+type Config struct {
+	Detectors []string        // Detectores incluidos a usar; vacío usa todos
+	Custom    []CustomPattern // Expresiones regulares propias
+	Tokenize  TokenizeConfig
+}
+
+// CustomPattern es un detector definido por el usuario
+type CustomPattern struct {
+	Name    string // Se usa como etiqueta en mayúsculas
+	Pattern string // Expresión regular (sintaxis RE2)
+}
+
+// Finding es un dato personal encontrado en un texto
+type Finding struct {
+	Detector string
+	Label    string
+	Start    int
+	End      int
+	Value    string
+}
+
+// Redactor aplica los detectores configurados. Es seguro para uso concurrente.
+type Redactor struct {
+	detectors []detector
+	tokenizer *tokenizer // nil en modo máscara
+}
+
+// New crea un redactor; devuelve error si un detector no existe o una
+// expresión regular propia no compila
+func New(config Config) (*Redactor, error) {
+	builtin := builtinDetectors()
+
+	enabled := config.Detectors
+	if len(enabled) == 0 {
+		enabled = detectorOrder
+	}
+	selected := make(map[string]bool, len(enabled))
+	for _, name := range enabled {
+		if _, ok := builtin[name]; !ok {
+			return nil, fmt.Errorf("detector desconocido: %q (disponibles: %s)", name, strings.Join(detectorOrder, ", "))
+		}
+		selected[name] = true
+	}
+
+	r := &Redactor{}
+	for _, name := range detectorOrder {
+		if selected[name] {
+			r.detectors = append(r.detectors, builtin[name]...)
+		}
+	}
+
+	// Los patrones propios van primero: suelen ser más específicos
+	custom := make([]detector, 0, len(config.Custom))
+	for _, pattern := range config.Custom {
+		if pattern.Name == "" {
+			return nil, fmt.Errorf("patrón propio sin nombre: %q", pattern.Pattern)
+		}
+		re, err := regexp.Compile(pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("patrón %s inválido: %w", pattern.Name, err)
+		}
+		custom = append(custom, detector{name: pattern.Name, label: customLabel(pattern.Name), re: re})
+	}
+	r.detectors = append(custom, r.detectors...)
+
+	if config.Tokenize.Enabled {
+		tokenizer, err := newTokenizer(config.Tokenize)
+		if err != nil {
+			return nil, err
+		}
+		r.tokenizer = tokenizer
+	}
+
+	return r, nil
+}
+
+// customLabel convierte el nombre de un patrón propio en etiqueta: mayúsculas,
+// dígitos y guiones bajos, para que sus tokens se reconozcan al revertirlos
+func customLabel(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// Find devuelve los datos personales de text, sin solapamientos y en orden
+func (r *Redactor) Find(text string) []Finding {
+	var findings []Finding
+	taken := func(start, end int) bool {
+		for _, f := range findings {
+			if start < f.End && end > f.Start {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, d := range r.detectors {
+		for _, loc := range d.re.FindAllStringIndex(text, -1) {
+			value := text[loc[0]:loc[1]]
+			if loc[0] == loc[1] || taken(loc[0], loc[1]) || (d.valid != nil && !d.valid(value)) {
+				continue
+			}
+			if d.skipAfter != nil && d.skipAfter.MatchString(text[max(0, loc[0]-32):loc[0]]) {
+				continue
+			}
+			findings = append(findings, Finding{Detector: d.name, Label: d.label, Start: loc[0], End: loc[1], Value: value})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].Start < findings[j].Start
+	})
+	return findings
+}
+
+// Redact sustituye los datos personales de text por su etiqueta o, con
+// tokenización habilitada, por un token reversible
+func (r *Redactor) Redact(text string) string {
+	return r.RedactUser("", text)
+}
+
+// RedactUser es Redact para un texto de userID: los tokens quedan en el vault
+// a su nombre y se eliminan con PurgeUser
+func (r *Redactor) RedactUser(userID, text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	findings := r.Find(text)
+	if len(findings) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, f := range findings {
+		b.WriteString(text[last:f.Start])
+		b.WriteString(r.replacement(userID, f))
+		last = f.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// replacement devuelve el texto que sustituye a un hallazgo
+func (r *Redactor) replacement(userID string, f Finding) string {
+	if r.tokenizer != nil {
+		if token, err := r.tokenizer.tokenize(userID, f.Label, f.Value); err == nil {
+			return token
+		}
+	}
+	return "[" + f.Label + "]"
+}
+
+// Detokenize restaura los valores originales de los tokens de text.
+// Solo funciona con tokenización habilitada y los tokens presentes en el vault.
+func (r *Redactor) Detokenize(text string) (string, error) {
+	if r == nil || r.tokenizer == nil {
+		return "", errTokenizeDisabled
+	}
+	return r.tokenizer.detokenize(text)
+}
+
+// PurgeUser elimina del vault los valores originales de userID y devuelve
+// cuántas entradas había. Sin tokenización no hace nada.
+func (r *Redactor) PurgeUser(userID string) (int, error) {
+	if r == nil || r.tokenizer == nil {
+		return 0, nil
+	}
+	return r.tokenizer.vault.PurgeUser(userID)
+}
+
+// PurgeBefore elimina del vault los valores sin usar desde antes de cutoff y
+// devuelve cuántas entradas había. Sin tokenización no hace nada.
+func (r *Redactor) PurgeBefore(cutoff time.Time) (int, error) {
+	if r == nil || r.tokenizer == nil {
+		return 0, nil
+	}
+	return r.tokenizer.vault.PurgeBefore(cutoff)
+}
+
+// Close guarda y cierra el vault de tokens
+func (r *Redactor) Close() error {
+	if r == nil || r.tokenizer == nil {
+		return nil
+	}
+	return r.tokenizer.vault.Close()
+}
@@ -0,0 +1,360 @@
+package redact
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultKeyEnv es la variable de entorno con la clave de tokenización
+const DefaultKeyEnv = "AGENT_REDACTION_KEY"
+
+var errTokenizeDisabled = errors.New("la tokenización no está habilitada")
+
+// tokenPattern reconoce los tokens generados por tokenize
+var tokenPattern = regexp.MustCompile(`\[([A-Z0-9_]+_[0-9a-f]{12})\]`)
+
+// TokenizeConfig configura la tokenización reversible
+type TokenizeConfig struct {
+	Enabled bool
+	// KeyEnv es la variable con la clave (32 bytes en base64, como la de "agent keygen");
+	// por defecto AGENT_REDACTION_KEY
+	KeyEnv string
+	// VaultPath es el archivo donde se guardan cifrados los valores originales.
+	// Vacío mantiene el vault solo en memoria.
+	VaultPath string
+}
+
+// tokenizer genera tokens deterministas: el mismo valor produce siempre el
+// mismo token con la misma clave, así que las estadísticas siguen siendo útiles
+type tokenizer struct {
+	mac   []byte
+	vault *Vault
+}
+
+func newTokenizer(config TokenizeConfig) (*tokenizer, error) {
+	keyEnv := config.KeyEnv
+	if keyEnv == "" {
+		keyEnv = DefaultKeyEnv
+	}
+	encoded := strings.TrimSpace(os.Getenv(keyEnv))
+	if encoded == "" {
+		return nil, fmt.Errorf("tokenización habilitada pero %s no está definida", keyEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("clave de tokenización inválida: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("clave de tokenización inválida: debe tener 32 bytes, tiene %d", len(key))
+	}
+
+	vault, err := OpenVault(config.VaultPath, deriveKey(key, "vault"))
+	if err != nil {
+		return nil, err
+	}
+	return &tokenizer{mac: deriveKey(key, "token"), vault: vault}, nil
+}
+
+// deriveKey obtiene una subclave independiente para cada uso
+func deriveKey(key []byte, purpose string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(purpose))
+	return h.Sum(nil)
+}
+
+// tokenize devuelve el token de un valor y lo registra en el vault a nombre de userID
+func (t *tokenizer) tokenize(userID, label, value string) (string, error) {
+	h := hmac.New(sha256.New, t.mac)
+	h.Write([]byte(label + "\x00" + value))
+	token := label + "_" + hex.EncodeToString(h.Sum(nil)[:6])
+
+	if err := t.vault.Put(userID, token, value); err != nil {
+		return "", err
+	}
+	return "[" + token + "]", nil
+}
+
+// detokenize sustituye los tokens conocidos por su valor original
+func (t *tokenizer) detokenize(text string) (string, error) {
+	var firstErr error
+	result := tokenPattern.ReplaceAllStringFunc(text, func(match string) string {
+		value, err := t.vault.Get(match[1 : len(match)-1])
+		if err != nil {
+			if firstErr == nil && !errors.Is(err, ErrUnknownToken) {
+				firstErr = err
+			}
+			return match
+		}
+		return value
+	})
+	return result, firstErr
+}
+
+// ErrUnknownToken indica que el vault no contiene el token
+var ErrUnknownToken = errors.New("token desconocido")
+
+// vaultTouchInterval es cada cuánto se renueva en disco la fecha de último uso
+// de una entrada que se sigue usando
+const vaultTouchInterval = 24 * time.Hour
+
+// Vault guarda los valores originales de los tokens cifrados con AES-256-GCM.
+// Cada entrada pertenece a un usuario (vacío si no se conoce) y recuerda su
+// último uso, para eliminarla con los datos del usuario o por antigüedad.
+// En disco es un archivo append-only con una entrada JSON por línea y permisos
+// 0600; las purgas lo reescriben.
+type Vault struct {
+	aead cipher.AEAD
+	path string
+
+	mu     sync.Mutex
+	values map[string]map[string]*vaultEntry // token -> usuario -> entrada
+	file   *os.File                          // nil si el vault es solo de memoria
+}
+
+// vaultEntry es una línea del archivo del vault
+type vaultEntry struct {
+	Token string    `json:"token"`
+	User  string    `json:"user,omitempty"`
+	Value string    `json:"value"` // Valor cifrado en base64
+	Seen  time.Time `json:"seen,omitempty"`
+}
+
+// OpenVault abre (o crea) el vault en path; con path vacío el vault vive solo en memoria
+func OpenVault(path string, key []byte) (*Vault, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Vault{aead: aead, path: path, values: make(map[string]map[string]*vaultEntry)}
+	if path == "" {
+		return v, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("error creando directorio del vault: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo vault: %w", err)
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry vaultEntry
+		// Una última línea truncada por un corte no invalida el resto
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Token == "" {
+			continue
+		}
+		// Las líneas posteriores de la misma entrada solo renuevan su último uso
+		v.set(&entry)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error leyendo vault: %w", err)
+	}
+
+	v.file = file
+	return v, nil
+}
+
+// set guarda una entrada en memoria (requiere mu salvo al abrir)
+func (v *Vault) set(entry *vaultEntry) {
+	users, ok := v.values[entry.Token]
+	if !ok {
+		users = make(map[string]*vaultEntry)
+		v.values[entry.Token] = users
+	}
+	users[entry.User] = entry
+}
+
+// Put registra el valor de un token para userID, o renueva su último uso si ya estaba
+func (v *Vault) Put(userID, token, value string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now().UTC()
+	entry := v.values[token][userID]
+	switch {
+	case entry != nil && now.Sub(entry.Seen) < vaultTouchInterval:
+		return nil
+	case entry != nil:
+		touched := *entry
+		touched.Seen = now
+		entry = &touched
+	default:
+		nonce := make([]byte, v.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		sealed := base64.StdEncoding.EncodeToString(v.aead.Seal(nonce, nonce, []byte(value), []byte(token)))
+		entry = &vaultEntry{Token: token, User: userID, Value: sealed, Seen: now}
+	}
+
+	if v.file != nil {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := v.file.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("error escribiendo vault: %w", err)
+		}
+		// El valor original solo existe aquí: no se da por guardado hasta llegar al disco
+		if err := v.file.Sync(); err != nil {
+			return fmt.Errorf("error sincronizando vault: %w", err)
+		}
+	}
+	v.set(entry)
+	return nil
+}
+
+// Get devuelve el valor original de un token
+func (v *Vault) Get(token string) (string, error) {
+	v.mu.Lock()
+	var sealed string
+	for _, entry := range v.values[token] {
+		sealed = entry.Value
+		break
+	}
+	v.mu.Unlock()
+	if sealed == "" {
+		return "", ErrUnknownToken
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < v.aead.NonceSize() {
+		return "", fmt.Errorf("entrada del vault corrupta: %s", token)
+	}
+	nonce, ciphertext := data[:v.aead.NonceSize()], data[v.aead.NonceSize():]
+	plain, err := v.aead.Open(nil, nonce, ciphertext, []byte(token))
+	if err != nil {
+		return "", fmt.Errorf("no se pudo descifrar %s: clave incorrecta o vault alterado", token)
+	}
+	return string(plain), nil
+}
+
+// PurgeUser elimina las entradas de userID y devuelve cuántas había. Un
+// token que también usan otros usuarios se conserva para ellos.
+func (v *Vault) PurgeUser(userID string) (int, error) {
+	return v.purge(func(entry *vaultEntry) bool { return entry.User == userID })
+}
+
+// PurgeBefore elimina las entradas sin usar desde antes de cutoff y devuelve cuántas había
+func (v *Vault) PurgeBefore(cutoff time.Time) (int, error) {
+	return v.purge(func(entry *vaultEntry) bool { return entry.Seen.Before(cutoff) })
+}
+
+// purge elimina las entradas que cumplen match y reescribe el archivo sin ellas
+func (v *Vault) purge(match func(entry *vaultEntry) bool) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	removed := 0
+	for _, users := range v.values {
+		for _, entry := range users {
+			if match(entry) {
+				removed++
+			}
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	// Primero el disco: si falla, la memoria sigue igual y la purga se puede repetir
+	if v.file != nil {
+		if err := v.rewrite(match); err != nil {
+			return 0, err
+		}
+	}
+	for token, users := range v.values {
+		for user, entry := range users {
+			if match(entry) {
+				delete(users, user)
+			}
+		}
+		if len(users) == 0 {
+			delete(v.values, token)
+		}
+	}
+	return removed, nil
+}
+
+// rewrite sustituye el archivo por uno sin las entradas que cumplen skip (requiere mu)
+func (v *Vault) rewrite(skip func(entry *vaultEntry) bool) error {
+	tmpPath := v.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("error creando vault: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, users := range v.values {
+		for _, entry := range users {
+			if skip(entry) {
+				continue
+			}
+			if err := encoder.Encode(entry); err != nil {
+				tmp.Close()
+				return err
+			}
+		}
+	}
+	if err := writer.Flush(); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error escribiendo vault: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, v.path); err != nil {
+		return fmt.Errorf("error reemplazando vault: %w", err)
+	}
+	file, err := os.OpenFile(v.path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("error abriendo vault: %w", err)
+	}
+	v.file.Close()
+	v.file = file
+	return nil
+}
+
+// Close sincroniza y cierra el archivo del vault
+func (v *Vault) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.file == nil {
+		return nil
+	}
+	err := v.file.Sync()
+	if closeErr := v.file.Close(); err == nil {
+		err = closeErr
+	}
+	v.file = nil
+	return err
+}
@@ -0,0 +1,108 @@
+package redact
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKey es una clave de tokenización válida: 32 bytes en base64
+const testKey = "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s="
+
+func tokenizingRedactor(t *testing.T, vault string) *Redactor {
+	t.Helper()
+	t.Setenv(DefaultKeyEnv, testKey)
+	redactor, err := New(Config{Tokenize: TokenizeConfig{Enabled: true, VaultPath: vault}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { redactor.Close() })
+	return redactor
+}
+
+func TestVaultPurgeUser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.jsonl")
+	redactor := tokenizingRedactor(t, path)
+
+	ana := redactor.RedactUser("ana", "escribe a ana@example.com o a comun@example.com")
+	luis := redactor.RedactUser("luis", "escribe a comun@example.com")
+	if strings.Contains(ana, "@") || strings.Contains(luis, "@") {
+		t.Fatalf("sin tokenizar: %q, %q", ana, luis)
+	}
+
+	removed, err := redactor.PurgeUser("ana")
+	if err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("PurgeUser = %d, want 2", removed)
+	}
+
+	// Lo de ana desaparece, también del archivo; el token compartido sigue para luis
+	check := func(redactor *Redactor) {
+		t.Helper()
+		if got, err := redactor.Detokenize(ana); err != nil || strings.Contains(got, "ana@example.com") {
+			t.Errorf("Detokenize(ana) = %q, %v", got, err)
+		}
+		if got, err := redactor.Detokenize(luis); err != nil || got != "escribe a comun@example.com" {
+			t.Errorf("Detokenize(luis) = %q, %v", got, err)
+		}
+	}
+	check(redactor)
+
+	if err := redactor.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if strings.Contains(string(data), `"user":"ana"`) {
+		t.Errorf("el vault conserva entradas de ana:\n%s", data)
+	}
+	check(tokenizingRedactor(t, path))
+}
+
+func TestVaultPurgeBefore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.jsonl")
+	key := deriveKey([]byte(strings.Repeat("k", 32)), "vault")
+	vault, err := OpenVault(path, key)
+	if err != nil {
+		t.Fatalf("OpenVault: %v", err)
+	}
+	defer vault.Close()
+
+	if err := vault.Put("ana", "EMAIL_000000000001", "ana@example.com"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// Nada se ha dejado de usar antes de ahora mismo menos un minuto
+	if removed, err := vault.PurgeBefore(time.Now().Add(-time.Minute)); err != nil || removed != 0 {
+		t.Errorf("PurgeBefore(pasado) = %d, %v, want 0", removed, err)
+	}
+	if removed, err := vault.PurgeBefore(time.Now().Add(time.Minute)); err != nil || removed != 1 {
+		t.Errorf("PurgeBefore(futuro) = %d, %v, want 1", removed, err)
+	}
+	if _, err := vault.Get("EMAIL_000000000001"); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("Get tras purgar = %v, want ErrUnknownToken", err)
+	}
+
+	// La purga reescribe el archivo y el vault sigue aceptando entradas
+	if err := vault.Put("luis", "EMAIL_000000000002", "luis@example.com"); err != nil {
+		t.Fatalf("Put tras purgar: %v", err)
+	}
+	vault.Close()
+	reopened, err := OpenVault(path, key)
+	if err != nil {
+		t.Fatalf("OpenVault: %v", err)
+	}
+	defer reopened.Close()
+	if got, err := reopened.Get("EMAIL_000000000002"); err != nil || got != "luis@example.com" {
+		t.Errorf("Get tras reabrir = %q, %v", got, err)
+	}
+	if _, err := reopened.Get("EMAIL_000000000001"); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("Get de la entrada purgada tras reabrir = %v, want ErrUnknownToken", err)
+	}
+}
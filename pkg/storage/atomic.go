@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic escribe un archivo de forma segura ante cortes de energía:
+// escribe en un temporal del mismo directorio, hace fsync y lo renombra
+// sobre el destino, de modo que nunca queda un archivo a medio escribir.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creando temporal para %s: %w", path, err)
+	}
+	tmpPath := tmp.Name()
+
+	// Si algo falla, no dejar temporales huérfanos
+	success := false
+	defer func() {
+		if !success {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("error escribiendo %s: %w", path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return fmt.Errorf("error ajustando permisos de %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("error sincronizando %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error cerrando %s: %w", path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("error reemplazando %s: %w", path, err)
+	}
+	success = true
+
+	return syncDir(dir)
+}
+
+// syncDir hace fsync del directorio para que el rename sea persistente
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer d.Close()
+
+	// Algunos sistemas (Windows) no permiten fsync de directorios
+	d.Sync()
+	return nil
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultBackupGenerations es el número de copias que se conservan por defecto
+const defaultBackupGenerations = 7
+
+// backupTimeFormat da nombre a las copias; incluye microsegundos para que
+// dos copias del mismo segundo no se sobrescriban y el orden alfabético sea el cronológico
+const backupTimeFormat = "20060102_150405.000000"
+
+// checksumExt es la extensión del archivo con la suma SHA-256 de cada copia
+const checksumExt = ".sha256"
+
+var (
+	// ErrChecksumMismatch indica que una copia no coincide con su suma de verificación
+	ErrChecksumMismatch = errors.New("la suma de verificación del backup no coincide")
+	// ErrNoChecksum indica que una copia no tiene archivo de suma de verificación
+	ErrNoChecksum = errors.New("el backup no tiene suma de verificación")
+)
+
+// backupGenerations devuelve cuántas copias conservar
+func (c Config) backupGenerations() int {
+	if c.BackupGenerations <= 0 {
+		return defaultBackupGenerations
+	}
+	return c.BackupGenerations
+}
+
+// newBackupPath devuelve la ruta de una copia nueva en <dataDir>/backups
+func newBackupPath(dataDir, ext string) (string, error) {
+	backupDir := filepath.Join(dataDir, "backups")
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return "", fmt.Errorf("error creando directorio de backups: %w", err)
+	}
+	name := "backup_" + time.Now().Format(backupTimeFormat) + ext
+	return filepath.Join(backupDir, name), nil
+}
+
+// fileChecksum calcula la suma SHA-256 de un archivo
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeChecksum guarda la suma de una copia en <copia>.sha256, con el formato de sha256sum
+func writeChecksum(path string) error {
+	sum, err := fileChecksum(path)
+	if err != nil {
+		return fmt.Errorf("error calculando suma de verificación: %w", err)
+	}
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+	return writeFileAtomic(path+checksumExt, []byte(line), 0644)
+}
+
+// VerifyBackup comprueba una copia contra su archivo .sha256
+func VerifyBackup(path string) error {
+	data, err := os.ReadFile(path + checksumExt)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNoChecksum
+	}
+	if err != nil {
+		return fmt.Errorf("error leyendo suma de verificación: %w", err)
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: archivo %s vacío", ErrChecksumMismatch, filepath.Base(path+checksumExt))
+	}
+
+	sum, err := fileChecksum(path)
+	if err != nil {
+		return fmt.Errorf("error calculando suma de verificación: %w", err)
+	}
+	if !strings.EqualFold(sum, fields[0]) {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, filepath.Base(path))
+	}
+	return nil
+}
+
+// verifyBeforeRestore exige que la suma coincida si existe; las copias anteriores
+// a las sumas de verificación se validan solo por su contenido
+func verifyBeforeRestore(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("error abriendo backup: %w", err)
+	}
+	if err := VerifyBackup(path); err != nil && !errors.Is(err, ErrNoChecksum) {
+		return err
+	}
+	return nil
+}
+
+// finishBackup registra la suma de una copia recién creada, la verifica
+// y elimina las generaciones sobrantes
+func finishBackup(config Config, dataDir, path string, exts ...string) error {
+	if err := writeChecksum(path); err != nil {
+		return err
+	}
+	if err := VerifyBackup(path); err != nil {
+		return err
+	}
+	return pruneBackups(dataDir, config.backupGenerations(), exts...)
+}
+
+// pruneBackups conserva las keep copias más recientes y elimina el resto con sus sumas
+func pruneBackups(dataDir string, keep int, exts ...string) error {
+	paths, err := backupFiles(dataDir, exts...)
+	if err != nil {
+		return err
+	}
+
+	// Los nombres llevan la fecha, así que el orden alfabético es el cronológico
+	sort.Slice(paths, func(i, j int) bool {
+		return filepath.Base(paths[i]) < filepath.Base(paths[j])
+	})
+
+	for len(paths) > keep {
+		path := paths[0]
+		paths = paths[1:]
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error eliminando backup antiguo: %w", err)
+		}
+		if err := os.Remove(path + checksumExt); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error eliminando suma de verificación: %w", err)
+		}
+	}
+	return nil
+}
+
+// replaceBackup actualiza la suma de una copia reescrita en newPath y, si la
+// ruta cambió, elimina la copia anterior con su suma
+func replaceBackup(oldPath, newPath string) error {
+	if err := writeChecksum(newPath); err != nil {
+		return err
+	}
+	if oldPath == newPath {
+		return nil
+	}
+	if err := os.Remove(oldPath); err != nil {
+		return err
+	}
+	if err := os.Remove(oldPath + checksumExt); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// RunBackups crea copias periódicas cada config.BackupInterval segundos hasta que se cancele ctx.
+// No hace nada si las copias están deshabilitadas. onRun, si no es nil, recibe
+// la ruta de cada copia o el error producido.
+func RunBackups(ctx context.Context, store Store, config Config, onRun func(path string, err error)) {
+	if !config.BackupEnabled || config.BackupInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.BackupInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		path, err := store.Backup()
+		if onRun != nil {
+			onRun(path, err)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Backup crea una copia de seguridad con interacciones, patrones, estadísticas
+// y conversaciones. Con cifrado habilitado la copia se cifra completa.
+func (s *JSONStore) Backup() (string, error) {
+	if !s.config.BackupEnabled {
+		return "", nil
+	}
+
+	backupPath, err := newBackupPath(s.dataDir, ".json")
+	if err != nil {
+		return "", err
+	}
+
+	conversations, err := s.readConversations()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.RLock()
+	backup := jsonBackup{
+		Interactions:  s.interactions,
+		Patterns:      s.sortedPatterns(),
+		Stats:         s.stats,
+		Conversations: conversations,
+		Timestamp:     time.Now(),
+	}
+	data, err := json.MarshalIndent(backup, "", "  ")
+	s.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
+	if s.keys != nil {
+		if data, err = s.keys.SealFile(data); err != nil {
+			return "", err
+		}
+		backupPath += encryptedExt
+	}
+
+	if err := writeFileAtomic(backupPath, data, 0644); err != nil {
+		return "", err
+	}
+	if err := finishBackup(s.config, s.dataDir, backupPath, ".json", ".json"+encryptedExt); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// readConversations lee todas las conversaciones de disco
+func (s *JSONStore) readConversations() ([]*Conversation, error) {
+	s.convMu.Lock()
+	defer s.convMu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.conversationsDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	conversations := make([]*Conversation, 0, len(paths))
+	for _, path := range paths {
+		conversation, err := s.readConversation(path)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conversation)
+	}
+	return conversations, nil
+}
+
+// readBackup lee, descifra y valida una copia JSON
+func (s *JSONStore) readBackup(path string) (*jsonBackup, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo backup: %w", err)
+	}
+	if data, err = s.keys.OpenFile(data); err != nil {
+		return nil, err
+	}
+
+	var backup jsonBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("error decodificando backup: %w", err)
+	}
+
+	for _, interaction := range backup.Interactions {
+		if interaction == nil || interaction.ID == "" {
+			return nil, errors.New("backup inválido: interacción sin ID")
+		}
+		if err := s.keys.openInteraction(interaction); err != nil {
+			return nil, err
+		}
+	}
+	for _, pattern := range backup.Patterns {
+		if pattern == nil || pattern.Key == "" {
+			return nil, errors.New("backup inválido: patrón sin clave")
+		}
+		if err := s.keys.openPattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, conversation := range backup.Conversations {
+		if conversation == nil {
+			return nil, errors.New("backup inválido: conversación vacía")
+		}
+		if _, err := s.conversationPath(conversation.ID); err != nil {
+			return nil, fmt.Errorf("backup inválido: %w", err)
+		}
+		conversation.TurnCount = len(conversation.Turns)
+	}
+	if backup.Stats == nil {
+		backup.Stats = &Stats{}
+	}
+	return &backup, nil
+}
+
+// restoreManifest confirma una restauración preparada en restoreDir. Mientras
+// exista, los archivos preparados sustituyen a los actuales al abrir el almacenamiento.
+type restoreManifest struct {
+	Conversations []string  `json:"conversations"` // IDs de la copia; el resto se elimina
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// restoreDir es el directorio donde se prepara una restauración
+func (s *JSONStore) restoreDir() string {
+	return filepath.Join(s.dataDir, ".restore")
+}
+
+// Restore valida una copia y reemplaza con ella el estado actual. Todos los
+// archivos se preparan antes en .restore y el manifiesto confirma la
+// restauración: si el proceso se interrumpe antes, los datos actuales no se han
+// tocado; si se interrumpe después, NewJSONStore la completa al abrir.
+func (s *JSONStore) Restore(path string) error {
+	if err := verifyBeforeRestore(path); err != nil {
+		return err
+	}
+	backup, err := s.readBackup(path)
+	if err != nil {
+		return err
+	}
+
+	restored := s.restoredState(backup)
+
+	if err := s.beginWrite(); err != nil {
+		return err
+	}
+	defer s.ioMu.Unlock()
+	s.convMu.Lock()
+	defer s.convMu.Unlock()
+
+	if err := s.stageRestore(restored, backup.Conversations); err != nil {
+		os.RemoveAll(s.restoreDir())
+		return err
+	}
+	if err := s.finishRestore(); err != nil {
+		return fmt.Errorf("%w (la restauración se completará al volver a abrir el almacenamiento)", err)
+	}
+
+	s.mu.Lock()
+	s.interactions, s.index, s.search = restored.interactions, restored.index, restored.search
+	s.patterns, s.stats = restored.patterns, restored.stats
+	s.mu.Unlock()
+	s.journalEntries = 0
+	s.journalDirty, s.patternsDirty, s.statsDirty = false, false, false
+	return nil
+}
+
+// restoredState construye en memoria el estado de una copia, aparte del actual
+// para no tocarlo si la restauración falla
+func (s *JSONStore) restoredState(backup *jsonBackup) *JSONStore {
+	restored := &JSONStore{
+		keys:     s.keys,
+		index:    make(map[string]int, len(backup.Interactions)),
+		search:   newSearchIndex(),
+		patterns: make(map[string]*Pattern, len(backup.Patterns)),
+		stats:    backup.Stats,
+	}
+	for _, interaction := range backup.Interactions {
+		restored.putLocked(interaction)
+	}
+	for _, pattern := range backup.Patterns {
+		restored.patterns[pattern.Key] = pattern
+	}
+	return restored
+}
+
+// stageRestore escribe en restoreDir los archivos del estado restaurado y, al
+// final, el manifiesto que confirma la restauración (requiere ioMu y convMu)
+func (s *JSONStore) stageRestore(restored *JSONStore, conversations []*Conversation) error {
+	dir := s.restoreDir()
+	// Restos de un intento anterior que no llegó a confirmarse
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("error limpiando restauración anterior: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "conversations"), 0755); err != nil {
+		return fmt.Errorf("error preparando restauración: %w", err)
+	}
+
+	interactions, err := restored.encodeInteractions()
+	if err != nil {
+		return err
+	}
+	patterns, err := restored.encodePatterns()
+	if err != nil {
+		return err
+	}
+	stats, err := json.MarshalIndent(restored.stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error codificando estadísticas: %w", err)
+	}
+	files := map[string][]byte{"interactions.json": interactions, "patterns.json": patterns, "stats.json": stats}
+
+	manifest := restoreManifest{Conversations: make([]string, 0, len(conversations)), Timestamp: time.Now()}
+	for _, conversation := range conversations {
+		data, err := s.encodeConversation(conversation)
+		if err != nil {
+			return err
+		}
+		files[filepath.Join("conversations", conversation.ID+".json")] = data
+		manifest.Conversations = append(manifest.Conversations, conversation.ID)
+	}
+	for name, data := range files {
+		if err := writeFileAtomic(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, "manifest.json"), data, 0644)
+}
+
+// finishRestore sustituye los archivos actuales por los preparados en
+// restoreDir y vacía el journal. Los archivos ya movidos no se vuelven a mover,
+// así que también completa una restauración interrumpida. Sin manifiesto, la
+// restauración no llegó a confirmarse y se descarta. (requiere ioMu y convMu)
+func (s *JSONStore) finishRestore() error {
+	dir := s.restoreDir()
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return os.RemoveAll(dir)
+	}
+	if err != nil {
+		return fmt.Errorf("error leyendo manifiesto de restauración: %w", err)
+	}
+	var manifest restoreManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("error decodificando manifiesto de restauración: %w", err)
+	}
+
+	if err := os.MkdirAll(s.conversationsDir(), 0755); err != nil {
+		return fmt.Errorf("error creando directorio de conversaciones: %w", err)
+	}
+	restored := make(map[string]bool, len(manifest.Conversations))
+	for _, id := range manifest.Conversations {
+		path, err := s.conversationPath(id)
+		if err != nil {
+			return err
+		}
+		if err := moveStaged(filepath.Join(dir, "conversations", id+".json"), path); err != nil {
+			return err
+		}
+		restored[id] = true
+	}
+	paths, err := filepath.Glob(filepath.Join(s.conversationsDir(), "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if restored[strings.TrimSuffix(filepath.Base(path), ".json")] {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("error eliminando conversación: %w", err)
+		}
+	}
+	syncDir(s.conversationsDir())
+
+	for _, target := range []string{s.patternsPath(), s.statsPath(), s.interactionsPath()} {
+		if err := moveStaged(filepath.Join(dir, filepath.Base(target)), target); err != nil {
+			return err
+		}
+	}
+	// El journal solo tiene escrituras anteriores a la copia
+	if err := truncateFile(s.journalPath()); err != nil {
+		return fmt.Errorf("error vaciando journal: %w", err)
+	}
+	syncDir(s.dataDir)
+
+	return os.RemoveAll(dir)
+}
+
+// moveStaged mueve un archivo preparado a su destino; si ya no está, es que
+// se movió antes de una interrupción
+func moveStaged(from, to string) error {
+	if err := os.Rename(from, to); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error restaurando %s: %w", filepath.Base(to), err)
+	}
+	return nil
+}
+
+// truncateFile vacía un archivo, si existe, y lo sincroniza
+func truncateFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
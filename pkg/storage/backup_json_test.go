@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// stagedRestore abre un almacenamiento con la interacción y la conversación
+// "actual", prepara la restauración de una copia con "restaurada" y lo cierra
+// como si el proceso se hubiera interrumpido tras prepararla
+func stagedRestore(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data.json")
+	store, err := NewJSONStore(Config{Path: path})
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	now := time.Now()
+	if err := store.SaveInteraction(&Interaction{ID: "actual", Timestamp: now}); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+	if err := store.SaveConversation(&Conversation{ID: "actual", StartedAt: now}); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+
+	backup := &jsonBackup{
+		Interactions:  []*Interaction{{ID: "restaurada", Timestamp: now}},
+		Patterns:      []*Pattern{{Key: "saludo", Pattern: "hola"}},
+		Stats:         &Stats{TotalInteractions: 1},
+		Conversations: []*Conversation{{ID: "restaurada", StartedAt: now}},
+	}
+	if err := store.stageRestore(store.restoredState(backup), backup.Conversations); err != nil {
+		t.Fatalf("stageRestore: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return path
+}
+
+// assertState comprueba qué interacción y qué conversación hay al reabrir
+func assertState(t *testing.T, path, want string) {
+	t.Helper()
+	store, err := NewJSONStore(Config{Path: path})
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	defer store.Close()
+
+	interactions, err := store.GetRecentInteractions(0)
+	if err != nil {
+		t.Fatalf("GetRecentInteractions: %v", err)
+	}
+	if len(interactions) != 1 || interactions[0].ID != want {
+		t.Errorf("interacciones = %v, want solo %q", ids(interactions), want)
+	}
+	conversations, err := store.ListConversations(ConversationFilter{})
+	if err != nil {
+		t.Fatalf("ListConversations: %v", err)
+	}
+	if len(conversations) != 1 || conversations[0].ID != want {
+		t.Errorf("conversaciones = %d, want solo %q", len(conversations), want)
+	}
+	if _, err := os.Stat(store.restoreDir()); !os.IsNotExist(err) {
+		t.Errorf("quedó el directorio de restauración: %v", err)
+	}
+}
+
+func ids(interactions []*Interaction) []string {
+	var ids []string
+	for _, interaction := range interactions {
+		ids = append(ids, interaction.ID)
+	}
+	return ids
+}
+
+func TestJSONRestoreInterrupted(t *testing.T) {
+	t.Run("before manifest", func(t *testing.T) {
+		path := stagedRestore(t)
+		os.Remove(filepath.Join(filepath.Dir(path), ".restore", "manifest.json"))
+		assertState(t, path, "actual")
+	})
+
+	t.Run("after manifest", func(t *testing.T) {
+		assertState(t, stagedRestore(t), "restaurada")
+	})
+
+	t.Run("during swap", func(t *testing.T) {
+		path := stagedRestore(t)
+		dir := filepath.Dir(path)
+		for _, name := range []string{"patterns.json", filepath.Join("conversations", "restaurada.json")} {
+			if err := moveStaged(filepath.Join(dir, ".restore", name), filepath.Join(dir, name)); err != nil {
+				t.Fatalf("moveStaged: %v", err)
+			}
+		}
+		assertState(t, path, "restaurada")
+	})
+}
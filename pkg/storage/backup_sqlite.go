@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// restoreTables son las tablas con datos que Restore reemplaza, en orden
+var restoreTables = []string{"interactions", "patterns", "stats", "conversations", "conversation_turns"}
+
+// Backup crea una copia de la base de datos con VACUUM INTO, que funciona
+// en línea con ambos drivers sin bloquear las escrituras más que la lectura.
+// Con cifrado habilitado la copia se cifra completa (backup_<fecha>.db.enc).
+func (s *SQLiteStore) Backup() (string, error) {
+	if !s.config.BackupEnabled {
+		return "", nil
+	}
+
+	dataDir := filepath.Dir(s.config.Path)
+	backupPath, err := newBackupPath(dataDir, ".db")
+	if err != nil {
+		return "", err
+	}
+
+	plainPath := backupPath
+	if s.keys != nil {
+		plainPath = backupPath + ".tmp"
+		defer os.Remove(plainPath)
+	}
+
+	if _, err := s.db.Exec(`VACUUM INTO ?`, plainPath); err != nil {
+		return "", fmt.Errorf("error creando backup: %w", err)
+	}
+	if err := checkIntegrity(s.driver, plainPath); err != nil {
+		os.Remove(plainPath)
+		return "", err
+	}
+
+	if s.keys != nil {
+		backupPath += encryptedExt
+		if err := sealFileTo(s.keys, plainPath, backupPath); err != nil {
+			return "", err
+		}
+	}
+
+	if err := finishBackup(s.config, dataDir, backupPath, ".db", ".db"+encryptedExt); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// checkIntegrity ejecuta PRAGMA integrity_check sobre un archivo SQLite
+func checkIntegrity(driver, path string) error {
+	db, err := sql.Open(driver, path)
+	if err != nil {
+		return fmt.Errorf("error abriendo %s: %w", filepath.Base(path), err)
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("error verificando %s: %w", filepath.Base(path), err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("backup %s corrupto: %s", filepath.Base(path), result)
+	}
+	return nil
+}
+
+// Restore valida una copia y reemplaza el contenido de todas las tablas en una
+// única transacción: si algo falla, la base de datos queda como estaba.
+// La copia se migra al esquema actual sobre un archivo temporal; el original no se modifica.
+func (s *SQLiteStore) Restore(path string) error {
+	if err := verifyBeforeRestore(path); err != nil {
+		return err
+	}
+
+	tmpPath := s.config.Path + ".restore"
+	defer os.Remove(tmpPath)
+	if err := openFileTo(s.keys, path, tmpPath); err != nil {
+		return err
+	}
+	if err := checkIntegrity(s.driver, tmpPath); err != nil {
+		return err
+	}
+
+	// Aplicar las migraciones pendientes para que las columnas coincidan
+	restored, err := openSQLiteCopy(tmpPath, s.driver, s.keys)
+	if err != nil {
+		return fmt.Errorf("error preparando backup: %w", err)
+	}
+	if err := restored.Close(); err != nil {
+		return err
+	}
+
+	// ATTACH no se permite dentro de una transacción, así que se fija una conexión
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS restored`, tmpPath); err != nil {
+		return fmt.Errorf("error abriendo backup: %w", err)
+	}
+	defer conn.ExecContext(ctx, `DETACH DATABASE restored`)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range restoreTables {
+		columns, err := tableColumns(tx, table)
+		if err != nil {
+			return err
+		}
+		list := strings.Join(columns, ", ")
+
+		if _, err := tx.Exec(`DELETE FROM main.` + table); err != nil {
+			return fmt.Errorf("error vaciando %s: %w", table, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO main.` + table + ` (` + list + `) SELECT ` + list + ` FROM restored.` + table); err != nil {
+			return fmt.Errorf("error restaurando %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error confirmando restauración: %w", err)
+	}
+	return nil
+}
+
+// tableColumns devuelve las columnas de una tabla de la base de datos principal
+func tableColumns(tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info(?, 'main')`, table)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo columnas de %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
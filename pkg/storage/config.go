@@ -1,9 +1,22 @@
 package storage
 
+import "time"
+
 // Config contiene la configuración de almacenamiento
 type Config struct {
-	Type           string
-	Path           string
-	BackupEnabled  bool
-	BackupInterval int
+	Type              string
+	Path              string
+	BackupEnabled     bool
+	BackupInterval    int // Segundos entre copias programadas con RunBackups
+	BackupGenerations int // Copias que se conservan (por defecto 7)
+
+	// Encryption cifra los campos sensibles y las copias de seguridad
+	Encryption EncryptionConfig
+
+	// Retention se aplica con RunRetention; vacía conserva todo
+	Retention RetentionPolicy
+
+	// Opciones del almacenamiento JSON
+	FlushInterval    time.Duration // Espera antes de volcar cambios a disco (por defecto 1s)
+	CompactThreshold int           // Entradas del journal que disparan la compactación (por defecto 1000)
 }
@@ -0,0 +1,485 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultKeyEnv es la variable de entorno por defecto con las claves de cifrado
+const DefaultKeyEnv = "AGENT_ENCRYPTION_KEY"
+
+// Prefijos que identifican los datos cifrados
+const (
+	fieldPrefix   = "enc:v2:" // Campo cifrado con la fila y el campo como datos asociados
+	fieldPrefixV1 = "enc:v1:" // Campo cifrado sin datos asociados, de versiones anteriores
+	fileMagic     = "AGENTENC1"
+)
+
+// Los textos en claro que empiezan por escapeNamespace se guardan con
+// plainPrefix delante, para no confundirlos con un campo cifrado
+const (
+	escapeNamespace = "enc:"
+	plainPrefix     = "enc:plain:"
+)
+
+// encryptedExt es la extensión añadida a las copias de seguridad cifradas
+const encryptedExt = ".enc"
+
+// encryptedContextKey es la clave con la que se guarda el contexto cifrado
+const encryptedContextKey = "_enc"
+
+// errEncryptionDisabled se devuelve al pedir Reencrypt sin claves configuradas
+var errEncryptionDisabled = errors.New("el cifrado no está habilitado")
+
+// ReencryptReport resume una ejecución de Reencrypt
+type ReencryptReport struct {
+	KeyID         string `json:"key_id"`
+	Interactions  int    `json:"interactions"`
+	Patterns      int    `json:"patterns"`
+	Conversations int    `json:"conversations"`
+	Backups       int    `json:"backups"`
+}
+
+// ErrEncrypted indica que hay datos cifrados y no se configuró la clave para leerlos
+var ErrEncrypted = errors.New("los datos están cifrados y no hay clave configurada")
+
+// EncryptionConfig configura el cifrado en reposo
+type EncryptionConfig struct {
+	Enabled bool
+	KeyEnv  string // Variable de entorno con las claves (por defecto AGENT_ENCRYPTION_KEY)
+	KeyFile string // Archivo con una clave por línea; tiene prioridad sobre KeyEnv
+}
+
+// KeyRing cifra con AES-256-GCM usando la clave actual y descifra con
+// cualquiera de las claves conocidas, lo que permite rotarlas: la nueva
+// clave se añade la primera y las anteriores se conservan hasta ejecutar
+// Reencrypt. Las claves son 32 bytes codificados en base64.
+//
+// Un KeyRing nil no cifra, y al leer devuelve ErrEncrypted si encuentra datos cifrados.
+type KeyRing struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// GenerateKey genera una clave aleatoria codificada en base64
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("error generando clave: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// NewKeyRing crea un anillo de claves; la primera es la actual
+func NewKeyRing(keys ...string) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no se proporcionó ninguna clave de cifrado")
+	}
+
+	ring := &KeyRing{keys: make(map[string]cipher.AEAD, len(keys))}
+	for i, encoded := range keys {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("clave %d inválida: %w", i+1, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("clave %d inválida: debe tener 32 bytes, tiene %d", i+1, len(key))
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(key)
+		id := hex.EncodeToString(sum[:4])
+		if i == 0 {
+			ring.current = id
+		}
+		ring.keys[id] = aead
+	}
+
+	return ring, nil
+}
+
+// LoadKeyRing carga las claves indicadas por la configuración (nil si el cifrado está deshabilitado)
+func LoadKeyRing(config EncryptionConfig) (*KeyRing, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	var keys []string
+	if config.KeyFile != "" {
+		file, err := os.Open(config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error abriendo archivo de claves: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				keys = append(keys, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("error leyendo archivo de claves: %w", err)
+		}
+	} else {
+		env := config.KeyEnv
+		if env == "" {
+			env = DefaultKeyEnv
+		}
+		for _, key := range strings.Split(os.Getenv(env), ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("cifrado habilitado pero %s está vacía", env)
+		}
+	}
+
+	return NewKeyRing(keys...)
+}
+
+// KeyID devuelve el identificador de la clave actual
+func (k *KeyRing) KeyID() string {
+	if k == nil {
+		return ""
+	}
+	return k.current
+}
+
+// seal cifra con la clave actual y devuelve id, nonce y texto cifrado.
+// additional se autentica pero no se cifra.
+func (k *KeyRing) seal(plaintext, additional []byte) (string, []byte, error) {
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", nil, fmt.Errorf("error generando nonce: %w", err)
+	}
+	return k.current, aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open descifra datos producidos por seal con los mismos datos asociados
+func (k *KeyRing) open(id string, sealed, additional []byte) ([]byte, error) {
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("clave de cifrado %s desconocida", id)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("dato cifrado truncado")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additional)
+	if err != nil {
+		return nil, fmt.Errorf("error descifrando con la clave %s: %w", id, err)
+	}
+	return plaintext, nil
+}
+
+// fieldAAD son los datos asociados de un campo: identifican la fila y el
+// campo, de modo que un valor cifrado no se puede mover a otro sitio
+func fieldAAD(id, field string) string {
+	return id + "\x00" + field
+}
+
+// SealString cifra un campo de texto; aad identifica la fila y el campo (ver
+// fieldAAD) y hay que repetirlo en OpenString. Con un KeyRing nil o un texto
+// vacío lo devuelve en claro, escapado si pudiera pasar por un campo cifrado.
+func (k *KeyRing) SealString(value, aad string) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+	if k == nil {
+		if strings.HasPrefix(value, escapeNamespace) {
+			return plainPrefix + value, nil
+		}
+		return value, nil
+	}
+	id, sealed, err := k.seal([]byte(value), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return fieldPrefix + id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenString descifra un campo guardado por SealString con el mismo aad;
+// los valores sin cifrar se devuelven tal cual
+func (k *KeyRing) OpenString(value, aad string) (string, error) {
+	var additional []byte
+	switch {
+	case strings.HasPrefix(value, plainPrefix):
+		return strings.TrimPrefix(value, plainPrefix), nil
+	case strings.HasPrefix(value, fieldPrefix):
+		value, additional = strings.TrimPrefix(value, fieldPrefix), []byte(aad)
+	case strings.HasPrefix(value, fieldPrefixV1):
+		value = strings.TrimPrefix(value, fieldPrefixV1)
+	default:
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrEncrypted
+	}
+
+	id, encoded, ok := strings.Cut(value, ":")
+	if !ok {
+		return "", errors.New("formato de campo cifrado inválido")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("formato de campo cifrado inválido: %w", err)
+	}
+	plaintext, err := k.open(id, sealed, additional)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// SealFile cifra el contenido completo de un archivo
+func (k *KeyRing) SealFile(data []byte) ([]byte, error) {
+	id, sealed, err := k.seal(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(fileMagic)+len(id)+len(sealed))
+	out = append(out, fileMagic...)
+	out = append(out, id...)
+	return append(out, sealed...), nil
+}
+
+// OpenFile descifra un archivo producido por SealFile; el contenido sin cifrar se devuelve tal cual
+func (k *KeyRing) OpenFile(data []byte) ([]byte, error) {
+	if !IsEncryptedFile(data) {
+		return data, nil
+	}
+	if k == nil {
+		return nil, ErrEncrypted
+	}
+	header := len(fileMagic) + 8
+	if len(data) < header {
+		return nil, errors.New("archivo cifrado truncado")
+	}
+	return k.open(string(data[len(fileMagic):header]), data[header:], nil)
+}
+
+// sealFileTo cifra el archivo src y lo escribe de forma atómica en dst
+func sealFileTo(k *KeyRing, src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("error leyendo %s: %w", src, err)
+	}
+	sealed, err := k.SealFile(data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, sealed, 0644)
+}
+
+// openFileTo descifra el archivo src y lo escribe en dst
+func openFileTo(k *KeyRing, src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("error leyendo %s: %w", src, err)
+	}
+	opened, err := k.OpenFile(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	return writeFileAtomic(dst, opened, 0600)
+}
+
+// IsEncryptedFile indica si el contenido fue cifrado con SealFile
+func IsEncryptedFile(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(fileMagic))
+}
+
+// sealContext cifra el contexto completo de la interacción id como un único campo
+func (k *KeyRing) sealContext(id string, context map[string]interface{}) (map[string]interface{}, error) {
+	if k == nil || len(context) == 0 {
+		return context, nil
+	}
+	data, err := json.Marshal(context)
+	if err != nil {
+		return nil, fmt.Errorf("error codificando contexto: %w", err)
+	}
+	sealed, err := k.SealString(string(data), fieldAAD(id, "context"))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{encryptedContextKey: sealed}, nil
+}
+
+// openContext descifra un contexto guardado por sealContext
+func (k *KeyRing) openContext(id string, context map[string]interface{}) (map[string]interface{}, error) {
+	sealed, ok := context[encryptedContextKey].(string)
+	if !ok || len(context) != 1 {
+		return context, nil
+	}
+	data, err := k.OpenString(sealed, fieldAAD(id, "context"))
+	if err != nil {
+		return nil, err
+	}
+	var opened map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &opened); err != nil {
+		return nil, fmt.Errorf("error decodificando contexto: %w", err)
+	}
+	return opened, nil
+}
+
+// sealInteraction devuelve una copia de la interacción con los campos sensibles
+// cifrados. Sin claves solo escapa los textos que pudieran pasar por cifrados.
+func (k *KeyRing) sealInteraction(interaction *Interaction) (*Interaction, error) {
+	if k == nil && !needsEscape(interaction.UserInput, interaction.Response, feedbackComment(interaction.Feedback)) {
+		return interaction, nil
+	}
+
+	sealed := copyInteraction(interaction)
+	var err error
+	if sealed.UserInput, err = k.SealString(sealed.UserInput, fieldAAD(sealed.ID, "user_input")); err != nil {
+		return nil, err
+	}
+	if sealed.Response, err = k.SealString(sealed.Response, fieldAAD(sealed.ID, "response")); err != nil {
+		return nil, err
+	}
+	if sealed.Context, err = k.sealContext(sealed.ID, sealed.Context); err != nil {
+		return nil, err
+	}
+	if sealed.Feedback != nil {
+		if sealed.Feedback.Comment, err = k.SealString(sealed.Feedback.Comment, fieldAAD(sealed.ID, "feedback_comment")); err != nil {
+			return nil, err
+		}
+	}
+	return sealed, nil
+}
+
+// needsEscape indica si algún texto empieza como un campo cifrado
+func needsEscape(values ...string) bool {
+	for _, value := range values {
+		if strings.HasPrefix(value, escapeNamespace) {
+			return true
+		}
+	}
+	return false
+}
+
+func feedbackComment(feedback *Feedback) string {
+	if feedback == nil {
+		return ""
+	}
+	return feedback.Comment
+}
+
+// openInteraction descifra en su sitio los campos sensibles de una interacción
+func (k *KeyRing) openInteraction(interaction *Interaction) error {
+	var err error
+	if interaction.UserInput, err = k.OpenString(interaction.UserInput, fieldAAD(interaction.ID, "user_input")); err != nil {
+		return err
+	}
+	if interaction.Response, err = k.OpenString(interaction.Response, fieldAAD(interaction.ID, "response")); err != nil {
+		return err
+	}
+	if interaction.Context, err = k.openContext(interaction.ID, interaction.Context); err != nil {
+		return err
+	}
+	if interaction.Feedback != nil {
+		if interaction.Feedback.Comment, err = k.OpenString(interaction.Feedback.Comment, fieldAAD(interaction.ID, "feedback_comment")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sealPattern devuelve una copia del patrón con el texto cifrado
+func (k *KeyRing) sealPattern(pattern *Pattern) (*Pattern, error) {
+	if k == nil && !needsEscape(pattern.Pattern, pattern.Response) {
+		return pattern, nil
+	}
+	sealed := *pattern
+	var err error
+	if sealed.Pattern, err = k.SealString(sealed.Pattern, fieldAAD(sealed.Key, "pattern")); err != nil {
+		return nil, err
+	}
+	if sealed.Response, err = k.SealString(sealed.Response, fieldAAD(sealed.Key, "response")); err != nil {
+		return nil, err
+	}
+	return &sealed, nil
+}
+
+// openPattern descifra en su sitio el texto de un patrón
+func (k *KeyRing) openPattern(pattern *Pattern) error {
+	var err error
+	if pattern.Pattern, err = k.OpenString(pattern.Pattern, fieldAAD(pattern.Key, "pattern")); err != nil {
+		return err
+	}
+	pattern.Response, err = k.OpenString(pattern.Response, fieldAAD(pattern.Key, "response"))
+	return err
+}
+
+// sealConversation devuelve una copia de la conversación con resumen y turnos cifrados
+func (k *KeyRing) sealConversation(conversation *Conversation) (*Conversation, error) {
+	sealed := *conversation
+	var err error
+	if sealed.Summary, err = k.SealString(sealed.Summary, fieldAAD(sealed.ID, "summary")); err != nil {
+		return nil, err
+	}
+	if sealed.Turns, err = k.sealTurns(sealed.ID, conversation.Turns); err != nil {
+		return nil, err
+	}
+	return &sealed, nil
+}
+
+// sealTurns devuelve una copia de los turnos de la conversación id con el contenido cifrado
+func (k *KeyRing) sealTurns(id string, turns []Turn) ([]Turn, error) {
+	if k == nil {
+		escape := false
+		for _, turn := range turns {
+			escape = escape || needsEscape(turn.Content)
+		}
+		if !escape {
+			return turns, nil
+		}
+	}
+	sealed := make([]Turn, len(turns))
+	for i, turn := range turns {
+		var err error
+		if turn.Content, err = k.SealString(turn.Content, fieldAAD(id, "turn")); err != nil {
+			return nil, err
+		}
+		sealed[i] = turn
+	}
+	return sealed, nil
+}
+
+// openConversation descifra en su sitio el resumen y los turnos de una conversación
+func (k *KeyRing) openConversation(conversation *Conversation) error {
+	var err error
+	if conversation.Summary, err = k.OpenString(conversation.Summary, fieldAAD(conversation.ID, "summary")); err != nil {
+		return err
+	}
+	for i := range conversation.Turns {
+		if conversation.Turns[i].Content, err = k.OpenString(conversation.Turns[i].Content, fieldAAD(conversation.ID, "turn")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
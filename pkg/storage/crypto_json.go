@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Reencrypt reescribe interacciones, patrones, conversaciones y copias con la clave actual.
+// Los datos en memoria están en claro, así que basta con volver a escribirlos.
+func (s *JSONStore) Reencrypt() (*ReencryptReport, error) {
+	if s.keys == nil {
+		return nil, errEncryptionDisabled
+	}
+
+	report := &ReencryptReport{KeyID: s.keys.KeyID()}
+
+	if err := s.beginWrite(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	report.Interactions = len(s.interactions)
+	report.Patterns = len(s.patterns)
+	s.mu.RUnlock()
+
+	err := s.compactLocked()
+	if err == nil {
+		s.patternsDirty = true
+		err = s.flushLocked()
+	}
+	s.ioMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Conversations, err = s.reencryptConversations(); err != nil {
+		return nil, err
+	}
+
+	backups, err := backupFiles(s.dataDir, ".json", ".json"+encryptedExt)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range backups {
+		if err := s.reencryptBackup(path); err != nil {
+			return nil, fmt.Errorf("error recifrando %s: %w", path, err)
+		}
+		report.Backups++
+	}
+
+	return report, nil
+}
+
+// reencryptConversations lee y vuelve a escribir cada conversación
+func (s *JSONStore) reencryptConversations() (int, error) {
+	s.convMu.Lock()
+	defer s.convMu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.conversationsDir(), "*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, path := range paths {
+		conversation, err := s.readConversation(path)
+		if err != nil {
+			return 0, err
+		}
+		if err := s.writeConversation(conversation); err != nil {
+			return 0, err
+		}
+	}
+	return len(paths), nil
+}
+
+// reencryptBackup cifra la copia completa con la clave actual.
+// Las copias en claro se sustituyen por su versión .enc.
+func (s *JSONStore) reencryptBackup(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error leyendo backup: %w", err)
+	}
+	if data, err = s.keys.OpenFile(data); err != nil {
+		return err
+	}
+	if data, err = s.keys.SealFile(data); err != nil {
+		return err
+	}
+
+	encryptedPath := path
+	if !strings.HasSuffix(path, encryptedExt) {
+		encryptedPath = path + encryptedExt
+	}
+	if err := writeFileAtomic(encryptedPath, data, 0644); err != nil {
+		return err
+	}
+	return replaceBackup(path, encryptedPath)
+}
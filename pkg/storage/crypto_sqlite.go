@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Reencrypt vuelve a cifrar todas las tablas y copias de seguridad con la clave actual
+func (s *SQLiteStore) Reencrypt() (*ReencryptReport, error) {
+	if s.keys == nil {
+		return nil, errEncryptionDisabled
+	}
+
+	report := &ReencryptReport{KeyID: s.keys.KeyID()}
+	if err := s.reencryptTables(report); err != nil {
+		return nil, err
+	}
+
+	backups, err := backupFiles(filepath.Dir(s.config.Path), ".db", ".db"+encryptedExt)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range backups {
+		if err := s.reencryptBackup(path); err != nil {
+			return nil, fmt.Errorf("error recifrando %s: %w", path, err)
+		}
+		report.Backups++
+	}
+
+	return report, nil
+}
+
+// reencryptTables recifra los campos sensibles en una transacción y compacta
+// la base de datos para eliminar las páginas con los valores anteriores
+func (s *SQLiteStore) reencryptTables(report *ReencryptReport) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback()
+
+	steps := []struct {
+		count *int
+		query string
+		fn    func(tx *sql.Tx, query string) (int, error)
+	}{
+		{&report.Interactions, `SELECT id, user_input, response, context, feedback_comment FROM interactions`, s.reencryptInteractions},
+		{&report.Patterns, `SELECT pattern_key, pattern, response FROM patterns`, s.reencryptPatterns},
+		{&report.Conversations, `SELECT id, summary FROM conversations`, s.reencryptConversations},
+	}
+	for _, step := range steps {
+		n, err := step.fn(tx, step.query)
+		if err != nil {
+			return err
+		}
+		*step.count = n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("error compactando base de datos: %w", err)
+	}
+	return nil
+}
+
+// reseal descifra un valor con cualquier clave conocida y lo cifra con la actual
+func (s *SQLiteStore) reseal(value sql.NullString, aad string) (interface{}, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	plain, err := s.keys.OpenString(value.String, aad)
+	if err != nil {
+		return nil, err
+	}
+	return s.keys.SealString(plain, aad)
+}
+
+// queryRows lee todas las filas de una consulta antes de actualizarlas
+func queryRows(tx *sql.Tx, query string, columns int) ([][]sql.NullString, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result [][]sql.NullString
+	for rows.Next() {
+		values := make([]sql.NullString, columns)
+		dest := make([]interface{}, columns)
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		result = append(result, values)
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLiteStore) reencryptInteractions(tx *sql.Tx, query string) (int, error) {
+	rows, err := queryRows(tx, query, 5)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		id := row[0].String
+		sealed := make([]interface{}, 0, 5)
+		for _, column := range []struct {
+			index int
+			field string
+		}{{1, "user_input"}, {2, "response"}, {4, "feedback_comment"}} {
+			v, err := s.reseal(row[column.index], fieldAAD(id, column.field))
+			if err != nil {
+				return 0, fmt.Errorf("interacción %s: %w", id, err)
+			}
+			sealed = append(sealed, v)
+		}
+
+		context, err := s.resealContext(id, row[3])
+		if err != nil {
+			return 0, fmt.Errorf("interacción %s: %w", id, err)
+		}
+
+		if _, err := tx.Exec(`UPDATE interactions SET user_input = ?, response = ?, feedback_comment = ?, context = ? WHERE id = ?`,
+			append(sealed, context, id)...); err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), nil
+}
+
+// resealContext recifra el contexto guardado como JSON
+func (s *SQLiteStore) resealContext(id string, value sql.NullString) (interface{}, error) {
+	if !value.Valid || value.String == "" || value.String == "null" {
+		return value.String, nil
+	}
+
+	var context map[string]interface{}
+	if err := json.Unmarshal([]byte(value.String), &context); err != nil {
+		return nil, fmt.Errorf("error decodificando contexto: %w", err)
+	}
+	context, err := s.keys.openContext(id, context)
+	if err != nil {
+		return nil, err
+	}
+	if context, err = s.keys.sealContext(id, context); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(context)
+	if err != nil {
+		return nil, fmt.Errorf("error codificando contexto: %w", err)
+	}
+	return string(data), nil
+}
+
+func (s *SQLiteStore) reencryptPatterns(tx *sql.Tx, query string) (int, error) {
+	rows, err := queryRows(tx, query, 3)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range rows {
+		pattern, err := s.reseal(row[1], fieldAAD(row[0].String, "pattern"))
+		if err != nil {
+			return 0, fmt.Errorf("patrón %s: %w", row[0].String, err)
+		}
+		response, err := s.reseal(row[2], fieldAAD(row[0].String, "response"))
+		if err != nil {
+			return 0, fmt.Errorf("patrón %s: %w", row[0].String, err)
+		}
+		if _, err := tx.Exec(`UPDATE patterns SET pattern = ?, response = ? WHERE pattern_key = ?`,
+			pattern, response, row[0].String); err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), nil
+}
+
+func (s *SQLiteStore) reencryptConversations(tx *sql.Tx, query string) (int, error) {
+	rows, err := queryRows(tx, query, 2)
+	if err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
+		summary, err := s.reseal(row[1], fieldAAD(row[0].String, "summary"))
+		if err != nil {
+			return 0, fmt.Errorf("conversación %s: %w", row[0].String, err)
+		}
+		if _, err := tx.Exec(`UPDATE conversations SET summary = ? WHERE id = ?`, summary, row[0].String); err != nil {
+			return 0, err
+		}
+	}
+
+	turns, err := queryRows(tx, `SELECT conversation_id, seq, content FROM conversation_turns`, 3)
+	if err != nil {
+		return 0, err
+	}
+	for _, turn := range turns {
+		content, err := s.reseal(turn[2], fieldAAD(turn[0].String, "turn"))
+		if err != nil {
+			return 0, fmt.Errorf("conversación %s: %w", turn[0].String, err)
+		}
+		if _, err := tx.Exec(`UPDATE conversation_turns SET content = ? WHERE conversation_id = ? AND seq = ?`,
+			content, turn[0].String, turn[1].String); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(rows), nil
+}
+
+// reencryptBackup recifra el contenido de una copia y la guarda cifrada completa.
+// Las copias en claro se sustituyen por su versión .enc.
+func (s *SQLiteStore) reencryptBackup(path string) error {
+	tmpPath := strings.TrimSuffix(path, encryptedExt) + ".tmp"
+	defer os.Remove(tmpPath)
+
+	if err := openFileTo(s.keys, path, tmpPath); err != nil {
+		return err
+	}
+
+	backup, err := openSQLiteCopy(tmpPath, s.driver, s.keys)
+	if err != nil {
+		return err
+	}
+	var report ReencryptReport
+	err = backup.reencryptTables(&report)
+	if closeErr := backup.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	encryptedPath := path
+	if !strings.HasSuffix(path, encryptedExt) {
+		encryptedPath = path + encryptedExt
+	}
+	if err := sealFileTo(s.keys, tmpPath, encryptedPath); err != nil {
+		return err
+	}
+	return replaceBackup(path, encryptedPath)
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// Un campo cifrado solo se abre en la fila y el campo para los que se cifró
+func TestSealedFieldBoundToRow(t *testing.T) {
+	key, _ := GenerateKey()
+	keys, err := NewKeyRing(key)
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+
+	sealed, err := keys.sealInteraction(&Interaction{ID: "a", UserInput: "secreto", Response: "otro"})
+	if err != nil {
+		t.Fatalf("sealInteraction: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		aad   string
+	}{
+		{"otra fila", sealed.UserInput, fieldAAD("b", "user_input")},
+		{"otro campo", sealed.UserInput, fieldAAD("a", "response")},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := keys.OpenString(tt.value, tt.aad); err == nil || errors.Is(err, ErrEncrypted) {
+				t.Errorf("OpenString = %v, want error de autenticación", err)
+			}
+		})
+	}
+
+	swapped := *sealed
+	swapped.ID = "b"
+	if err := keys.openInteraction(&swapped); err == nil {
+		t.Error("openInteraction abrió campos copiados de otra fila")
+	}
+
+	// Los campos de versiones anteriores, sin datos asociados, se siguen leyendo
+	id, legacy, err := keys.seal([]byte("antiguo"), nil)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	value := fieldPrefixV1 + id + ":" + base64.StdEncoding.EncodeToString(legacy)
+	if got, err := keys.OpenString(value, fieldAAD("a", "user_input")); err != nil || got != "antiguo" {
+		t.Errorf("OpenString(v1) = %q, %v, want antiguo", got, err)
+	}
+}
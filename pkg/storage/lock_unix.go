@@ -0,0 +1,20 @@
+//go:build linux || darwin
+
+package storage
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile toma un bloqueo exclusivo sin esperar; devuelve ErrLocked si lo
+// tiene otro proceso. El sistema lo libera al cerrar el archivo o al morir el
+// proceso, así que no quedan bloqueos huérfanos.
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrLocked
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/akosej/agent/pkg/metrics"
+)
+
+// metricsStore mide la latencia y los errores de cada operación del Store
+type metricsStore struct {
+	Store
+	duration metrics.Histogram
+	errors   metrics.Counter
+}
+
+// WithMetrics envuelve un Store para registrar la duración de cada operación y
+// sus errores por tipo. ErrNotFound no cuenta como error.
+func WithMetrics(store Store, m metrics.Metrics) Store {
+	if m == nil {
+		return store
+	}
+	return &metricsStore{
+		Store: store,
+		duration: m.Histogram("agent_storage_operation_duration_seconds",
+			"Duración de las operaciones del almacenamiento", metrics.DurationBuckets, "operation"),
+		errors: m.Counter("agent_backend_errors_total",
+			"Errores de los servicios externos por tipo", "backend", "type"),
+	}
+}
+
+// observe anota la duración de la operación y su error, si lo hubo
+func (s *metricsStore) observe(operation string, start time.Time, err error) {
+	s.duration.Observe(time.Since(start).Seconds(), operation)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		s.errors.Add(1, "storage", storageErrorType(err))
+	}
+}
+
+// storageErrorType clasifica un error del almacenamiento: busy (base de datos
+// bloqueada), corrupt, no_space, permission u otro tipo de metrics.ErrorType
+func storageErrorType(err error) string {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "database is locked") || strings.Contains(message, "SQLITE_BUSY"):
+		return "busy"
+	case strings.Contains(message, "malformed") || strings.Contains(message, "not a database"):
+		return "corrupt"
+	case errors.Is(err, syscall.ENOSPC):
+		return "no_space"
+	case errors.Is(err, os.ErrPermission):
+		return "permission"
+	default:
+		return metrics.ErrorType(err)
+	}
+}
+
+func (s *metricsStore) SaveInteraction(interaction *Interaction) (err error) {
+	defer func(start time.Time) { s.observe("save_interaction", start, err) }(time.Now())
+	return s.Store.SaveInteraction(interaction)
+}
+
+func (s *metricsStore) GetInteraction(id string) (_ *Interaction, err error) {
+	defer func(start time.Time) { s.observe("get_interaction", start, err) }(time.Now())
+	return s.Store.GetInteraction(id)
+}
+
+func (s *metricsStore) GetRecentInteractions(limit int) (_ []*Interaction, err error) {
+	defer func(start time.Time) { s.observe("get_recent_interactions", start, err) }(time.Now())
+	return s.Store.GetRecentInteractions(limit)
+}
+
+func (s *metricsStore) SaveFeedback(interactionID string, feedback *Feedback) (err error) {
+	defer func(start time.Time) { s.observe("save_feedback", start, err) }(time.Now())
+	return s.Store.SaveFeedback(interactionID, feedback)
+}
+
+func (s *metricsStore) SearchInteractions(query string, filters SearchFilters) (_ []*SearchResult, err error) {
+	defer func(start time.Time) { s.observe("search_interactions", start, err) }(time.Now())
+	return s.Store.SearchInteractions(query, filters)
+}
+
+func (s *metricsStore) DeleteInteraction(id string) (err error) {
+	defer func(start time.Time) { s.observe("delete_interaction", start, err) }(time.Now())
+	return s.Store.DeleteInteraction(id)
+}
+
+func (s *metricsStore) SavePattern(pattern *Pattern) (err error) {
+	defer func(start time.Time) { s.observe("save_pattern", start, err) }(time.Now())
+	return s.Store.SavePattern(pattern)
+}
+
+func (s *metricsStore) GetPattern(key string) (_ *Pattern, err error) {
+	defer func(start time.Time) { s.observe("get_pattern", start, err) }(time.Now())
+	return s.Store.GetPattern(key)
+}
+
+func (s *metricsStore) GetPatterns() (_ []*Pattern, err error) {
+	defer func(start time.Time) { s.observe("get_patterns", start, err) }(time.Now())
+	return s.Store.GetPatterns()
+}
+
+func (s *metricsStore) DeletePattern(key string) (err error) {
+	defer func(start time.Time) { s.observe("delete_pattern", start, err) }(time.Now())
+	return s.Store.DeletePattern(key)
+}
+
+func (s *metricsStore) UpdateStats(stats *Stats) (err error) {
+	defer func(start time.Time) { s.observe("update_stats", start, err) }(time.Now())
+	return s.Store.UpdateStats(stats)
+}
+
+func (s *metricsStore) GetStats() (_ *Stats, err error) {
+	defer func(start time.Time) { s.observe("get_stats", start, err) }(time.Now())
+	return s.Store.GetStats()
+}
+
+func (s *metricsStore) SaveConversation(conversation *Conversation) (err error) {
+	defer func(start time.Time) { s.observe("save_conversation", start, err) }(time.Now())
+	return s.Store.SaveConversation(conversation)
+}
+
+func (s *metricsStore) AppendTurns(conversationID string, turns ...Turn) (err error) {
+	defer func(start time.Time) { s.observe("append_turns", start, err) }(time.Now())
+	return s.Store.AppendTurns(conversationID, turns...)
+}
+
+func (s *metricsStore) GetConversation(id string) (_ *Conversation, err error) {
+	defer func(start time.Time) { s.observe("get_conversation", start, err) }(time.Now())
+	return s.Store.GetConversation(id)
+}
+
+func (s *metricsStore) ListConversations(filter ConversationFilter) (_ []*Conversation, err error) {
+	defer func(start time.Time) { s.observe("list_conversations", start, err) }(time.Now())
+	return s.Store.ListConversations(filter)
+}
+
+func (s *metricsStore) DeleteConversation(id string) (err error) {
+	defer func(start time.Time) { s.observe("delete_conversation", start, err) }(time.Now())
+	return s.Store.DeleteConversation(id)
+}
+
+func (s *metricsStore) ApplyRetention(policy RetentionPolicy) (_ *RetentionReport, err error) {
+	defer func(start time.Time) { s.observe("apply_retention", start, err) }(time.Now())
+	return s.Store.ApplyRetention(policy)
+}
+
+func (s *metricsStore) DeleteUserData(userID string) (_ *DeletionReport, err error) {
+	defer func(start time.Time) { s.observe("delete_user_data", start, err) }(time.Now())
+	return s.Store.DeleteUserData(userID)
+}
+
+func (s *metricsStore) Reencrypt() (_ *ReencryptReport, err error) {
+	defer func(start time.Time) { s.observe("reencrypt", start, err) }(time.Now())
+	return s.Store.Reencrypt()
+}
+
+func (s *metricsStore) Backup() (_ string, err error) {
+	defer func(start time.Time) { s.observe("backup", start, err) }(time.Now())
+	return s.Store.Backup()
+}
+
+func (s *metricsStore) Restore(path string) (err error) {
+	defer func(start time.Time) { s.observe("restore", start, err) }(time.Now())
+	return s.Store.Restore(path)
+}
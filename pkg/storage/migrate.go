@@ -0,0 +1,483 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrSchemaTooNew indica que la base de datos fue migrada por un binario más reciente
+var ErrSchemaTooNew = errors.New("la base de datos tiene un esquema más nuevo que este binario")
+
+// Migration es una migración versionada del esquema SQLite.
+// Las migraciones se aplican en orden, cada una dentro de su propia transacción.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sql.Tx) error
+}
+
+// MigrationStatus describe el estado de una migración en una base de datos
+type MigrationStatus struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+}
+
+// execSQL crea una migración que ejecuta sentencias SQL
+func execSQL(statements string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(statements)
+		return err
+	}
+}
+
+// migrations es la lista ordenada de migraciones del esquema.
+// Nunca se modifica una migración publicada: los cambios van en una nueva.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "esquema inicial",
+		Up: execSQL(`
+		CREATE TABLE IF NOT EXISTS interactions (
+			id TEXT PRIMARY KEY,
+			timestamp DATETIME,
+			user_input TEXT,
+			response TEXT,
+			intent TEXT,
+			context TEXT,
+			feedback_rating INTEGER,
+			feedback_comment TEXT,
+			feedback_timestamp DATETIME
+		);
+
+		CREATE TABLE IF NOT EXISTS patterns (
+			pattern_key TEXT PRIMARY KEY,
+			pattern TEXT,
+			response TEXT,
+			frequency INTEGER,
+			confidence REAL,
+			last_used DATETIME
+		);
+
+		CREATE TABLE IF NOT EXISTS stats (
+			id INTEGER PRIMARY KEY,
+			total_interactions INTEGER,
+			positive_feedback INTEGER,
+			negative_feedback INTEGER,
+			average_rating REAL,
+			last_updated DATETIME
+		);
+
+		CREATE TABLE IF NOT EXISTS conversations (
+			id TEXT PRIMARY KEY,
+			started_at DATETIME,
+			summary TEXT
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_interactions_timestamp ON interactions(timestamp);
+		CREATE INDEX IF NOT EXISTS idx_interactions_intent ON interactions(intent);
+		`),
+	},
+	{
+		Version:     2,
+		Description: "usuario de cada interacción",
+		Up: execSQL(`
+		ALTER TABLE interactions ADD COLUMN user_id TEXT;
+		CREATE INDEX IF NOT EXISTS idx_interactions_user ON interactions(user_id);
+		`),
+	},
+	{
+		Version:     3,
+		Description: "conversaciones con turnos enlazados a interacciones",
+		Up:          migrateConversationTurns,
+	},
+	{
+		Version:     4,
+		Description: "usuario de los patrones aprendidos",
+		Up: execSQL(`
+		ALTER TABLE patterns ADD COLUMN user_id TEXT;
+		CREATE INDEX IF NOT EXISTS idx_patterns_user ON patterns(user_id);
+		`),
+	},
+	{
+		Version:     5,
+		Description: "latencia de cada interacción",
+		Up:          execSQL(`ALTER TABLE interactions ADD COLUMN latency_ms INTEGER`),
+	},
+	{
+		Version:     6,
+		Description: "fechas en UTC con formato fijo",
+		Up:          normalizeTimestamps,
+	},
+}
+
+// timestampColumns son las columnas de fecha de las tablas con datos
+var timestampColumns = []struct{ table, column string }{
+	{"interactions", "timestamp"},
+	{"interactions", "feedback_timestamp"},
+	{"patterns", "last_used"},
+	{"stats", "last_updated"},
+	{"conversations", "started_at"},
+	{"conversations", "ended_at"},
+	{"conversation_turns", "timestamp"},
+}
+
+// normalizeTimestamps reescribe en sqlTimeFormat las fechas guardadas con otro
+// formato, como la hora local con desplazamiento que escribía el driver CGO.
+// La retención y los filtros de búsqueda comparan las fechas como texto, así
+// que solo funcionan si todas siguen el mismo formato. Los valores que no se
+// reconocen se dejan como están.
+func normalizeTimestamps(tx *sql.Tx) error {
+	type update struct {
+		rowid int64
+		value interface{}
+	}
+	for _, c := range timestampColumns {
+		rows, err := tx.Query(`SELECT rowid, CAST(` + c.column + ` AS TEXT) FROM ` + c.table + ` WHERE ` + c.column + ` IS NOT NULL`)
+		if err != nil {
+			return fmt.Errorf("error leyendo %s.%s: %w", c.table, c.column, err)
+		}
+		var updates []update
+		for rows.Next() {
+			var rowid int64
+			var value string
+			if err := rows.Scan(&rowid, &value); err != nil {
+				rows.Close()
+				return fmt.Errorf("error leyendo %s.%s: %w", c.table, c.column, err)
+			}
+			var parsed sqlTime
+			if parsed.parse(value) != nil {
+				continue
+			}
+			if canonical := formatTime(parsed.Time); canonical != value {
+				updates = append(updates, update{rowid, canonical})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error leyendo %s.%s: %w", c.table, c.column, err)
+		}
+
+		for _, u := range updates {
+			if _, err := tx.Exec(`UPDATE `+c.table+` SET `+c.column+` = ? WHERE rowid = ?`, u.value, u.rowid); err != nil {
+				return fmt.Errorf("error actualizando %s.%s: %w", c.table, c.column, err)
+			}
+		}
+	}
+	return nil
+}
+
+// hasColumn indica si la tabla tiene la columna
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	return count > 0, err
+}
+
+// migrateConversationTurns crea la tabla conversation_turns y pasa a ella los
+// mensajes que las bases de datos anteriores a las migraciones guardaban como
+// JSON en conversations.messages
+func migrateConversationTurns(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE conversations ADD COLUMN user_id TEXT;
+	ALTER TABLE conversations ADD COLUMN ended_at DATETIME;
+
+	CREATE TABLE conversation_turns (
+		conversation_id TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		interaction_id TEXT,
+		role TEXT,
+		content TEXT,
+		timestamp DATETIME,
+		PRIMARY KEY (conversation_id, seq)
+	);
+
+	CREATE INDEX idx_conversations_user ON conversations(user_id, started_at);
+	CREATE INDEX idx_conversation_turns_interaction ON conversation_turns(interaction_id);
+	`)
+	if err != nil {
+		return err
+	}
+
+	legacyMessages, err := hasColumn(tx, "conversations", "messages")
+	if err != nil || !legacyMessages {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT id, started_at, messages FROM conversations`)
+	if err != nil {
+		return err
+	}
+
+	type legacy struct {
+		id        string
+		startedAt interface{}
+		messages  []Turn
+	}
+	var pending []legacy
+	for rows.Next() {
+		var (
+			item     legacy
+			started  sqlTime
+			messages sql.NullString
+		)
+		if err := rows.Scan(&item.id, &started, &messages); err != nil {
+			rows.Close()
+			return err
+		}
+		if messages.Valid && messages.String != "" {
+			if err := json.Unmarshal([]byte(messages.String), &item.messages); err != nil {
+				rows.Close()
+				return fmt.Errorf("error decodificando mensajes de %s: %w", item.id, err)
+			}
+		}
+		item.startedAt = formatTime(started.Time)
+		pending = append(pending, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range pending {
+		for seq, turn := range item.messages {
+			if _, err := tx.Exec(`INSERT INTO conversation_turns (conversation_id, seq, role, content, timestamp) VALUES (?, ?, ?, ?, ?)`,
+				item.id, seq, turn.Role, turn.Content, item.startedAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec(`ALTER TABLE conversations DROP COLUMN messages`)
+	return err
+}
+
+// LatestSchemaVersion devuelve la versión de esquema que conoce este binario
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// Migrator aplica y consulta las migraciones de una base de datos SQLite
+type Migrator struct {
+	db *sql.DB
+}
+
+// NewMigrator crea un migrador sobre una conexión abierta
+func NewMigrator(db *sql.DB) *Migrator {
+	return &Migrator{db: db}
+}
+
+// migratorDriver devuelve el driver de SQLite del tipo de almacenamiento configurado
+func migratorDriver(config Config) (string, error) {
+	switch config.Type {
+	case "", "sqlite":
+		return defaultSQLiteDriver, nil
+	case "sqlite-purego":
+		return driverPureGo, nil
+	default:
+		return "", fmt.Errorf("las migraciones solo aplican a SQLite (tipo configurado: %s)", config.Type)
+	}
+}
+
+// OpenMigrator abre la base de datos configurada sin aplicar migraciones,
+// para poder consultar su estado antes de actualizarla
+func OpenMigrator(config Config) (*Migrator, error) {
+	driver, err := migratorDriver(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir := filepath.Dir(config.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("error creando directorio de datos: %w", err)
+		}
+	}
+
+	db, err := sql.Open(driver, sqliteDSN(driver, config.Path, true))
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo base de datos: %w", err)
+	}
+
+	return NewMigrator(db), nil
+}
+
+// OpenMigratorReadOnly abre la base de datos configurada en solo lectura para
+// consultar su estado sin crearla ni modificarla. Si no existe devuelve un
+// error que cumple errors.Is(err, os.ErrNotExist).
+func OpenMigratorReadOnly(config Config) (*Migrator, error) {
+	driver, err := migratorDriver(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(config.Path); err != nil {
+		return nil, fmt.Errorf("error abriendo base de datos: %w", err)
+	}
+
+	db, err := sql.Open(driver, sqliteReadOnlyDSN(driver, config.Path))
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo base de datos: %w", err)
+	}
+
+	return NewMigrator(db), nil
+}
+
+// Close cierra la conexión del migrador
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// ensureTable crea la tabla schema_migrations si no existe
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT,
+		applied_at DATETIME
+	)`)
+	if err != nil {
+		return fmt.Errorf("error creando schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// applied devuelve las versiones aplicadas con su fecha. Sin la tabla
+// schema_migrations no hay ninguna aplicada; no se crea aquí para que consultar
+// el estado no escriba en la base de datos.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	var tables int
+	if err := m.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&tables); err != nil {
+		return nil, fmt.Errorf("error leyendo schema_migrations: %w", err)
+	}
+	versions := make(map[int]time.Time)
+	if tables == 0 {
+		return versions, nil
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt sqlTime
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt.Time
+	}
+
+	return versions, rows.Err()
+}
+
+// Version devuelve la versión de esquema más alta aplicada (0 si ninguna)
+func (m *Migrator) Version() (int, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	current := 0
+	for version := range versions {
+		if version > current {
+			current = version
+		}
+	}
+	return current, nil
+}
+
+// Status devuelve el estado de todas las migraciones conocidas
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	versions, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		appliedAt, ok := versions[migration.Version]
+		status = append(status, MigrationStatus{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Applied:     ok,
+			AppliedAt:   appliedAt,
+		})
+	}
+
+	return status, nil
+}
+
+// checkCompatible falla si la base de datos es más nueva que el binario
+func (m *Migrator) checkCompatible() error {
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if current > LatestSchemaVersion() {
+		return fmt.Errorf("%w: versión %d, soportada hasta %d", ErrSchemaTooNew, current, LatestSchemaVersion())
+	}
+	return nil
+}
+
+// Up aplica las migraciones pendientes en orden y devuelve las aplicadas
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	if err := m.checkCompatible(); err != nil {
+		return nil, err
+	}
+
+	versions, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range migrations {
+		if _, ok := versions[migration.Version]; ok {
+			continue
+		}
+
+		if err := m.apply(migration); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// apply ejecuta una migración y la registra en la misma transacción
+func (m *Migrator) apply(migration Migration) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error iniciando migración %d: %w", migration.Version, err)
+	}
+
+	if err := migration.Up(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error aplicando migración %d (%s): %w", migration.Version, migration.Description, err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`,
+		migration.Version, migration.Description, formatTime(time.Now())); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error registrando migración %d: %w", migration.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error confirmando migración %d: %w", migration.Version, err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTimestamps(t *testing.T) {
+	for _, driver := range sqliteDrivers() {
+		driver := driver
+		t.Run(driver, func(t *testing.T) {
+			db, err := sql.Open(driver, sqliteDSN(driver, filepath.Join(t.TempDir(), "agent.db"), true))
+			if err != nil {
+				t.Fatalf("sql.Open: %v", err)
+			}
+			defer db.Close()
+
+			// Esquema anterior a la normalización, con fechas como las dejaban
+			// las versiones anteriores
+			m := NewMigrator(db)
+			if err := m.ensureTable(); err != nil {
+				t.Fatalf("ensureTable: %v", err)
+			}
+			for _, migration := range migrations {
+				if migration.Version < 6 {
+					if err := m.apply(migration); err != nil {
+						t.Fatalf("migración %d: %v", migration.Version, err)
+					}
+				}
+			}
+			_, err = db.Exec(`
+			INSERT INTO interactions (id, timestamp, feedback_timestamp) VALUES
+				('local', '2024-01-15 10:30:00+02:00', '2024-01-15T11:00:00+02:00'),
+				('utc', '2024-01-15 09:00:00.000000000', NULL),
+				('basura', 'ayer', NULL);
+			INSERT INTO patterns (pattern_key, last_used) VALUES ('saludo', '2024-01-15 10:30:00');
+			INSERT INTO conversations (id, started_at) VALUES ('c1', '2024-01-15 10:30:00.5-05:00');
+			`)
+			if err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+
+			if _, err := m.Up(); err != nil {
+				t.Fatalf("Up: %v", err)
+			}
+
+			tests := []struct {
+				query string
+				want  string
+			}{
+				{`SELECT timestamp FROM interactions WHERE id = 'local'`, "2024-01-15 08:30:00.000000000"},
+				{`SELECT feedback_timestamp FROM interactions WHERE id = 'local'`, "2024-01-15 09:00:00.000000000"},
+				{`SELECT timestamp FROM interactions WHERE id = 'utc'`, "2024-01-15 09:00:00.000000000"},
+				{`SELECT timestamp FROM interactions WHERE id = 'basura'`, "ayer"},
+				{`SELECT last_used FROM patterns`, "2024-01-15 10:30:00.000000000"},
+				{`SELECT started_at FROM conversations`, "2024-01-15 15:30:00.500000000"},
+			}
+			for _, tt := range tests {
+				var got string
+				if err := db.QueryRow(`SELECT CAST((` + tt.query + `) AS TEXT)`).Scan(&got); err != nil {
+					t.Fatalf("%s: %v", tt.query, err)
+				}
+				if got != tt.want {
+					t.Errorf("%s = %q, want %q", tt.query, got, tt.want)
+				}
+			}
+
+			// Las comparaciones como texto ya siguen el orden cronológico
+			var older string
+			cutoff := formatTime(time.Date(2024, 1, 15, 8, 45, 0, 0, time.UTC))
+			if err := db.QueryRow(`SELECT id FROM interactions WHERE timestamp < ?`, cutoff).Scan(&older); err != nil {
+				t.Fatalf("timestamp < ?: %v", err)
+			}
+			if older != "local" {
+				t.Errorf("anterior al corte = %q, want local", older)
+			}
+		})
+	}
+}
+
+// Consultar el estado no crea la base de datos ni la tabla schema_migrations
+func TestMigratorStatusReadOnly(t *testing.T) {
+	for _, driver := range sqliteDrivers() {
+		driver := driver
+		t.Run(driver, func(t *testing.T) {
+			typ := "sqlite"
+			if driver == driverPureGo {
+				typ = "sqlite-purego"
+			}
+			config := Config{Type: typ, Path: filepath.Join(t.TempDir(), "mis datos", "agent.db")}
+
+			if _, err := OpenMigratorReadOnly(config); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("sin base de datos = %v, want os.ErrNotExist", err)
+			}
+			if _, err := os.Stat(filepath.Dir(config.Path)); !os.IsNotExist(err) {
+				t.Fatalf("se creó el directorio de datos: %v", err)
+			}
+
+			if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(config.Path, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			m, err := OpenMigratorReadOnly(config)
+			if err != nil {
+				t.Fatalf("OpenMigratorReadOnly: %v", err)
+			}
+			status, err := m.Status()
+			if err != nil {
+				t.Fatalf("Status: %v", err)
+			}
+			for _, s := range status {
+				if s.Applied {
+					t.Errorf("migración %d aplicada en una base de datos vacía", s.Version)
+				}
+			}
+			if _, err := m.Up(); err == nil {
+				t.Error("Up escribió con una conexión de solo lectura")
+			}
+			m.Close()
+			if info, _ := os.Stat(config.Path); info.Size() != 0 {
+				t.Errorf("la base de datos ocupa %d bytes tras consultar el estado", info.Size())
+			}
+
+			m, err = OpenMigrator(config)
+			if err != nil {
+				t.Fatalf("OpenMigrator: %v", err)
+			}
+			if _, err := m.Up(); err != nil {
+				t.Fatalf("Up: %v", err)
+			}
+			m.Close()
+			m, err = OpenMigratorReadOnly(config)
+			if err != nil {
+				t.Fatalf("OpenMigratorReadOnly: %v", err)
+			}
+			defer m.Close()
+			if version, err := m.Version(); err != nil || version != LatestSchemaVersion() {
+				t.Errorf("Version = %d, %v, want %d", version, err, LatestSchemaVersion())
+			}
+		})
+	}
+}
+
+// Las conversaciones de las bases de datos anteriores a las migraciones
+// guardaban los mensajes como JSON; las nuevas nunca tienen esa columna
+func TestMigrateLegacyConversations(t *testing.T) {
+	for _, driver := range sqliteDrivers() {
+		driver := driver
+		t.Run(driver, func(t *testing.T) {
+			db, err := sql.Open(driver, sqliteDSN(driver, filepath.Join(t.TempDir(), "agent.db"), true))
+			if err != nil {
+				t.Fatalf("sql.Open: %v", err)
+			}
+			defer db.Close()
+
+			hasMessages := func() bool {
+				t.Helper()
+				var count int
+				if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('conversations') WHERE name = 'messages'`).Scan(&count); err != nil {
+					t.Fatalf("pragma_table_info: %v", err)
+				}
+				return count > 0
+			}
+
+			if _, err := NewMigrator(db).Up(); err != nil {
+				t.Fatalf("Up: %v", err)
+			}
+			if hasMessages() {
+				t.Error("una base de datos nueva tiene conversations.messages")
+			}
+
+			legacy, err := sql.Open(driver, sqliteDSN(driver, filepath.Join(t.TempDir(), "legacy.db"), true))
+			if err != nil {
+				t.Fatalf("sql.Open: %v", err)
+			}
+			defer legacy.Close()
+			_, err = legacy.Exec(`
+			CREATE TABLE conversations (id TEXT PRIMARY KEY, started_at DATETIME, summary TEXT, messages TEXT);
+			INSERT INTO conversations VALUES
+				('c1', '2024-01-15 10:30:00', '', '[{"role":"user","content":"hola"},{"role":"assistant","content":"buenas"}]'),
+				('c2', '2024-01-15 11:00:00', '', NULL);
+			`)
+			if err != nil {
+				t.Fatalf("esquema anterior: %v", err)
+			}
+			db = legacy
+			if _, err := NewMigrator(legacy).Up(); err != nil {
+				t.Fatalf("Up: %v", err)
+			}
+			if hasMessages() {
+				t.Error("conversations.messages sigue tras migrar")
+			}
+			var turns int
+			var content string
+			if err := legacy.QueryRow(`SELECT COUNT(*), MAX(CASE WHEN seq = 1 THEN content END) FROM conversation_turns WHERE conversation_id = 'c1'`).Scan(&turns, &content); err != nil {
+				t.Fatalf("conversation_turns: %v", err)
+			}
+			if turns != 2 || content != "buenas" {
+				t.Errorf("turnos de c1 = %d, segundo = %q, want 2 y buenas", turns, content)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Redactor oculta datos personales en un texto de un usuario; lo implementa redact.Redactor
+type Redactor interface {
+	RedactUser(userID, text string) string
+}
+
+// redactingStore aplica un Redactor a los textos libres antes de guardarlos
+type redactingStore struct {
+	Store
+	redactor Redactor
+
+	owners sync.Map // ID de conversación -> usuario, para redactar AppendTurns
+}
+
+// WithRedaction envuelve un Store para que entradas, respuestas, comentarios,
+// patrones y conversaciones se guarden ya redactados. Las lecturas no cambian.
+func WithRedaction(store Store, redactor Redactor) Store {
+	if redactor == nil {
+		return store
+	}
+	return &redactingStore{Store: store, redactor: redactor}
+}
+
+func (s *redactingStore) SaveInteraction(interaction *Interaction) error {
+	redacted := *interaction
+	redacted.UserInput = s.redactor.RedactUser(interaction.UserID, interaction.UserInput)
+	redacted.Response = s.redactor.RedactUser(interaction.UserID, interaction.Response)
+	redacted.Context = s.redactContext(interaction.UserID, interaction.Context)
+	if interaction.Feedback != nil {
+		feedback := *interaction.Feedback
+		feedback.Comment = s.redactor.RedactUser(interaction.UserID, feedback.Comment)
+		redacted.Feedback = &feedback
+	}
+	return s.Store.SaveInteraction(&redacted)
+}
+
+// redactContext redacta los valores de texto del contexto
+func (s *redactingStore) redactContext(userID string, context map[string]interface{}) map[string]interface{} {
+	if context == nil {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(context))
+	for key, value := range context {
+		if text, ok := value.(string); ok {
+			value = s.redactor.RedactUser(userID, text)
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+func (s *redactingStore) SaveFeedback(interactionID string, feedback *Feedback) error {
+	// Sin la interacción SaveFeedback devuelve ErrNotFound sin guardar nada
+	var userID string
+	if interaction, err := s.Store.GetInteraction(interactionID); err == nil {
+		userID = interaction.UserID
+	}
+	redacted := *feedback
+	redacted.Comment = s.redactor.RedactUser(userID, feedback.Comment)
+	return s.Store.SaveFeedback(interactionID, &redacted)
+}
+
+func (s *redactingStore) SavePattern(pattern *Pattern) error {
+	redacted := *pattern
+	redacted.Pattern = s.redactor.RedactUser(pattern.UserID, pattern.Pattern)
+	redacted.Response = s.redactor.RedactUser(pattern.UserID, pattern.Response)
+	return s.Store.SavePattern(&redacted)
+}
+
+func (s *redactingStore) SaveConversation(conversation *Conversation) error {
+	redacted := *conversation
+	redacted.Summary = s.redactor.RedactUser(conversation.UserID, conversation.Summary)
+	redacted.Turns = s.redactTurns(conversation.UserID, conversation.Turns)
+	if err := s.Store.SaveConversation(&redacted); err != nil {
+		return err
+	}
+	s.owners.Store(redacted.ID, redacted.UserID)
+	// SaveConversation asigna ID, fecha de inicio y número de turnos
+	conversation.ID = redacted.ID
+	conversation.StartedAt = redacted.StartedAt
+	conversation.TurnCount = redacted.TurnCount
+	return nil
+}
+
+func (s *redactingStore) AppendTurns(conversationID string, turns ...Turn) error {
+	userID, ok := s.owners.Load(conversationID)
+	if !ok {
+		conversation, err := s.Store.GetConversation(conversationID)
+		if err != nil {
+			return err
+		}
+		userID = conversation.UserID
+		s.owners.Store(conversationID, userID)
+	}
+	return s.Store.AppendTurns(conversationID, s.redactTurns(userID.(string), turns)...)
+}
+
+func (s *redactingStore) redactTurns(userID string, turns []Turn) []Turn {
+	if turns == nil {
+		return nil
+	}
+	redacted := make([]Turn, len(turns))
+	for i, turn := range turns {
+		turn.Content = s.redactor.RedactUser(userID, turn.Content)
+		redacted[i] = turn
+	}
+	return redacted
+}
+
+// Vault guarda los valores originales de los datos tokenizados por la
+// redacción; lo implementa redact.Redactor
+type Vault interface {
+	PurgeUser(userID string) (int, error)
+	PurgeBefore(cutoff time.Time) (int, error)
+}
+
+// vaultStore elimina del vault lo que ya no está en el almacenamiento
+type vaultStore struct {
+	Store
+	vault Vault
+}
+
+// WithVault envuelve un Store para que DeleteUserData elimine también del
+// vault los valores del usuario y ApplyRetention los que ya no se usan
+func WithVault(store Store, vault Vault) Store {
+	if vault == nil {
+		return store
+	}
+	return &vaultStore{Store: store, vault: vault}
+}
+
+func (s *vaultStore) DeleteUserData(userID string) (*DeletionReport, error) {
+	report, err := s.Store.DeleteUserData(userID)
+	if err != nil {
+		return nil, err
+	}
+	if report.VaultEntries, err = s.vault.PurgeUser(userID); err != nil {
+		return nil, fmt.Errorf("error depurando vault de redacción: %w", err)
+	}
+	return report, nil
+}
+
+func (s *vaultStore) ApplyRetention(policy RetentionPolicy) (*RetentionReport, error) {
+	report, err := s.Store.ApplyRetention(policy)
+	if err != nil {
+		return nil, err
+	}
+	if age := policy.longestAge(); age > 0 {
+		if report.VaultEntries, err = s.vault.PurgeBefore(report.AppliedAt.Add(-age)); err != nil {
+			return nil, fmt.Errorf("error depurando vault de redacción: %w", err)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,95 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// fakeVault anota por usuario los textos redactados y lo que se purga
+type fakeVault struct {
+	texts  map[string][]string
+	purged []string
+	cutoff time.Time
+}
+
+func (v *fakeVault) RedactUser(userID, text string) string {
+	if text != "" {
+		v.texts[userID] = append(v.texts[userID], text)
+	}
+	return text
+}
+
+func (v *fakeVault) PurgeUser(userID string) (int, error) {
+	v.purged = append(v.purged, userID)
+	return len(v.texts[userID]), nil
+}
+
+func (v *fakeVault) PurgeBefore(cutoff time.Time) (int, error) {
+	v.cutoff = cutoff
+	return 1, nil
+}
+
+func TestRedactionByUser(t *testing.T) {
+	raw, err := storage.NewJSONStore(storage.Config{Path: filepath.Join(t.TempDir(), "data.json")})
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	defer raw.Close()
+	vault := &fakeVault{texts: make(map[string][]string)}
+	store := storage.WithRedaction(storage.WithVault(raw, vault), vault)
+
+	if err := store.SaveInteraction(&storage.Interaction{ID: "i1", UserID: "ana", UserInput: "entrada", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+	if err := store.SaveFeedback("i1", &storage.Feedback{Rating: 5, Comment: "comentario"}); err != nil {
+		t.Fatalf("SaveFeedback: %v", err)
+	}
+	conversation := &storage.Conversation{UserID: "ana", Summary: "resumen"}
+	if err := store.SaveConversation(conversation); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+	if err := store.AppendTurns(conversation.ID, storage.Turn{Role: "user", Content: "turno"}); err != nil {
+		t.Fatalf("AppendTurns: %v", err)
+	}
+	if err := store.SavePattern(&storage.Pattern{Key: "p", UserID: "luis", Pattern: "patrón"}); err != nil {
+		t.Fatalf("SavePattern: %v", err)
+	}
+	if got := len(vault.texts["ana"]); got != 4 || len(vault.texts["luis"]) != 1 || len(vault.texts[""]) != 0 {
+		t.Errorf("textos por usuario = %v, want 4 de ana y 1 de luis", vault.texts)
+	}
+
+	report, err := store.DeleteUserData("ana")
+	if err != nil {
+		t.Fatalf("DeleteUserData: %v", err)
+	}
+	if report.VaultEntries != 4 || len(vault.purged) != 1 || vault.purged[0] != "ana" {
+		t.Errorf("DeleteUserData = %+v, purgados %v", report, vault.purged)
+	}
+
+	tests := []struct {
+		name   string
+		policy storage.RetentionPolicy
+		want   time.Duration // 0: no se purga el vault
+	}{
+		{"max age", storage.RetentionPolicy{MaxAge: time.Hour}, time.Hour},
+		{"longest intent", storage.RetentionPolicy{MaxAge: time.Hour, IntentMaxAge: map[string]time.Duration{"queja": 3 * time.Hour}}, 3 * time.Hour},
+		{"intent kept forever", storage.RetentionPolicy{MaxAge: time.Hour, IntentMaxAge: map[string]time.Duration{"queja": 0}}, 0},
+		{"count only", storage.RetentionPolicy{MaxInteractions: 10}, 0},
+	}
+	for _, tt := range tests {
+		vault.cutoff = time.Time{}
+		report, err := store.ApplyRetention(tt.policy)
+		if err != nil {
+			t.Fatalf("%s: ApplyRetention: %v", tt.name, err)
+		}
+		switch {
+		case tt.want == 0 && (!vault.cutoff.IsZero() || report.VaultEntries != 0):
+			t.Errorf("%s: purgó el vault antes de %v", tt.name, vault.cutoff)
+		case tt.want != 0 && (!vault.cutoff.Equal(report.AppliedAt.Add(-tt.want)) || report.VaultEntries != 1):
+			t.Errorf("%s: corte = %v, want %v", tt.name, vault.cutoff, report.AppliedAt.Add(-tt.want))
+		}
+	}
+}
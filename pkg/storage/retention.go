@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// defaultRetentionInterval es la frecuencia por defecto del trabajo de retención
+const defaultRetentionInterval = time.Hour
+
+// RetentionPolicy define cuánto tiempo se conservan interacciones y conversaciones.
+// Los campos en cero no limitan.
+type RetentionPolicy struct {
+	MaxAge          time.Duration            // Antigüedad máxima de interacciones y conversaciones
+	MaxInteractions int                      // Número máximo de interacciones (se conservan las más recientes)
+	IntentMaxAge    map[string]time.Duration // Antigüedad máxima por intención; 0 las conserva siempre
+	Interval        time.Duration            // Frecuencia del trabajo en segundo plano (por defecto 1h)
+}
+
+// Enabled indica si la política elimina algo
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxInteractions > 0 || len(p.IntentMaxAge) > 0
+}
+
+// maxAge devuelve la antigüedad máxima aplicable a una intención
+func (p RetentionPolicy) maxAge(intent string) time.Duration {
+	if age, ok := p.IntentMaxAge[intent]; ok {
+		return age
+	}
+	return p.MaxAge
+}
+
+// longestAge devuelve la mayor antigüedad que conserva la política, o 0 si
+// algo se conserva sin límite de antigüedad
+func (p RetentionPolicy) longestAge() time.Duration {
+	longest := p.MaxAge
+	if longest <= 0 {
+		return 0
+	}
+	for _, age := range p.IntentMaxAge {
+		if age <= 0 {
+			return 0
+		}
+		if age > longest {
+			longest = age
+		}
+	}
+	return longest
+}
+
+// expired indica si una interacción supera su antigüedad máxima
+func (p RetentionPolicy) expired(interaction *Interaction, now time.Time) bool {
+	age := p.maxAge(interaction.Intent)
+	return age > 0 && interaction.Timestamp.Before(now.Add(-age))
+}
+
+// RetentionReport resume una ejecución de la política de retención
+type RetentionReport struct {
+	Interactions  int       `json:"interactions"`
+	Conversations int       `json:"conversations"`
+	VaultEntries  int       `json:"vault_entries"` // Valores tokenizados sin usar desde la mayor antigüedad conservada
+	AppliedAt     time.Time `json:"applied_at"`
+}
+
+// DeletionReport detalla los datos eliminados por DeleteUserData
+type DeletionReport struct {
+	UserID        string        `json:"user_id"`
+	Interactions  int           `json:"interactions"`
+	Feedback      int           `json:"feedback"`
+	Conversations int           `json:"conversations"`
+	Turns         int           `json:"turns"`
+	Patterns      int           `json:"patterns"`
+	VaultEntries  int           `json:"vault_entries"` // Valores originales de los datos tokenizados
+	Backups       []BackupPurge `json:"backups,omitempty"`
+	Warnings      []string      `json:"warnings,omitempty"`
+	CompletedAt   time.Time     `json:"completed_at"`
+}
+
+// BackupPurge detalla lo eliminado de una copia de seguridad
+type BackupPurge struct {
+	Path          string `json:"path"`
+	Interactions  int    `json:"interactions"`
+	Conversations int    `json:"conversations"`
+	Patterns      int    `json:"patterns"`
+}
+
+// lastActivity devuelve el último momento en que la conversación tuvo actividad
+func (c *Conversation) lastActivity() time.Time {
+	last := c.StartedAt
+	if c.EndedAt.After(last) {
+		last = c.EndedAt
+	}
+	for _, turn := range c.Turns {
+		if turn.Timestamp.After(last) {
+			last = turn.Timestamp
+		}
+	}
+	return last
+}
+
+// RunRetention aplica la política periódicamente hasta que se cancele ctx.
+// onRun, si no es nil, recibe el resultado de cada ejecución.
+func RunRetention(ctx context.Context, store Store, policy RetentionPolicy, onRun func(*RetentionReport, error)) {
+	if !policy.Enabled() {
+		return
+	}
+
+	interval := policy.Interval
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := store.ApplyRetention(policy)
+		if onRun != nil {
+			onRun(report, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backupFiles devuelve las copias de seguridad con alguna de las extensiones dadas, ordenadas por nombre
+func backupFiles(dataDir string, exts ...string) ([]string, error) {
+	var paths []string
+	for _, ext := range exts {
+		matches, err := filepath.Glob(filepath.Join(dataDir, "backups", "backup_*"+ext))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// jsonBackup es el contenido de una copia de seguridad del almacenamiento JSON
+type jsonBackup struct {
+	Interactions  []*Interaction  `json:"interactions"`
+	Patterns      []*Pattern      `json:"patterns"`
+	Stats         *Stats          `json:"stats"`
+	Conversations []*Conversation `json:"conversations,omitempty"`
+	Timestamp     time.Time       `json:"timestamp"`
+}
+
+// purgeJSONBackup elimina los datos de un usuario de una copia JSON (cifrada o no) y la reescribe
+func purgeJSONBackup(keys *KeyRing, path, userID string) (BackupPurge, error) {
+	purge := BackupPurge{Path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return purge, fmt.Errorf("error leyendo backup: %w", err)
+	}
+	encrypted := IsEncryptedFile(data)
+	if data, err = keys.OpenFile(data); err != nil {
+		return purge, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	var backup jsonBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return purge, fmt.Errorf("error decodificando %s: %w", filepath.Base(path), err)
+	}
+
+	interactions := backup.Interactions[:0]
+	for _, interaction := range backup.Interactions {
+		if interaction.UserID == userID {
+			purge.Interactions++
+			continue
+		}
+		interactions = append(interactions, interaction)
+	}
+	backup.Interactions = interactions
+
+	patterns := backup.Patterns[:0]
+	for _, pattern := range backup.Patterns {
+		if pattern.UserID == userID {
+			purge.Patterns++
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	backup.Patterns = patterns
+
+	conversations := backup.Conversations[:0]
+	for _, conversation := range backup.Conversations {
+		if conversation.UserID == userID {
+			purge.Conversations++
+			continue
+		}
+		conversations = append(conversations, conversation)
+	}
+	backup.Conversations = conversations
+
+	if purge.Interactions == 0 && purge.Patterns == 0 && purge.Conversations == 0 {
+		return purge, nil
+	}
+
+	data, err = json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return purge, err
+	}
+	if encrypted {
+		if data, err = keys.SealFile(data); err != nil {
+			return purge, err
+		}
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return purge, err
+	}
+	return purge, writeChecksum(path)
+}
+
+// errEmptyUserID evita que DeleteUserData("") borre todo lo que no tiene usuario
+var errEmptyUserID = errors.New("se requiere un ID de usuario")
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// removeLocked elimina de una vez las interacciones indicadas y reconstruye el índice (requiere mu)
+func (s *JSONStore) removeLocked(remove func(*Interaction) bool) []*Interaction {
+	var removed []*Interaction
+	kept := s.interactions[:0]
+	for _, interaction := range s.interactions {
+		if remove(interaction) {
+			removed = append(removed, interaction)
+			s.search.remove(interaction.ID)
+			continue
+		}
+		kept = append(kept, interaction)
+	}
+	for i := len(kept); i < len(s.interactions); i++ {
+		s.interactions[i] = nil
+	}
+	s.interactions = kept
+
+	s.index = make(map[string]int, len(kept))
+	for i, interaction := range kept {
+		s.index[interaction.ID] = i
+	}
+	return removed
+}
+
+// ApplyRetention elimina las interacciones y conversaciones que exceden la política.
+// El journal se compacta de inmediato para no conservar los datos eliminados.
+func (s *JSONStore) ApplyRetention(policy RetentionPolicy) (*RetentionReport, error) {
+	now := time.Now()
+	report := &RetentionReport{AppliedAt: now}
+	if !policy.Enabled() {
+		return report, nil
+	}
+
+	if err := s.beginWrite(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	keep := make(map[string]bool)
+	if policy.MaxInteractions > 0 && len(s.interactions) > policy.MaxInteractions {
+		newest := make([]*Interaction, len(s.interactions))
+		copy(newest, s.interactions)
+		sort.SliceStable(newest, func(i, j int) bool {
+			return newest[i].Timestamp.After(newest[j].Timestamp)
+		})
+		for _, interaction := range newest[:policy.MaxInteractions] {
+			keep[interaction.ID] = true
+		}
+	}
+	removed := s.removeLocked(func(interaction *Interaction) bool {
+		if len(keep) > 0 && !keep[interaction.ID] {
+			return true
+		}
+		return policy.expired(interaction, now)
+	})
+	s.mu.Unlock()
+
+	report.Interactions = len(removed)
+	var err error
+	if len(removed) > 0 {
+		err = s.compactLocked()
+	}
+	s.ioMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if policy.MaxAge > 0 {
+		cutoff := now.Add(-policy.MaxAge)
+		report.Conversations, err = s.removeConversations(func(conversation *Conversation) bool {
+			return conversation.lastActivity().Before(cutoff)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// removeConversations elimina los archivos de las conversaciones que cumplen remove
+func (s *JSONStore) removeConversations(remove func(*Conversation) bool) (int, error) {
+	s.convMu.Lock()
+	defer s.convMu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.conversationsDir(), "*.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, path := range paths {
+		conversation, err := s.readConversation(path)
+		if err != nil {
+			return removed, err
+		}
+		if !remove(conversation) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("error eliminando conversación: %w", err)
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		return removed, syncDir(s.conversationsDir())
+	}
+	return removed, nil
+}
+
+// DeleteUserData elimina todos los datos de un usuario de los archivos JSON y de sus copias
+func (s *JSONStore) DeleteUserData(userID string) (*DeletionReport, error) {
+	if userID == "" {
+		return nil, errEmptyUserID
+	}
+
+	report := &DeletionReport{UserID: userID}
+
+	if err := s.beginWrite(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	removed := s.removeLocked(func(interaction *Interaction) bool {
+		return interaction.UserID == userID
+	})
+	for _, interaction := range removed {
+		if interaction.Feedback != nil {
+			report.Feedback++
+		}
+	}
+	for key, pattern := range s.patterns {
+		if pattern.UserID == userID {
+			delete(s.patterns, key)
+			report.Patterns++
+		}
+	}
+	s.mu.Unlock()
+	report.Interactions = len(removed)
+
+	// Compactar y volcar ya: ni el journal ni patterns.json deben conservar los datos
+	var err error
+	if report.Interactions > 0 {
+		err = s.compactLocked()
+	}
+	if err == nil && report.Patterns > 0 {
+		s.patternsDirty = true
+		err = s.flushLocked()
+	}
+	s.ioMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var turns int
+	report.Conversations, err = s.removeConversations(func(conversation *Conversation) bool {
+		if conversation.UserID != userID {
+			return false
+		}
+		turns += len(conversation.Turns)
+		return true
+	})
+	report.Turns = turns
+	if err != nil {
+		return nil, err
+	}
+
+	backups, err := backupFiles(s.dataDir, ".json", ".json"+encryptedExt)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range backups {
+		purge, err := purgeJSONBackup(s.keys, path, userID)
+		if err != nil {
+			return nil, fmt.Errorf("error depurando %s: %w", path, err)
+		}
+		report.Backups = append(report.Backups, purge)
+	}
+
+	report.CompletedAt = time.Now()
+	return report, nil
+}
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ApplyRetention elimina las interacciones y conversaciones que exceden la política
+func (s *SQLiteStore) ApplyRetention(policy RetentionPolicy) (*RetentionReport, error) {
+	now := time.Now()
+	report := &RetentionReport{AppliedAt: now}
+	if !policy.Enabled() {
+		return report, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback()
+
+	deleted := func(query string, args ...interface{}) error {
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("error aplicando retención: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		report.Interactions += int(n)
+		return nil
+	}
+
+	// Intenciones con antigüedad propia
+	intents := make([]interface{}, 0, len(policy.IntentMaxAge))
+	for intent, age := range policy.IntentMaxAge {
+		intents = append(intents, intent)
+		if age <= 0 {
+			continue
+		}
+		if err := deleted(`DELETE FROM interactions WHERE intent = ? AND timestamp < ?`,
+			intent, formatTime(now.Add(-age))); err != nil {
+			return nil, err
+		}
+	}
+
+	// Resto de intenciones con la antigüedad general
+	if policy.MaxAge > 0 {
+		query := `DELETE FROM interactions WHERE timestamp < ?`
+		args := []interface{}{formatTime(now.Add(-policy.MaxAge))}
+		if len(intents) > 0 {
+			query += ` AND (intent IS NULL OR intent NOT IN (?` + strings.Repeat(", ?", len(intents)-1) + `))`
+			args = append(args, intents...)
+		}
+		if err := deleted(query, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	if policy.MaxInteractions > 0 {
+		if err := deleted(`
+		DELETE FROM interactions WHERE rowid NOT IN (
+			SELECT rowid FROM interactions ORDER BY timestamp DESC, rowid DESC LIMIT ?
+		)`, policy.MaxInteractions); err != nil {
+			return nil, err
+		}
+	}
+
+	if policy.MaxAge > 0 {
+		result, err := tx.Exec(`
+		DELETE FROM conversations
+		WHERE MAX(started_at,
+			COALESCE(ended_at, started_at),
+			COALESCE((SELECT MAX(timestamp) FROM conversation_turns t WHERE t.conversation_id = conversations.id), started_at)
+		) < ?`, formatTime(now.Add(-policy.MaxAge)))
+		if err != nil {
+			return nil, fmt.Errorf("error aplicando retención de conversaciones: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		report.Conversations = int(n)
+
+		if _, err := tx.Exec(`DELETE FROM conversation_turns WHERE conversation_id NOT IN (SELECT id FROM conversations)`); err != nil {
+			return nil, fmt.Errorf("error eliminando turnos huérfanos: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// DeleteUserData elimina todos los datos de un usuario de la base de datos y de sus copias
+func (s *SQLiteStore) DeleteUserData(userID string) (*DeletionReport, error) {
+	if userID == "" {
+		return nil, errEmptyUserID
+	}
+
+	report := &DeletionReport{UserID: userID}
+	if err := s.purgeUser(userID, report); err != nil {
+		return nil, err
+	}
+
+	backups, err := backupFiles(filepath.Dir(s.config.Path), ".db", ".db"+encryptedExt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range backups {
+		purge, err := s.purgeBackup(path, userID, report)
+		if err != nil {
+			return nil, fmt.Errorf("error depurando %s: %w", path, err)
+		}
+		report.Backups = append(report.Backups, purge)
+	}
+
+	report.CompletedAt = time.Now()
+	return report, nil
+}
+
+// purgeBackup abre una copia con el mismo driver y elimina los datos del usuario.
+// La copia se migra al esquema actual si es anterior. Las copias cifradas
+// se descifran en un archivo temporal y se vuelven a cifrar al terminar.
+func (s *SQLiteStore) purgeBackup(path, userID string, parent *DeletionReport) (BackupPurge, error) {
+	purge := BackupPurge{Path: path}
+
+	dbPath := path
+	if strings.HasSuffix(path, encryptedExt) {
+		dbPath = strings.TrimSuffix(path, encryptedExt) + ".tmp"
+		defer os.Remove(dbPath)
+		if err := openFileTo(s.keys, path, dbPath); err != nil {
+			return purge, err
+		}
+	}
+
+	backup, err := openSQLiteCopy(dbPath, s.driver, s.keys)
+	if err != nil {
+		return purge, err
+	}
+	defer backup.Close()
+
+	var report DeletionReport
+	if err := backup.purgeUser(userID, &report); err != nil {
+		return purge, err
+	}
+
+	purge.Interactions = report.Interactions
+	purge.Conversations = report.Conversations
+	purge.Patterns = report.Patterns
+	parent.Warnings = append(parent.Warnings, report.Warnings...)
+
+	if err := backup.Close(); err != nil {
+		return purge, err
+	}
+	if dbPath != path {
+		if err := sealFileTo(s.keys, dbPath, path); err != nil {
+			return purge, err
+		}
+	}
+	return purge, writeChecksum(path)
+}
+
+// purgeUser elimina los datos del usuario y compacta la base de datos para
+// que no queden restos en páginas libres
+func (s *SQLiteStore) purgeUser(userID string, report *DeletionReport) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback()
+
+	counts := []struct {
+		dest  *int
+		query string
+	}{
+		{&report.Interactions, `SELECT COUNT(*) FROM interactions WHERE user_id = ?`},
+		{&report.Feedback, `SELECT COUNT(*) FROM interactions WHERE user_id = ? AND feedback_rating IS NOT NULL`},
+		{&report.Conversations, `SELECT COUNT(*) FROM conversations WHERE user_id = ?`},
+		{&report.Turns, `SELECT COUNT(*) FROM conversation_turns WHERE conversation_id IN (SELECT id FROM conversations WHERE user_id = ?)`},
+		{&report.Patterns, `SELECT COUNT(*) FROM patterns WHERE user_id = ?`},
+	}
+	for _, count := range counts {
+		if err := tx.QueryRow(count.query, userID).Scan(count.dest); err != nil {
+			return fmt.Errorf("error contando datos del usuario: %w", err)
+		}
+	}
+
+	statements := []string{
+		`DELETE FROM conversation_turns WHERE conversation_id IN (SELECT id FROM conversations WHERE user_id = ?)`,
+		`DELETE FROM conversations WHERE user_id = ?`,
+		`DELETE FROM interactions WHERE user_id = ?`,
+		`DELETE FROM patterns WHERE user_id = ?`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, userID); err != nil {
+			return fmt.Errorf("error eliminando datos del usuario: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return s.scrub(report)
+}
+
+// scrub elimina los restos de datos borrados del índice de búsqueda y de las páginas libres
+func (s *SQLiteStore) scrub(report *DeletionReport) error {
+	if s.fts {
+		if _, err := s.db.Exec(`INSERT INTO interactions_fts(interactions_fts) VALUES ('optimize')`); err != nil {
+			return fmt.Errorf("error optimizando índice de búsqueda: %w", err)
+		}
+	} else {
+		var count int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'interactions_fts'`).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			report.Warnings = append(report.Warnings, fmt.Sprintf(
+				"%s: el índice FTS5 no se pudo depurar porque el driver no incluye FTS5; ábrelo con un binario compilado con sqlite_fts5 o sin CGO",
+				s.config.Path))
+		}
+	}
+
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("error compactando base de datos: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package storage
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Marcadores con los que se resaltan las coincidencias en los fragmentos
+const (
+	HighlightStart = "«"
+	HighlightEnd   = "»"
+)
+
+// snippetWords es el número aproximado de palabras de cada fragmento
+const snippetWords = 16
+
+// SearchFilters restringe los resultados de SearchInteractions.
+// Los campos vacíos o en cero no filtran.
+type SearchFilters struct {
+	Intent    string
+	UserID    string
+	Since     time.Time // Incluida
+	Until     time.Time // Excluida
+	MinRating int       // 1-5; excluye interacciones sin feedback
+	MaxRating int       // 1-5; excluye interacciones sin feedback
+	Limit     int       // Por defecto 20
+}
+
+// SearchResult es una interacción encontrada con su relevancia y fragmento resaltado
+type SearchResult struct {
+	Interaction *Interaction `json:"interaction"`
+	Score       float64      `json:"score"`
+	Snippet     string       `json:"snippet"`
+}
+
+func (f SearchFilters) limit() int {
+	if f.Limit <= 0 {
+		return 20
+	}
+	return f.Limit
+}
+
+// matches indica si una interacción cumple los filtros
+func (f SearchFilters) matches(interaction *Interaction) bool {
+	if f.Intent != "" && interaction.Intent != f.Intent {
+		return false
+	}
+	if f.UserID != "" && interaction.UserID != f.UserID {
+		return false
+	}
+	if !f.Since.IsZero() && interaction.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !interaction.Timestamp.Before(f.Until) {
+		return false
+	}
+	if f.MinRating > 0 || f.MaxRating > 0 {
+		if interaction.Feedback == nil {
+			return false
+		}
+		if f.MinRating > 0 && interaction.Feedback.Rating < f.MinRating {
+			return false
+		}
+		if f.MaxRating > 0 && interaction.Feedback.Rating > f.MaxRating {
+			return false
+		}
+	}
+	return true
+}
+
+// diacritics mapea letras acentuadas a su forma base para búsquedas insensibles a tildes
+var diacritics = map[rune]rune{
+	'á': 'a', 'à': 'a', 'ä': 'a', 'â': 'a', 'ã': 'a',
+	'é': 'e', 'è': 'e', 'ë': 'e', 'ê': 'e',
+	'í': 'i', 'ì': 'i', 'ï': 'i', 'î': 'i',
+	'ó': 'o', 'ò': 'o', 'ö': 'o', 'ô': 'o', 'õ': 'o',
+	'ú': 'u', 'ù': 'u', 'ü': 'u', 'û': 'u',
+	'ñ': 'n', 'ç': 'c',
+}
+
+// foldTerm normaliza un término: minúsculas y sin tildes
+func foldTerm(term string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(term) {
+		if base, ok := diacritics[r]; ok {
+			r = base
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// searchTerms divide un texto en términos normalizados
+func searchTerms(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool { return !isWordRune(r) })
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		terms = append(terms, foldTerm(f))
+	}
+	return terms
+}
+
+// searchIndex es un índice invertido en memoria sobre las interacciones
+type searchIndex struct {
+	postings map[string]map[string]int // término -> ID -> frecuencia
+	docTerms map[string]map[string]int // ID -> término -> frecuencia
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[string]int),
+		docTerms: make(map[string]map[string]int),
+	}
+}
+
+// put indexa (o reindexa) una interacción
+func (idx *searchIndex) put(interaction *Interaction) {
+	idx.remove(interaction.ID)
+
+	terms := make(map[string]int)
+	for _, term := range searchTerms(interaction.UserInput + " " + interaction.Response) {
+		terms[term]++
+	}
+	idx.docTerms[interaction.ID] = terms
+
+	for term, freq := range terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[string]int)
+		}
+		idx.postings[term][interaction.ID] = freq
+	}
+}
+
+// remove elimina una interacción del índice
+func (idx *searchIndex) remove(id string) {
+	for term := range idx.docTerms[id] {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.docTerms, id)
+}
+
+// score devuelve la relevancia (TF-IDF con coincidencia por prefijo) de cada
+// documento que contiene todos los términos de la consulta
+func (idx *searchIndex) score(queryTerms []string) map[string]float64 {
+	total := float64(len(idx.docTerms))
+	var scores map[string]float64
+
+	for _, qt := range queryTerms {
+		termScores := make(map[string]float64)
+		for term, docs := range idx.postings {
+			if !strings.HasPrefix(term, qt) {
+				continue
+			}
+			idf := math.Log(1 + total/float64(len(docs)))
+			for id, freq := range docs {
+				termScores[id] += float64(freq) * idf
+			}
+		}
+
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		// Todos los términos deben aparecer (AND)
+		for id := range scores {
+			if s, ok := termScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	return scores
+}
+
+// rankInteractions busca en memoria sobre un conjunto de interacciones ya filtradas
+func rankInteractions(interactions []*Interaction, query string, filters SearchFilters) []*SearchResult {
+	queryTerms := searchTerms(query)
+
+	if len(queryTerms) == 0 {
+		// Sin texto: las más recientes que cumplan los filtros
+		var results []*SearchResult
+		for _, interaction := range interactions {
+			if filters.matches(interaction) {
+				results = append(results, &SearchResult{Interaction: interaction})
+			}
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Interaction.Timestamp.After(results[j].Interaction.Timestamp)
+		})
+		if len(results) > filters.limit() {
+			results = results[:filters.limit()]
+		}
+		return results
+	}
+
+	idx := newSearchIndex()
+	byID := make(map[string]*Interaction, len(interactions))
+	for _, interaction := range interactions {
+		if filters.matches(interaction) {
+			idx.put(interaction)
+			byID[interaction.ID] = interaction
+		}
+	}
+
+	return collectResults(idx.score(queryTerms), byID, queryTerms, filters.limit())
+}
+
+// collectResults ordena las puntuaciones y construye los resultados con fragmento
+func collectResults(scores map[string]float64, byID map[string]*Interaction, queryTerms []string, limit int) []*SearchResult {
+	results := make([]*SearchResult, 0, len(scores))
+	for id, score := range scores {
+		interaction, ok := byID[id]
+		if !ok {
+			continue
+		}
+		results = append(results, &SearchResult{Interaction: interaction, Score: score})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Interaction.Timestamp.After(results[j].Interaction.Timestamp)
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	for _, result := range results {
+		result.Snippet = buildSnippet(result.Interaction, queryTerms)
+	}
+	return results
+}
+
+// buildSnippet genera un fragmento resaltado del campo con más coincidencias
+func buildSnippet(interaction *Interaction, queryTerms []string) string {
+	best, bestMatches := "", -1
+	for _, text := range []string{interaction.UserInput, interaction.Response} {
+		snippet, matches := highlight(text, queryTerms, snippetWords)
+		if matches > bestMatches {
+			best, bestMatches = snippet, matches
+		}
+	}
+	return best
+}
+
+// highlight resalta las palabras que empiezan por algún término de la consulta
+// y recorta el texto a una ventana de maxWords palabras alrededor de la primera coincidencia
+func highlight(text string, queryTerms []string, maxWords int) (string, int) {
+	type word struct {
+		start, end int // posiciones en bytes
+		match      bool
+	}
+
+	var words []word
+	start := -1
+	for i, r := range text + " " {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			w := word{start: start, end: i}
+			folded := foldTerm(text[start:i])
+			for _, qt := range queryTerms {
+				if strings.HasPrefix(folded, qt) {
+					w.match = true
+					break
+				}
+			}
+			words = append(words, w)
+			start = -1
+		}
+	}
+
+	if len(words) == 0 {
+		return "", 0
+	}
+
+	first, matches := -1, 0
+	for i, w := range words {
+		if w.match {
+			matches++
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+
+	from := 0
+	if first > maxWords/2 {
+		from = first - maxWords/2
+	}
+	to := from + maxWords
+	if to > len(words) {
+		to = len(words)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := words[from].start
+	for _, w := range words[from:to] {
+		b.WriteString(text[pos:w.start])
+		if w.match {
+			b.WriteString(HighlightStart + text[w.start:w.end] + HighlightEnd)
+		} else {
+			b.WriteString(text[w.start:w.end])
+		}
+		pos = w.end
+	}
+	if to < len(words) {
+		b.WriteString("…")
+	}
+
+	return b.String(), matches
+}
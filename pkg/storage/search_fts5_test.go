@@ -0,0 +1,29 @@
+//go:build cgo && sqlite_fts5
+// +build cgo,sqlite_fts5
+
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/akosej/agent/pkg/storage"
+	"github.com/akosej/agent/pkg/storage/storagetest"
+)
+
+// Con la etiqueta sqlite_fts5 el driver CGO busca con el índice FTS5 (make test)
+func TestSQLiteStoreFTS5(t *testing.T) {
+	store, err := storage.NewSQLiteStore(storage.Config{Path: filepath.Join(t.TempDir(), "agent.db")})
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	fts := store.FullTextSearch()
+	store.Close()
+	if !fts {
+		t.Fatal("FullTextSearch = false compilando con sqlite_fts5")
+	}
+
+	storagetest.Run(t, func(config storage.Config) (storage.Store, error) {
+		return storage.NewSQLiteStore(config)
+	})
+}
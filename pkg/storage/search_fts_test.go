@@ -0,0 +1,34 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// El driver en Go puro siempre incluye FTS5; con cifrado no se usa
+func TestPureGoFullTextSearch(t *testing.T) {
+	store, err := storage.NewPureGoSQLiteStore(storage.Config{Path: filepath.Join(t.TempDir(), "agent.db")})
+	if err != nil {
+		t.Fatalf("NewPureGoSQLiteStore: %v", err)
+	}
+	defer store.Close()
+	if !store.FullTextSearch() {
+		t.Error("FullTextSearch = false con el driver en Go puro")
+	}
+
+	key, _ := storage.GenerateKey()
+	t.Setenv("AGENT_TEST_KEY", key)
+	encrypted, err := storage.NewPureGoSQLiteStore(storage.Config{
+		Path:       filepath.Join(t.TempDir(), "agent.db"),
+		Encryption: storage.EncryptionConfig{Enabled: true, KeyEnv: "AGENT_TEST_KEY"},
+	})
+	if err != nil {
+		t.Fatalf("NewPureGoSQLiteStore cifrado: %v", err)
+	}
+	defer encrypted.Close()
+	if encrypted.FullTextSearch() {
+		t.Error("FullTextSearch = true con cifrado")
+	}
+}
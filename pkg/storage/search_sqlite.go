@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// El índice FTS5 no forma parte de las migraciones porque no todos los
+// drivers lo incluyen: mattn/go-sqlite3 solo lo compila con la etiqueta
+// sqlite_fts5. Si el módulo no está disponible se eliminan los triggers
+// y la búsqueda se resuelve en memoria con rankInteractions.
+var ftsTriggers = map[string]string{
+	"interactions_fts_ai": `
+	CREATE TRIGGER interactions_fts_ai AFTER INSERT ON interactions BEGIN
+		INSERT INTO interactions_fts(rowid, user_input, response)
+		VALUES (new.rowid, new.user_input, new.response);
+	END`,
+	"interactions_fts_ad": `
+	CREATE TRIGGER interactions_fts_ad AFTER DELETE ON interactions BEGIN
+		INSERT INTO interactions_fts(interactions_fts, rowid, user_input, response)
+		VALUES ('delete', old.rowid, old.user_input, old.response);
+	END`,
+	"interactions_fts_au": `
+	CREATE TRIGGER interactions_fts_au AFTER UPDATE OF user_input, response ON interactions BEGIN
+		INSERT INTO interactions_fts(interactions_fts, rowid, user_input, response)
+		VALUES ('delete', old.rowid, old.user_input, old.response);
+		INSERT INTO interactions_fts(rowid, user_input, response)
+		VALUES (new.rowid, new.user_input, new.response);
+	END`,
+}
+
+// ensureSearchIndex crea el índice FTS5 y sus triggers si el driver lo soporta.
+// Con cifrado habilitado no se usa: indexaría el texto cifrado, o el texto
+// en claro si se indexara antes de cifrar.
+func (s *SQLiteStore) ensureSearchIndex() error {
+	if s.keys != nil {
+		return s.dropSearchIndex()
+	}
+
+	_, err := s.db.Exec(`
+	CREATE VIRTUAL TABLE IF NOT EXISTS interactions_fts USING fts5(
+		user_input, response,
+		content='interactions', content_rowid='rowid',
+		tokenize='unicode61 remove_diacritics 2'
+	)`)
+	if err == nil {
+		// Si la tabla ya existía, CREATE no comprueba que el módulo esté cargado
+		_, err = s.db.Exec(`SELECT rowid FROM interactions_fts LIMIT 0`)
+	}
+	if err != nil {
+		if !strings.Contains(err.Error(), "fts5") {
+			return fmt.Errorf("error creando índice de búsqueda: %w", err)
+		}
+
+		// Sin FTS5 los triggers harían fallar cada escritura
+		return s.dropTriggers()
+	}
+
+	// Si faltaba algún trigger el índice puede estar desactualizado
+	// (base de datos nueva o abierta antes con un driver sin FTS5)
+	rebuild := false
+	for name, statement := range ftsTriggers {
+		var count int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?`, name).Scan(&count); err != nil {
+			return fmt.Errorf("error consultando triggers: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := s.db.Exec(statement); err != nil {
+			return fmt.Errorf("error creando trigger %s: %w", name, err)
+		}
+		rebuild = true
+	}
+
+	if rebuild {
+		if _, err := s.db.Exec(`INSERT INTO interactions_fts(interactions_fts) VALUES ('rebuild')`); err != nil {
+			return fmt.Errorf("error reconstruyendo índice de búsqueda: %w", err)
+		}
+	}
+
+	s.fts = true
+	return nil
+}
+
+// FullTextSearch indica si las búsquedas usan el índice FTS5. Es false con
+// cifrado o si el driver no incluye FTS5 (el driver CGO sin la etiqueta
+// sqlite_fts5); entonces se busca en memoria, más despacio y sin BM25.
+func (s *SQLiteStore) FullTextSearch() bool {
+	return s.fts
+}
+
+// dropTriggers elimina los triggers del índice y desactiva FTS5
+func (s *SQLiteStore) dropTriggers() error {
+	for name := range ftsTriggers {
+		if _, err := s.db.Exec(`DROP TRIGGER IF EXISTS ` + name); err != nil {
+			return fmt.Errorf("error eliminando trigger %s: %w", name, err)
+		}
+	}
+	s.fts = false
+	return nil
+}
+
+// dropSearchIndex elimina el índice FTS5 y sus triggers. Si el driver no
+// incluye FTS5 la tabla no se puede eliminar y solo se quitan los triggers.
+func (s *SQLiteStore) dropSearchIndex() error {
+	if err := s.dropTriggers(); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DROP TABLE IF EXISTS interactions_fts`); err != nil && !strings.Contains(err.Error(), "fts5") {
+		return fmt.Errorf("error eliminando índice de búsqueda: %w", err)
+	}
+	return nil
+}
+
+// sqlConditions traduce los filtros a condiciones SQL sobre la tabla interactions
+func (f SearchFilters) sqlConditions(prefix string) ([]string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if f.Intent != "" {
+		conditions = append(conditions, prefix+"intent = ?")
+		args = append(args, f.Intent)
+	}
+	if f.UserID != "" {
+		conditions = append(conditions, prefix+"user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, prefix+"timestamp >= ?")
+		args = append(args, formatTime(f.Since))
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, prefix+"timestamp < ?")
+		args = append(args, formatTime(f.Until))
+	}
+	if f.MinRating > 0 {
+		conditions = append(conditions, prefix+"feedback_rating >= ?")
+		args = append(args, f.MinRating)
+	}
+	if f.MaxRating > 0 {
+		conditions = append(conditions, prefix+"feedback_rating <= ?")
+		args = append(args, f.MaxRating)
+	}
+
+	return conditions, args
+}
+
+// SearchInteractions busca texto en las interacciones usando FTS5 (BM25)
+// o, si no está disponible, el índice en memoria
+func (s *SQLiteStore) SearchInteractions(query string, filters SearchFilters) ([]*SearchResult, error) {
+	queryTerms := searchTerms(query)
+	if len(queryTerms) == 0 || !s.fts {
+		return s.searchFallback(queryTerms, filters)
+	}
+
+	// Cada término como prefijo entre comillas; FTS5 los combina con AND
+	match := make([]string, len(queryTerms))
+	for i, term := range queryTerms {
+		match[i] = `"` + term + `"*`
+	}
+
+	conditions, args := filters.sqlConditions("i.")
+	conditions = append([]string{"interactions_fts MATCH ?"}, conditions...)
+	args = append([]interface{}{strings.Join(match, " ")}, args...)
+	args = append(args, filters.limit())
+
+	rows, err := s.db.Query(`
+	SELECT `+interactionColumns("i.")+`, bm25(interactions_fts)
+	FROM interactions_fts
+	JOIN interactions i ON i.rowid = interactions_fts.rowid
+	WHERE `+strings.Join(conditions, " AND ")+`
+	ORDER BY bm25(interactions_fts), i.timestamp DESC
+	LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error buscando interacciones: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]*SearchResult, 0)
+	for rows.Next() {
+		var rank float64
+		interaction, err := s.readInteraction(rows, &rank)
+		if err != nil {
+			return nil, err
+		}
+		// bm25 devuelve valores negativos: cuanto menor, más relevante
+		results = append(results, &SearchResult{
+			Interaction: interaction,
+			Score:       -rank,
+			Snippet:     buildSnippet(interaction, queryTerms),
+		})
+	}
+
+	return results, rows.Err()
+}
+
+// searchFallback filtra en SQL y ordena en memoria
+func (s *SQLiteStore) searchFallback(queryTerms []string, filters SearchFilters) ([]*SearchResult, error) {
+	conditions, args := filters.sqlConditions("")
+
+	query := `SELECT ` + interactionColumns("") + ` FROM interactions`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY timestamp DESC, rowid DESC`
+	if len(queryTerms) == 0 {
+		query += ` LIMIT ?`
+		args = append(args, filters.limit())
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error buscando interacciones: %w", err)
+	}
+	defer rows.Close()
+
+	interactions := make([]*Interaction, 0)
+	for rows.Next() {
+		interaction, err := s.readInteraction(rows)
+		if err != nil {
+			return nil, err
+		}
+		interactions = append(interactions, interaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := rankInteractions(interactions, strings.Join(queryTerms, " "), filters)
+	if results == nil {
+		results = make([]*SearchResult, 0)
+	}
+	return results, nil
+}
@@ -0,0 +1,11 @@
+//go:build cgo
+// +build cgo
+
+package storage
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// defaultSQLiteDriver es el driver de "sqlite" cuando CGO está disponible
+const defaultSQLiteDriver = driverCGO
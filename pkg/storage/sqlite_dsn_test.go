@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sqliteDrivers son los drivers compilados en el binario de test
+func sqliteDrivers() []string {
+	if defaultSQLiteDriver == driverPureGo {
+		return []string{driverPureGo}
+	}
+	return []string{driverPureGo, defaultSQLiteDriver}
+}
+
+func TestSQLiteConnectionOptions(t *testing.T) {
+	for _, driver := range sqliteDrivers() {
+		driver := driver
+		t.Run(driver, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := openSQLiteStore(Config{Path: filepath.Join(dir, "agent.db"), BackupEnabled: true}, driver, nil, true)
+			if err != nil {
+				t.Fatalf("openSQLiteStore: %v", err)
+			}
+			defer store.Close()
+
+			var mode string
+			var timeout int
+			if err := store.db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil {
+				t.Fatalf("journal_mode: %v", err)
+			}
+			if err := store.db.QueryRow(`PRAGMA busy_timeout`).Scan(&timeout); err != nil {
+				t.Fatalf("busy_timeout: %v", err)
+			}
+			if mode != "wal" || timeout != sqliteBusyTimeout {
+				t.Errorf("journal_mode = %q, busy_timeout = %d, want wal y %d", mode, timeout, sqliteBusyTimeout)
+			}
+
+			// Las copias no dependen de archivos -wal ni -shm
+			backup, err := store.Backup()
+			if err != nil {
+				t.Fatalf("Backup: %v", err)
+			}
+			copied, err := openSQLiteCopy(backup, driver, nil)
+			if err != nil {
+				t.Fatalf("openSQLiteCopy: %v", err)
+			}
+			if err := copied.db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil {
+				t.Fatalf("journal_mode: %v", err)
+			}
+			copied.Close()
+			if mode == "wal" {
+				t.Error("la copia quedó en modo WAL")
+			}
+			if _, err := os.Stat(backup + "-wal"); !os.IsNotExist(err) {
+				t.Errorf("la copia dejó un archivo -wal: %v", err)
+			}
+		})
+	}
+}
+
+// Dos procesos (aquí, dos almacenamientos) escriben a la vez en el mismo
+// archivo: con busy_timeout esperan al bloqueo en lugar de fallar
+func TestSQLiteConcurrentWriters(t *testing.T) {
+	for _, driver := range sqliteDrivers() {
+		driver := driver
+		t.Run(driver, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agent.db")
+			var stores []*SQLiteStore
+			for i := 0; i < 2; i++ {
+				store, err := openSQLiteStore(Config{Path: path}, driver, nil, true)
+				if err != nil {
+					t.Fatalf("openSQLiteStore: %v", err)
+				}
+				defer store.Close()
+				stores = append(stores, store)
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 2)
+			for i, store := range stores {
+				wg.Add(1)
+				go func(i int, store *SQLiteStore) {
+					defer wg.Done()
+					for n := 0; n < 50; n++ {
+						err := store.SaveInteraction(&Interaction{
+							ID:        fmt.Sprintf("w%d-%02d", i, n),
+							UserInput: "hola",
+							Timestamp: time.Now(),
+						})
+						if err != nil {
+							errs <- err
+							return
+						}
+					}
+				}(i, store)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Errorf("SaveInteraction: %v", err)
+			}
+
+			interactions, err := stores[0].GetRecentInteractions(0)
+			if err != nil {
+				t.Fatalf("GetRecentInteractions: %v", err)
+			}
+			if len(interactions) != 100 {
+				t.Errorf("interacciones = %d, want 100", len(interactions))
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !cgo
+// +build !cgo
+
+package storage
+
+// defaultSQLiteDriver es el driver de "sqlite" en binarios sin CGO
+const defaultSQLiteDriver = driverPureGo
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sqlTimeFormat es el formato fijo (UTC, ancho constante) con el que se
+// guardan las fechas en SQLite, de modo que el orden textual coincide con
+// el cronológico y funciona igual con cualquier driver.
+const sqlTimeFormat = "2006-01-02 15:04:05.000000000"
+
+// sqlTimeLayouts son los formatos aceptados al leer fechas guardadas
+// por versiones anteriores o por otros drivers.
+var sqlTimeLayouts = []string{
+	sqlTimeFormat,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	time.RFC3339Nano,
+}
+
+// formatTime convierte una fecha al formato de almacenamiento (NULL si es cero)
+func formatTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(sqlTimeFormat)
+}
+
+// sqlTime lee columnas de fecha tanto si el driver devuelve time.Time como texto
+type sqlTime struct {
+	Time time.Time
+}
+
+// Scan implementa sql.Scanner
+func (t *sqlTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	default:
+		return fmt.Errorf("tipo de fecha no soportado: %T", value)
+	}
+}
+
+func (t *sqlTime) parse(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	// Formato de time.Time.String(), usado por algunos drivers
+	if idx := strings.Index(value, " m="); idx > 0 {
+		value = value[:idx]
+	}
+	for _, layout := range append(sqlTimeLayouts, "2006-01-02 15:04:05.999999999 -0700 MST") {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+
+	return fmt.Errorf("fecha con formato desconocido: %q", value)
+}
@@ -1,129 +1,291 @@
-//go:build cgo
-// +build cgo
-
 package storage
 
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	_ "modernc.org/sqlite" // Driver en Go puro, siempre disponible
+)
+
+// Drivers de SQLite registrados en database/sql
+const (
+	driverCGO    = "sqlite3" // github.com/mattn/go-sqlite3 (requiere CGO)
+	driverPureGo = "sqlite"  // modernc.org/sqlite (Go puro, sin CGO)
 )
 
-// Storage maneja el almacenamiento persistente usando SQLite
-type Storage struct {
+// sqliteBusyTimeout es lo que espera una conexión a que se libere un bloqueo
+// antes de fallar con "database is locked", en milisegundos
+const sqliteBusyTimeout = 5000
+
+// sqliteDSN añade a path las opciones de conexión con la sintaxis de cada driver.
+// La base de datos principal usa WAL para que las lecturas no bloqueen las
+// escrituras; las copias y los temporales se quedan en un único archivo.
+func sqliteDSN(driver, path string, wal bool) string {
+	if driver == driverCGO {
+		dsn := fmt.Sprintf("%s?_busy_timeout=%d", path, sqliteBusyTimeout)
+		if wal {
+			dsn += "&_journal_mode=WAL"
+		}
+		return dsn
+	}
+	dsn := fmt.Sprintf("%s?_pragma=busy_timeout(%d)", path, sqliteBusyTimeout)
+	if wal {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+	return dsn
+}
+
+// sqliteReadOnlyDSN abre path en solo lectura: SQLite no crea el archivo ni
+// puede escribir en él. mode=ro solo se reconoce en la forma URI "file:".
+func sqliteReadOnlyDSN(driver, path string) string {
+	escaped := strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23", " ", "%20").Replace(filepath.ToSlash(path))
+	return strings.Replace(sqliteDSN(driver, "file:"+escaped, false), "?", "?mode=ro&", 1)
+}
+
+// SQLiteStore maneja el almacenamiento persistente usando SQLite
+type SQLiteStore struct {
 	db     *sql.DB
 	config Config
+	driver string
+	fts    bool     // true si el índice FTS5 está disponible
+	keys   *KeyRing // nil si el cifrado está deshabilitado
+}
+
+// NewSQLiteStore crea una nueva instancia de almacenamiento SQLite.
+// Usa el driver CGO si está compilado y el driver en Go puro en caso contrario.
+func NewSQLiteStore(config Config) (*SQLiteStore, error) {
+	return newSQLiteStore(config, defaultSQLiteDriver)
+}
+
+// NewPureGoSQLiteStore crea un almacenamiento SQLite con el driver en Go puro,
+// disponible también en binarios estáticos compilados con CGO_ENABLED=0
+func NewPureGoSQLiteStore(config Config) (*SQLiteStore, error) {
+	return newSQLiteStore(config, driverPureGo)
+}
+
+func newSQLiteStore(config Config, driver string) (*SQLiteStore, error) {
+	keys, err := LoadKeyRing(config.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	return openSQLiteStore(config, driver, keys, true)
 }
 
-// NewStorage crea una nueva instancia de almacenamiento
-func NewStorage(config Config) (*Storage, error) {
-	db, err := sql.Open("sqlite3", config.Path)
+// openSQLiteCopy abre una copia o un archivo temporal sin WAL, para que al
+// cerrarlo todo quede en el archivo que después se cifra, copia o verifica
+func openSQLiteCopy(path, driver string, keys *KeyRing) (*SQLiteStore, error) {
+	return openSQLiteStore(Config{Path: path}, driver, keys, false)
+}
+
+func openSQLiteStore(config Config, driver string, keys *KeyRing, wal bool) (*SQLiteStore, error) {
+	if dir := filepath.Dir(config.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("error creando directorio de datos: %w", err)
+		}
+	}
+
+	db, err := sql.Open(driver, sqliteDSN(driver, config.Path, wal))
 	if err != nil {
 		return nil, fmt.Errorf("error abriendo base de datos: %w", err)
 	}
 
-	storage := &Storage{
+	storage := &SQLiteStore{
 		db:     db,
 		config: config,
+		driver: driver,
+		keys:   keys,
 	}
 
 	if err := storage.initTables(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := storage.ensureSearchIndex(); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return storage, nil
 }
 
-// initTables inicializa las tablas de la base de datos
-func (s *Storage) initTables() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS interactions (
-		id TEXT PRIMARY KEY,
-		timestamp DATETIME,
-		user_input TEXT,
-		response TEXT,
-		intent TEXT,
-		context TEXT,
-		feedback_rating INTEGER,
-		feedback_comment TEXT,
-		feedback_timestamp DATETIME
-	);
-
-	CREATE TABLE IF NOT EXISTS patterns (
-		pattern_key TEXT PRIMARY KEY,
-		pattern TEXT,
-		response TEXT,
-		frequency INTEGER,
-		confidence REAL,
-		last_used DATETIME
-	);
-
-	CREATE TABLE IF NOT EXISTS stats (
-		id INTEGER PRIMARY KEY,
-		total_interactions INTEGER,
-		positive_feedback INTEGER,
-		negative_feedback INTEGER,
-		average_rating REAL,
-		last_updated DATETIME
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_interactions_timestamp ON interactions(timestamp);
-	CREATE INDEX IF NOT EXISTS idx_interactions_intent ON interactions(intent);
-	`
+// initTables aplica las migraciones pendientes del esquema
+func (s *SQLiteStore) initTables() error {
+	if _, err := NewMigrator(s.db).Up(); err != nil {
+		return err
+	}
+	return nil
+}
 
-	_, err := s.db.Exec(schema)
-	return err
+// Migrator devuelve el migrador asociado a la base de datos del almacenamiento
+func (s *SQLiteStore) Migrator() *Migrator {
+	return NewMigrator(s.db)
 }
 
 // SaveInteraction guarda una interacción en la base de datos
-func (s *Storage) SaveInteraction(interaction map[string]interface{}) error {
-	contextJSON, _ := json.Marshal(interaction["context"])
+func (s *SQLiteStore) SaveInteraction(interaction *Interaction) error {
+	interaction, err := s.keys.sealInteraction(interaction)
+	if err != nil {
+		return err
+	}
+
+	contextJSON, err := json.Marshal(interaction.Context)
+	if err != nil {
+		return fmt.Errorf("error codificando contexto: %w", err)
+	}
 
+	// UPSERT en lugar de INSERT OR REPLACE para conservar el rowid y
+	// que los triggers del índice de búsqueda vean la actualización
 	query := `
-	INSERT INTO interactions (id, timestamp, user_input, response, intent, context)
-	VALUES (?, ?, ?, ?, ?, ?)
+	INSERT INTO interactions (id, user_id, timestamp, user_input, response, intent, context,
+		feedback_rating, feedback_comment, feedback_timestamp, latency_ms)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(id) DO UPDATE SET
+		user_id = excluded.user_id,
+		timestamp = excluded.timestamp,
+		user_input = excluded.user_input,
+		response = excluded.response,
+		intent = excluded.intent,
+		context = excluded.context,
+		feedback_rating = excluded.feedback_rating,
+		feedback_comment = excluded.feedback_comment,
+		feedback_timestamp = excluded.feedback_timestamp,
+		latency_ms = excluded.latency_ms
 	`
 
-	_, err := s.db.Exec(query,
-		interaction["id"],
-		interaction["timestamp"],
-		interaction["user_input"],
-		interaction["response"],
-		interaction["intent"],
+	var rating, comment, feedbackTime interface{}
+	if fb := interaction.Feedback; fb != nil {
+		rating, comment, feedbackTime = fb.Rating, fb.Comment, formatTime(fb.Timestamp)
+	}
+
+	_, err = s.db.Exec(query,
+		interaction.ID,
+		nullString(interaction.UserID),
+		formatTime(interaction.Timestamp),
+		interaction.UserInput,
+		interaction.Response,
+		interaction.Intent,
 		string(contextJSON),
+		rating,
+		comment,
+		feedbackTime,
+		nullLatency(interaction.Latency),
 	)
 
 	return err
 }
 
-// SavePattern guarda un patrón aprendido
-func (s *Storage) SavePattern(patternKey string, pattern map[string]interface{}) error {
-	query := `
-	INSERT OR REPLACE INTO patterns (pattern_key, pattern, response, frequency, confidence, last_used)
-	VALUES (?, ?, ?, ?, ?, ?)
-	`
+// nullString guarda las cadenas vacías como NULL
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
 
-	_, err := s.db.Exec(query,
-		patternKey,
-		pattern["pattern"],
-		pattern["response"],
-		pattern["frequency"],
-		pattern["confidence"],
-		pattern["last_used"],
+// nullLatency guarda la latencia en milisegundos, o NULL si no se midió
+func nullLatency(latency time.Duration) interface{} {
+	if latency <= 0 {
+		return nil
+	}
+	return latency.Milliseconds()
+}
+
+// interactionColumns devuelve las columnas leídas por scanInteraction con un prefijo de tabla opcional
+func interactionColumns(prefix string) string {
+	columns := []string{"id", "user_id", "timestamp", "user_input", "response", "intent", "context",
+		"feedback_rating", "feedback_comment", "feedback_timestamp", "latency_ms"}
+	for i, column := range columns {
+		columns[i] = prefix + column
+	}
+	return strings.Join(columns, ", ")
+}
+
+// scanInteraction lee una fila con las columnas de interactionColumns
+// (más los destinos adicionales indicados en extra)
+func scanInteraction(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*Interaction, error) {
+	var (
+		interaction     Interaction
+		userID          sql.NullString
+		timestamp       sqlTime
+		contextJSON     sql.NullString
+		rating          sql.NullInt64
+		comment         sql.NullString
+		feedbackTime    sqlTime
+		userInput, resp sql.NullString
+		intent          sql.NullString
+		latencyMs       sql.NullInt64
 	)
 
-	return err
+	dest := append([]interface{}{&interaction.ID, &userID, &timestamp, &userInput, &resp, &intent, &contextJSON,
+		&rating, &comment, &feedbackTime, &latencyMs}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	interaction.UserID = userID.String
+	interaction.Timestamp = timestamp.Time
+	interaction.UserInput = userInput.String
+	interaction.Response = resp.String
+	interaction.Intent = intent.String
+	interaction.Latency = time.Duration(latencyMs.Int64) * time.Millisecond
+
+	if contextJSON.Valid && contextJSON.String != "" && contextJSON.String != "null" {
+		if err := json.Unmarshal([]byte(contextJSON.String), &interaction.Context); err != nil {
+			return nil, fmt.Errorf("error decodificando contexto de %s: %w", interaction.ID, err)
+		}
+	}
+
+	if rating.Valid {
+		interaction.Feedback = &Feedback{
+			Rating:    int(rating.Int64),
+			Comment:   comment.String,
+			Timestamp: feedbackTime.Time,
+		}
+	}
+
+	return &interaction, nil
+}
+
+// readInteraction lee una fila y descifra los campos sensibles
+func (s *SQLiteStore) readInteraction(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*Interaction, error) {
+	interaction, err := scanInteraction(row, extra...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.keys.openInteraction(interaction); err != nil {
+		return nil, fmt.Errorf("interacción %s: %w", interaction.ID, err)
+	}
+	return interaction, nil
+}
+
+// GetInteraction obtiene una interacción por su ID
+func (s *SQLiteStore) GetInteraction(id string) (*Interaction, error) {
+	row := s.db.QueryRow(`SELECT `+interactionColumns("")+` FROM interactions WHERE id = ?`, id)
+
+	interaction, err := s.readInteraction(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return interaction, err
 }
 
 // GetRecentInteractions obtiene las interacciones más recientes
-func (s *Storage) GetRecentInteractions(limit int) ([]map[string]interface{}, error) {
+func (s *SQLiteStore) GetRecentInteractions(limit int) ([]*Interaction, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
 	query := `
-	SELECT id, timestamp, user_input, response, intent, context
+	SELECT ` + interactionColumns("") + `
 	FROM interactions
-	ORDER BY timestamp DESC
+	ORDER BY timestamp DESC, rowid DESC
 	LIMIT ?
 	`
 
@@ -133,77 +295,425 @@ func (s *Storage) GetRecentInteractions(limit int) ([]map[string]interface{}, er
 	}
 	defer rows.Close()
 
-	var interactions []map[string]interface{}
+	interactions := make([]*Interaction, 0)
 	for rows.Next() {
-		var id, userInput, response, intent, contextJSON string
-		var timestamp time.Time
-
-		if err := rows.Scan(&id, &timestamp, &userInput, &response, &intent, &contextJSON); err != nil {
-			continue
+		interaction, err := s.readInteraction(rows)
+		if err != nil {
+			return nil, err
 		}
+		interactions = append(interactions, interaction)
+	}
 
-		var context map[string]interface{}
-		json.Unmarshal([]byte(contextJSON), &context)
+	return interactions, rows.Err()
+}
 
-		interactions = append(interactions, map[string]interface{}{
-			"id":         id,
-			"timestamp":  timestamp,
-			"user_input": userInput,
-			"response":   response,
-			"intent":     intent,
-			"context":    context,
-		})
+// DeleteInteraction elimina una interacción; el feedback se guarda en la misma
+// fila y los disparadores la quitan del índice de búsqueda
+func (s *SQLiteStore) DeleteInteraction(id string) error {
+	result, err := s.db.Exec(`DELETE FROM interactions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error eliminando interacción: %w", err)
 	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
 
-	return interactions, nil
+// SaveFeedback guarda la retroalimentación de una interacción
+func (s *SQLiteStore) SaveFeedback(interactionID string, feedback *Feedback) error {
+	query := `
+	UPDATE interactions
+	SET feedback_rating = ?, feedback_comment = ?, feedback_timestamp = ?
+	WHERE id = ?
+	`
+
+	comment, err := s.keys.SealString(feedback.Comment, fieldAAD(interactionID, "feedback_comment"))
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(query, feedback.Rating, comment, formatTime(feedback.Timestamp), interactionID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
-// GetPattern obtiene un patrón por su clave
-func (s *Storage) GetPattern(patternKey string) (map[string]interface{}, error) {
+// SavePattern guarda un patrón aprendido
+func (s *SQLiteStore) SavePattern(pattern *Pattern) error {
+	pattern, err := s.keys.sealPattern(pattern)
+	if err != nil {
+		return err
+	}
+
 	query := `
-	SELECT pattern, response, frequency, confidence, last_used
-	FROM patterns
-	WHERE pattern_key = ?
+	INSERT OR REPLACE INTO patterns (pattern_key, user_id, pattern, response, frequency, confidence, last_used)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 
-	var pattern, response string
-	var frequency int
-	var confidence float64
-	var lastUsed time.Time
+	_, err = s.db.Exec(query,
+		pattern.Key,
+		nullString(pattern.UserID),
+		pattern.Pattern,
+		pattern.Response,
+		pattern.Frequency,
+		pattern.Confidence,
+		formatTime(pattern.LastUsed),
+	)
+
+	return err
+}
+
+const patternColumns = `pattern_key, user_id, pattern, response, frequency, confidence, last_used`
+
+func scanPattern(row interface{ Scan(...interface{}) error }) (*Pattern, error) {
+	var (
+		pattern                Pattern
+		userID, text, response sql.NullString
+		frequency              sql.NullInt64
+		confidence             sql.NullFloat64
+		lastUsed               sqlTime
+	)
 
-	err := s.db.QueryRow(query, patternKey).Scan(&pattern, &response, &frequency, &confidence, &lastUsed)
+	if err := row.Scan(&pattern.Key, &userID, &text, &response, &frequency, &confidence, &lastUsed); err != nil {
+		return nil, err
+	}
+
+	pattern.UserID = userID.String
+	pattern.Pattern = text.String
+	pattern.Response = response.String
+	pattern.Frequency = int(frequency.Int64)
+	pattern.Confidence = confidence.Float64
+	pattern.LastUsed = lastUsed.Time
+
+	return &pattern, nil
+}
+
+// readPattern lee una fila y descifra el texto del patrón
+func (s *SQLiteStore) readPattern(row interface{ Scan(...interface{}) error }) (*Pattern, error) {
+	pattern, err := scanPattern(row)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.keys.openPattern(pattern); err != nil {
+		return nil, fmt.Errorf("patrón %s: %w", pattern.Key, err)
+	}
+	return pattern, nil
+}
+
+// GetPattern obtiene un patrón por su clave
+func (s *SQLiteStore) GetPattern(patternKey string) (*Pattern, error) {
+	row := s.db.QueryRow(`SELECT `+patternColumns+` FROM patterns WHERE pattern_key = ?`, patternKey)
 
-	return map[string]interface{}{
-		"pattern":    pattern,
-		"response":   response,
-		"frequency":  frequency,
-		"confidence": confidence,
-		"last_used":  lastUsed,
-	}, nil
+	pattern, err := s.readPattern(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return pattern, err
+}
+
+// DeletePattern elimina un patrón aprendido
+func (s *SQLiteStore) DeletePattern(patternKey string) error {
+	result, err := s.db.Exec(`DELETE FROM patterns WHERE pattern_key = ?`, patternKey)
+	if err != nil {
+		return fmt.Errorf("error eliminando patrón: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetPatterns obtiene todos los patrones aprendidos
+func (s *SQLiteStore) GetPatterns() ([]*Pattern, error) {
+	rows, err := s.db.Query(`SELECT ` + patternColumns + ` FROM patterns ORDER BY pattern_key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	patterns := make([]*Pattern, 0)
+	for rows.Next() {
+		pattern, err := s.readPattern(rows)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, rows.Err()
 }
 
 // UpdateStats actualiza las estadísticas
-func (s *Storage) UpdateStats(stats map[string]interface{}) error {
+func (s *SQLiteStore) UpdateStats(stats *Stats) error {
 	query := `
 	INSERT OR REPLACE INTO stats (id, total_interactions, positive_feedback, negative_feedback, average_rating, last_updated)
 	VALUES (1, ?, ?, ?, ?, ?)
 	`
 
 	_, err := s.db.Exec(query,
-		stats["total_interactions"],
-		stats["positive_feedback"],
-		stats["negative_feedback"],
-		stats["average_rating"],
-		time.Now(),
+		stats.TotalInteractions,
+		stats.PositiveFeedback,
+		stats.NegativeFeedback,
+		stats.AverageRating,
+		formatTime(time.Now()),
 	)
 
 	return err
 }
 
+// GetStats obtiene las estadísticas
+func (s *SQLiteStore) GetStats() (*Stats, error) {
+	query := `
+	SELECT total_interactions, positive_feedback, negative_feedback, average_rating, last_updated
+	FROM stats
+	WHERE id = 1
+	`
+
+	var (
+		stats       Stats
+		lastUpdated sqlTime
+	)
+
+	err := s.db.QueryRow(query).Scan(&stats.TotalInteractions, &stats.PositiveFeedback,
+		&stats.NegativeFeedback, &stats.AverageRating, &lastUpdated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Stats{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stats.LastUpdated = lastUpdated.Time
+	return &stats, nil
+}
+
+// SaveConversation guarda una conversación y reemplaza sus turnos
+func (s *SQLiteStore) SaveConversation(conversation *Conversation) error {
+	prepareConversation(conversation)
+
+	sealed, err := s.keys.sealConversation(conversation)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT OR REPLACE INTO conversations (id, user_id, started_at, ended_at, summary)
+	VALUES (?, ?, ?, ?, ?)
+	`
+
+	if _, err := tx.Exec(query,
+		sealed.ID,
+		nullString(sealed.UserID),
+		formatTime(sealed.StartedAt),
+		formatTime(sealed.EndedAt),
+		sealed.Summary,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM conversation_turns WHERE conversation_id = ?`, conversation.ID); err != nil {
+		return err
+	}
+	if err := insertTurns(tx, sealed.ID, 0, sealed.Turns); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertTurns inserta turnos consecutivos a partir de la posición first
+func insertTurns(tx *sql.Tx, conversationID string, first int, turns []Turn) error {
+	query := `
+	INSERT INTO conversation_turns (conversation_id, seq, interaction_id, role, content, timestamp)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	for i, turn := range turns {
+		if _, err := tx.Exec(query,
+			conversationID,
+			first+i,
+			nullString(turn.InteractionID),
+			turn.Role,
+			turn.Content,
+			formatTime(turn.Timestamp),
+		); err != nil {
+			return fmt.Errorf("error guardando turno: %w", err)
+		}
+	}
+	return nil
+}
+
+// AppendTurns añade turnos al final de una conversación
+func (s *SQLiteStore) AppendTurns(conversationID string, turns ...Turn) error {
+	turns, err := s.keys.sealTurns(conversationID, turns)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		exists int
+		next   int
+	)
+	err = tx.QueryRow(`
+	SELECT COUNT(*), COALESCE((SELECT MAX(seq) + 1 FROM conversation_turns WHERE conversation_id = ?), 0)
+	FROM conversations WHERE id = ?`, conversationID, conversationID).Scan(&exists, &next)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+
+	if err := insertTurns(tx, conversationID, next, turns); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const conversationColumns = `id, user_id, started_at, ended_at, summary,
+	(SELECT COUNT(*) FROM conversation_turns t WHERE t.conversation_id = conversations.id)`
+
+func scanConversation(row interface{ Scan(...interface{}) error }) (*Conversation, error) {
+	var (
+		conversation       Conversation
+		userID, summary    sql.NullString
+		startedAt, endedAt sqlTime
+	)
+
+	if err := row.Scan(&conversation.ID, &userID, &startedAt, &endedAt, &summary, &conversation.TurnCount); err != nil {
+		return nil, err
+	}
+
+	conversation.UserID = userID.String
+	conversation.StartedAt = startedAt.Time
+	conversation.EndedAt = endedAt.Time
+	conversation.Summary = summary.String
+
+	return &conversation, nil
+}
+
+// GetConversation obtiene una conversación con sus turnos
+func (s *SQLiteStore) GetConversation(id string) (*Conversation, error) {
+	row := s.db.QueryRow(`SELECT `+conversationColumns+` FROM conversations WHERE id = ?`, id)
+
+	conversation, err := scanConversation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+	SELECT interaction_id, role, content, timestamp
+	FROM conversation_turns
+	WHERE conversation_id = ?
+	ORDER BY seq`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversation.Turns = make([]Turn, 0, conversation.TurnCount)
+	for rows.Next() {
+		var (
+			turn                         Turn
+			interactionID, role, content sql.NullString
+			timestamp                    sqlTime
+		)
+		if err := rows.Scan(&interactionID, &role, &content, &timestamp); err != nil {
+			return nil, err
+		}
+		turn.InteractionID = interactionID.String
+		turn.Role = role.String
+		turn.Content = content.String
+		turn.Timestamp = timestamp.Time
+		conversation.Turns = append(conversation.Turns, turn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.keys.openConversation(conversation); err != nil {
+		return nil, fmt.Errorf("conversación %s: %w", id, err)
+	}
+	return conversation, nil
+}
+
+// ListConversations lista las conversaciones sin sus turnos
+func (s *SQLiteStore) ListConversations(filter ConversationFilter) ([]*Conversation, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := s.db.Query(`
+	SELECT `+conversationColumns+`
+	FROM conversations
+	WHERE ? = '' OR user_id = ?
+	ORDER BY started_at DESC, id DESC
+	LIMIT ?`, filter.UserID, filter.UserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := make([]*Conversation, 0)
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.keys.openConversation(conversation); err != nil {
+			return nil, fmt.Errorf("conversación %s: %w", conversation.ID, err)
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	return conversations, rows.Err()
+}
+
+// DeleteConversation elimina una conversación y sus turnos
+func (s *SQLiteStore) DeleteConversation(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error iniciando transacción: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM conversations WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM conversation_turns WHERE conversation_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // Close cierra la conexión a la base de datos
-func (s *Storage) Close() error {
+func (s *SQLiteStore) Close() error {
 	return s.db.Close()
 }
@@ -1,214 +1,959 @@
-//go:build !cgo
-// +build !cgo
-
 package storage
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
-// Storage maneja el almacenamiento persistente usando archivos JSON (sin CGO)
-type Storage struct {
-	config       Config
-	dataDir      string
+const (
+	defaultFlushInterval    = time.Second
+	defaultCompactThreshold = 1000
+)
+
+// ErrLocked indica que otro proceso tiene abierto el almacenamiento JSON
+var ErrLocked = errors.New("el almacenamiento está en uso por otro proceso")
+
+// JSONStore maneja el almacenamiento persistente usando archivos JSON.
+// No depende de CGO y es el respaldo cuando SQLite no está disponible.
+//
+// Las interacciones se guardan en un journal append-only (interactions.jsonl)
+// que se compacta periódicamente sobre interactions.json; patrones y
+// estadísticas se vuelcan con escrituras atómicas tras un breve intervalo.
+type JSONStore struct {
+	config  Config
+	dataDir string
+	keys    *KeyRing // nil si el cifrado está deshabilitado
+	lock    *os.File // store.lock, bloqueado en exclusiva mientras esté abierto
+
+	// mu protege el estado en memoria
 	mu           sync.RWMutex
-	interactions []map[string]interface{}
-	patterns     []map[string]interface{}
-	stats        map[string]interface{}
+	interactions []*Interaction
+	index        map[string]int
+	search       *searchIndex
+	patterns     map[string]*Pattern
+	stats        *Stats
+
+	// ioMu serializa las escrituras a disco; se adquiere siempre antes que mu
+	ioMu           sync.Mutex
+	journal        *os.File
+	journalEntries int
+	journalDirty   bool
+	patternsDirty  bool
+	statsDirty     bool
+	flushTimer     *time.Timer
+	flushErr       error
+	closed         bool
+
+	// convMu serializa el acceso a los archivos de conversaciones
+	convMu sync.Mutex
+}
+
+// journalEntry es una línea del journal de interacciones
+type journalEntry struct {
+	Op          string       `json:"op"` // "put" o "delete"
+	ID          string       `json:"id"`
+	Interaction *Interaction `json:"interaction,omitempty"`
 }
 
-// NewStorage crea una nueva instancia de almacenamiento basado en archivos JSON
-func NewStorage(config Config) (*Storage, error) {
+// NewJSONStore crea una nueva instancia de almacenamiento basado en archivos JSON
+func NewJSONStore(config Config) (*JSONStore, error) {
 	// Crear directorio de datos si no existe
 	dataDir := filepath.Dir(config.Path)
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return nil, fmt.Errorf("error creando directorio de datos: %w", err)
 	}
 
-	storage := &Storage{
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	if config.CompactThreshold <= 0 {
+		config.CompactThreshold = defaultCompactThreshold
+	}
+
+	keys, err := LoadKeyRing(config.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := &JSONStore{
 		config:       config,
 		dataDir:      dataDir,
-		interactions: make([]map[string]interface{}, 0),
-		patterns:     make([]map[string]interface{}, 0),
-		stats:        make(map[string]interface{}),
+		keys:         keys,
+		interactions: make([]*Interaction, 0),
+		index:        make(map[string]int),
+		search:       newSearchIndex(),
+		patterns:     make(map[string]*Pattern),
+		stats:        &Stats{},
+	}
+
+	// Solo un proceso puede abrir los datos: dos journals y compactaciones
+	// independientes se pisarían entre sí
+	if err := storage.acquireLock(); err != nil {
+		return nil, err
+	}
+
+	// Completar una restauración interrumpida antes de leer los datos
+	if err := storage.finishRestore(); err != nil {
+		storage.lock.Close()
+		return nil, err
 	}
 
 	// Cargar datos existentes
 	if err := storage.loadData(); err != nil {
-		// Si no existe, no es error, solo inicia vacío
-		fmt.Printf("Iniciando almacenamiento nuevo en: %s\n", dataDir)
+		storage.lock.Close()
+		return nil, err
+	}
+
+	journal, err := os.OpenFile(storage.journalPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		storage.lock.Close()
+		return nil, fmt.Errorf("error abriendo journal: %w", err)
 	}
+	storage.journal = journal
 
 	return storage, nil
 }
 
-// loadData carga datos desde archivos JSON
-func (s *Storage) loadData() error {
+// acquireLock bloquea store.lock en el directorio de datos; falla con
+// ErrLocked si otro proceso (u otro JSONStore) ya lo tiene
+func (s *JSONStore) acquireLock() error {
+	lock, err := os.OpenFile(filepath.Join(s.dataDir, "store.lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("error abriendo store.lock: %w", err)
+	}
+	if err := lockFile(lock); err != nil {
+		lock.Close()
+		if errors.Is(err, ErrLocked) {
+			return fmt.Errorf("%w: %s", ErrLocked, s.dataDir)
+		}
+		return fmt.Errorf("error bloqueando store.lock: %w", err)
+	}
+	s.lock = lock
+	return nil
+}
+
+func (s *JSONStore) interactionsPath() string {
+	return filepath.Join(s.dataDir, "interactions.json")
+}
+
+func (s *JSONStore) journalPath() string {
+	return filepath.Join(s.dataDir, "interactions.jsonl")
+}
+
+func (s *JSONStore) patternsPath() string {
+	return filepath.Join(s.dataDir, "patterns.json")
+}
+
+func (s *JSONStore) statsPath() string {
+	return filepath.Join(s.dataDir, "stats.json")
+}
+
+// loadData carga la última compactación, reproduce el journal y carga patrones y estadísticas
+func (s *JSONStore) loadData() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Cargar interacciones
-	interactionsPath := filepath.Join(s.dataDir, "interactions.json")
-	if data, err := os.ReadFile(interactionsPath); err == nil {
-		json.Unmarshal(data, &s.interactions)
+	var snapshot []*Interaction
+	if err := readJSONFile(s.interactionsPath(), &snapshot); err != nil {
+		return err
+	}
+	for _, interaction := range snapshot {
+		if err := s.keys.openInteraction(interaction); err != nil {
+			return fmt.Errorf("interacción %s: %w", interaction.ID, err)
+		}
+		s.putLocked(interaction)
+	}
+
+	if err := s.replayJournal(); err != nil {
+		return err
 	}
 
 	// Cargar patrones
-	patternsPath := filepath.Join(s.dataDir, "patterns.json")
-	if data, err := os.ReadFile(patternsPath); err == nil {
-		json.Unmarshal(data, &s.patterns)
+	var patterns []*Pattern
+	if err := readJSONFile(s.patternsPath(), &patterns); err != nil {
+		return err
+	}
+	for _, pattern := range patterns {
+		if err := s.keys.openPattern(pattern); err != nil {
+			return fmt.Errorf("patrón %s: %w", pattern.Key, err)
+		}
+		s.patterns[pattern.Key] = pattern
 	}
 
 	// Cargar estadísticas
-	statsPath := filepath.Join(s.dataDir, "stats.json")
-	if data, err := os.ReadFile(statsPath); err == nil {
-		json.Unmarshal(data, &s.stats)
+	return readJSONFile(s.statsPath(), s.stats)
+}
+
+// replayJournal aplica las entradas del journal sobre las interacciones cargadas.
+// Una última línea incompleta (escritura interrumpida) se descarta y se trunca.
+func (s *JSONStore) replayJournal() error {
+	file, err := os.OpenFile(s.journalPath(), os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil
 	}
+	if err != nil {
+		return fmt.Errorf("error abriendo journal: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	line := 0
+
+	for {
+		data, err := reader.ReadBytes('\n')
+		if len(data) == 0 && err == io.EOF {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("error leyendo journal: %w", err)
+		}
+		line++
+
+		if err == io.EOF {
+			// Última línea sin salto final: escritura interrumpida por un corte
+			return file.Truncate(offset)
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(bytes.TrimSpace(data), &entry); err != nil {
+			return fmt.Errorf("journal corrupto en línea %d: %w", line, err)
+		}
+
+		switch entry.Op {
+		case "put":
+			if entry.Interaction != nil {
+				if err := s.keys.openInteraction(entry.Interaction); err != nil {
+					return fmt.Errorf("journal en línea %d: %w", line, err)
+				}
+				s.putLocked(entry.Interaction)
+			}
+		case "delete":
+			s.deleteLocked(entry.ID)
+		}
+		s.journalEntries++
+		offset += int64(len(data))
+	}
+}
 
+// readJSONFile decodifica un archivo JSON; un archivo inexistente no es error
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error leyendo %s: %w", path, err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("error decodificando %s: %w", path, err)
+	}
 	return nil
 }
 
-// saveData guarda datos en archivos JSON
-func (s *Storage) saveData() error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// putLocked inserta o reemplaza una interacción (requiere mu)
+func (s *JSONStore) putLocked(interaction *Interaction) {
+	s.search.put(interaction)
+	if idx, exists := s.index[interaction.ID]; exists {
+		s.interactions[idx] = interaction
+		return
+	}
+	s.index[interaction.ID] = len(s.interactions)
+	s.interactions = append(s.interactions, interaction)
+}
+
+// deleteLocked elimina una interacción y reconstruye el índice (requiere mu)
+func (s *JSONStore) deleteLocked(id string) bool {
+	idx, exists := s.index[id]
+	if !exists {
+		return false
+	}
+	s.interactions = append(s.interactions[:idx], s.interactions[idx+1:]...)
+	delete(s.index, id)
+	s.search.remove(id)
+	for i := idx; i < len(s.interactions); i++ {
+		s.index[s.interactions[i].ID] = i
+	}
+	return true
+}
+
+// appendJournal escribe una entrada en el journal (requiere ioMu)
+func (s *JSONStore) appendJournal(entry journalEntry) error {
+	if entry.Interaction != nil {
+		sealed, err := s.keys.sealInteraction(entry.Interaction)
+		if err != nil {
+			return err
+		}
+		entry.Interaction = sealed
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error codificando entrada del journal: %w", err)
+	}
+	data = append(data, '\n')
+
+	if _, err := s.journal.Write(data); err != nil {
+		return fmt.Errorf("error escribiendo journal: %w", err)
+	}
+	s.journalEntries++
+	s.journalDirty = true
+	return nil
+}
+
+// beginWrite adquiere ioMu y reintenta un volcado diferido fallido.
+// Si el disco sigue fallando, el error se devuelve al llamador.
+func (s *JSONStore) beginWrite() error {
+	s.ioMu.Lock()
+	if s.closed {
+		s.ioMu.Unlock()
+		return errors.New("almacenamiento cerrado")
+	}
+	if s.flushErr != nil {
+		if err := s.flushLocked(); err != nil {
+			s.ioMu.Unlock()
+			return fmt.Errorf("error en escritura diferida: %w", err)
+		}
+	}
+	return nil
+}
+
+// scheduleFlush programa un volcado diferido si no hay uno pendiente (requiere ioMu)
+func (s *JSONStore) scheduleFlush() {
+	if s.flushTimer != nil {
+		return
+	}
+	s.flushTimer = time.AfterFunc(s.config.FlushInterval, func() {
+		s.ioMu.Lock()
+		defer s.ioMu.Unlock()
+
+		s.flushTimer = nil
+		if !s.closed {
+			s.flushErr = s.flushLocked()
+		}
+	})
+}
+
+// flushLocked sincroniza el journal, compacta si procede y escribe
+// patrones y estadísticas pendientes (requiere ioMu)
+func (s *JSONStore) flushLocked() error {
+	if s.journalDirty {
+		if err := s.journal.Sync(); err != nil {
+			return fmt.Errorf("error sincronizando journal: %w", err)
+		}
+		s.journalDirty = false
+	}
+
+	if s.journalEntries >= s.config.CompactThreshold {
+		if err := s.compactLocked(); err != nil {
+			return err
+		}
+	}
+
+	if s.patternsDirty {
+		s.mu.RLock()
+		data, err := s.encodePatterns()
+		s.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(s.patternsPath(), data, 0644); err != nil {
+			return err
+		}
+		s.patternsDirty = false
+	}
 
-	// Guardar interacciones
-	interactionsPath := filepath.Join(s.dataDir, "interactions.json")
-	if data, err := json.MarshalIndent(s.interactions, "", "  "); err == nil {
-		os.WriteFile(interactionsPath, data, 0644)
+	if s.statsDirty {
+		s.mu.RLock()
+		data, err := json.MarshalIndent(s.stats, "", "  ")
+		s.mu.RUnlock()
+		if err != nil {
+			return fmt.Errorf("error codificando estadísticas: %w", err)
+		}
+		if err := writeFileAtomic(s.statsPath(), data, 0644); err != nil {
+			return err
+		}
+		s.statsDirty = false
 	}
 
-	// Guardar patrones
-	patternsPath := filepath.Join(s.dataDir, "patterns.json")
-	if data, err := json.MarshalIndent(s.patterns, "", "  "); err == nil {
-		os.WriteFile(patternsPath, data, 0644)
+	s.flushErr = nil
+	return nil
+}
+
+// compactLocked reescribe interactions.json con el estado actual y vacía el journal.
+// Si el proceso se interrumpe entre ambos pasos, reproducir el journal sobre la
+// nueva compactación es idempotente. (requiere ioMu)
+func (s *JSONStore) compactLocked() error {
+	s.mu.RLock()
+	data, err := s.encodeInteractions()
+	s.mu.RUnlock()
+	if err != nil {
+		return err
 	}
 
-	// Guardar estadísticas
-	statsPath := filepath.Join(s.dataDir, "stats.json")
-	if data, err := json.MarshalIndent(s.stats, "", "  "); err == nil {
-		os.WriteFile(statsPath, data, 0644)
+	if err := writeFileAtomic(s.interactionsPath(), data, 0644); err != nil {
+		return err
 	}
 
+	if err := s.journal.Truncate(0); err != nil {
+		return fmt.Errorf("error vaciando journal: %w", err)
+	}
+	if err := s.journal.Sync(); err != nil {
+		return fmt.Errorf("error sincronizando journal: %w", err)
+	}
+	s.journalEntries = 0
 	return nil
 }
 
+// encodeInteractions codifica las interacciones como en interactions.json,
+// cifradas o escapadas según proceda (requiere mu)
+func (s *JSONStore) encodeInteractions() ([]byte, error) {
+	interactions := make([]*Interaction, len(s.interactions))
+	for i, interaction := range s.interactions {
+		var err error
+		if interactions[i], err = s.keys.sealInteraction(interaction); err != nil {
+			return nil, fmt.Errorf("error codificando interacciones: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error codificando interacciones: %w", err)
+	}
+	return data, nil
+}
+
+// encodePatterns codifica los patrones como en patterns.json (requiere mu)
+func (s *JSONStore) encodePatterns() ([]byte, error) {
+	patterns, err := s.sealedPatterns()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(patterns, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error codificando patrones: %w", err)
+	}
+	return data, nil
+}
+
+// Flush vuelca inmediatamente todos los cambios pendientes a disco
+func (s *JSONStore) Flush() error {
+	s.ioMu.Lock()
+	defer s.ioMu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	return s.flushLocked()
+}
+
+// sortedPatterns devuelve los patrones ordenados por clave (requiere mu)
+func (s *JSONStore) sortedPatterns() []*Pattern {
+	patterns := make([]*Pattern, 0, len(s.patterns))
+	for _, pattern := range s.patterns {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return patterns[i].Key < patterns[j].Key
+	})
+	return patterns
+}
+
+// sealedPatterns devuelve los patrones ordenados y cifrados para escribirlos (requiere mu)
+func (s *JSONStore) sealedPatterns() ([]*Pattern, error) {
+	patterns := s.sortedPatterns()
+	sealed := make([]*Pattern, len(patterns))
+	for i, pattern := range patterns {
+		var err error
+		if sealed[i], err = s.keys.sealPattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return sealed, nil
+}
+
 // SaveInteraction guarda una interacción
-func (s *Storage) SaveInteraction(interaction map[string]interface{}) error {
+func (s *JSONStore) SaveInteraction(interaction *Interaction) error {
+	stored := copyInteraction(interaction)
+
+	// Normalizar el contexto igual que al leerlo del disco (números como float64)
+	if stored.Context != nil {
+		data, err := json.Marshal(stored.Context)
+		if err != nil {
+			return fmt.Errorf("error codificando contexto: %w", err)
+		}
+		stored.Context = nil
+		if err := json.Unmarshal(data, &stored.Context); err != nil {
+			return fmt.Errorf("error codificando contexto: %w", err)
+		}
+	}
+
+	if err := s.beginWrite(); err != nil {
+		return err
+	}
+	defer s.ioMu.Unlock()
+
+	if err := s.appendJournal(journalEntry{Op: "put", ID: stored.ID, Interaction: stored}); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
-	s.interactions = append(s.interactions, interaction)
+	s.putLocked(stored)
 	s.mu.Unlock()
 
-	return s.saveData()
+	s.scheduleFlush()
+	return nil
 }
 
-// GetInteractions obtiene interacciones con límite
-func (s *Storage) GetInteractions(limit int) ([]map[string]interface{}, error) {
+// GetInteraction obtiene una interacción por su ID
+func (s *JSONStore) GetInteraction(id string) (*Interaction, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if limit <= 0 || limit > len(s.interactions) {
-		limit = len(s.interactions)
+	idx, exists := s.index[id]
+	if !exists {
+		return nil, ErrNotFound
 	}
+	return copyInteraction(s.interactions[idx]), nil
+}
 
-	// Retornar las últimas 'limit' interacciones
-	start := len(s.interactions) - limit
-	if start < 0 {
-		start = 0
+// GetRecentInteractions obtiene las interacciones más recientes
+func (s *JSONStore) GetRecentInteractions(limit int) ([]*Interaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Orden estable por fecha descendente; a igual fecha, la última guardada primero
+	ordered := make([]*Interaction, len(s.interactions))
+	for i, interaction := range s.interactions {
+		ordered[len(ordered)-1-i] = interaction
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.After(ordered[j].Timestamp)
+	})
+
+	if limit <= 0 || limit > len(ordered) {
+		limit = len(ordered)
 	}
 
-	return s.interactions[start:], nil
+	result := make([]*Interaction, 0, limit)
+	for _, interaction := range ordered[:limit] {
+		result = append(result, copyInteraction(interaction))
+	}
+	return result, nil
+}
+
+// SearchInteractions busca interacciones usando el índice invertido en memoria
+func (s *JSONStore) SearchInteractions(query string, filters SearchFilters) ([]*SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	queryTerms := searchTerms(query)
+	if len(queryTerms) == 0 {
+		return copyResults(rankInteractions(s.interactions, "", filters)), nil
+	}
+
+	scores := s.search.score(queryTerms)
+	byID := make(map[string]*Interaction, len(scores))
+	for id := range scores {
+		interaction := s.interactions[s.index[id]]
+		if filters.matches(interaction) {
+			byID[id] = interaction
+		}
+	}
+
+	return copyResults(collectResults(scores, byID, queryTerms, filters.limit())), nil
+}
+
+// copyResults desacopla los resultados del estado interno
+func copyResults(results []*SearchResult) []*SearchResult {
+	for _, result := range results {
+		result.Interaction = copyInteraction(result.Interaction)
+	}
+	return results
+}
+
+// DeleteInteraction elimina una interacción y compacta el journal de inmediato
+// para que no conserve sus datos
+func (s *JSONStore) DeleteInteraction(id string) error {
+	if err := s.beginWrite(); err != nil {
+		return err
+	}
+	defer s.ioMu.Unlock()
+
+	s.mu.Lock()
+	deleted := s.deleteLocked(id)
+	s.mu.Unlock()
+	if !deleted {
+		return ErrNotFound
+	}
+	return s.compactLocked()
+}
+
+// SaveFeedback guarda la retroalimentación de una interacción
+func (s *JSONStore) SaveFeedback(interactionID string, feedback *Feedback) error {
+	if err := s.beginWrite(); err != nil {
+		return err
+	}
+	defer s.ioMu.Unlock()
+
+	s.mu.RLock()
+	idx, exists := s.index[interactionID]
+	var updated *Interaction
+	if exists {
+		updated = copyInteraction(s.interactions[idx])
+	}
+	s.mu.RUnlock()
+	if !exists {
+		return ErrNotFound
+	}
+
+	stored := *feedback
+	updated.Feedback = &stored
+
+	if err := s.appendJournal(journalEntry{Op: "put", ID: updated.ID, Interaction: updated}); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.putLocked(updated)
+	s.mu.Unlock()
+
+	s.scheduleFlush()
+	return nil
 }
 
 // SavePattern guarda un patrón aprendido
-func (s *Storage) SavePattern(pattern map[string]interface{}) error {
+func (s *JSONStore) SavePattern(pattern *Pattern) error {
+	stored := *pattern
+
+	if err := s.beginWrite(); err != nil {
+		return err
+	}
+	defer s.ioMu.Unlock()
+
+	s.mu.Lock()
+	s.patterns[stored.Key] = &stored
+	s.mu.Unlock()
+
+	s.patternsDirty = true
+	s.scheduleFlush()
+	return nil
+}
+
+// GetPattern obtiene un patrón por su clave
+func (s *JSONStore) GetPattern(key string) (*Pattern, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pattern, exists := s.patterns[key]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	result := *pattern
+	return &result, nil
+}
+
+// DeletePattern elimina un patrón y vuelca patterns.json de inmediato
+func (s *JSONStore) DeletePattern(key string) error {
+	if err := s.beginWrite(); err != nil {
+		return err
+	}
+	defer s.ioMu.Unlock()
+
 	s.mu.Lock()
-	s.patterns = append(s.patterns, pattern)
+	_, exists := s.patterns[key]
+	delete(s.patterns, key)
 	s.mu.Unlock()
+	if !exists {
+		return ErrNotFound
+	}
 
-	return s.saveData()
+	s.patternsDirty = true
+	return s.flushLocked()
 }
 
 // GetPatterns obtiene patrones aprendidos
-func (s *Storage) GetPatterns() ([]map[string]interface{}, error) {
+func (s *JSONStore) GetPatterns() ([]*Pattern, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return s.patterns, nil
+	patterns := s.sortedPatterns()
+	result := make([]*Pattern, len(patterns))
+	for i, pattern := range patterns {
+		copied := *pattern
+		result[i] = &copied
+	}
+	return result, nil
 }
 
 // UpdateStats actualiza las estadísticas
-func (s *Storage) UpdateStats(stats map[string]interface{}) error {
-	s.mu.Lock()
-	for k, v := range stats {
-		s.stats[k] = v
+func (s *JSONStore) UpdateStats(stats *Stats) error {
+	if err := s.beginWrite(); err != nil {
+		return err
 	}
-	s.stats["last_updated"] = time.Now()
+	defer s.ioMu.Unlock()
+
+	s.mu.Lock()
+	updated := *stats
+	updated.LastUpdated = time.Now()
+	s.stats = &updated
 	s.mu.Unlock()
 
-	return s.saveData()
+	s.statsDirty = true
+	s.scheduleFlush()
+	return nil
 }
 
 // GetStats obtiene las estadísticas
-func (s *Storage) GetStats() (map[string]interface{}, error) {
+func (s *JSONStore) GetStats() (*Stats, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return s.stats, nil
+	stats := *s.stats
+	return &stats, nil
+}
+
+func (s *JSONStore) conversationsDir() string {
+	return filepath.Join(s.dataDir, "conversations")
 }
 
-// SaveConversation guarda una conversación completa
-func (s *Storage) SaveConversation(conversation map[string]interface{}) error {
-	conversationsDir := filepath.Join(s.dataDir, "conversations")
-	os.MkdirAll(conversationsDir, 0755)
+// conversationPath devuelve el archivo de una conversación, rechazando IDs que salgan del directorio
+func (s *JSONStore) conversationPath(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("ID de conversación inválido: %q", id)
+	}
+	return filepath.Join(s.conversationsDir(), id+".json"), nil
+}
 
-	timestamp := time.Now().Unix()
-	filename := filepath.Join(conversationsDir, fmt.Sprintf("conversation_%d.json", timestamp))
+// conversationFile es el formato en disco; acepta también los archivos
+// antiguos conversation_<unix>.json con los mensajes en "messages"
+type conversationFile struct {
+	Conversation
+	Messages []Turn `json:"messages,omitempty"`
+}
 
-	data, err := json.MarshalIndent(conversation, "", "  ")
+// readConversation lee una conversación de disco y la descifra
+func (s *JSONStore) readConversation(path string) (*Conversation, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("error leyendo conversación: %w", err)
+	}
+
+	var file conversationFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error decodificando %s: %w", filepath.Base(path), err)
 	}
 
-	return os.WriteFile(filename, data, 0644)
+	conversation := file.Conversation
+	if conversation.ID == "" {
+		conversation.ID = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+	if len(conversation.Turns) == 0 && len(file.Messages) > 0 {
+		conversation.Turns = file.Messages
+	}
+	conversation.TurnCount = len(conversation.Turns)
+
+	if err := s.keys.openConversation(&conversation); err != nil {
+		return nil, fmt.Errorf("conversación %s: %w", conversation.ID, err)
+	}
+	return &conversation, nil
 }
 
-// Close cierra el almacenamiento (guarda datos pendientes)
-func (s *Storage) Close() error {
-	return s.saveData()
+// writeConversation guarda una conversación de forma atómica
+func (s *JSONStore) writeConversation(conversation *Conversation) error {
+	path, err := s.conversationPath(conversation.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.conversationsDir(), 0755); err != nil {
+		return fmt.Errorf("error creando directorio de conversaciones: %w", err)
+	}
+
+	data, err := s.encodeConversation(conversation)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
 }
 
-// Backup crea una copia de seguridad
-func (s *Storage) Backup() error {
-	if !s.config.BackupEnabled {
-		return nil
+// encodeConversation codifica una conversación como en su archivo, cifrada si procede
+func (s *JSONStore) encodeConversation(conversation *Conversation) ([]byte, error) {
+	sealed, err := s.keys.sealConversation(conversation)
+	if err != nil {
+		return nil, err
 	}
+	return json.MarshalIndent(sealed, "", "  ")
+}
 
-	backupDir := filepath.Join(s.dataDir, "backups")
-	os.MkdirAll(backupDir, 0755)
+// SaveConversation guarda una conversación completa en conversations/<ID>.json
+func (s *JSONStore) SaveConversation(conversation *Conversation) error {
+	prepareConversation(conversation)
 
-	timestamp := time.Now().Format("20060102_150405")
-	backupPath := filepath.Join(backupDir, fmt.Sprintf("backup_%s.json", timestamp))
+	s.convMu.Lock()
+	defer s.convMu.Unlock()
 
-	s.mu.RLock()
-	backupData := map[string]interface{}{
-		"interactions": s.interactions,
-		"patterns":     s.patterns,
-		"stats":        s.stats,
-		"timestamp":    time.Now(),
+	return s.writeConversation(conversation)
+}
+
+// AppendTurns añade turnos al final de una conversación
+func (s *JSONStore) AppendTurns(conversationID string, turns ...Turn) error {
+	s.convMu.Lock()
+	defer s.convMu.Unlock()
+
+	path, err := s.conversationPath(conversationID)
+	if err != nil {
+		return ErrNotFound
 	}
-	s.mu.RUnlock()
 
-	data, err := json.MarshalIndent(backupData, "", "  ")
+	conversation, err := s.readConversation(path)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(backupPath, data, 0644)
+	conversation.Turns = append(conversation.Turns, turns...)
+	conversation.TurnCount = len(conversation.Turns)
+	return s.writeConversation(conversation)
+}
+
+// GetConversation obtiene una conversación con sus turnos
+func (s *JSONStore) GetConversation(id string) (*Conversation, error) {
+	path, err := s.conversationPath(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	s.convMu.Lock()
+	defer s.convMu.Unlock()
+
+	conversation, err := s.readConversation(path)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.Turns == nil {
+		conversation.Turns = make([]Turn, 0)
+	}
+	return conversation, nil
+}
+
+// ListConversations lista las conversaciones sin sus turnos
+func (s *JSONStore) ListConversations(filter ConversationFilter) ([]*Conversation, error) {
+	s.convMu.Lock()
+	defer s.convMu.Unlock()
+
+	entries, err := os.ReadDir(s.conversationsDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return make([]*Conversation, 0), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo conversaciones: %w", err)
+	}
+
+	conversations := make([]*Conversation, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		conversation, err := s.readConversation(filepath.Join(s.conversationsDir(), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if !filter.matches(conversation) {
+			continue
+		}
+		conversation.Turns = nil
+		conversations = append(conversations, conversation)
+	}
+
+	sort.Slice(conversations, func(i, j int) bool {
+		if !conversations[i].StartedAt.Equal(conversations[j].StartedAt) {
+			return conversations[i].StartedAt.After(conversations[j].StartedAt)
+		}
+		return conversations[i].ID > conversations[j].ID
+	})
+
+	if filter.Limit > 0 && len(conversations) > filter.Limit {
+		conversations = conversations[:filter.Limit]
+	}
+	return conversations, nil
+}
+
+// DeleteConversation elimina el archivo de una conversación
+func (s *JSONStore) DeleteConversation(id string) error {
+	path, err := s.conversationPath(id)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	s.convMu.Lock()
+	defer s.convMu.Unlock()
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("error eliminando conversación: %w", err)
+	}
+	return syncDir(s.conversationsDir())
+}
+
+// Close vuelca los datos pendientes, compacta el journal y cierra el almacenamiento
+func (s *JSONStore) Close() error {
+	s.ioMu.Lock()
+	defer s.ioMu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+
+	err := s.flushLocked()
+	if err == nil && s.journalEntries > 0 {
+		err = s.compactLocked()
+	}
+
+	if closeErr := s.journal.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("error cerrando journal: %w", closeErr)
+	}
+	// Cerrar el archivo libera el bloqueo
+	s.lock.Close()
+	s.closed = true
+
+	return err
+}
+
+// copyInteraction copia una interacción para no compartir punteros con el llamador
+func copyInteraction(interaction *Interaction) *Interaction {
+	copied := *interaction
+	if interaction.Feedback != nil {
+		feedback := *interaction.Feedback
+		copied.Feedback = &feedback
+	}
+	if interaction.Context != nil {
+		copied.Context = make(map[string]interface{}, len(interaction.Context))
+		for k, v := range interaction.Context {
+			copied.Context[k] = v
+		}
+	}
+	return &copied
 }
@@ -0,0 +1,1122 @@
+// Package storagetest contiene la batería de conformidad que todo backend
+// de storage.Store debe superar, para garantizar que SQLite y JSON se
+// comportan igual independientemente de las etiquetas de compilación.
+//
+// Uso desde el test de un backend:
+//
+//	storagetest.Run(t, func(config storage.Config) (storage.Store, error) {
+//		return storage.NewJSONStore(config)
+//	})
+package storagetest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akosej/agent/pkg/storage"
+)
+
+// Opener abre (o reabre) un backend con la configuración dada
+type Opener func(config storage.Config) (storage.Store, error)
+
+// Run ejecuta la batería de conformidad contra el backend que abre open
+func Run(t *testing.T, open Opener) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, open Opener, config storage.Config)
+	}{
+		{"InteractionRoundTrip", testInteractionRoundTrip},
+		{"InteractionReplace", testInteractionReplace},
+		{"RecentInteractions", testRecentInteractions},
+		{"Feedback", testFeedback},
+		{"DeleteInteraction", testDeleteInteraction},
+		{"Search", testSearch},
+		{"SearchFilters", testSearchFilters},
+		{"Patterns", testPatterns},
+		{"DeletePattern", testDeletePattern},
+		{"Stats", testStats},
+		{"Conversation", testConversation},
+		{"Retention", testRetention},
+		{"DeleteUserData", testDeleteUserData},
+		{"Encryption", testEncryption},
+		{"LookalikePlaintext", testLookalikePlaintext},
+		{"Persistence", testPersistence},
+		{"BackupRotation", testBackupRotation},
+		{"Restore", testRestore},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			config := storage.Config{
+				Path:          filepath.Join(t.TempDir(), "agent.db"),
+				BackupEnabled: true,
+			}
+			tt.fn(t, open, config)
+		})
+	}
+}
+
+// mustOpen abre el backend y lo cierra al terminar el test
+func mustOpen(t *testing.T, open Opener, config storage.Config) storage.Store {
+	t.Helper()
+
+	store, err := open(config)
+	if err != nil {
+		t.Fatalf("error abriendo store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// baseTime es una fecha fija con nanosegundos para detectar pérdidas de precisión
+var baseTime = time.Date(2025, 3, 14, 9, 26, 53, 589793238, time.UTC)
+
+func sampleInteraction(id string, offset time.Duration) *storage.Interaction {
+	return &storage.Interaction{
+		ID:        id,
+		UserID:    "usuario-1",
+		Timestamp: baseTime.Add(offset),
+		UserInput: "¿Cómo configuro la VPN?",
+		Response:  "Abre el cliente y selecciona el perfil | corporativo\ncon saltos de línea",
+		Intent:    "pregunta",
+		Context:   map[string]interface{}{"canal": "texto", "turno": float64(3)},
+		Latency:   1500 * time.Millisecond,
+	}
+}
+
+func assertInteraction(t *testing.T, got, want *storage.Interaction) {
+	t.Helper()
+
+	if got.ID != want.ID || got.UserID != want.UserID || got.UserInput != want.UserInput || got.Response != want.Response || got.Intent != want.Intent {
+		t.Errorf("interacción distinta:\n got  %+v\n want %+v", got, want)
+	}
+	if got.Latency != want.Latency {
+		t.Errorf("latencia = %v, want %v", got.Latency, want.Latency)
+	}
+	if !got.Timestamp.Equal(want.Timestamp) {
+		t.Errorf("timestamp = %v, want %v", got.Timestamp, want.Timestamp)
+	}
+	if !reflect.DeepEqual(got.Context, want.Context) {
+		t.Errorf("context = %#v, want %#v", got.Context, want.Context)
+	}
+	switch {
+	case (got.Feedback == nil) != (want.Feedback == nil):
+		t.Errorf("feedback = %+v, want %+v", got.Feedback, want.Feedback)
+	case got.Feedback != nil:
+		if got.Feedback.Rating != want.Feedback.Rating || got.Feedback.Comment != want.Feedback.Comment ||
+			!got.Feedback.Timestamp.Equal(want.Feedback.Timestamp) {
+			t.Errorf("feedback = %+v, want %+v", got.Feedback, want.Feedback)
+		}
+	}
+}
+
+func testInteractionRoundTrip(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+
+	want := sampleInteraction("int_1", 0)
+	if err := store.SaveInteraction(want); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+
+	got, err := store.GetInteraction("int_1")
+	if err != nil {
+		t.Fatalf("GetInteraction: %v", err)
+	}
+	assertInteraction(t, got, want)
+
+	if _, err := store.GetInteraction("no-existe"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetInteraction(no-existe) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testInteractionReplace(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+
+	first := sampleInteraction("int_1", 0)
+	if err := store.SaveInteraction(first); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+
+	second := sampleInteraction("int_1", time.Minute)
+	second.Response = "respuesta corregida"
+	if err := store.SaveInteraction(second); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+
+	all, err := store.GetRecentInteractions(0)
+	if err != nil {
+		t.Fatalf("GetRecentInteractions: %v", err)
+	}
+	if len(all) != 1 {
+		t.Fatalf("len = %d, want 1", len(all))
+	}
+	assertInteraction(t, all[0], second)
+}
+
+func testRecentInteractions(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+
+	// Se guardan desordenadas para comprobar que se ordenan por fecha
+	for _, offset := range []int{2, 0, 3, 1} {
+		id := "int_" + string(rune('a'+offset))
+		if err := store.SaveInteraction(sampleInteraction(id, time.Duration(offset)*time.Second)); err != nil {
+			t.Fatalf("SaveInteraction: %v", err)
+		}
+	}
+
+	recent, err := store.GetRecentInteractions(2)
+	if err != nil {
+		t.Fatalf("GetRecentInteractions: %v", err)
+	}
+	if got := ids(recent); !reflect.DeepEqual(got, []string{"int_d", "int_c"}) {
+		t.Errorf("recientes = %v, want [int_d int_c]", got)
+	}
+
+	all, err := store.GetRecentInteractions(0)
+	if err != nil {
+		t.Fatalf("GetRecentInteractions: %v", err)
+	}
+	if got := ids(all); !reflect.DeepEqual(got, []string{"int_d", "int_c", "int_b", "int_a"}) {
+		t.Errorf("todas = %v, want [int_d int_c int_b int_a]", got)
+	}
+}
+
+func ids(interactions []*storage.Interaction) []string {
+	result := make([]string, len(interactions))
+	for i, interaction := range interactions {
+		result[i] = interaction.ID
+	}
+	return result
+}
+
+func testFeedback(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+
+	interaction := sampleInteraction("int_1", 0)
+	if err := store.SaveInteraction(interaction); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+
+	feedback := &storage.Feedback{Rating: 2, Comment: "incompleta", Timestamp: baseTime.Add(time.Hour)}
+	if err := store.SaveFeedback("int_1", feedback); err != nil {
+		t.Fatalf("SaveFeedback: %v", err)
+	}
+
+	got, err := store.GetInteraction("int_1")
+	if err != nil {
+		t.Fatalf("GetInteraction: %v", err)
+	}
+	interaction.Feedback = feedback
+	assertInteraction(t, got, interaction)
+
+	if err := store.SaveFeedback("no-existe", feedback); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("SaveFeedback(no-existe) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testDeleteInteraction(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+
+	for i, id := range []string{"int_1", "int_2"} {
+		interaction := sampleInteraction(id, time.Duration(i)*time.Minute)
+		interaction.UserInput = "mensaje secreto " + id
+		if err := store.SaveInteraction(interaction); err != nil {
+			t.Fatalf("SaveInteraction: %v", err)
+		}
+	}
+	if err := store.SaveFeedback("int_1", &storage.Feedback{Rating: 5, Timestamp: baseTime}); err != nil {
+		t.Fatalf("SaveFeedback: %v", err)
+	}
+
+	if err := store.DeleteInteraction("int_1"); err != nil {
+		t.Fatalf("DeleteInteraction: %v", err)
+	}
+	if _, err := store.GetInteraction("int_1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetInteraction(int_1) error = %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteInteraction("int_1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("DeleteInteraction(int_1) repetido error = %v, want ErrNotFound", err)
+	}
+
+	results, err := store.SearchInteractions("secreto", storage.SearchFilters{})
+	if err != nil {
+		t.Fatalf("SearchInteractions: %v", err)
+	}
+	if got := resultIDs(results); !reflect.DeepEqual(got, []string{"int_2"}) {
+		t.Errorf("búsqueda tras borrar = %v, want [int_2]", got)
+	}
+
+	// El borrado sobrevive a reabrir el almacenamiento
+	store.Close()
+	store = mustOpen(t, open, config)
+	recent, err := store.GetRecentInteractions(0)
+	if err != nil {
+		t.Fatalf("GetRecentInteractions: %v", err)
+	}
+	if got := ids(recent); !reflect.DeepEqual(got, []string{"int_2"}) {
+		t.Errorf("interacciones tras reabrir = %v, want [int_2]", got)
+	}
+}
+
+// searchFixture guarda un conjunto de interacciones variadas para las pruebas de búsqueda
+func searchFixture(t *testing.T, store storage.Store) {
+	t.Helper()
+
+	fixtures := []*storage.Interaction{
+		{ID: "int_vpn", UserID: "ana", Intent: "pregunta", Timestamp: baseTime,
+			UserInput: "¿Cómo configuro la VPN de la oficina?",
+			Response:  "Abre el cliente VPN y selecciona el perfil corporativo.",
+			Feedback:  &storage.Feedback{Rating: 5, Timestamp: baseTime}},
+		{ID: "int_impresora", UserID: "luis", Intent: "problema", Timestamp: baseTime.Add(time.Hour),
+			UserInput: "La impresora no imprime",
+			Response:  "Revisa que la impresora esté encendida y conectada a la red.",
+			Feedback:  &storage.Feedback{Rating: 2, Timestamp: baseTime}},
+		{ID: "int_canción", UserID: "ana", Intent: "peticion", Timestamp: baseTime.Add(2 * time.Hour),
+			UserInput: "Pon una canción tranquila",
+			Response:  "Reproduciendo música relajante."},
+		{ID: "int_vpn2", UserID: "luis", Intent: "problema", Timestamp: baseTime.Add(3 * time.Hour),
+			UserInput: "La VPN se desconecta cada hora",
+			Response:  "Actualiza el cliente VPN; la versión antigua cierra las sesiones largas."},
+	}
+	for _, interaction := range fixtures {
+		if err := store.SaveInteraction(interaction); err != nil {
+			t.Fatalf("SaveInteraction: %v", err)
+		}
+	}
+}
+
+func resultIDs(results []*storage.SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Interaction.ID
+	}
+	return ids
+}
+
+func testSearch(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+	searchFixture(t, store)
+
+	results, err := store.SearchInteractions("vpn", storage.SearchFilters{})
+	if err != nil {
+		t.Fatalf("SearchInteractions: %v", err)
+	}
+	if got := resultIDs(results); len(got) != 2 {
+		t.Fatalf("resultados = %v, want int_vpn e int_vpn2", got)
+	}
+	for _, result := range results {
+		if result.Score <= 0 {
+			t.Errorf("score de %s = %v, want > 0", result.Interaction.ID, result.Score)
+		}
+		if !strings.Contains(result.Snippet, storage.HighlightStart+"VPN"+storage.HighlightEnd) {
+			t.Errorf("fragmento sin resaltar: %q", result.Snippet)
+		}
+	}
+
+	// Insensible a mayúsculas y tildes, con coincidencia por prefijo
+	results, err = store.SearchInteractions("CANCION", storage.SearchFilters{})
+	if err != nil {
+		t.Fatalf("SearchInteractions: %v", err)
+	}
+	if got := resultIDs(results); !reflect.DeepEqual(got, []string{"int_canción"}) {
+		t.Errorf("búsqueda sin tildes = %v, want [int_canción]", got)
+	}
+
+	results, err = store.SearchInteractions("impres red", storage.SearchFilters{})
+	if err != nil {
+		t.Fatalf("SearchInteractions: %v", err)
+	}
+	if got := resultIDs(results); !reflect.DeepEqual(got, []string{"int_impresora"}) {
+		t.Errorf("búsqueda por prefijos = %v, want [int_impresora]", got)
+	}
+
+	// Todos los términos deben aparecer
+	results, err = store.SearchInteractions("vpn impresora", storage.SearchFilters{})
+	if err != nil {
+		t.Fatalf("SearchInteractions: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("búsqueda AND = %v, want vacía", resultIDs(results))
+	}
+
+	// Los cambios de texto se reflejan en el índice
+	updated := &storage.Interaction{ID: "int_canción", UserID: "ana", Intent: "peticion",
+		Timestamp: baseTime.Add(2 * time.Hour), UserInput: "Pon un podcast", Response: "Reproduciendo podcast."}
+	if err := store.SaveInteraction(updated); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+	if results, err = store.SearchInteractions("canción", storage.SearchFilters{}); err != nil || len(results) != 0 {
+		t.Errorf("búsqueda tras reemplazar = %v, %v; want vacía", resultIDs(results), err)
+	}
+	if results, err = store.SearchInteractions("podcast", storage.SearchFilters{}); err != nil || len(results) != 1 {
+		t.Errorf("búsqueda del texto nuevo = %v, %v; want [int_canción]", resultIDs(results), err)
+	}
+}
+
+func testSearchFilters(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+	searchFixture(t, store)
+
+	tests := []struct {
+		name    string
+		query   string
+		filters storage.SearchFilters
+		want    []string
+	}{
+		{"sin consulta", "", storage.SearchFilters{}, []string{"int_vpn2", "int_canción", "int_impresora", "int_vpn"}},
+		{"límite", "", storage.SearchFilters{Limit: 1}, []string{"int_vpn2"}},
+		{"usuario", "vpn", storage.SearchFilters{UserID: "ana"}, []string{"int_vpn"}},
+		{"intención", "", storage.SearchFilters{Intent: "problema"}, []string{"int_vpn2", "int_impresora"}},
+		{"fechas", "", storage.SearchFilters{Since: baseTime.Add(time.Hour), Until: baseTime.Add(3 * time.Hour)}, []string{"int_canción", "int_impresora"}},
+		{"valoración mínima", "", storage.SearchFilters{MinRating: 4}, []string{"int_vpn"}},
+		{"valoración máxima", "", storage.SearchFilters{MaxRating: 3}, []string{"int_impresora"}},
+		{"consulta y filtros", "cliente", storage.SearchFilters{Intent: "problema", Since: baseTime.Add(time.Minute)}, []string{"int_vpn2"}},
+	}
+
+	for _, tt := range tests {
+		results, err := store.SearchInteractions(tt.query, tt.filters)
+		if err != nil {
+			t.Fatalf("%s: SearchInteractions: %v", tt.name, err)
+		}
+		if got := resultIDs(results); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: resultados = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func testPatterns(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+
+	for _, key := range []string{"saludo", "pregunta"} {
+		pattern := &storage.Pattern{
+			Key:        key,
+			Pattern:    "entrada " + key,
+			Response:   "respuesta " + key,
+			Frequency:  1,
+			Confidence: 0.5,
+			LastUsed:   baseTime,
+		}
+		if err := store.SavePattern(pattern); err != nil {
+			t.Fatalf("SavePattern: %v", err)
+		}
+	}
+
+	updated := &storage.Pattern{Key: "saludo", Pattern: "hola", Response: "¡Hola!", Frequency: 7, Confidence: 0.9, LastUsed: baseTime.Add(time.Hour)}
+	if err := store.SavePattern(updated); err != nil {
+		t.Fatalf("SavePattern: %v", err)
+	}
+
+	got, err := store.GetPattern("saludo")
+	if err != nil {
+		t.Fatalf("GetPattern: %v", err)
+	}
+	if got.Pattern != updated.Pattern || got.Frequency != updated.Frequency || got.Confidence != updated.Confidence || !got.LastUsed.Equal(updated.LastUsed) {
+		t.Errorf("GetPattern = %+v, want %+v", got, updated)
+	}
+
+	if _, err := store.GetPattern("no-existe"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetPattern(no-existe) error = %v, want ErrNotFound", err)
+	}
+
+	patterns, err := store.GetPatterns()
+	if err != nil {
+		t.Fatalf("GetPatterns: %v", err)
+	}
+	var keys []string
+	for _, p := range patterns {
+		keys = append(keys, p.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"pregunta", "saludo"}) {
+		t.Errorf("claves = %v, want [pregunta saludo]", keys)
+	}
+}
+
+func testDeletePattern(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+
+	for _, key := range []string{"saludo", "pregunta"} {
+		if err := store.SavePattern(&storage.Pattern{Key: key, Pattern: "entrada " + key, Frequency: 1, LastUsed: baseTime}); err != nil {
+			t.Fatalf("SavePattern: %v", err)
+		}
+	}
+
+	if err := store.DeletePattern("saludo"); err != nil {
+		t.Fatalf("DeletePattern: %v", err)
+	}
+	if err := store.DeletePattern("saludo"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("DeletePattern(saludo) repetido error = %v, want ErrNotFound", err)
+	}
+
+	store.Close()
+	store = mustOpen(t, open, config)
+	if _, err := store.GetPattern("saludo"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetPattern(saludo) tras reabrir error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetPattern("pregunta"); err != nil {
+		t.Errorf("GetPattern(pregunta): %v", err)
+	}
+}
+
+func testStats(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+
+	empty, err := store.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if *empty != (storage.Stats{}) {
+		t.Errorf("stats iniciales = %+v, want vacías", empty)
+	}
+
+	before := time.Now().Add(-time.Second)
+	want := &storage.Stats{TotalInteractions: 10, PositiveFeedback: 4, NegativeFeedback: 1, AverageRating: 3.75}
+	if err := store.UpdateStats(want); err != nil {
+		t.Fatalf("UpdateStats: %v", err)
+	}
+
+	got, err := store.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if got.TotalInteractions != 10 || got.PositiveFeedback != 4 || got.NegativeFeedback != 1 || got.AverageRating != 3.75 {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+	if got.LastUpdated.Before(before) {
+		t.Errorf("LastUpdated = %v, want posterior a %v", got.LastUpdated, before)
+	}
+}
+
+func testConversation(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+
+	conversation := &storage.Conversation{
+		UserID: "ana",
+		Turns: []storage.Turn{
+			{InteractionID: "int_1", Role: "user", Content: "hola", Timestamp: baseTime},
+			{InteractionID: "int_1", Role: "assistant", Content: "¡Hola! ¿En qué te ayudo?", Timestamp: baseTime.Add(time.Second)},
+		},
+	}
+	if err := store.SaveConversation(conversation); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+	if conversation.ID == "" || conversation.StartedAt.IsZero() {
+		t.Fatalf("SaveConversation no asignó ID ni fecha: %+v", conversation)
+	}
+
+	// Dos conversaciones iniciadas en el mismo instante no colisionan
+	other := &storage.Conversation{UserID: "luis", StartedAt: conversation.StartedAt}
+	if err := store.SaveConversation(other); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+	if other.ID == conversation.ID {
+		t.Fatalf("IDs repetidos: %s", other.ID)
+	}
+
+	more := []storage.Turn{
+		{InteractionID: "int_2", Role: "user", Content: "¿qué hora es?", Timestamp: baseTime.Add(time.Minute)},
+		{InteractionID: "int_2", Role: "assistant", Content: "Son las diez.", Timestamp: baseTime.Add(time.Minute + time.Second)},
+	}
+	if err := store.AppendTurns(conversation.ID, more...); err != nil {
+		t.Fatalf("AppendTurns: %v", err)
+	}
+	if err := store.AppendTurns("no-existe", more...); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("AppendTurns(no-existe) error = %v, want ErrNotFound", err)
+	}
+
+	got, err := store.GetConversation(conversation.ID)
+	if err != nil {
+		t.Fatalf("GetConversation: %v", err)
+	}
+	want := append(append([]storage.Turn{}, conversation.Turns...), more...)
+	if len(got.Turns) != len(want) || got.TurnCount != len(want) {
+		t.Fatalf("turnos = %d (TurnCount %d), want %d", len(got.Turns), got.TurnCount, len(want))
+	}
+	for i := range want {
+		if got.Turns[i].InteractionID != want[i].InteractionID || got.Turns[i].Role != want[i].Role ||
+			got.Turns[i].Content != want[i].Content || !got.Turns[i].Timestamp.Equal(want[i].Timestamp) {
+			t.Errorf("turno %d = %+v, want %+v", i, got.Turns[i], want[i])
+		}
+	}
+	if got.UserID != "ana" || !got.StartedAt.Equal(conversation.StartedAt) || !got.Active() {
+		t.Errorf("conversación = %+v", got)
+	}
+
+	// Cerrar con resumen y reanudar
+	got.Summary = "Saludo y consulta de la hora"
+	got.EndedAt = baseTime.Add(time.Hour)
+	if err := store.SaveConversation(got); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+	resumed, err := storage.ResumeConversation(store, conversation.ID)
+	if err != nil {
+		t.Fatalf("ResumeConversation: %v", err)
+	}
+	if !resumed.Active() || resumed.Summary != got.Summary || len(resumed.Turns) != len(want) {
+		t.Errorf("conversación reanudada = %+v", resumed)
+	}
+
+	list, err := store.ListConversations(storage.ConversationFilter{UserID: "ana"})
+	if err != nil {
+		t.Fatalf("ListConversations: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != conversation.ID || list[0].TurnCount != len(want) || list[0].Turns != nil {
+		t.Errorf("ListConversations(ana) = %+v", list)
+	}
+	if list, err = store.ListConversations(storage.ConversationFilter{}); err != nil || len(list) != 2 {
+		t.Errorf("ListConversations() = %d conversaciones, %v; want 2", len(list), err)
+	}
+	if list, err = store.ListConversations(storage.ConversationFilter{Limit: 1}); err != nil || len(list) != 1 {
+		t.Errorf("ListConversations(Limit 1) = %d conversaciones, %v; want 1", len(list), err)
+	}
+
+	if err := store.DeleteConversation(conversation.ID); err != nil {
+		t.Fatalf("DeleteConversation: %v", err)
+	}
+	if _, err := store.GetConversation(conversation.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetConversation tras borrar error = %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteConversation(conversation.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("DeleteConversation repetido error = %v, want ErrNotFound", err)
+	}
+}
+
+func testRetention(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+
+	now := time.Now()
+	fixtures := []struct {
+		id, intent string
+		age        time.Duration
+	}{
+		{"int_viejo", "pregunta", 40 * 24 * time.Hour},
+		{"int_saludo", "saludo", 3 * 24 * time.Hour},
+		{"int_queja", "queja", 90 * 24 * time.Hour},
+		{"int_reciente", "pregunta", time.Hour},
+		{"int_ultimo", "pregunta", time.Minute},
+	}
+	for _, f := range fixtures {
+		interaction := &storage.Interaction{ID: f.id, Intent: f.intent, Timestamp: now.Add(-f.age), UserInput: f.id}
+		if err := store.SaveInteraction(interaction); err != nil {
+			t.Fatalf("SaveInteraction: %v", err)
+		}
+	}
+
+	old := &storage.Conversation{StartedAt: now.Add(-60 * 24 * time.Hour)}
+	active := &storage.Conversation{StartedAt: now.Add(-60 * 24 * time.Hour),
+		Turns: []storage.Turn{{Role: "user", Content: "sigo aquí", Timestamp: now.Add(-time.Hour)}}}
+	for _, conversation := range []*storage.Conversation{old, active} {
+		if err := store.SaveConversation(conversation); err != nil {
+			t.Fatalf("SaveConversation: %v", err)
+		}
+	}
+
+	policy := storage.RetentionPolicy{
+		MaxAge:       30 * 24 * time.Hour,
+		IntentMaxAge: map[string]time.Duration{"saludo": 24 * time.Hour, "queja": 0},
+	}
+	report, err := store.ApplyRetention(policy)
+	if err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+	if report.Interactions != 2 || report.Conversations != 1 {
+		t.Errorf("informe = %+v, want 2 interacciones y 1 conversación", report)
+	}
+
+	all, err := store.GetRecentInteractions(0)
+	if err != nil {
+		t.Fatalf("GetRecentInteractions: %v", err)
+	}
+	if got := ids(all); !reflect.DeepEqual(got, []string{"int_ultimo", "int_reciente", "int_queja"}) {
+		t.Errorf("tras la retención por antigüedad = %v", got)
+	}
+
+	if _, err := store.GetConversation(old.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("conversación antigua: error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetConversation(active.ID); err != nil {
+		t.Errorf("conversación con actividad reciente: %v", err)
+	}
+
+	report, err = store.ApplyRetention(storage.RetentionPolicy{MaxInteractions: 2})
+	if err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+	if report.Interactions != 1 {
+		t.Errorf("informe por número = %+v, want 1 interacción", report)
+	}
+	if all, err = store.GetRecentInteractions(0); err != nil || !reflect.DeepEqual(ids(all), []string{"int_ultimo", "int_reciente"}) {
+		t.Errorf("tras la retención por número = %v, %v", ids(all), err)
+	}
+}
+
+func testDeleteUserData(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+	searchFixture(t, store)
+
+	for _, pattern := range []*storage.Pattern{
+		{Key: "ana:vpn", UserID: "ana", Pattern: "vpn"},
+		{Key: "global", Pattern: "hola"},
+	} {
+		if err := store.SavePattern(pattern); err != nil {
+			t.Fatalf("SavePattern: %v", err)
+		}
+	}
+	for _, user := range []string{"ana", "luis"} {
+		conversation := &storage.Conversation{UserID: user, Turns: []storage.Turn{
+			{Role: "user", Content: "hola"}, {Role: "assistant", Content: "¡Hola!"},
+		}}
+		if err := store.SaveConversation(conversation); err != nil {
+			t.Fatalf("SaveConversation: %v", err)
+		}
+	}
+
+	// Una copia previa también debe quedar limpia
+	backupPath, err := store.Backup()
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	if _, err := store.DeleteUserData(""); err == nil {
+		t.Errorf("DeleteUserData(\"\") no devolvió error")
+	}
+
+	report, err := store.DeleteUserData("ana")
+	if err != nil {
+		t.Fatalf("DeleteUserData: %v", err)
+	}
+	if report.Interactions != 2 || report.Feedback != 1 || report.Conversations != 1 || report.Turns != 2 || report.Patterns != 1 {
+		t.Errorf("informe = %+v", report)
+	}
+	if len(report.Backups) != 1 || report.Backups[0].Path != backupPath || report.Backups[0].Interactions != 2 {
+		t.Errorf("copias depuradas = %+v, want %s con 2 interacciones", report.Backups, backupPath)
+	}
+
+	results, err := store.SearchInteractions("", storage.SearchFilters{UserID: "ana"})
+	if err != nil || len(results) != 0 {
+		t.Errorf("interacciones de ana tras borrar = %v, %v", resultIDs(results), err)
+	}
+	if results, err = store.SearchInteractions("vpn", storage.SearchFilters{}); err != nil || !reflect.DeepEqual(resultIDs(results), []string{"int_vpn2"}) {
+		t.Errorf("búsqueda tras borrar = %v, %v; want [int_vpn2]", resultIDs(results), err)
+	}
+	if _, err := store.GetPattern("ana:vpn"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("patrón de ana: error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetPattern("global"); err != nil {
+		t.Errorf("patrón global: %v", err)
+	}
+	list, err := store.ListConversations(storage.ConversationFilter{})
+	if err != nil || len(list) != 1 || list[0].UserID != "luis" {
+		t.Errorf("conversaciones tras borrar = %+v, %v", list, err)
+	}
+
+	// Repetir no encuentra nada más
+	report, err = store.DeleteUserData("ana")
+	if err != nil {
+		t.Fatalf("DeleteUserData: %v", err)
+	}
+	if report.Interactions != 0 || report.Backups[0].Interactions != 0 {
+		t.Errorf("segundo borrado = %+v", report)
+	}
+}
+
+// assertNoPlaintext falla si algún archivo bajo dir contiene secret
+func assertNoPlaintext(t *testing.T, dir, secret string) {
+	t.Helper()
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("%s contiene texto en claro", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error recorriendo %s: %v", dir, err)
+	}
+}
+
+func testEncryption(t *testing.T, open Opener, config storage.Config) {
+	const env = "STORAGETEST_ENCRYPTION_KEY"
+	oldKey, _ := storage.GenerateKey()
+	newKey, _ := storage.GenerateKey()
+	secret := "contraseña-del-wifi-1234"
+	dir := filepath.Dir(config.Path)
+
+	t.Setenv(env, oldKey)
+	config.Encryption = storage.EncryptionConfig{Enabled: true, KeyEnv: env}
+
+	store, err := open(config)
+	if err != nil {
+		t.Fatalf("error abriendo store: %v", err)
+	}
+	want := sampleInteraction("int_1", 0)
+	want.UserInput = "¿Cuál es la " + secret + "?"
+	want.Context = map[string]interface{}{"nota": secret}
+	if err := store.SaveInteraction(want); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+	feedback := &storage.Feedback{Rating: 4, Comment: secret, Timestamp: baseTime}
+	if err := store.SaveFeedback("int_1", feedback); err != nil {
+		t.Fatalf("SaveFeedback: %v", err)
+	}
+	want.Feedback = feedback
+	if err := store.SavePattern(&storage.Pattern{Key: "wifi", Pattern: secret, Response: secret}); err != nil {
+		t.Fatalf("SavePattern: %v", err)
+	}
+	conversation := &storage.Conversation{Summary: secret, Turns: []storage.Turn{{Role: "user", Content: secret}}}
+	if err := store.SaveConversation(conversation); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+
+	got, err := store.GetInteraction("int_1")
+	if err != nil {
+		t.Fatalf("GetInteraction: %v", err)
+	}
+	assertInteraction(t, got, want)
+
+	results, err := store.SearchInteractions("wifi", storage.SearchFilters{})
+	if err != nil || len(results) != 1 {
+		t.Errorf("búsqueda con cifrado = %d resultados, %v; want 1", len(results), err)
+	}
+
+	if _, err := store.Backup(); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	assertNoPlaintext(t, dir, secret)
+
+	// Sin clave los datos cifrados no se pueden leer
+	plain := config
+	plain.Encryption = storage.EncryptionConfig{}
+	if store, err := open(plain); err == nil {
+		if _, err := store.GetInteraction("int_1"); !errors.Is(err, storage.ErrEncrypted) {
+			t.Errorf("GetInteraction sin clave error = %v, want ErrEncrypted", err)
+		}
+		store.Close()
+	} else if !errors.Is(err, storage.ErrEncrypted) {
+		t.Errorf("abrir sin clave error = %v, want ErrEncrypted", err)
+	}
+
+	// Rotación: la clave nueva primero, recifrar y retirar la antigua
+	t.Setenv(env, newKey+","+oldKey)
+	store, err = open(config)
+	if err != nil {
+		t.Fatalf("error abriendo store con dos claves: %v", err)
+	}
+	report, err := store.Reencrypt()
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if report.Interactions != 1 || report.Patterns != 1 || report.Conversations != 1 || report.Backups != 1 {
+		t.Errorf("informe de recifrado = %+v", report)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	t.Setenv(env, newKey)
+	store = mustOpen(t, open, config)
+	if got, err = store.GetInteraction("int_1"); err != nil {
+		t.Fatalf("GetInteraction con la clave nueva: %v", err)
+	}
+	assertInteraction(t, got, want)
+	if pattern, err := store.GetPattern("wifi"); err != nil || pattern.Pattern != secret {
+		t.Errorf("GetPattern con la clave nueva = %+v, %v", pattern, err)
+	}
+	if got, err := store.GetConversation(conversation.ID); err != nil || got.Summary != secret || got.Turns[0].Content != secret {
+		t.Errorf("GetConversation con la clave nueva = %+v, %v", got, err)
+	}
+
+	// Las copias recifradas siguen siendo legibles con la clave nueva
+	if _, err := store.DeleteUserData("usuario-1"); err != nil {
+		t.Errorf("DeleteUserData sobre copias recifradas: %v", err)
+	}
+	assertNoPlaintext(t, dir, secret)
+}
+
+// Los textos en claro que parecen campos cifrados se leen tal cual, con y
+// sin cifrado
+func testLookalikePlaintext(t *testing.T, open Opener, config storage.Config) {
+	const env = "STORAGETEST_ENCRYPTION_KEY"
+	key, _ := storage.GenerateKey()
+	t.Setenv(env, key)
+
+	for _, encrypted := range []bool{false, true} {
+		config := config
+		name := "plain"
+		if encrypted {
+			name = "encrypted"
+			config.Path = filepath.Join(t.TempDir(), "agent.db")
+			config.Encryption = storage.EncryptionConfig{Enabled: true, KeyEnv: env}
+		}
+		t.Run(name, func(t *testing.T) {
+			store, err := open(config)
+			if err != nil {
+				t.Fatalf("error abriendo store: %v", err)
+			}
+			want := sampleInteraction("int_1", 0)
+			want.UserInput = "enc:v1:hola"
+			want.Response = "enc:plain:adiós"
+			if err := store.SaveInteraction(want); err != nil {
+				t.Fatalf("SaveInteraction: %v", err)
+			}
+			feedback := &storage.Feedback{Rating: 5, Comment: "enc:v2:k:AAAA", Timestamp: baseTime}
+			if err := store.SaveFeedback("int_1", feedback); err != nil {
+				t.Fatalf("SaveFeedback: %v", err)
+			}
+			want.Feedback = feedback
+			if err := store.SavePattern(&storage.Pattern{Key: "enc", Pattern: "enc:v1:hola", Response: "enc:"}); err != nil {
+				t.Fatalf("SavePattern: %v", err)
+			}
+			conversation := &storage.Conversation{Summary: "enc:v1:resumen", Turns: []storage.Turn{{Role: "user", Content: "enc:v1:hola"}}}
+			if err := store.SaveConversation(conversation); err != nil {
+				t.Fatalf("SaveConversation: %v", err)
+			}
+			if err := store.AppendTurns(conversation.ID, storage.Turn{Role: "assistant", Content: "enc:v1:adiós"}); err != nil {
+				t.Fatalf("AppendTurns: %v", err)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			store = mustOpen(t, open, config)
+			got, err := store.GetInteraction("int_1")
+			if err != nil {
+				t.Fatalf("GetInteraction: %v", err)
+			}
+			assertInteraction(t, got, want)
+			if pattern, err := store.GetPattern("enc"); err != nil || pattern.Pattern != "enc:v1:hola" || pattern.Response != "enc:" {
+				t.Errorf("GetPattern = %+v, %v", pattern, err)
+			}
+			saved, err := store.GetConversation(conversation.ID)
+			if err != nil {
+				t.Fatalf("GetConversation: %v", err)
+			}
+			if saved.Summary != "enc:v1:resumen" || len(saved.Turns) != 2 ||
+				saved.Turns[0].Content != "enc:v1:hola" || saved.Turns[1].Content != "enc:v1:adiós" {
+				t.Errorf("GetConversation = %+v", saved)
+			}
+		})
+	}
+}
+
+func testPersistence(t *testing.T, open Opener, config storage.Config) {
+	store, err := open(config)
+	if err != nil {
+		t.Fatalf("error abriendo store: %v", err)
+	}
+
+	want := sampleInteraction("int_1", 0)
+	if err := store.SaveInteraction(want); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+	if err := store.SavePattern(&storage.Pattern{Key: "pregunta", Pattern: "vpn", Frequency: 2, LastUsed: baseTime}); err != nil {
+		t.Fatalf("SavePattern: %v", err)
+	}
+	if err := store.UpdateStats(&storage.Stats{TotalInteractions: 1}); err != nil {
+		t.Fatalf("UpdateStats: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened := mustOpen(t, open, config)
+
+	got, err := reopened.GetInteraction("int_1")
+	if err != nil {
+		t.Fatalf("GetInteraction tras reabrir: %v", err)
+	}
+	assertInteraction(t, got, want)
+
+	if _, err := reopened.GetPattern("pregunta"); err != nil {
+		t.Errorf("GetPattern tras reabrir: %v", err)
+	}
+
+	stats, err := reopened.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats tras reabrir: %v", err)
+	}
+	if stats.TotalInteractions != 1 {
+		t.Errorf("TotalInteractions tras reabrir = %d, want 1", stats.TotalInteractions)
+	}
+
+	path, err := reopened.Backup()
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if path == "" {
+		t.Errorf("Backup no devolvió ruta con copias habilitadas")
+	}
+}
+
+func testBackupRotation(t *testing.T, open Opener, config storage.Config) {
+	config.BackupGenerations = 2
+	store := mustOpen(t, open, config)
+
+	if err := store.SaveInteraction(sampleInteraction("int_1", 0)); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+
+	var paths []string
+	for i := 0; i < 4; i++ {
+		path, err := store.Backup()
+		if err != nil {
+			t.Fatalf("Backup %d: %v", i, err)
+		}
+		paths = append(paths, path)
+	}
+
+	for i, path := range paths {
+		_, err := os.Stat(path)
+		if i < 2 && !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("la copia antigua %s no se eliminó", filepath.Base(path))
+		}
+		_, sumErr := os.Stat(path + ".sha256")
+		if i < 2 && !errors.Is(sumErr, os.ErrNotExist) {
+			t.Errorf("la suma de %s no se eliminó", filepath.Base(path))
+		}
+		if i >= 2 {
+			if err := storage.VerifyBackup(path); err != nil {
+				t.Errorf("VerifyBackup(%s): %v", filepath.Base(path), err)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(filepath.Dir(config.Path), "backups"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("archivos en backups = %d, want 4 (2 copias y 2 sumas)", len(entries))
+	}
+
+	// Alterar la copia debe detectarse
+	latest := paths[len(paths)-1]
+	file, err := os.OpenFile(latest, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	file.Write([]byte("x"))
+	file.Close()
+	if err := storage.VerifyBackup(latest); !errors.Is(err, storage.ErrChecksumMismatch) {
+		t.Errorf("VerifyBackup de copia alterada = %v, want ErrChecksumMismatch", err)
+	}
+	if err := store.Restore(latest); !errors.Is(err, storage.ErrChecksumMismatch) {
+		t.Errorf("Restore de copia alterada = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func testRestore(t *testing.T, open Opener, config storage.Config) {
+	store := mustOpen(t, open, config)
+
+	want := sampleInteraction("int_1", 0)
+	if err := store.SaveInteraction(want); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+	if err := store.SavePattern(&storage.Pattern{Key: "pregunta", Pattern: "vpn", Frequency: 2, LastUsed: baseTime}); err != nil {
+		t.Fatalf("SavePattern: %v", err)
+	}
+	if err := store.UpdateStats(&storage.Stats{TotalInteractions: 1}); err != nil {
+		t.Fatalf("UpdateStats: %v", err)
+	}
+	conversation := &storage.Conversation{
+		ID:        "conv_1",
+		UserID:    "usuario-1",
+		StartedAt: baseTime,
+		Turns:     []storage.Turn{{Role: "user", Content: "hola", Timestamp: baseTime}},
+	}
+	if err := store.SaveConversation(conversation); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+
+	path, err := store.Backup()
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	// Cambios posteriores a la copia
+	if err := store.SaveInteraction(sampleInteraction("int_2", time.Minute)); err != nil {
+		t.Fatalf("SaveInteraction: %v", err)
+	}
+	if err := store.SavePattern(&storage.Pattern{Key: "otro", Pattern: "correo", LastUsed: baseTime}); err != nil {
+		t.Fatalf("SavePattern: %v", err)
+	}
+	if err := store.UpdateStats(&storage.Stats{TotalInteractions: 2}); err != nil {
+		t.Fatalf("UpdateStats: %v", err)
+	}
+	if err := store.DeleteConversation("conv_1"); err != nil {
+		t.Fatalf("DeleteConversation: %v", err)
+	}
+	if err := store.SaveConversation(&storage.Conversation{ID: "conv_2", StartedAt: baseTime}); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+
+	// Una copia corrupta no debe tocar los datos
+	corrupt := filepath.Join(t.TempDir(), filepath.Base(path))
+	if err := os.WriteFile(corrupt, []byte("no es una copia"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := store.Restore(corrupt); err == nil {
+		t.Errorf("Restore de copia corrupta no devolvió error")
+	}
+	if _, err := store.GetInteraction("int_2"); err != nil {
+		t.Errorf("GetInteraction tras restauración fallida: %v", err)
+	}
+
+	if err := store.Restore(path); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	got, err := store.GetInteraction("int_1")
+	if err != nil {
+		t.Fatalf("GetInteraction tras Restore: %v", err)
+	}
+	assertInteraction(t, got, want)
+	if _, err := store.GetInteraction("int_2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetInteraction(int_2) tras Restore = %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetPattern("otro"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetPattern(otro) tras Restore = %v, want ErrNotFound", err)
+	}
+	stats, err := store.GetStats()
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.TotalInteractions != 1 {
+		t.Errorf("TotalInteractions tras Restore = %d, want 1", stats.TotalInteractions)
+	}
+
+	restored, err := store.GetConversation("conv_1")
+	if err != nil {
+		t.Fatalf("GetConversation tras Restore: %v", err)
+	}
+	if len(restored.Turns) != 1 || restored.Turns[0].Content != "hola" {
+		t.Errorf("turnos restaurados = %+v", restored.Turns)
+	}
+	if _, err := store.GetConversation("conv_2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetConversation(conv_2) tras Restore = %v, want ErrNotFound", err)
+	}
+
+	results, err := store.SearchInteractions("vpn", storage.SearchFilters{})
+	if err != nil {
+		t.Fatalf("SearchInteractions tras Restore: %v", err)
+	}
+	if ids := resultIDs(results); len(ids) != 1 || ids[0] != "int_1" {
+		t.Errorf("búsqueda tras Restore = %v, want [int_1]", ids)
+	}
+}
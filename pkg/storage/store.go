@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound se devuelve cuando el registro solicitado no existe
+var ErrNotFound = errors.New("registro no encontrado")
+
+// Store es la interfaz común de todos los backends de almacenamiento.
+// Todas las implementaciones deben comportarse igual; el paquete
+// storagetest contiene la batería de conformidad que lo verifica.
+type Store interface {
+	// SaveInteraction guarda una interacción nueva o reemplaza una existente con el mismo ID
+	SaveInteraction(interaction *Interaction) error
+	// GetInteraction obtiene una interacción por su ID (ErrNotFound si no existe)
+	GetInteraction(id string) (*Interaction, error)
+	// GetRecentInteractions obtiene las interacciones más recientes, de la más nueva a la más antigua.
+	// Un límite <= 0 devuelve todas.
+	GetRecentInteractions(limit int) ([]*Interaction, error)
+	// SaveFeedback asocia retroalimentación a una interacción existente
+	SaveFeedback(interactionID string, feedback *Feedback) error
+	// SearchInteractions busca texto en entradas y respuestas, ordenando por relevancia.
+	// Con una consulta vacía devuelve las más recientes que cumplan los filtros.
+	SearchInteractions(query string, filters SearchFilters) ([]*SearchResult, error)
+	// DeleteInteraction elimina una interacción y su feedback (ErrNotFound si no existe).
+	// Los turnos de conversación y las copias de seguridad no se modifican.
+	DeleteInteraction(id string) error
+
+	// SavePattern guarda o reemplaza el patrón identificado por pattern.Key
+	SavePattern(pattern *Pattern) error
+	// GetPattern obtiene un patrón por su clave (ErrNotFound si no existe)
+	GetPattern(key string) (*Pattern, error)
+	// GetPatterns obtiene todos los patrones ordenados por clave
+	GetPatterns() ([]*Pattern, error)
+	// DeletePattern elimina el patrón identificado por key (ErrNotFound si no existe)
+	DeletePattern(key string) error
+
+	// UpdateStats reemplaza las estadísticas y actualiza LastUpdated
+	UpdateStats(stats *Stats) error
+	// GetStats obtiene las estadísticas (vacías si nunca se guardaron)
+	GetStats() (*Stats, error)
+
+	// SaveConversation guarda o reemplaza una conversación con todos sus turnos.
+	// Asigna ID y fecha de inicio si faltan.
+	SaveConversation(conversation *Conversation) error
+	// AppendTurns añade turnos al final de una conversación existente (ErrNotFound si no existe)
+	AppendTurns(conversationID string, turns ...Turn) error
+	// GetConversation obtiene una conversación con sus turnos en orden (ErrNotFound si no existe)
+	GetConversation(id string) (*Conversation, error)
+	// ListConversations lista las conversaciones sin sus turnos, de la más reciente a la más antigua
+	ListConversations(filter ConversationFilter) ([]*Conversation, error)
+	// DeleteConversation elimina una conversación y sus turnos (ErrNotFound si no existe).
+	// Las interacciones enlazadas no se eliminan.
+	DeleteConversation(id string) error
+
+	// ApplyRetention elimina las interacciones y conversaciones que exceden la política
+	ApplyRetention(policy RetentionPolicy) (*RetentionReport, error)
+	// DeleteUserData elimina interacciones, feedback, conversaciones y patrones de un usuario,
+	// también de las copias de seguridad existentes
+	DeleteUserData(userID string) (*DeletionReport, error)
+
+	// Reencrypt vuelve a cifrar los datos y las copias de seguridad con la clave actual,
+	// incluidos los guardados antes de habilitar el cifrado
+	Reencrypt() (*ReencryptReport, error)
+
+	// Backup crea una copia de seguridad verificada con su suma SHA-256, elimina
+	// las generaciones sobrantes y devuelve su ruta (cadena vacía si las copias
+	// están deshabilitadas)
+	Backup() (string, error)
+	// Restore valida una copia de seguridad y reemplaza con ella todos los datos
+	// de forma atómica
+	Restore(path string) error
+
+	// Close libera los recursos y guarda los datos pendientes
+	Close() error
+}
+
+// Interaction representa una interacción almacenada
+type Interaction struct {
+	ID        string                 `json:"id"`
+	UserID    string                 `json:"user_id,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	UserInput string                 `json:"user_input"`
+	Response  string                 `json:"response"`
+	Intent    string                 `json:"intent"`
+	Context   map[string]interface{} `json:"context,omitempty"`
+	Feedback  *Feedback              `json:"feedback,omitempty"`
+	Latency   time.Duration          `json:"latency,omitempty"` // Tiempo hasta la respuesta; SQLite lo guarda en milisegundos
+}
+
+// Feedback representa la retroalimentación de una interacción
+type Feedback struct {
+	Rating    int       `json:"rating"`
+	Comment   string    `json:"comment,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Pattern representa un patrón aprendido
+type Pattern struct {
+	Key        string    `json:"key"`
+	UserID     string    `json:"user_id,omitempty"` // Vacío si el patrón es global
+	Pattern    string    `json:"pattern"`
+	Response   string    `json:"response"`
+	Frequency  int       `json:"frequency"`
+	Confidence float64   `json:"confidence"`
+	LastUsed   time.Time `json:"last_used"`
+}
+
+// Stats contiene las estadísticas agregadas del agente
+type Stats struct {
+	TotalInteractions int       `json:"total_interactions"`
+	PositiveFeedback  int       `json:"positive_feedback"`
+	NegativeFeedback  int       `json:"negative_feedback"`
+	AverageRating     float64   `json:"average_rating"`
+	LastUpdated       time.Time `json:"last_updated"`
+}
+
+// Conversation es una sesión de conversación con su historial de turnos
+type Conversation struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"` // Cero mientras sigue abierta
+	Summary   string    `json:"summary,omitempty"`
+	TurnCount int       `json:"turn_count"`
+	Turns     []Turn    `json:"turns,omitempty"`
+}
+
+// Turn es un mensaje de una conversación. Los turnos de usuario y asistente
+// generados por una misma interacción comparten InteractionID.
+type Turn struct {
+	InteractionID string    `json:"interaction_id,omitempty"`
+	Role          string    `json:"role"` // "user", "assistant" o "system"
+	Content       string    `json:"content"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// ConversationFilter restringe los resultados de ListConversations
+type ConversationFilter struct {
+	UserID string
+	Limit  int // <= 0 devuelve todas
+}
+
+// Active indica si la conversación sigue abierta
+func (c *Conversation) Active() bool {
+	return c.EndedAt.IsZero()
+}
+
+// matches indica si una conversación cumple el filtro
+func (f ConversationFilter) matches(conversation *Conversation) bool {
+	return f.UserID == "" || conversation.UserID == f.UserID
+}
+
+// prepareConversation asigna ID y fecha de inicio si faltan y actualiza el número de turnos
+func prepareConversation(conversation *Conversation) {
+	if conversation.StartedAt.IsZero() {
+		conversation.StartedAt = time.Now()
+	}
+	if conversation.ID == "" {
+		conversation.ID = newConversationID(conversation.StartedAt)
+	}
+	conversation.TurnCount = len(conversation.Turns)
+}
+
+// newConversationID genera un ID ordenable por fecha con un sufijo aleatorio,
+// de modo que dos conversaciones iniciadas a la vez no colisionan
+func newConversationID(startedAt time.Time) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("conv_%d", startedAt.UnixNano())
+	}
+	return fmt.Sprintf("conv_%s_%s", startedAt.UTC().Format("20060102T150405"), hex.EncodeToString(suffix))
+}
+
+// ResumeConversation reabre una conversación guardada y la devuelve con su historial
+// para continuarla con AppendTurns
+func ResumeConversation(store Store, id string) (*Conversation, error) {
+	conversation, err := store.GetConversation(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conversation.Active() {
+		conversation.EndedAt = time.Time{}
+		if err := store.SaveConversation(conversation); err != nil {
+			return nil, fmt.Errorf("error reabriendo conversación %s: %w", id, err)
+		}
+	}
+
+	return conversation, nil
+}
+
+// NewStorage abre el backend indicado por config.Type:
+//   - "sqlite": SQLite con el driver CGO, o el de Go puro si se compiló sin CGO
+//   - "sqlite-purego": SQLite con el driver en Go puro
+//   - "json": archivos JSON
+func NewStorage(config Config) (Store, error) {
+	switch config.Type {
+	case "", "sqlite":
+		return NewSQLiteStore(config)
+	case "sqlite-purego":
+		return NewPureGoSQLiteStore(config)
+	case "json":
+		return NewJSONStore(config)
+	default:
+		return nil, fmt.Errorf("tipo de almacenamiento no soportado: %s", config.Type)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build cgo
+// +build cgo
+
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/akosej/agent/pkg/storage"
+	"github.com/akosej/agent/pkg/storage/storagetest"
+)
+
+// Con CGO, NewSQLiteStore usa el driver de mattn/go-sqlite3
+func TestSQLiteStore(t *testing.T) {
+	storagetest.Run(t, func(config storage.Config) (storage.Store, error) {
+		return storage.NewSQLiteStore(config)
+	})
+}
@@ -0,0 +1,46 @@
+package storage_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/akosej/agent/pkg/storage"
+	"github.com/akosej/agent/pkg/storage/storagetest"
+)
+
+func TestJSONStore(t *testing.T) {
+	storagetest.Run(t, func(config storage.Config) (storage.Store, error) {
+		return storage.NewJSONStore(config)
+	})
+}
+
+// Un segundo JSONStore sobre los mismos datos se rechaza hasta cerrar el primero
+func TestJSONStoreLock(t *testing.T) {
+	config := storage.Config{Path: filepath.Join(t.TempDir(), "data.json")}
+	first, err := storage.NewJSONStore(config)
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	if second, err := storage.NewJSONStore(config); !errors.Is(err, storage.ErrLocked) {
+		if second != nil {
+			second.Close()
+		}
+		t.Fatalf("segundo NewJSONStore: %v, want ErrLocked", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := storage.NewJSONStore(config)
+	if err != nil {
+		t.Fatalf("NewJSONStore tras Close: %v", err)
+	}
+	reopened.Close()
+}
+
+func TestPureGoSQLiteStore(t *testing.T) {
+	storagetest.Run(t, func(config storage.Config) (storage.Store, error) {
+		return storage.NewPureGoSQLiteStore(config)
+	})
+}
@@ -0,0 +1,308 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpanData es un span terminado tal como lo recibe el exportador
+type SpanData struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string // Vacío en el span raíz
+	Name         string
+	Kind         Kind
+	Start        time.Time
+	End          time.Time
+	Attributes   []Attribute
+	Error        string // Vacío si la etapa terminó bien
+}
+
+// Exporter envía los spans terminados a su destino
+type Exporter interface {
+	ExportSpan(span SpanData) error
+	// Close envía lo pendiente y libera el destino
+	Close() error
+}
+
+// kindNames son los nombres de Kind en la salida JSON por líneas
+var kindNames = map[Kind]string{KindInternal: "internal", KindServer: "server", KindClient: "client"}
+
+// writerExporter escribe cada span como una línea JSON
+type writerExporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer // nil si el destino no se cierra (stdout)
+}
+
+// NewWriterExporter escribe cada span como una línea JSON en w, p. ej. os.Stdout
+func NewWriterExporter(w io.Writer) Exporter {
+	return &writerExporter{w: w}
+}
+
+// NewFileExporter añade cada span como una línea JSON al archivo path,
+// creando el directorio si hace falta
+func NewFileExporter(path string) (Exporter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creando directorio de trazas: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo archivo de trazas: %w", err)
+	}
+	return &writerExporter{w: file, closer: file}, nil
+}
+
+func (e *writerExporter) ExportSpan(span SpanData) error {
+	line := struct {
+		TraceID      string                 `json:"trace_id"`
+		SpanID       string                 `json:"span_id"`
+		ParentSpanID string                 `json:"parent_span_id,omitempty"`
+		Name         string                 `json:"name"`
+		Kind         string                 `json:"kind"`
+		Start        time.Time              `json:"start"`
+		DurationMs   float64                `json:"duration_ms"`
+		Attributes   map[string]interface{} `json:"attributes,omitempty"`
+		Error        string                 `json:"error,omitempty"`
+	}{
+		TraceID:      span.TraceID,
+		SpanID:       span.SpanID,
+		ParentSpanID: span.ParentSpanID,
+		Name:         span.Name,
+		Kind:         kindNames[span.Kind],
+		Start:        span.Start,
+		DurationMs:   float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+		Error:        span.Error,
+	}
+	if len(span.Attributes) > 0 {
+		line.Attributes = make(map[string]interface{}, len(span.Attributes))
+		for _, attr := range span.Attributes {
+			line.Attributes[attr.Key] = attr.Value
+		}
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("error codificando span: %w", err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(data, '\n'))
+	return err
+}
+
+func (e *writerExporter) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// Valores por defecto del exportador OTLP
+const (
+	otlpBatchSize     = 256
+	otlpQueueSize     = 2048
+	otlpFlushInterval = 5 * time.Second
+	otlpTimeout       = 10 * time.Second
+)
+
+// otlpExporter agrupa los spans y los envía a un colector con OTLP/HTTP en JSON
+type otlpExporter struct {
+	url     string
+	service string
+	client  *http.Client
+	onError func(err error)
+
+	mu     sync.RWMutex // closed y el cierre de queue
+	closed bool
+	queue  chan SpanData
+	done   chan struct{}
+}
+
+// NewOTLPExporter envía los spans en lotes a endpoint + /v1/traces con
+// OTLP/HTTP en JSON (puerto 4318 de Jaeger, Tempo o el OpenTelemetry Collector).
+// Si la cola se llena, los spans nuevos se descartan en lugar de frenar al
+// agente. onError recibe los fallos de envío; puede ser nil.
+func NewOTLPExporter(endpoint, serviceName string, onError func(err error)) Exporter {
+	if serviceName == "" {
+		serviceName = "agent"
+	}
+	e := &otlpExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: serviceName,
+		client:  &http.Client{Timeout: otlpTimeout},
+		onError: onError,
+		queue:   make(chan SpanData, otlpQueueSize),
+		done:    make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *otlpExporter) ExportSpan(span SpanData) error {
+	// Los spans que terminan durante o después de Close se descartan: enviar
+	// a la cola cerrada provocaría un pánico
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return fmt.Errorf("exportador cerrado: span %s descartado", span.Name)
+	}
+
+	select {
+	case e.queue <- span:
+		return nil
+	default:
+		return fmt.Errorf("cola de trazas llena: span %s descartado", span.Name)
+	}
+}
+
+// Close envía los spans pendientes y espera a que termine el envío
+func (e *otlpExporter) Close() error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.mu.Unlock()
+	<-e.done
+	return nil
+}
+
+// run agrupa los spans de la cola y los envía por lotes o cada otlpFlushInterval
+func (e *otlpExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, otlpBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil && e.onError != nil {
+			e.onError(err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case span, ok := <-e.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= otlpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// send envía un lote con el formato ExportTraceServiceRequest de OTLP en JSON
+func (e *otlpExporter) send(batch []SpanData) error {
+	type keyValue struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+	type status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	type span struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              Kind       `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            status     `json:"status"`
+	}
+
+	spans := make([]span, len(batch))
+	for i, data := range batch {
+		spans[i] = span{
+			TraceID:           data.TraceID,
+			SpanID:            data.SpanID,
+			ParentSpanID:      data.ParentSpanID,
+			Name:              data.Name,
+			Kind:              data.Kind,
+			StartTimeUnixNano: strconv.FormatInt(data.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(data.End.UnixNano(), 10),
+			Status:            status{Code: 1}, // OK
+		}
+		if data.Error != "" {
+			spans[i].Status = status{Code: 2, Message: data.Error}
+		}
+		for _, attr := range data.Attributes {
+			spans[i].Attributes = append(spans[i].Attributes, keyValue{Key: attr.Key, Value: otlpValue(attr.Value)})
+		}
+	}
+
+	request := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []keyValue{{Key: "service.name", Value: otlpValue(e.service)}},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "github.com/akosej/agent"},
+				"spans": spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("error codificando trazas: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), otlpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creando request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error enviando %d spans a %s: %w", len(batch), e.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("el colector de trazas respondió con error %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// otlpValue convierte un valor en AnyValue de OTLP; los enteros van como texto
+// porque así codifica OTLP/JSON los int64
+func otlpValue(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+}
@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Los spans que terminan mientras se cierra el exportador se descartan sin pánico
+func TestOTLPExportAfterClose(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer collector.Close()
+	exporter := NewOTLPExporter(collector.URL, "test", nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 200; n++ {
+				exporter.ExportSpan(SpanData{Name: "etapa"})
+			}
+		}()
+	}
+	if err := exporter.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	wg.Wait()
+
+	if err := exporter.ExportSpan(SpanData{Name: "tarde"}); err == nil {
+		t.Error("ExportSpan tras Close no devolvió error")
+	}
+	if err := exporter.Close(); err != nil {
+		t.Errorf("segundo Close: %v", err)
+	}
+}
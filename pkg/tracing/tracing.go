@@ -0,0 +1,280 @@
+// Package tracing registra trazas de las etapas de una interacción (voz,
+// intención, generación, aprendizaje, almacenamiento) para saber cuál es la lenta.
+//
+// Cada etapa abre un Span con Tracer.Start a partir del ctx que recibe, de modo
+// que las etapas anidadas quedan enlazadas con su padre. Los spans terminados se
+// envían a un Exporter: JSON por líneas (stdout o archivo) u OTLP/HTTP para
+// Jaeger, Tempo o un OpenTelemetry Collector. Un *Tracer nil no registra nada,
+// así que los componentes pueden usarlo sin comprobarlo.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akosej/agent/pkg/logger"
+)
+
+// Kind es el tipo de span según OpenTelemetry
+type Kind int
+
+const (
+	KindInternal Kind = 1 // Trabajo dentro del proceso
+	KindServer   Kind = 2 // Atención de una petición entrante
+	KindClient   Kind = 3 // Llamada a un servicio externo (Ollama, whisper)
+)
+
+// Attribute es un par clave-valor de un span; el valor es string, int64,
+// float64 o bool
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String crea un atributo de texto
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int crea un atributo entero
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+// Int64 crea un atributo entero de 64 bits
+func Int64(key string, value int64) Attribute { return Attribute{Key: key, Value: value} }
+
+// Float crea un atributo decimal
+func Float(key string, value float64) Attribute { return Attribute{Key: key, Value: value} }
+
+// Bool crea un atributo booleano
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// Config contiene la configuración del tracer
+type Config struct {
+	ServiceName string          // service.name en OTLP (por defecto "agent")
+	SampleRatio float64         // Fracción de trazas exportadas; fuera de (0, 1] exporta todas
+	Exporter    Exporter        // Destino de los spans terminados
+	OnError     func(err error) // Errores de exportación; nil los descarta
+}
+
+// Tracer crea spans y los entrega al exportador al terminar
+type Tracer struct {
+	config    Config
+	threshold uint64 // Las trazas con ID por debajo se exportan
+}
+
+// NewTracer crea un tracer con el exportador indicado
+func NewTracer(config Config) (*Tracer, error) {
+	if config.Exporter == nil {
+		return nil, fmt.Errorf("el tracer necesita un exportador")
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = "agent"
+	}
+	t := &Tracer{config: config, threshold: ^uint64(0)}
+	if config.SampleRatio > 0 && config.SampleRatio < 1 {
+		t.threshold = uint64(config.SampleRatio * float64(^uint64(0)))
+	}
+	return t, nil
+}
+
+// Close exporta los spans pendientes y cierra el exportador
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	return t.config.Exporter.Close()
+}
+
+// Span es una etapa con su duración y atributos. Sus métodos son seguros para
+// uso concurrente y no hacen nada sobre un *Span nil.
+type Span struct {
+	tracer   *Tracer
+	name     string
+	kind     Kind
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	sampled  bool
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs []Attribute
+	err   string
+	ended bool
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// remoteParent es el span de otro proceso recibido en la cabecera traceparent
+type remoteParent struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+}
+
+// Start abre un span hijo del que haya en ctx (o de la traza remota extraída
+// con Extract) y devuelve un contexto que lo contiene, con su trace ID para los
+// logs. Hay que cerrarlo con End.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind, attrs ...Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{tracer: t, name: name, kind: kind, start: time.Now(), attrs: attrs}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+		span.sampled = parent.sampled
+	} else if remote, ok := ctx.Value(remoteKey{}).(remoteParent); ok {
+		span.traceID = remote.traceID
+		span.parentID = remote.spanID
+		span.sampled = remote.sampled
+	} else {
+		randomBytes(span.traceID[:])
+		span.sampled = binary.BigEndian.Uint64(span.traceID[8:]) <= t.threshold
+	}
+	randomBytes(span.spanID[:])
+
+	ctx = context.WithValue(ctx, spanKey{}, span)
+	if logger.TraceID(ctx) != span.TraceID() {
+		ctx = logger.WithTraceID(ctx, span.TraceID())
+	}
+	return ctx, span
+}
+
+// randomBytes rellena b con bytes aleatorios
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		// Sin aleatoriedad del sistema, el reloj evita IDs repetidos entre spans
+		binary.BigEndian.PutUint64(b[len(b)-8:], uint64(time.Now().UnixNano()))
+	}
+}
+
+// SpanFromContext devuelve el span activo en ctx, o nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// TraceID devuelve el ID de la traza en hexadecimal ("" si el span es nil)
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// SetAttributes añade o reemplaza atributos
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attr := range attrs {
+		replaced := false
+		for i := range s.attrs {
+			if s.attrs[i].Key == attr.Key {
+				s.attrs[i] = attr
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			s.attrs = append(s.attrs, attr)
+		}
+	}
+}
+
+// RecordError marca el span como fallido si err no es nil
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End cierra el span y lo entrega al exportador si la traza se muestrea.
+// Las llamadas posteriores no hacen nada.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		TraceID:    hex.EncodeToString(s.traceID[:]),
+		SpanID:     hex.EncodeToString(s.spanID[:]),
+		Name:       s.name,
+		Kind:       s.kind,
+		Start:      s.start,
+		End:        time.Now(),
+		Attributes: append([]Attribute(nil), s.attrs...),
+		Error:      s.err,
+	}
+	if s.parentID != ([8]byte{}) {
+		data.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	s.mu.Unlock()
+
+	if !s.sampled {
+		return
+	}
+	if err := s.tracer.config.Exporter.ExportSpan(data); err != nil && s.tracer.config.OnError != nil {
+		s.tracer.config.OnError(err)
+	}
+}
+
+// traceparentHeader es la cabecera de W3C Trace Context
+const traceparentHeader = "traceparent"
+
+// Extract devuelve un contexto con la traza remota de la cabecera traceparent,
+// para que los spans de esta petición continúen la traza del cliente. Una
+// cabecera ausente o inválida devuelve ctx sin cambios.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	parts := strings.Split(strings.TrimSpace(header.Get(traceparentHeader)), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ctx
+	}
+
+	var remote remoteParent
+	if _, err := hex.Decode(remote.traceID[:], []byte(parts[1])); err != nil || remote.traceID == ([16]byte{}) {
+		return ctx
+	}
+	if _, err := hex.Decode(remote.spanID[:], []byte(parts[2])); err != nil || remote.spanID == ([8]byte{}) {
+		return ctx
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return ctx
+	}
+	remote.sampled = flags[0]&1 == 1
+	return context.WithValue(ctx, remoteKey{}, remote)
+}
+
+// Inject añade la cabecera traceparent del span activo en ctx a una petición
+// saliente, para que el servicio llamado pueda continuar la traza
+func Inject(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	flags := "00"
+	if span.sampled {
+		flags = "01"
+	}
+	header.Set(traceparentHeader, fmt.Sprintf("00-%s-%s-%s",
+		hex.EncodeToString(span.traceID[:]), hex.EncodeToString(span.spanID[:]), flags))
+}
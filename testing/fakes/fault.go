@@ -0,0 +1,106 @@
+// Package fakes contiene servidores deterministas que sustituyen a Ollama y a
+// la API de Whisper, y un whisper.cpp falso, para probar nlp, speech y el
+// agente completo sin modelos ni red.
+//
+// Uso desde un test:
+//
+//	ollama := fakes.NewOllama()
+//	defer ollama.Close()
+//	ollama.On(fakes.Match{System: "intenciones"}, fakes.FormatIntent("saludo", 0.9, nil))
+//	ollama.Reply("¡Hola! ¿En qué te ayudo?")
+//
+//	processor := nlp.NewProcessor(ollama.URL, nlp.Config{Model: "llama3.2:3b"})
+package fakes
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault es un fallo inyectado en las peticiones a una ruta
+type Fault struct {
+	Status    int           // Código de error a devolver (500 si solo hay Body)
+	Body      string        // Cuerpo de la respuesta de error
+	Delay     time.Duration // Espera antes de responder; se corta si el cliente cancela
+	Malformed bool          // Responde 200 con JSON inválido
+	CutAfter  int           // En stream, cierra la conexión tras este número de fragmentos sin "done"
+	Times     int           // Peticiones afectadas; 0 afecta a todas hasta ClearFaults
+}
+
+// faults guarda los fallos pendientes por ruta
+type faults struct {
+	mu     sync.Mutex
+	byPath map[string][]*Fault
+}
+
+// add registra un fallo para la ruta ("" para todas)
+func (f *faults) add(path string, fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.byPath == nil {
+		f.byPath = make(map[string][]*Fault)
+	}
+	f.byPath[path] = append(f.byPath[path], &fault)
+}
+
+// clear elimina todos los fallos
+func (f *faults) clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.byPath = nil
+}
+
+// next devuelve el fallo que toca a una petición a path, o nil, y descuenta
+// los de Times limitado
+func (f *faults) next(path string) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range []string{path, ""} {
+		pending := f.byPath[key]
+		if len(pending) == 0 {
+			continue
+		}
+		fault := *pending[0]
+		if pending[0].Times > 0 {
+			pending[0].Times--
+			if pending[0].Times == 0 {
+				f.byPath[key] = pending[1:]
+			}
+		}
+		return &fault
+	}
+	return nil
+}
+
+// apply aplica la espera y el error del fallo. Devuelve true si ya respondió
+// y el handler no debe seguir.
+func (fault *Fault) apply(w http.ResponseWriter, r *http.Request) bool {
+	if fault == nil {
+		return false
+	}
+	if fault.Delay > 0 {
+		timer := time.NewTimer(fault.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if fault.Status != 0 || fault.Body != "" {
+		status := fault.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, strings.TrimSpace(fault.Body), status)
+		return true
+	}
+	if fault.Malformed {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message": {"content": `))
+		return true
+	}
+	return false
+}
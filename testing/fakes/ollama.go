@@ -0,0 +1,484 @@
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefaultModel es el modelo instalado en un Ollama falso recién creado
+const DefaultModel = "llama3.2:3b"
+
+const (
+	evalDuration   = 500 * time.Millisecond // Fija para que los tokens por segundo sean deterministas
+	embedDimension = 64                     // Dimensión de los vectores de Embedding
+)
+
+// Message es un mensaje de /api/chat
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request es una petición recibida por el Ollama falso
+type Request struct {
+	Path     string
+	Model    string
+	Messages []Message              // /api/chat
+	Stream   bool                   // /api/chat y /api/pull
+	Options  map[string]interface{} // /api/chat
+	Input    []string               // /api/embed
+	Header   http.Header
+}
+
+// System devuelve el contenido del primer mensaje de sistema
+func (r Request) System() string {
+	for _, message := range r.Messages {
+		if message.Role == "system" {
+			return message.Content
+		}
+	}
+	return ""
+}
+
+// User devuelve el contenido del último mensaje del usuario
+func (r Request) User() string {
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == "user" {
+			return r.Messages[i].Content
+		}
+	}
+	return ""
+}
+
+// Match selecciona peticiones de chat por subcadenas (sin distinguir
+// mayúsculas); los campos vacíos aceptan cualquier valor
+type Match struct {
+	Model  string
+	System string // Mensaje de sistema, p. ej. "intenciones" para DetectIntent
+	User   string // Último mensaje del usuario
+}
+
+// matches indica si la petición cumple todas las condiciones
+func (m Match) matches(r *Request) bool {
+	return contains(r.Model, m.Model) && contains(r.System(), m.System) && contains(r.User(), m.User)
+}
+
+// contains compara sin distinguir mayúsculas; substr vacío siempre coincide
+func contains(s, substr string) bool {
+	return substr == "" || strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// rule es una respuesta programada con On
+type rule struct {
+	match Match
+	reply func(r Request) string
+}
+
+// Ollama es un servidor que imita la API de Ollama (/api/chat, /api/embed,
+// /api/tags y /api/pull) con respuestas programadas. Las respuestas se eligen
+// en este orden: la cola de Script, la primera regla de On que coincide y la
+// respuesta por defecto de Reply. Es seguro para uso concurrente.
+type Ollama struct {
+	URL string // URL base para nlp.NewProcessor
+
+	server *httptest.Server
+	faults faults
+
+	mu           sync.Mutex
+	models       []string
+	script       []string
+	rules        []rule
+	defaultReply string
+	requests     []Request
+}
+
+// NewOllama arranca un Ollama falso con los modelos indicados instalados
+// (DefaultModel si no se indica ninguno). Los modelos no instalados responden
+// 404 como Ollama hasta que se descargan con /api/pull o AddModel.
+func NewOllama(models ...string) *Ollama {
+	if len(models) == 0 {
+		models = []string{DefaultModel}
+	}
+	o := &Ollama{
+		models:       append([]string(nil), models...),
+		defaultReply: "Respuesta de prueba.",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/chat", o.handleChat)
+	mux.HandleFunc("/api/embed", o.handleEmbed)
+	mux.HandleFunc("/api/tags", o.handleTags)
+	mux.HandleFunc("/api/pull", o.handlePull)
+	o.server = httptest.NewServer(mux)
+	o.URL = o.server.URL
+	return o
+}
+
+// Close detiene el servidor
+func (o *Ollama) Close() {
+	o.server.Close()
+}
+
+// Reply fija la respuesta de chat cuando no hay guion ni regla que coincida
+func (o *Ollama) Reply(content string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.defaultReply = content
+}
+
+// On responde content a las peticiones de chat que cumplen match. Las reglas
+// se evalúan en el orden en que se añadieron.
+func (o *Ollama) On(match Match, content string) {
+	o.OnFunc(match, func(Request) string { return content })
+}
+
+// OnFunc es como On pero calcula la respuesta a partir de la petición
+func (o *Ollama) OnFunc(match Match, reply func(r Request) string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.rules = append(o.rules, rule{match: match, reply: reply})
+}
+
+// Script encola respuestas que se devuelven una por petición de chat, en
+// orden, antes de consultar las reglas
+func (o *Ollama) Script(contents ...string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.script = append(o.script, contents...)
+}
+
+// Fail inyecta un fallo en las peticiones a path ("/api/chat", "/api/embed",
+// "/api/tags", "/api/pull" o "" para todas)
+func (o *Ollama) Fail(path string, fault Fault) {
+	o.faults.add(path, fault)
+}
+
+// ClearFaults elimina los fallos inyectados
+func (o *Ollama) ClearFaults() {
+	o.faults.clear()
+}
+
+// AddModel instala un modelo, como si se hubiera descargado
+func (o *Ollama) AddModel(model string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.hasModel(model) {
+		o.models = append(o.models, model)
+	}
+}
+
+// Requests devuelve una copia de las peticiones recibidas, en orden
+func (o *Ollama) Requests() []Request {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Request(nil), o.requests...)
+}
+
+// Count devuelve cuántas peticiones se recibieron en path
+func (o *Ollama) Count(path string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for _, r := range o.requests {
+		if r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// FormatIntent devuelve la respuesta que DetectIntent espera del modelo
+func FormatIntent(name string, confidence float64, entities map[string]string) string {
+	var pairs []string
+	for key, value := range entities {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return fmt.Sprintf("INTENCIÓN: %s\nCONFIANZA: %.2f\nENTIDADES: %s", name, confidence, strings.Join(pairs, ", "))
+}
+
+// FormatJudgement devuelve la respuesta que JudgeResponse espera del modelo
+func FormatJudgement(score int, reason string) string {
+	return fmt.Sprintf("PUNTUACIÓN: %d\nRAZÓN: %s", score, reason)
+}
+
+// Embedding es el vector que el Ollama falso devuelve para text: una bolsa de
+// palabras con hashing, normalizada, de modo que textos con palabras en común
+// tienen similitud coseno alta y un mismo texto siempre da el mismo vector
+func Embedding(text string) []float64 {
+	vector := make([]float64, embedDimension)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		vector[h.Sum32()%embedDimension]++
+	}
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+	return vector
+}
+
+// record guarda la petición
+func (o *Ollama) record(r Request) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.requests = append(o.requests, r)
+}
+
+// hasModel indica si el modelo está instalado; "modelo" equivale a "modelo:latest".
+// Requiere o.mu.
+func (o *Ollama) hasModel(model string) bool {
+	for _, installed := range o.models {
+		if installed == model || installed == model+":latest" || installed+":latest" == model {
+			return true
+		}
+	}
+	return false
+}
+
+// checkModel responde 404 como Ollama si el modelo no está instalado
+func (o *Ollama) checkModel(w http.ResponseWriter, model string) bool {
+	o.mu.Lock()
+	installed := o.hasModel(model)
+	o.mu.Unlock()
+	if !installed {
+		writeJSON(w, http.StatusNotFound, map[string]string{
+			"error": fmt.Sprintf("model %q not found, try pulling it first", model),
+		})
+	}
+	return installed
+}
+
+// reply elige la respuesta de chat para la petición
+func (o *Ollama) reply(r Request) string {
+	o.mu.Lock()
+	if len(o.script) > 0 {
+		content := o.script[0]
+		o.script = o.script[1:]
+		o.mu.Unlock()
+		return content
+	}
+	for _, rule := range o.rules {
+		if rule.match.matches(&r) {
+			o.mu.Unlock()
+			return rule.reply(r)
+		}
+	}
+	content := o.defaultReply
+	o.mu.Unlock()
+	return content
+}
+
+// handleChat atiende /api/chat con o sin stream
+func (o *Ollama) handleChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Model    string                 `json:"model"`
+		Messages []Message              `json:"messages"`
+		Stream   *bool                  `json:"stream"`
+		Options  map[string]interface{} `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// Ollama hace stream salvo que se pida "stream": false
+	stream := body.Stream == nil || *body.Stream
+	request := Request{Path: r.URL.Path, Model: body.Model, Messages: body.Messages, Stream: stream, Options: body.Options, Header: r.Header.Clone()}
+	o.record(request)
+
+	fault := o.faults.next(r.URL.Path)
+	if fault.apply(w, r) || !o.checkModel(w, body.Model) {
+		return
+	}
+
+	content := o.reply(request)
+	promptTokens := 0
+	for _, message := range body.Messages {
+		promptTokens += len(strings.Fields(message.Content))
+	}
+	final := map[string]interface{}{
+		"model":             body.Model,
+		"created_at":        time.Now().UTC().Format(time.RFC3339Nano),
+		"message":           Message{Role: "assistant", Content: ""},
+		"done":              true,
+		"done_reason":       "stop",
+		"prompt_eval_count": promptTokens,
+		"eval_duration":     evalDuration.Nanoseconds(),
+	}
+
+	if !stream {
+		final["message"] = Message{Role: "assistant", Content: content}
+		final["eval_count"] = len(tokens(content))
+		writeJSON(w, http.StatusOK, final)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	chunks := tokens(content)
+	for i, token := range chunks {
+		if fault != nil && fault.CutAfter > 0 && i == fault.CutAfter {
+			return
+		}
+		encoder.Encode(map[string]interface{}{
+			"model":      body.Model,
+			"created_at": time.Now().UTC().Format(time.RFC3339Nano),
+			"message":    Message{Role: "assistant", Content: token},
+			"done":       false,
+		})
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if r.Context().Err() != nil {
+			return
+		}
+	}
+	if fault != nil && fault.CutAfter > 0 {
+		return
+	}
+	final["eval_count"] = len(chunks)
+	encoder.Encode(final)
+}
+
+// handleEmbed atiende /api/embed con los vectores de Embedding
+func (o *Ollama) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// input puede ser un texto o una lista de textos
+	var input []string
+	if err := json.Unmarshal(body.Input, &input); err != nil {
+		var single string
+		if err := json.Unmarshal(body.Input, &single); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid input"})
+			return
+		}
+		input = []string{single}
+	}
+	o.record(Request{Path: r.URL.Path, Model: body.Model, Input: input, Header: r.Header.Clone()})
+
+	if o.faults.next(r.URL.Path).apply(w, r) || !o.checkModel(w, body.Model) {
+		return
+	}
+	embeddings := make([][]float64, len(input))
+	for i, text := range input {
+		embeddings[i] = Embedding(text)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"model": body.Model, "embeddings": embeddings})
+}
+
+// handleTags atiende /api/tags con los modelos instalados
+func (o *Ollama) handleTags(w http.ResponseWriter, r *http.Request) {
+	o.record(Request{Path: r.URL.Path, Header: r.Header.Clone()})
+	if o.faults.next(r.URL.Path).apply(w, r) {
+		return
+	}
+	o.mu.Lock()
+	models := make([]map[string]interface{}, 0, len(o.models))
+	for _, name := range o.models {
+		models = append(models, map[string]interface{}{
+			"name":        name,
+			"model":       name,
+			"size":        2_000_000_000,
+			"modified_at": "2024-01-01T00:00:00Z",
+		})
+	}
+	o.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"models": models})
+}
+
+// handlePull atiende /api/pull: informa del progreso en tres pasos e instala el modelo
+func (o *Ollama) handlePull(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Model  string `json:"model"`
+		Name   string `json:"name"`
+		Stream *bool  `json:"stream"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if body.Model == "" {
+		body.Model = body.Name
+	}
+	stream := body.Stream == nil || *body.Stream
+	o.record(Request{Path: r.URL.Path, Model: body.Model, Stream: stream, Header: r.Header.Clone()})
+
+	fault := o.faults.next(r.URL.Path)
+	if fault.apply(w, r) {
+		return
+	}
+	const total = 1000
+	progress := []map[string]interface{}{
+		{"status": "pulling manifest"},
+		{"status": "pulling " + body.Model, "digest": "sha256:fake", "total": total, "completed": total / 2},
+		{"status": "pulling " + body.Model, "digest": "sha256:fake", "total": total, "completed": total},
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	for i, p := range progress {
+		if fault != nil && fault.CutAfter > 0 && i == fault.CutAfter {
+			return
+		}
+		if stream {
+			encoder.Encode(p)
+		}
+	}
+	o.AddModel(body.Model)
+	encoder.Encode(map[string]interface{}{"status": "success"})
+}
+
+// tokens divide content en fragmentos de stream: cada palabra con el espacio que la sigue
+func tokens(content string) []string {
+	var chunks []string
+	start := 0
+	for i := 1; i <= len(content); i++ {
+		if i == len(content) || (content[i-1] == ' ' && content[i] != ' ') {
+			chunks = append(chunks, content[start:i])
+			start = i
+		}
+	}
+	return chunks
+}
+
+// writeJSON responde con status y el valor codificado
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
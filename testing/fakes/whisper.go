@@ -0,0 +1,181 @@
+package fakes
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Segment es un tramo de una transcripción, en segundos
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// Transcription es la respuesta de /transcribe. Si Segments está vacío se
+// genera un segmento por frase, de un segundo cada uno.
+type Transcription struct {
+	Text     string    `json:"text"`
+	Language string    `json:"language,omitempty"`
+	Segments []Segment `json:"segments,omitempty"`
+}
+
+// withSegments devuelve la transcripción con sus segmentos calculados
+func (t Transcription) withSegments() Transcription {
+	if len(t.Segments) > 0 || strings.TrimSpace(t.Text) == "" {
+		return t
+	}
+	for i, sentence := range sentences(t.Text) {
+		t.Segments = append(t.Segments, Segment{Start: float64(i), End: float64(i + 1), Text: sentence})
+	}
+	return t
+}
+
+// Upload es un archivo recibido por la API de Whisper falsa
+type Upload struct {
+	Filename string
+	Language string
+	Size     int
+	Header   http.Header
+}
+
+// WhisperAPI es un servidor que imita una API local de Whisper: POST
+// /transcribe con el audio en el campo "file" y GET /health. Las
+// transcripciones se eligen por nombre de archivo con On o, si no, la de Reply.
+type WhisperAPI struct {
+	URL string // URL base para speech.NewTranscriberWithAPI
+
+	server *httptest.Server
+	faults faults
+
+	mu           sync.Mutex
+	byFile       map[string]Transcription
+	defaultReply Transcription
+	uploads      []Upload
+}
+
+// NewWhisperAPI arranca una API de Whisper falsa que transcribe cualquier
+// audio como "Hola, esto es una prueba."
+func NewWhisperAPI() *WhisperAPI {
+	w := &WhisperAPI{
+		byFile:       make(map[string]Transcription),
+		defaultReply: Transcription{Text: "Hola, esto es una prueba.", Language: "es"},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/transcribe", w.handleTranscribe)
+	mux.HandleFunc("/health", w.handleHealth)
+	w.server = httptest.NewServer(mux)
+	w.URL = w.server.URL
+	return w
+}
+
+// Close detiene el servidor
+func (w *WhisperAPI) Close() {
+	w.server.Close()
+}
+
+// Reply fija la transcripción de los archivos sin una propia
+func (w *WhisperAPI) Reply(transcription Transcription) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.defaultReply = transcription
+}
+
+// On fija la transcripción de los archivos con ese nombre (sin directorio)
+func (w *WhisperAPI) On(filename string, transcription Transcription) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.byFile[filename] = transcription
+}
+
+// Fail inyecta un fallo en las peticiones a path ("/transcribe", "/health" o "" para todas)
+func (w *WhisperAPI) Fail(path string, fault Fault) {
+	w.faults.add(path, fault)
+}
+
+// ClearFaults elimina los fallos inyectados
+func (w *WhisperAPI) ClearFaults() {
+	w.faults.clear()
+}
+
+// Uploads devuelve una copia de los archivos recibidos, en orden
+func (w *WhisperAPI) Uploads() []Upload {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Upload(nil), w.uploads...)
+}
+
+// handleTranscribe atiende /transcribe
+func (w *WhisperAPI) handleTranscribe(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSON(rw, http.StatusBadRequest, map[string]string{"error": "falta el campo file: " + err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeJSON(rw, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	upload := Upload{
+		Filename: filepath.Base(header.Filename),
+		Language: r.FormValue("language"),
+		Size:     len(data),
+		Header:   r.Header.Clone(),
+	}
+	w.mu.Lock()
+	w.uploads = append(w.uploads, upload)
+	transcription, ok := w.byFile[upload.Filename]
+	if !ok {
+		transcription = w.defaultReply
+	}
+	w.mu.Unlock()
+
+	if w.faults.next(r.URL.Path).apply(rw, r) {
+		return
+	}
+	if len(data) == 0 {
+		writeJSON(rw, http.StatusBadRequest, map[string]string{"error": "el archivo está vacío"})
+		return
+	}
+	if transcription.Language == "" {
+		transcription.Language = upload.Language
+	}
+	writeJSON(rw, http.StatusOK, transcription.withSegments())
+}
+
+// handleHealth atiende /health
+func (w *WhisperAPI) handleHealth(rw http.ResponseWriter, r *http.Request) {
+	if w.faults.next(r.URL.Path).apply(rw, r) {
+		return
+	}
+	writeJSON(rw, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// sentences divide un texto en frases terminadas en . ? o !
+func sentences(text string) []string {
+	var result []string
+	start := 0
+	for i, r := range text {
+		if r == '.' || r == '?' || r == '!' {
+			if sentence := strings.TrimSpace(text[start : i+1]); sentence != "" {
+				result = append(result, sentence)
+			}
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		result = append(result, rest)
+	}
+	return result
+}
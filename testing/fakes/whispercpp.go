@@ -0,0 +1,227 @@
+package fakes
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// whisperCppEnv convierte el proceso en un whisper.cpp falso; su valor es la
+// ruta de la configuración que escribió NewWhisperCpp
+const whisperCppEnv = "AGENT_FAKE_WHISPER_CPP"
+
+// El binario de test hace de whisper.cpp: NewWhisperCpp apunta el Transcriber
+// al propio ejecutable y fija whisperCppEnv, que los procesos hijos heredan.
+// Así el exec se prueba de verdad en cualquier sistema sin compilar nada.
+func init() {
+	if path := os.Getenv(whisperCppEnv); path != "" {
+		os.Exit(runWhisperCpp(path, os.Args[1:], os.Stdout, os.Stderr))
+	}
+}
+
+// WhisperCppConfig define el comportamiento del whisper.cpp falso
+type WhisperCppConfig struct {
+	Transcription Transcription // Lo que "oye"; sin Segments, un segmento por frase
+	ExitCode      int           // Si no es 0, escribe Stderr y termina con este código
+	Stderr        string
+	Delay         time.Duration // Espera antes de transcribir, para probar cancelaciones
+}
+
+// WhisperCpp es un ejecutable de whisper.cpp falso con un modelo vacío
+type WhisperCpp struct {
+	Path      string // Ejecutable para speech.NewTranscriber
+	ModelPath string // Modelo para speech.NewTranscriber
+
+	dir string
+}
+
+// whisperCppState es la configuración que lee el proceso hijo
+type whisperCppState struct {
+	Config WhisperCppConfig `json:"config"`
+	Calls  string           `json:"calls"`
+}
+
+// NewWhisperCpp prepara un whisper.cpp falso para el test. Usa t.Setenv, así
+// que no vale para tests con t.Parallel.
+func NewWhisperCpp(t testing.TB, config WhisperCppConfig) *WhisperCpp {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("error localizando el ejecutable de test: %v", err)
+	}
+	if config.Transcription.Text == "" && len(config.Transcription.Segments) == 0 {
+		config.Transcription.Text = "Hola, esto es una prueba."
+	}
+
+	dir := t.TempDir()
+	w := &WhisperCpp{Path: executable, ModelPath: filepath.Join(dir, "ggml-fake.bin"), dir: dir}
+	if err := os.WriteFile(w.ModelPath, []byte("ggml"), 0644); err != nil {
+		t.Fatalf("error creando modelo falso: %v", err)
+	}
+	data, err := json.Marshal(whisperCppState{Config: config, Calls: filepath.Join(dir, "calls.jsonl")})
+	if err != nil {
+		t.Fatalf("error codificando configuración: %v", err)
+	}
+	statePath := filepath.Join(dir, "whisper-cpp.json")
+	if err := os.WriteFile(statePath, data, 0644); err != nil {
+		t.Fatalf("error guardando configuración: %v", err)
+	}
+	t.Setenv(whisperCppEnv, statePath)
+	return w
+}
+
+// Calls devuelve los argumentos de cada ejecución, en orden
+func (w *WhisperCpp) Calls() [][]string {
+	file, err := os.Open(filepath.Join(w.dir, "calls.jsonl"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var calls [][]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var args []string
+		if json.Unmarshal(scanner.Bytes(), &args) == nil {
+			calls = append(calls, args)
+		}
+	}
+	return calls
+}
+
+// runWhisperCpp imita la línea de comandos de whisper.cpp con las opciones que
+// usa el Transcriber y devuelve el código de salida
+func runWhisperCpp(statePath string, args []string, stdout, stderr io.Writer) int {
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: fake whisper.cpp: %v\n", err)
+		return 1
+	}
+	var state whisperCppState
+	if err := json.Unmarshal(data, &state); err != nil {
+		fmt.Fprintf(stderr, "error: fake whisper.cpp: %v\n", err)
+		return 1
+	}
+	if line, err := json.Marshal(args); err == nil {
+		if calls, err := os.OpenFile(state.Calls, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+			calls.Write(append(line, '\n'))
+			calls.Close()
+		}
+	}
+
+	var model, audio, language, outputPrefix string
+	noTimestamps, outputJSON := false, false
+	for i := 0; i < len(args); i++ {
+		value := func() string {
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		switch args[i] {
+		case "-m", "--model":
+			model = value()
+		case "-f", "--file":
+			audio = value()
+		case "-l", "--language":
+			language = value()
+		case "-of", "--output-file":
+			outputPrefix = value()
+		case "-t", "--threads":
+			value()
+		case "-nt", "--no-timestamps":
+			noTimestamps = true
+		case "-oj", "--output-json":
+			outputJSON = true
+		default:
+			fmt.Fprintf(stderr, "error: unknown argument: %s\n", args[i])
+			return 1
+		}
+	}
+
+	fmt.Fprintf(stderr, "whisper_init_from_file_with_params_no_state: loading model from '%s'\n", model)
+	if _, err := os.Stat(model); err != nil {
+		fmt.Fprintf(stderr, "whisper_init_from_file_with_params_no_state: failed to open '%s'\n", model)
+		fmt.Fprintf(stderr, "error: failed to initialize whisper context\n")
+		return 3
+	}
+	if _, err := os.Stat(audio); err != nil {
+		fmt.Fprintf(stderr, "error: failed to read WAV file '%s'\n", audio)
+		return 4
+	}
+
+	config := state.Config
+	time.Sleep(config.Delay)
+	if config.ExitCode != 0 {
+		fmt.Fprint(stderr, config.Stderr)
+		return config.ExitCode
+	}
+
+	transcription := config.Transcription.withSegments()
+	if transcription.Language == "" {
+		transcription.Language = language
+	}
+	fmt.Fprintf(stderr, "whisper_full_with_state: auto-detected language: %s\n", transcription.Language)
+	for _, segment := range transcription.Segments {
+		if noTimestamps {
+			fmt.Fprintf(stdout, " %s\n", segment.Text)
+		} else {
+			fmt.Fprintf(stdout, "[%s --> %s]   %s\n", whisperTimestamp(segment.Start, "."), whisperTimestamp(segment.End, "."), segment.Text)
+		}
+	}
+
+	if outputJSON {
+		if outputPrefix == "" {
+			outputPrefix = audio
+		}
+		type offsets struct {
+			From int64 `json:"from"`
+			To   int64 `json:"to"`
+		}
+		type timestamps struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		type segment struct {
+			Timestamps timestamps `json:"timestamps"`
+			Offsets    offsets    `json:"offsets"`
+			Text       string     `json:"text"`
+		}
+		output := struct {
+			Result struct {
+				Language string `json:"language"`
+			} `json:"result"`
+			Transcription []segment `json:"transcription"`
+		}{}
+		output.Result.Language = transcription.Language
+		output.Transcription = []segment{}
+		for _, s := range transcription.Segments {
+			output.Transcription = append(output.Transcription, segment{
+				Timestamps: timestamps{From: whisperTimestamp(s.Start, ","), To: whisperTimestamp(s.End, ",")},
+				Offsets:    offsets{From: int64(s.Start * 1000), To: int64(s.End * 1000)},
+				Text:       " " + s.Text,
+			})
+		}
+		data, err := json.MarshalIndent(output, "", "\t")
+		if err == nil {
+			err = os.WriteFile(outputPrefix+".json", data, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "error: failed to write JSON output: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stderr, "output_json: saving output to '%s.json'\n", outputPrefix)
+	}
+	return 0
+}
+
+// whisperTimestamp formatea segundos como hh:mm:ss.mmm (o con coma), como whisper.cpp
+func whisperTimestamp(seconds float64, separator string) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}